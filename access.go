@@ -0,0 +1,75 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+)
+
+// Access restricts the use of an address range of a Server.
+type Access int
+
+const (
+	// AccessReadOnly answers writes with an illegal function exception.
+	AccessReadOnly Access = iota + 1
+	// AccessWriteOnly answers reads with an illegal function exception.
+	AccessWriteOnly
+	// AccessHidden answers all requests with an illegal data address
+	// exception as if the range did not exist.
+	AccessHidden
+)
+
+// AccessRule restricts the access to a range of protocol addresses. A
+// unit id of zero applies the rule to all units.
+type AccessRule struct {
+	Range  AddressRange
+	Access Access
+}
+
+// readRange returns the range read by request, ok is false if the request
+// does not read coils, inputs or registers.
+func readRange(unit byte, request *Pdu) (r AddressRange, ok bool) {
+	r.UnitId = unit
+	switch request.FunctionCode {
+	case FunctionReadCoil:
+		r.Table = TableCoils
+	case FunctionReadDiscreteInputs:
+		r.Table = TableDiscreteInputs
+	case FunctionReadHoldingRegister, FunctionReadWriteMultipleRegister, FunctionMaskWriteRegister:
+		r.Table = TableHoldingRegisters
+	case FunctionReadInputRegister:
+		r.Table = TableInputRegisters
+	default:
+		return r, false
+	}
+	if len(request.Data) < 4 {
+		return r, false
+	}
+	r.Address = binary.BigEndian.Uint16(request.Data)
+	r.Quantity = 1
+	if request.FunctionCode != FunctionMaskWriteRegister {
+		r.Quantity = binary.BigEndian.Uint16(request.Data[2:])
+	}
+	return r, true
+}
+
+// checkAccess returns the exception code answering request according to
+// the access rules of the server, zero if it is permitted.
+func (s *Server) checkAccess(unit byte, request *Pdu) byte {
+	if len(s.Access) == 0 {
+		return 0
+	}
+	read, isRead := readRange(unit, request)
+	write, isWrite := writeRange(unit, request)
+	var code byte
+	for _, rule := range s.Access {
+		touchesRead := isRead && rule.Range.overlaps(read)
+		touchesWrite := isWrite && rule.Range.overlaps(write)
+		switch {
+		case rule.Access == AccessHidden && (touchesRead || touchesWrite):
+			return ExcIllegalDataAdr
+		case rule.Access == AccessReadOnly && touchesWrite,
+			rule.Access == AccessWriteOnly && touchesRead:
+			code = ExcIllegalFunction
+		}
+	}
+	return code
+}
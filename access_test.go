@@ -0,0 +1,32 @@
+package modbustcp
+
+import "testing"
+
+func TestServerAccess(t *testing.T) {
+	s := NewServer()
+	s.Access = []AccessRule{
+		{Range: AddressRange{Table: TableHoldingRegisters, Address: 0, Quantity: 10}, Access: AccessReadOnly},
+		{Range: AddressRange{Table: TableHoldingRegisters, Address: 10, Quantity: 10}, Access: AccessWriteOnly},
+		{Range: AddressRange{UnitId: 1, Table: TableCoils, Address: 0, Quantity: 8}, Access: AccessHidden},
+	}
+	c := startServer(t, s)
+	if _, err := c.ReadHoldingRegisters(0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteSingleRegister(9, 1); err != ErrorIllegalFunction {
+		t.Fatalf("write of read-only register expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+	if err := c.WriteMultipleRegisters(10, []uint16{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadHoldingRegisters(8, 4); err != ErrorIllegalFunction {
+		t.Fatalf("read of write-only register expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+	if _, err := c.ReadCoils(7, 1); err != ErrorIllegalDataAddress {
+		t.Fatalf("read of hidden coil expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+	c.SlaveId = 2
+	if _, err := c.ReadCoils(7, 1); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,55 @@
+package modbustcp
+
+import (
+	"crypto/tls"
+	"net"
+	"net/netip"
+)
+
+// ClientRule grants masters access to a Server.
+type ClientRule struct {
+	// Prefix matches the source address, e.g. 10.1.0.0/16 or a single
+	// host 10.1.0.7/32. The zero prefix matches any address.
+	Prefix netip.Prefix
+	// Role additionally requires a TLS client certificate with this
+	// Modbus/TCP Security role if not empty.
+	Role string
+	// ReadOnly rejects requests other than reads with an illegal
+	// function exception.
+	ReadOnly bool
+}
+
+// matches reports whether the rule grants access to a master connected
+// from addr presenting the certificate role, empty without one.
+func (r *ClientRule) matches(addr netip.Addr, role string) bool {
+	if r.Prefix.IsValid() && !r.Prefix.Contains(addr) {
+		return false
+	}
+	return r.Role == "" || r.Role == role
+}
+
+// authorize returns the first client rule matching conn, nil if none does.
+func (s *Server) authorize(conn net.Conn) *ClientRule {
+	var addr netip.Addr
+	if tcp, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		addr = tcp.AddrPort().Addr().Unmap()
+	}
+	var role string
+	if tc, ok := conn.(*tls.Conn); ok {
+		if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+			role, _ = CertificateRole(certs[0])
+		}
+	}
+	return s.clientRule(addr, role)
+}
+
+// clientRule returns the first rule matching a client, nil if there is
+// none.
+func (s *Server) clientRule(addr netip.Addr, role string) *ClientRule {
+	for i := range s.Clients {
+		if s.Clients[i].matches(addr, role) {
+			return &s.Clients[i]
+		}
+	}
+	return nil
+}
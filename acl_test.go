@@ -0,0 +1,45 @@
+package modbustcp
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestServerClients(t *testing.T) {
+	s := NewServer()
+	s.Clients = []ClientRule{{Prefix: netip.MustParsePrefix("127.0.0.0/8"), ReadOnly: true}}
+	c := startServer(t, s)
+	if _, err := c.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteSingleRegister(0, 1); err != ErrorIllegalFunction {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+
+	s = NewServer()
+	s.Clients = []ClientRule{{Prefix: netip.MustParsePrefix("10.0.0.0/8")}}
+	c = startServer(t, s)
+	if _, err := c.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("connection from outside the allowed prefix expected to be rejected")
+	}
+}
+
+func TestClientRuleMatches(t *testing.T) {
+	addr := netip.MustParseAddr("192.168.1.20")
+	tests := []struct {
+		rule  ClientRule
+		role  string
+		match bool
+	}{
+		{ClientRule{}, "", true},
+		{ClientRule{Prefix: netip.MustParsePrefix("192.168.1.0/24")}, "", true},
+		{ClientRule{Prefix: netip.MustParsePrefix("192.168.2.0/24")}, "", false},
+		{ClientRule{Role: "Operator"}, "", false},
+		{ClientRule{Role: "Operator"}, "Operator", true},
+	}
+	for i, test := range tests {
+		if match := test.rule.matches(addr, test.role); match != test.match {
+			t.Errorf("rule %v: match expected %v, actual %v", i, test.match, match)
+		}
+	}
+}
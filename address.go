@@ -0,0 +1,220 @@
+package modbustcp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Table identifies one of the four Modbus data tables.
+type Table int
+
+const (
+	TableCoils Table = iota
+	TableDiscreteInputs
+	TableInputRegisters
+	TableHoldingRegisters
+)
+
+var tableNames = []string{"coils", "discrete", "input", "holding"}
+
+// tablePrefixes holds the leading digit of each table in Modicon notation.
+var tablePrefixes = []byte{'0', '1', '3', '4'}
+
+func (t Table) String() string {
+	if t < 0 || int(t) >= len(tableNames) {
+		return fmt.Sprintf("Table(%d)", int(t))
+	}
+	return tableNames[t]
+}
+
+// ParseTable parses the case insensitive name of a table, e.g. "holding".
+func ParseTable(s string) (Table, error) {
+	for i, name := range tableNames {
+		if strings.EqualFold(s, name) {
+			return Table(i), nil
+		}
+	}
+	return TableHoldingRegisters, fmt.Errorf("modbus: unknown table '%v'", s)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t Table) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *Table) UnmarshalText(b []byte) (err error) {
+	*t, err = ParseTable(string(b))
+	return err
+}
+
+// IsBit reports whether the table holds single bits rather than registers.
+func (t Table) IsBit() bool {
+	return t == TableCoils || t == TableDiscreteInputs
+}
+
+// Writable reports whether the table may be written by a master.
+func (t Table) Writable() bool {
+	return t == TableCoils || t == TableHoldingRegisters
+}
+
+// ReadFunction returns the function code reading the table.
+func (t Table) ReadFunction() byte {
+	switch t {
+	case TableCoils:
+		return FunctionReadCoil
+	case TableDiscreteInputs:
+		return FunctionReadDiscreteInputs
+	case TableInputRegisters:
+		return FunctionReadInputRegister
+	}
+	return FunctionReadHoldingRegister
+}
+
+// Address references an entry of a data table by its protocol offset.
+type Address struct {
+	Table  Table
+	Offset uint16
+}
+
+// ParseAddress parses an address in Modicon notation where the leading
+// digit selects the table and the remaining digits count from one:
+// "00001" is coil 0, "10001" discrete input 0, "30001" input register 0
+// and "40001" holding register 0. The extended six digit notation
+// "400001" to "465536" covers the full address range. Alternatively the
+// table can be named explicitly followed by the protocol offset, e.g.
+// "holding:1000" or "input:0".
+func ParseAddress(s string) (Address, error) {
+	if name, offset, ok := strings.Cut(s, ":"); ok {
+		table, err := ParseTable(name)
+		if err != nil {
+			return Address{}, err
+		}
+		n, err := strconv.ParseUint(offset, 10, 16)
+		if err != nil {
+			return Address{}, fmt.Errorf("modbus: invalid offset '%v' in address '%v'", offset, s)
+		}
+		return Address{Table: table, Offset: uint16(n)}, nil
+	}
+	if len(s) != 5 && len(s) != 6 {
+		return Address{}, fmt.Errorf("modbus: address '%v' is not in Modicon notation", s)
+	}
+	table := -1
+	for i, prefix := range tablePrefixes {
+		if s[0] == prefix {
+			table = i
+		}
+	}
+	n, err := strconv.ParseUint(s[1:], 10, 32)
+	if table < 0 || err != nil || n < 1 || n > 65536 {
+		return Address{}, fmt.Errorf("modbus: address '%v' is not in Modicon notation", s)
+	}
+	return Address{Table: Table(table), Offset: uint16(n - 1)}, nil
+}
+
+// String formats the address in Modicon notation, using six digits for
+// offsets beyond the five digit range.
+func (a Address) String() string {
+	if a.Offset >= 9999 {
+		return fmt.Sprintf("%c%05d", tablePrefixes[a.Table], int(a.Offset)+1)
+	}
+	return fmt.Sprintf("%c%04d", tablePrefixes[a.Table], int(a.Offset)+1)
+}
+
+// protocolAddress converts an address passed to the client API into the
+// address transmitted on the wire.
+func (c *ModbusTcpClient) protocolAddress(address uint16) (uint16, error) {
+	if !c.OneBased {
+		return address, nil
+	}
+	if address == 0 {
+		return 0, fmt.Errorf("modbus: address '0' is invalid with one-based addressing")
+	}
+	return address - 1, nil
+}
+
+// apiAddress converts a protocol offset into an address of the client API.
+func (c *ModbusTcpClient) apiAddress(offset uint16) (uint16, error) {
+	if !c.OneBased {
+		return offset, nil
+	}
+	if offset == 0xFFFF {
+		return 0, fmt.Errorf("modbus: offset '%v' is not addressable with one-based addressing", offset)
+	}
+	return offset + 1, nil
+}
+
+// ReadRegistersAt reads quantity registers starting at ref in Modicon
+// notation, e.g. "30017", "400001" or "holding:100". The address mode of the client does
+// not apply.
+func (c *ModbusTcpClient) ReadRegistersAt(ref string, quantity uint16) ([]uint16, error) {
+	a, err := ParseAddress(ref)
+	if err != nil {
+		return nil, err
+	}
+	if a.Table.IsBit() {
+		return nil, fmt.Errorf("modbus: address '%v' does not reference registers", ref)
+	}
+	address, err := c.apiAddress(a.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if a.Table == TableInputRegisters {
+		return c.ReadInputRegisters(address, quantity)
+	}
+	return c.ReadHoldingRegisters(address, quantity)
+}
+
+// ReadBitsAt reads quantity coils or discrete inputs starting at ref in
+// Modicon notation, e.g. "00123" or "10001".
+func (c *ModbusTcpClient) ReadBitsAt(ref string, quantity uint16) ([]bool, error) {
+	a, err := ParseAddress(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !a.Table.IsBit() {
+		return nil, fmt.Errorf("modbus: address '%v' does not reference bits", ref)
+	}
+	address, err := c.apiAddress(a.Offset)
+	if err != nil {
+		return nil, err
+	}
+	if a.Table == TableDiscreteInputs {
+		return c.ReadDiscreteInputs(address, quantity)
+	}
+	return c.ReadCoils(address, quantity)
+}
+
+// WriteRegistersAt writes values to the holding registers starting at ref
+// in Modicon notation.
+func (c *ModbusTcpClient) WriteRegistersAt(ref string, values []uint16) error {
+	a, err := ParseAddress(ref)
+	if err != nil {
+		return err
+	}
+	if a.Table != TableHoldingRegisters {
+		return fmt.Errorf("modbus: address '%v' does not reference holding registers", ref)
+	}
+	address, err := c.apiAddress(a.Offset)
+	if err != nil {
+		return err
+	}
+	return c.WriteMultipleRegisters(address, values)
+}
+
+// WriteBitsAt writes values to the coils starting at ref in Modicon notation.
+func (c *ModbusTcpClient) WriteBitsAt(ref string, values []bool) error {
+	a, err := ParseAddress(ref)
+	if err != nil {
+		return err
+	}
+	if a.Table != TableCoils {
+		return fmt.Errorf("modbus: address '%v' does not reference coils", ref)
+	}
+	address, err := c.apiAddress(a.Offset)
+	if err != nil {
+		return err
+	}
+	return c.WriteMultipleCoils(address, values)
+}
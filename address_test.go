@@ -0,0 +1,65 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	// String formats offsets beyond 9998 with six digits and the others
+	// with five
+	tests := []struct {
+		s       string
+		address Address
+		str     string
+	}{
+		{"40001", Address{TableHoldingRegisters, 0}, "40001"},
+		{"30017", Address{TableInputRegisters, 16}, "30017"},
+		{"00123", Address{TableCoils, 122}, "00123"},
+		{"19999", Address{TableDiscreteInputs, 9998}, "19999"},
+		{"400001", Address{TableHoldingRegisters, 0}, "40001"},
+		{"465536", Address{TableHoldingRegisters, 65535}, "465536"},
+		{"310000", Address{TableInputRegisters, 9999}, "310000"},
+		{"holding:1000", Address{TableHoldingRegisters, 1000}, "41001"},
+		{"holding:10000", Address{TableHoldingRegisters, 10000}, "410001"},
+		{"coils:10000", Address{TableCoils, 10000}, "010001"},
+	}
+	for _, test := range tests {
+		a, err := ParseAddress(test.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != test.address {
+			t.Errorf("%v: address expected %v, actual %v", test.s, test.address, a)
+		}
+		if a.String() != test.str {
+			t.Errorf("%v: string expected %v, actual %v", test.s, test.str, a)
+		}
+		if round, err := ParseAddress(a.String()); err != nil || round != a {
+			t.Errorf("%v: round trip expected %v, actual %v (%v)", test.s, a, round, err)
+		}
+	}
+	for _, s := range []string{"40000", "50001", "4001", "4x001", "465537", "holding:x"} {
+		if _, err := ParseAddress(s); err == nil {
+			t.Errorf("%v: error expected", s)
+		}
+	}
+}
+
+func TestOneBasedAddressing(t *testing.T) {
+	var address uint16
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		address = binary.BigEndian.Uint16(request.Data)
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0, 0}}
+	})
+	c.OneBased = true
+	if _, err := c.ReadHoldingRegisters(1, 1); err != nil || address != 0 {
+		t.Fatalf("address expected %v, actual %v (%v)", 0, address, err)
+	}
+	if _, err := c.ReadRegistersAt("40010", 1); err != nil || address != 9 {
+		t.Fatalf("address expected %v, actual %v (%v)", 9, address, err)
+	}
+	if _, err := c.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("error expected for address zero")
+	}
+}
@@ -0,0 +1,207 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AlarmKind selects the limit checked by an alarm rule.
+type AlarmKind int
+
+const (
+	// AlarmHigh is raised when the value exceeds the limit.
+	AlarmHigh AlarmKind = iota
+	// AlarmLow is raised when the value falls below the limit.
+	AlarmLow
+)
+
+func (k AlarmKind) String() string {
+	if k == AlarmHigh {
+		return "high"
+	}
+	return "low"
+}
+
+// AlarmRule raises an alarm when a tag crosses a limit.
+type AlarmRule struct {
+	Name string
+	Tag  string
+	Kind AlarmKind
+	// Limit raising the alarm.
+	Limit float64
+	// Hysteresis is the distance the value has to move back from the
+	// limit to clear the alarm.
+	Hysteresis float64
+	// OnDelay is the time the limit has to be violated continuously
+	// before the alarm is raised.
+	OnDelay time.Duration
+}
+
+// violated reports whether value violates the limit of the rule, active
+// selects the clear threshold shifted by the hysteresis.
+func (r *AlarmRule) violated(value float64, active bool) bool {
+	limit := r.Limit
+	if active {
+		if r.Kind == AlarmHigh {
+			limit -= r.Hysteresis
+		} else {
+			limit += r.Hysteresis
+		}
+	}
+	if r.Kind == AlarmHigh {
+		return value > limit
+	}
+	return value < limit
+}
+
+// AlarmEvent reports the raising or clearing of an alarm.
+type AlarmEvent struct {
+	Rule   string
+	Tag    string
+	Kind   AlarmKind
+	Active bool
+	Value  float64
+	Time   time.Time
+}
+
+type alarmState struct {
+	active bool
+	// since is the time of the first violating update while pending.
+	since time.Time
+}
+
+// AlarmEngine evaluates alarm rules on tag updates. Its Record method can
+// be used as Handler of a Poller, or it is set as Alarms of a Poller.
+type AlarmEngine struct {
+	mu     sync.Mutex
+	rules  []AlarmRule
+	states []alarmState
+	events chan AlarmEvent
+	// pending holds the events waiting for room in events, at most one
+	// per rule, which a delivering goroutine sends while it is not empty
+	pending    []AlarmEvent
+	delivering bool
+	superseded uint64
+}
+
+// NewAlarmEngine creates an engine evaluating rules.
+func NewAlarmEngine(rules ...AlarmRule) (*AlarmEngine, error) {
+	names := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if r.Name == "" || r.Tag == "" {
+			return nil, fmt.Errorf("modbus: alarm rule needs a name and a tag")
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("modbus: duplicate alarm rule '%v'", r.Name)
+		}
+		if r.Hysteresis < 0 || r.OnDelay < 0 {
+			return nil, fmt.Errorf("modbus: alarm rule '%v' has negative hysteresis or delay", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return &AlarmEngine{
+		rules:  rules,
+		states: make([]alarmState, len(rules)),
+		events: make(chan AlarmEvent, 64),
+	}, nil
+}
+
+// Events returns the channel of raise and clear events. While its buffer
+// is full, the events of a rule are coalesced into the latest one so that
+// alarms never stall polling and the final state of each rule is always
+// delivered, see Superseded.
+func (e *AlarmEngine) Events() <-chan AlarmEvent {
+	return e.events
+}
+
+// Superseded returns the number of events replaced by a later event of
+// the same rule before they could be delivered.
+func (e *AlarmEngine) Superseded() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.superseded
+}
+
+// Active returns the names of the raised alarms.
+func (e *AlarmEngine) Active() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var names []string
+	for i := range e.rules {
+		if e.states[i].active {
+			names = append(names, e.rules[i].Name)
+		}
+	}
+	return names
+}
+
+// Record evaluates the rules of the updated tag.
+func (e *AlarmEngine) Record(u TagUpdate) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range e.rules {
+		r, s := &e.rules[i], &e.states[i]
+		if r.Tag != u.Tag {
+			continue
+		}
+		violated := r.violated(u.Value, s.active)
+		switch {
+		case s.active && !violated:
+			s.active = false
+			e.emit(r, false, u)
+		case !s.active && violated:
+			if s.since.IsZero() {
+				s.since = u.Time
+			}
+			if u.Time.Sub(s.since) >= r.OnDelay {
+				s.active = true
+				s.since = time.Time{}
+				e.emit(r, true, u)
+			}
+		case !violated:
+			s.since = time.Time{}
+		}
+	}
+}
+
+// emit sends the event of r, or queues it if the buffer of events is full.
+// The caller holds the lock.
+func (e *AlarmEngine) emit(r *AlarmRule, active bool, u TagUpdate) {
+	ev := AlarmEvent{Rule: r.Name, Tag: r.Tag, Kind: r.Kind, Active: active, Value: u.Value, Time: u.Time}
+	if len(e.pending) == 0 {
+		select {
+		case e.events <- ev:
+			return
+		default:
+		}
+	}
+	for i := range e.pending {
+		if e.pending[i].Rule == ev.Rule {
+			e.pending[i] = ev
+			e.superseded++
+			return
+		}
+	}
+	e.pending = append(e.pending, ev)
+	if !e.delivering {
+		e.delivering = true
+		go e.deliver()
+	}
+}
+
+// deliver sends the pending events in order until none is left.
+func (e *AlarmEngine) deliver() {
+	for {
+		e.mu.Lock()
+		if len(e.pending) == 0 {
+			e.delivering = false
+			e.mu.Unlock()
+			return
+		}
+		ev := e.pending[0]
+		e.pending = e.pending[1:]
+		e.mu.Unlock()
+		e.events <- ev
+	}
+}
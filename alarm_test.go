@@ -0,0 +1,111 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlarmEngine(t *testing.T) {
+	e, err := NewAlarmEngine(AlarmRule{
+		Name: "overheat", Tag: "temp", Kind: AlarmHigh, Limit: 80, Hysteresis: 5, OnDelay: 2 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	steps := []struct {
+		value  float64
+		offset time.Duration
+		event  string
+	}{
+		{85, 0, ""},
+		{70, time.Second, ""},
+		{85, 2 * time.Second, ""},
+		{86, 3 * time.Second, ""},
+		{81, 4 * time.Second, "raise"},
+		{76, 5 * time.Second, ""},
+		{75, 6 * time.Second, "clear"},
+	}
+	for i, step := range steps {
+		e.Record(TagUpdate{Tag: "temp", Time: now.Add(step.offset), Reading: Reading{Value: step.value}})
+		event := ""
+		select {
+		case ev := <-e.Events():
+			event = "clear"
+			if ev.Active {
+				event = "raise"
+			}
+			if ev.Rule != "overheat" || ev.Value != step.value {
+				t.Errorf("step %v: event expected overheat/%v, actual %v/%v", i, step.value, ev.Rule, ev.Value)
+			}
+		default:
+		}
+		if event != step.event {
+			t.Errorf("step %v: event expected '%v', actual '%v'", i, step.event, event)
+		}
+	}
+}
+
+func TestAlarmEngineLow(t *testing.T) {
+	e, _ := NewAlarmEngine(AlarmRule{Name: "empty", Tag: "level", Kind: AlarmLow, Limit: 10})
+	e.Record(TagUpdate{Tag: "level", Reading: Reading{Value: 9}})
+	if active := e.Active(); len(active) != 1 || active[0] != "empty" {
+		t.Fatalf("active expected [empty], actual %v", active)
+	}
+	e.Record(TagUpdate{Tag: "level", Reading: Reading{Value: 10}})
+	if active := e.Active(); len(active) != 0 {
+		t.Fatalf("active expected none, actual %v", active)
+	}
+}
+
+func TestPollerAlarms(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0x00, 0x2A}}
+	})
+	p := NewPoller(c, PollGroup{
+		Name:     "fast",
+		Interval: 10 * time.Millisecond,
+		Tags:     []Tag{{Name: "level", Table: TableHoldingRegisters, Address: 1}},
+	})
+	p.Handler = func(TagUpdate) {}
+	p.Alarms, _ = NewAlarmEngine(AlarmRule{Name: "full", Tag: "level", Limit: 40})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	ev := <-p.Alarms.Events()
+	p.Stop()
+	if ev.Rule != "full" || !ev.Active || ev.Value != 42 {
+		t.Fatalf("event expected raised full at 42, actual %+v", ev)
+	}
+}
+
+func TestAlarmEngineFullBuffer(t *testing.T) {
+	e, _ := NewAlarmEngine(
+		AlarmRule{Name: "full", Tag: "level", Limit: 90},
+		AlarmRule{Name: "empty", Tag: "level", Kind: AlarmLow, Limit: 10},
+	)
+	// overflow the buffer with alternating raise and clear events of full
+	for i := 0; i < cap(e.events)+4; i++ {
+		e.Record(TagUpdate{Tag: "level", Reading: Reading{Value: float64(91 - i%2*11)}})
+	}
+	e.Record(TagUpdate{Tag: "level", Reading: Reading{Value: 5}})
+	if e.Superseded() == 0 {
+		t.Fatal("superseded events expected")
+	}
+	last := map[string]bool{}
+	for {
+		select {
+		case ev := <-e.Events():
+			last[ev.Rule] = ev.Active
+			continue
+		case <-time.After(50 * time.Millisecond):
+		}
+		break
+	}
+	if active, ok := last["full"]; !ok || active {
+		t.Fatal("latest event of full expected to clear it")
+	}
+	if !last["empty"] {
+		t.Fatal("latest event of empty expected to raise it")
+	}
+}
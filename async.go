@@ -0,0 +1,121 @@
+package modbustcp
+
+// Future is the pending result of an asynchronous request.
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// complete sets the result, it must be called once.
+func (f *Future[T]) complete(value T, err error) {
+	f.value, f.err = value, err
+	close(f.done)
+}
+
+// Go runs f on a new goroutine and returns its future result.
+func Go[T any](f func() (T, error)) *Future[T] {
+	future := newFuture[T]()
+	go func() {
+		future.complete(f())
+	}()
+	return future
+}
+
+// Done returns a channel closed when the result is available, for use
+// in select statements.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the request completed and returns its result.
+func (f *Future[T]) Wait() (T, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// Then calls handler with the result once available, without blocking.
+func (f *Future[T]) Then(handler func(T, error)) {
+	go func() {
+		handler(f.Wait())
+	}()
+}
+
+// noValue adapts functions returning only an error to Go.
+func noValue(f func() error) func() (struct{}, error) {
+	return func() (struct{}, error) {
+		return struct{}{}, f()
+	}
+}
+
+// ExecuteAsync sends request in the background. If the client pipelines
+// requests by MaxInFlight, it returns once the request is written, which
+// waits for a free in-flight slot, and the reader of the connection
+// completes the future. Otherwise the request runs on a new goroutine and
+// the requests of concurrent futures are serialized by the client in
+// arbitrary order.
+func (c *ModbusTcpClient) ExecuteAsync(request *Pdu) *Future[*Pdu] {
+	if c.MaxInFlight <= 1 {
+		return Go(func() (*Pdu, error) { return c.Execute(request) })
+	}
+	unit := c.SlaveId
+	if err := c.checkWrite(unit, request); err != nil {
+		future := newFuture[*Pdu]()
+		future.complete(nil, err)
+		return future
+	}
+	done := func() {}
+	if r, ok := writeRange(unit, request); ok {
+		done = func() { c.Cache.invalidate(r) }
+	}
+	return c.executePipelinedAsync(PriorityNormal, unit, request, done)
+}
+
+// The typed variants below run the blocking methods on a new goroutine
+// each, as they split large quantities into several requests, serve reads
+// from the Cache and verify writes. Their requests overlap on the wire
+// only if the client pipelines them by MaxInFlight.
+
+// ReadCoilsAsync is the asynchronous variant of ReadCoils.
+func (c *ModbusTcpClient) ReadCoilsAsync(address, quantity uint16) *Future[[]bool] {
+	return Go(func() ([]bool, error) { return c.ReadCoils(address, quantity) })
+}
+
+// ReadDiscreteInputsAsync is the asynchronous variant of ReadDiscreteInputs.
+func (c *ModbusTcpClient) ReadDiscreteInputsAsync(address, quantity uint16) *Future[[]bool] {
+	return Go(func() ([]bool, error) { return c.ReadDiscreteInputs(address, quantity) })
+}
+
+// ReadHoldingRegistersAsync is the asynchronous variant of ReadHoldingRegisters.
+func (c *ModbusTcpClient) ReadHoldingRegistersAsync(address, quantity uint16) *Future[[]uint16] {
+	return Go(func() ([]uint16, error) { return c.ReadHoldingRegisters(address, quantity) })
+}
+
+// ReadInputRegistersAsync is the asynchronous variant of ReadInputRegisters.
+func (c *ModbusTcpClient) ReadInputRegistersAsync(address, quantity uint16) *Future[[]uint16] {
+	return Go(func() ([]uint16, error) { return c.ReadInputRegisters(address, quantity) })
+}
+
+// WriteSingleCoilAsync is the asynchronous variant of WriteSingleCoil.
+func (c *ModbusTcpClient) WriteSingleCoilAsync(address uint16, value bool) *Future[struct{}] {
+	return Go(noValue(func() error { return c.WriteSingleCoil(address, value) }))
+}
+
+// WriteSingleRegisterAsync is the asynchronous variant of WriteSingleRegister.
+func (c *ModbusTcpClient) WriteSingleRegisterAsync(address, value uint16) *Future[struct{}] {
+	return Go(noValue(func() error { return c.WriteSingleRegister(address, value) }))
+}
+
+// WriteMultipleCoilsAsync is the asynchronous variant of WriteMultipleCoils.
+func (c *ModbusTcpClient) WriteMultipleCoilsAsync(address uint16, values []bool) *Future[struct{}] {
+	return Go(noValue(func() error { return c.WriteMultipleCoils(address, values) }))
+}
+
+// WriteMultipleRegistersAsync is the asynchronous variant of WriteMultipleRegisters.
+func (c *ModbusTcpClient) WriteMultipleRegistersAsync(address uint16, values []uint16) *Future[struct{}] {
+	return Go(noValue(func() error { return c.WriteMultipleRegisters(address, values) }))
+}
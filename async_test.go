@@ -0,0 +1,69 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadHoldingRegistersAsync(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		if request.FunctionCode == FunctionWriteSingleRegister {
+			return request
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0x12, 0x34}}
+	})
+	read := c.ReadHoldingRegistersAsync(0, 1)
+	write := c.WriteSingleRegisterAsync(1, 2)
+	<-read.Done()
+	regs, err := read.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(regs, []uint16{0x1234}) {
+		t.Fatalf("registers expected [4660], actual %v", regs)
+	}
+	if _, err = write.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecuteAsyncPipelined(t *testing.T) {
+	c := newPipelineClient(t, func(conn net.Conn, requests <-chan []byte) {
+		// answers once all requests are received, the last one never
+		var batch [][]byte
+		for request := range requests {
+			if batch = append(batch, request); len(batch) < 5 {
+				continue
+			}
+			for i := len(batch) - 2; i >= 0; i-- {
+				conn.Write(readResponse(batch[i]))
+			}
+		}
+	})
+	c.Timeout = 200 * time.Millisecond
+	// the futures are sent from one goroutine without waiting for responses
+	futures := make([]*Future[*Pdu], 5)
+	for i := range futures {
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint16(data, uint16(i))
+		binary.BigEndian.PutUint16(data[2:], 1)
+		futures[i] = c.ExecuteAsync(&Pdu{FunctionCode: FunctionReadHoldingRegister, Data: data})
+	}
+	for i, f := range futures[:4] {
+		response, err := f.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := binary.BigEndian.Uint16(response.Data[1:]); v != uint16(i) {
+			t.Fatalf("response %v expected register %v, actual %v", i, i, v)
+		}
+	}
+	if _, err := futures[4].Wait(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("unanswered request expected %v, actual %v", os.ErrDeadlineExceeded, err)
+	}
+}
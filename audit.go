@@ -0,0 +1,127 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a write request received by a Server.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Unit       byte      `json:"unit"`
+	Function   byte      `json:"function"`
+	Table      Table     `json:"table"`
+	Address    uint16    `json:"address"`
+	Quantity   uint16    `json:"quantity"`
+	// Values are the written registers, 0 or 1 for coils, and the and
+	// and or masks of a mask write.
+	Values   []uint16 `json:"values"`
+	Accepted bool     `json:"accepted"`
+	// Exception is the exception code of a rejected write, zero if it
+	// was not answered.
+	Exception byte `json:"exception,omitempty"`
+}
+
+// AuditSink receives the audit records of a Server. Audit is called
+// synchronously before the response is sent, so that the record of a
+// write precedes its acknowledgement. Errors are logged by the server.
+type AuditSink interface {
+	Audit(r AuditRecord) error
+}
+
+// AuditFunc adapts a function to an AuditSink.
+type AuditFunc func(r AuditRecord) error
+
+// Audit implements AuditSink.
+func (f AuditFunc) Audit(r AuditRecord) error {
+	return f(r)
+}
+
+// AuditLog is an AuditSink writing each record as JSON line.
+type AuditLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewAuditLog creates an audit log writing to w, e.g. an append only
+// file.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{enc: json.NewEncoder(w)}
+}
+
+// Audit implements AuditSink.
+func (l *AuditLog) Audit(r AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(r)
+}
+
+// audit passes the record of the write request r and its response to
+// the audit sink.
+func (s *Server) audit(r *Request, response *Pdu) {
+	record := AuditRecord{Time: time.Now(), Unit: r.Unit, Function: r.Pdu.FunctionCode}
+	if r.RemoteAddr != nil {
+		record.RemoteAddr = r.RemoteAddr.String()
+	}
+	if w, ok := writeRange(r.Unit, r.Pdu); ok {
+		record.Table, record.Address, record.Quantity = w.Table, w.Address, w.Quantity
+		record.Values = writtenValues(r.Pdu)
+	}
+	if response != nil {
+		record.Accepted = response.FunctionCode&ExcExceptionOffset == 0
+		if !record.Accepted && len(response.Data) > 0 {
+			record.Exception = response.Data[0]
+		}
+	}
+	if err := s.Audit.Audit(record); err != nil {
+		s.logf("modbus: audit of %v failed: %v", record.RemoteAddr, err)
+	}
+}
+
+// writtenValues returns the values of a write request, as far as they
+// are present in a malformed one.
+func writtenValues(request *Pdu) []uint16 {
+	d := request.Data
+	switch request.FunctionCode {
+	case FunctionWriteSingleCoil:
+		if binary.BigEndian.Uint16(d[2:]) == 0xFF00 {
+			return []uint16{1}
+		}
+		return []uint16{0}
+	case FunctionWriteSingleRegister:
+		return []uint16{binary.BigEndian.Uint16(d[2:])}
+	case FunctionMaskWriteRegister:
+		if len(d) < 6 {
+			return nil
+		}
+		return []uint16{binary.BigEndian.Uint16(d[2:]), binary.BigEndian.Uint16(d[4:])}
+	case FunctionWriteMultipleCoils:
+		if len(d) < 5 {
+			return nil
+		}
+		quantity := min(int(binary.BigEndian.Uint16(d[2:])), 8*len(d[5:]))
+		bits, _ := UnpackBits(d[5:], quantity)
+		values := make([]uint16, len(bits))
+		for i, b := range bits {
+			if b {
+				values[i] = 1
+			}
+		}
+		return values
+	case FunctionWriteMultipleRegister:
+		if len(d) < 5 {
+			return nil
+		}
+		return registers(d[5 : 5+len(d[5:])&^1])
+	case FunctionReadWriteMultipleRegister:
+		if len(d) < 9 {
+			return nil
+		}
+		return registers(d[9 : 9+len(d[9:])&^1])
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package modbustcp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestServerAudit(t *testing.T) {
+	s := NewServer()
+	s.Access = []AccessRule{{Range: AddressRange{Table: TableHoldingRegisters, Address: 0, Quantity: 10}, Access: AccessReadOnly}}
+	records := make(chan AuditRecord, 8)
+	s.Audit = AuditFunc(func(r AuditRecord) error {
+		records <- r
+		return nil
+	})
+	c := startServer(t, s)
+	if _, err := c.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteMultipleRegisters(10, []uint16{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteSingleRegister(9, 7); err != ErrorIllegalFunction {
+		t.Fatalf("write of read-only register expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+	if err := c.WriteMultipleCoils(3, []bool{true, false, true}); err != nil {
+		t.Fatal(err)
+	}
+	r := <-records
+	if !r.Accepted || r.Function != FunctionWriteMultipleRegister || r.Table != TableHoldingRegisters || r.Address != 10 ||
+		r.Quantity != 2 || len(r.Values) != 2 || r.Values[1] != 2 || r.Unit != 1 || r.RemoteAddr == "" {
+		t.Fatalf("accepted write expected, actual %+v", r)
+	}
+	if r = <-records; r.Accepted || r.Exception != ExcIllegalFunction || r.Address != 9 || r.Values[0] != 7 {
+		t.Fatalf("rejected write expected, actual %+v", r)
+	}
+	if r = <-records; r.Table != TableCoils || len(r.Values) != 3 || r.Values[0] != 1 || r.Values[1] != 0 {
+		t.Fatalf("coil write expected, actual %+v", r)
+	}
+	select {
+	case r = <-records:
+		t.Fatalf("reads expected not to be audited, actual %+v", r)
+	default:
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewAuditLog(&buf)
+	l.Audit(AuditRecord{Unit: 1, Function: FunctionWriteSingleRegister, Table: TableHoldingRegisters, Address: 4, Quantity: 1, Values: []uint16{5}, Accepted: true})
+	line := buf.String()
+	if !strings.HasSuffix(line, "\n") || !strings.Contains(line, `"table":"holding"`) || !strings.Contains(line, `"values":[5]`) {
+		t.Fatalf("JSON line expected, actual %q", line)
+	}
+}
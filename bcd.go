@@ -0,0 +1,90 @@
+package modbustcp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorInvalidBCD is returned when a register holds a nibble above 9.
+var ErrorInvalidBCD = errors.New("modbus: invalid BCD digit")
+
+// bcdRegisters returns the number of registers occupied by a packed BCD
+// value with the given number of digits.
+func bcdRegisters(digits int) (int, error) {
+	switch digits {
+	case 2, 4:
+		return 1, nil
+	case 8:
+		return 2, nil
+	}
+	return 0, fmt.Errorf("modbus: unsupported BCD digit count '%v'", digits)
+}
+
+// DecodeBCD decodes a packed BCD value of 2, 4 or 8 digits. Two digit values
+// are held in the low byte of the register, eight digit values span two
+// registers in the given word order.
+func DecodeBCD(regs []uint16, digits int, order WordOrder) (uint64, error) {
+	n, err := bcdRegisters(digits)
+	if err != nil {
+		return 0, err
+	}
+	var packed uint64
+	if n == 2 {
+		packed = uint64(order.Uint32(regs))
+	} else {
+		packed = uint64(regs[0])
+	}
+	var v, scale uint64 = 0, 1
+	for i := 0; i < digits; i++ {
+		digit := packed & 0xF
+		if digit > 9 {
+			return 0, ErrorInvalidBCD
+		}
+		v += digit * scale
+		scale *= 10
+		packed >>= 4
+	}
+	return v, nil
+}
+
+// EncodeBCD encodes v as a packed BCD value of 2, 4 or 8 digits.
+func EncodeBCD(v uint64, digits int, order WordOrder) ([]uint16, error) {
+	n, err := bcdRegisters(digits)
+	if err != nil {
+		return nil, err
+	}
+	var packed uint64
+	for i := 0; i < digits; i++ {
+		packed |= (v % 10) << uint(4*i)
+		v /= 10
+	}
+	if v != 0 {
+		return nil, fmt.Errorf("modbus: value does not fit into '%v' BCD digits", digits)
+	}
+	if n == 2 {
+		return order.PutUint32(uint32(packed)), nil
+	}
+	return []uint16{uint16(packed)}, nil
+}
+
+// ReadBCD reads a packed BCD value of 2, 4 or 8 digits from holding registers.
+func (c *ModbusTcpClient) ReadBCD(address uint16, digits int, order ...WordOrder) (uint64, error) {
+	n, err := bcdRegisters(digits)
+	if err != nil {
+		return 0, err
+	}
+	regs, err := c.ReadHoldingRegisters(address, uint16(n))
+	if err != nil {
+		return 0, err
+	}
+	return DecodeBCD(regs, digits, c.wordOrder(order))
+}
+
+// WriteBCD writes v as a packed BCD value of 2, 4 or 8 digits to holding registers.
+func (c *ModbusTcpClient) WriteBCD(address uint16, digits int, value uint64, order ...WordOrder) error {
+	regs, err := EncodeBCD(value, digits, c.wordOrder(order))
+	if err != nil {
+		return err
+	}
+	return c.WriteMultipleRegisters(address, regs)
+}
@@ -0,0 +1,27 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestBCD(t *testing.T) {
+	regs, err := EncodeBCD(12345678, 8, OrderABCD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 0x1234 || regs[1] != 0x5678 {
+		t.Fatalf("registers expected [1234 5678], actual %x", regs)
+	}
+	if v, err := DecodeBCD([]uint16{0x5678, 0x1234}, 8, OrderCDAB); err != nil || v != 12345678 {
+		t.Fatalf("value expected %v, actual %v (%v)", 12345678, v, err)
+	}
+	if v, err := DecodeBCD([]uint16{0x0042}, 2, OrderABCD); err != nil || v != 42 {
+		t.Fatalf("value expected %v, actual %v (%v)", 42, v, err)
+	}
+	if _, err := DecodeBCD([]uint16{0x00A1}, 4, OrderABCD); err != ErrorInvalidBCD {
+		t.Fatalf("error expected %v, actual %v", ErrorInvalidBCD, err)
+	}
+	if _, err := EncodeBCD(100, 2, OrderABCD); err == nil {
+		t.Fatal("error expected for value exceeding the digit count")
+	}
+}
@@ -0,0 +1,72 @@
+package modbustcp
+
+import (
+	"fmt"
+)
+
+// Bitfield describes a range of bits within a single register, e.g. a
+// flag or a mode selector packed into a status or command word.
+type Bitfield struct {
+	// Offset is the position of the least significant bit, 0 to 15.
+	Offset uint
+	// Width is the number of bits, 1 to 16.
+	Width uint
+}
+
+// Bit returns the bitfield of the single bit n.
+func Bit(n uint) Bitfield {
+	return Bitfield{Offset: n, Width: 1}
+}
+
+func (b Bitfield) mask() uint16 {
+	return uint16((uint32(1)<<b.Width - 1) << b.Offset)
+}
+
+func (b Bitfield) validate() error {
+	if b.Width < 1 || b.Offset+b.Width > 16 {
+		return fmt.Errorf("modbus: bitfield offset '%v' width '%v' exceeds register", b.Offset, b.Width)
+	}
+	return nil
+}
+
+// Get extracts the bitfield value from reg.
+func (b Bitfield) Get(reg uint16) uint16 {
+	return (reg & b.mask()) >> b.Offset
+}
+
+// Set returns reg with the bitfield replaced by value.
+func (b Bitfield) Set(reg, value uint16) uint16 {
+	return reg&^b.mask() | (value<<b.Offset)&b.mask()
+}
+
+// ReadBitfield reads the holding register at address and extracts field.
+func (c *ModbusTcpClient) ReadBitfield(address uint16, field Bitfield) (uint16, error) {
+	if err := field.validate(); err != nil {
+		return 0, err
+	}
+	regs, err := c.ReadHoldingRegisters(address, 1)
+	if err != nil {
+		return 0, err
+	}
+	return field.Get(regs[0]), nil
+}
+
+// WriteBitfield replaces field in the holding register at address, leaving
+// the other bits untouched. Function code 22 is used if MaskWrite is set,
+// otherwise the register is read and written back which is not atomic.
+func (c *ModbusTcpClient) WriteBitfield(address uint16, field Bitfield, value uint16) error {
+	if err := field.validate(); err != nil {
+		return err
+	}
+	if value > field.mask()>>field.Offset {
+		return fmt.Errorf("modbus: value '%v' exceeds bitfield width '%v'", value, field.Width)
+	}
+	if c.MaskWrite {
+		return c.MaskWriteRegister(address, ^field.mask(), field.Set(0, value))
+	}
+	regs, err := c.ReadHoldingRegisters(address, 1)
+	if err != nil {
+		return err
+	}
+	return c.WriteSingleRegister(address, field.Set(regs[0], value))
+}
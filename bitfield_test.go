@@ -0,0 +1,31 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestBitfield(t *testing.T) {
+	field := Bitfield{Offset: 4, Width: 3}
+	if v := field.Get(0xFF5F); v != 5 {
+		t.Fatalf("value expected %v, actual %v", 5, v)
+	}
+	if reg := field.Set(0xFFFF, 2); reg != 0xFFAF {
+		t.Fatalf("register expected %x, actual %x", 0xFFAF, reg)
+	}
+	if reg := Bit(15).Set(0, 1); reg != 0x8000 {
+		t.Fatalf("register expected %x, actual %x", 0x8000, reg)
+	}
+}
+
+func TestWriteBitfieldMaskWrite(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		if request.FunctionCode != FunctionMaskWriteRegister {
+			t.Errorf("function code expected %v, actual %v", FunctionMaskWriteRegister, request.FunctionCode)
+		}
+		return request
+	})
+	c.MaskWrite = true
+	if err := c.WriteBitfield(10, Bit(3), 1); err != nil {
+		t.Fatal(err)
+	}
+}
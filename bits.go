@@ -0,0 +1,46 @@
+package modbustcp
+
+import (
+	"fmt"
+)
+
+// PackBits packs values into bytes, the first value in the least
+// significant bit of the first byte as used by the coil and discrete
+// input function codes.
+func PackBits(values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return packed
+}
+
+// UnpackBits unpacks quantity values from packed.
+func UnpackBits(packed []byte, quantity int) ([]bool, error) {
+	if len(packed) < (quantity+7)/8 {
+		return nil, fmt.Errorf("modbus: '%v' bytes cannot hold '%v' bits", len(packed), quantity)
+	}
+	values := make([]bool, quantity)
+	for i := range values {
+		values[i] = packed[i/8]&(1<<uint(i%8)) != 0
+	}
+	return values, nil
+}
+
+// PackBitsWithCount packs values preceded by the byte count.
+func PackBitsWithCount(values []bool) []byte {
+	packed := PackBits(values)
+	return append([]byte{byte(len(packed))}, packed...)
+}
+
+// UnpackBitsWithCount unpacks quantity values from data starting with a
+// byte count, which must match the quantity exactly.
+func UnpackBitsWithCount(data []byte, quantity int) ([]bool, error) {
+	count := (quantity + 7) / 8
+	if len(data) != count+1 || int(data[0]) != count {
+		return nil, fmt.Errorf("modbus: response byte count '%v' does not match expected '%v'", len(data)-1, count)
+	}
+	return UnpackBits(data[1:], quantity)
+}
@@ -0,0 +1,25 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestPackBits(t *testing.T) {
+	values := []bool{true, false, true, true, false, false, true, true, true, true}
+	data := PackBitsWithCount(values)
+	if len(data) != 3 || data[0] != 2 || data[1] != 0xCD || data[2] != 0x03 {
+		t.Fatalf("packed expected [02 cd 03], actual % x", data)
+	}
+	unpacked, err := UnpackBitsWithCount(data, len(values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range values {
+		if unpacked[i] != values[i] {
+			t.Fatalf("bit %v expected %v, actual %v", i, values[i], unpacked[i])
+		}
+	}
+	if _, err := UnpackBitsWithCount(data, 17); err == nil {
+		t.Fatal("error expected for byte count mismatch")
+	}
+}
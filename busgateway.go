@@ -0,0 +1,239 @@
+package modbustcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GatewayRoute forwards the requests of a unit to a backend.
+type GatewayRoute struct {
+	Backend Backend
+	// Unit is the unit id on the backend, the unit id of the request if
+	// zero.
+	Unit byte
+}
+
+// BusGateway forwards the requests received by a Server to backends,
+// e.g. to RTU slaves on serial lines behind a TCP front end:
+//
+//	g := NewBusGateway()
+//	g.Route(1, GatewayRoute{Backend: NewRTUClient(port, 9600)})
+//	s := NewServer()
+//	s.Use(g.Middleware)
+//
+// Requests of units without route are passed on to the server. Backend
+// timeouts are answered with a gateway target device failed exception,
+// other backend failures with a gateway path unavailable exception.
+type BusGateway struct {
+	// ErrorHandler is invoked for failed transactions with the unit id
+	// of the request.
+	ErrorHandler func(unit byte, err error)
+
+	mu      sync.RWMutex
+	routes  map[byte]GatewayRoute
+	closers []io.Closer
+}
+
+// NewBusGateway creates a gateway without routes.
+func NewBusGateway() *BusGateway {
+	return &BusGateway{routes: make(map[byte]GatewayRoute)}
+}
+
+// Route forwards the requests of unit by route, replacing a route of the
+// unit.
+func (g *BusGateway) Route(unit byte, route GatewayRoute) {
+	g.mu.Lock()
+	g.routes[unit] = route
+	g.mu.Unlock()
+}
+
+// Middleware forwards the requests of routed units, it is passed to
+// Server.Use.
+func (g *BusGateway) Middleware(next RequestHandler) RequestHandler {
+	return func(r *Request) *Pdu {
+		g.mu.RLock()
+		route, ok := g.routes[r.Unit]
+		g.mu.RUnlock()
+		if !ok {
+			return next(r)
+		}
+		return g.forward(r, route)
+	}
+}
+
+// forward executes r on the backend of route.
+func (g *BusGateway) forward(r *Request, route GatewayRoute) *Pdu {
+	unit := route.Unit
+	if unit == 0 {
+		unit = r.Unit
+	}
+	response, err := route.Backend.Transact(unit, r.Pdu)
+	if err == nil {
+		return response
+	}
+	if g.ErrorHandler != nil {
+		g.ErrorHandler(r.Unit, err)
+	}
+	return gatewayException(r.Pdu, err)
+}
+
+// gatewayException answers request after the backend failed with err.
+func gatewayException(request *Pdu, err error) *Pdu {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return Exception(request, ExcGateTargetFailed)
+	}
+	return Exception(request, ExcGatePathUnavailable)
+}
+
+// Close closes the serial ports opened for the backends of a gateway
+// created by NewBusGatewayFromConfig.
+func (g *BusGateway) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var err error
+	for _, c := range g.closers {
+		if e := c.Close(); err == nil {
+			err = e
+		}
+	}
+	g.closers = nil
+	return err
+}
+
+// Transact implements Backend, connecting if necessary. The connection is
+// closed after transport failures and reestablished by the next request.
+func (c *ModbusTcpClient) Transact(unit byte, request *Pdu) (*Pdu, error) {
+	c.transact.Lock()
+	defer c.transact.Unlock()
+	if c.Conn == nil {
+		if err := c.Connect(); err != nil {
+			c.Conn = nil
+			return nil, err
+		}
+	}
+	response, err := c.ExecuteUnit(unit, request)
+	if IsException(err) {
+		return Exception(request, ErrorToFailureCode(err)), nil
+	} else if err != nil {
+		c.Disconnect()
+	}
+	return response, err
+}
+
+// BusGatewayRoute configures the route of a unit.
+type BusGatewayRoute struct {
+	Unit byte `json:"unit"`
+	// Backend is a downstream server "tcp://host:port" or a serial line
+	// "rtu:///dev/ttyUSB0?baud=9600", both accept a timeout parameter,
+	// e.g. "?timeout=500ms". Serial lines have to be configured, e.g. by
+	// stty, routes of the same backend share its connection. Concurrent
+	// identical reads are coalesced, the parameter cache sets the TTL of
+	// cached responses, e.g. "?cache=1s".
+	Backend string `json:"backend"`
+	// TargetUnit is the unit id on the backend, Unit if zero.
+	TargetUnit byte `json:"target_unit,omitempty"`
+}
+
+// BusGatewayConfig configures a BusGateway.
+type BusGatewayConfig struct {
+	// Listen is the address of the server, ":502" if empty.
+	Listen string            `json:"listen,omitempty"`
+	Routes []BusGatewayRoute `json:"routes"`
+}
+
+// LoadBusGatewayConfig reads a gateway configuration from a .json, .yaml
+// or .yml file.
+func LoadBusGatewayConfig(path string) (*BusGatewayConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &BusGatewayConfig{}
+	if err = unmarshalConfig(data, configFormat(path), cfg); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// NewBusGatewayFromConfig creates a gateway with the routes of cfg,
+// opening the serial lines of its backends.
+func NewBusGatewayFromConfig(cfg *BusGatewayConfig) (*BusGateway, error) {
+	g := NewBusGateway()
+	backends := make(map[string]Backend)
+	for _, r := range cfg.Routes {
+		if _, ok := g.routes[r.Unit]; ok {
+			g.Close()
+			return nil, fmt.Errorf("modbus: duplicate route of unit '%v'", r.Unit)
+		}
+		b, ok := backends[r.Backend]
+		if !ok {
+			var err error
+			if b, err = g.openBackend(r.Backend); err != nil {
+				g.Close()
+				return nil, fmt.Errorf("modbus: route of unit '%v': %v", r.Unit, err)
+			}
+			backends[r.Backend] = b
+		}
+		g.Route(r.Unit, GatewayRoute{Backend: b, Unit: r.TargetUnit})
+	}
+	return g, nil
+}
+
+// openBackend creates the backend of a route specification.
+func (g *BusGateway) openBackend(spec string) (Backend, error) {
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	var timeout, ttl time.Duration
+	if s := query.Get("timeout"); s != "" {
+		if timeout, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid timeout '%v'", s)
+		}
+	}
+	if s := query.Get("cache"); s != "" {
+		if ttl, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid cache ttl '%v'", s)
+		}
+	}
+	switch u.Scheme {
+	case "tcp":
+		host, port, err := net.SplitHostPort(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port '%v'", port)
+		}
+		c := NewModbusTcpClient(host, p)
+		c.Timeout = timeout
+		return NewCoalescingBackend(c, ttl), nil
+	case "rtu":
+		baud := 9600
+		if s := query.Get("baud"); s != "" {
+			if baud, err = strconv.Atoi(s); err != nil || baud <= 0 {
+				return nil, fmt.Errorf("invalid baud rate '%v'", s)
+			}
+		}
+		f, err := os.OpenFile(u.Path, os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		g.mu.Lock()
+		g.closers = append(g.closers, f)
+		g.mu.Unlock()
+		c := NewRTUClient(f, baud)
+		c.Timeout = timeout
+		return NewCoalescingBackend(c, ttl), nil
+	}
+	return nil, fmt.Errorf("unknown backend '%v'", spec)
+}
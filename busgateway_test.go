@@ -0,0 +1,172 @@
+package modbustcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startRTUSlave serves s as RTU slave and returns a master connected to it.
+func startRTUSlave(t *testing.T, s *Server) *RTUClient {
+	port, master := net.Pipe()
+	go s.ServeRTU(port, 115200)
+	t.Cleanup(func() { master.Close() })
+	return NewRTUClient(master, 115200)
+}
+
+func TestRTUClient(t *testing.T) {
+	s := NewServer()
+	s.UnitId = 3
+	s.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{1, 2})
+	c := startRTUSlave(t, s)
+	response, err := c.Transact(3, &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0, 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.FunctionCode != FunctionReadHoldingRegister || len(response.Data) != 5 || response.Data[4] != 2 {
+		t.Fatalf("response expected registers [1 2], actual %+v", response)
+	}
+	if response, err = c.Transact(3, &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0xffff, 2)}); err != nil {
+		t.Fatal(err)
+	}
+	if response.FunctionCode != FunctionReadHoldingRegister|ExcExceptionOffset || response.Data[0] != ExcIllegalDataAdr {
+		t.Fatalf("exception response expected, actual %+v", response)
+	}
+}
+
+func TestRTUClientLateResponse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		request := make([]byte, 8)
+		for _, response := range [][]byte{
+			rtuFrame(3, FunctionReadHoldingRegister, 2, 0, 1),
+			rtuFrame(3, FunctionReadHoldingRegister, 2, 0, 2),
+			rtuFrame(3, FunctionReadInputRegister, 2, 0, 3),
+		} {
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+			if response[4] == 1 {
+				// answered after the timeout of the master
+				time.Sleep(100 * time.Millisecond)
+			}
+			conn.Write(response)
+		}
+	}()
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := NewRTUClient(conn, 115200)
+	c.Timeout = 50 * time.Millisecond
+	read := &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0, 1)}
+	if _, err := c.Transact(3, read); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("error expected %v, actual %v", os.ErrDeadlineExceeded, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	response, err := c.Transact(3, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Data[2] != 2 {
+		t.Fatalf("register of the second response expected 2, actual %v", response.Data[2])
+	}
+	if response, err = c.Transact(3, read); err == nil {
+		t.Fatalf("response of another function expected to fail, actual %+v", response)
+	}
+}
+
+func TestBusGateway(t *testing.T) {
+	slave := NewServer()
+	slave.UnitId = 7
+	slave.Store = NewDataStore(0, 0, 0, 10)
+	slave.Store.SetRegisters(TableInputRegisters, 5, []uint16{42})
+	// a bus without slave, the requests are discarded
+	port, silent := net.Pipe()
+	go io.Copy(io.Discard, port)
+	defer silent.Close()
+	lost := NewRTUClient(silent, 115200)
+	lost.Timeout = 50 * time.Millisecond
+
+	g := NewBusGateway()
+	g.Route(1, GatewayRoute{Backend: startRTUSlave(t, slave), Unit: 7})
+	g.Route(2, GatewayRoute{Backend: lost})
+	s := NewServer()
+	s.Use(g.Middleware)
+	s.Store.SetRegisters(TableInputRegisters, 5, []uint16{3})
+	c := startServer(t, s)
+	regs, err := c.ReadInputRegisters(5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 42 {
+		t.Fatalf("register of the slave expected 42, actual %v", regs[0])
+	}
+	if _, err = c.ReadInputRegisters(20, 1); err != ErrorIllegalDataAddress {
+		t.Fatalf("exception of the slave expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+	c.SlaveId = 2
+	if _, err = c.ReadInputRegisters(5, 1); err != ErrorGatewayTargetFailed {
+		t.Fatalf("error expected %v, actual %v", ErrorGatewayTargetFailed, err)
+	}
+	c.SlaveId = 9
+	if regs, err = c.ReadInputRegisters(5, 1); err != nil || regs[0] != 3 {
+		t.Fatalf("register of the gateway expected 3, actual %v %v", regs, err)
+	}
+}
+
+func TestBusGatewayConfig(t *testing.T) {
+	downstream := NewServer()
+	downstream.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{11})
+	d := startServer(t, downstream)
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	config := fmt.Sprintf("listen: \":1502\"\nroutes:\n  - unit: 4\n    backend: tcp://%v:%v?timeout=1s\n    target_unit: 1\n", d.IpAddress, d.Port)
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadBusGatewayConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != ":1502" || len(cfg.Routes) != 1 || cfg.Routes[0].TargetUnit != 1 {
+		t.Fatalf("configuration expected 1 route, actual %+v", cfg)
+	}
+	g, err := NewBusGatewayFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	s := NewServer()
+	s.Use(g.Middleware)
+	c := startServer(t, s)
+	c.SlaveId = 4
+	regs, err := c.ReadHoldingRegisters(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 11 {
+		t.Fatalf("register of the downstream server expected 11, actual %v", regs[0])
+	}
+
+	for _, backend := range []string{"udp://localhost:502", "rtu:///nonexistent/tty", "tcp://localhost"} {
+		cfg = &BusGatewayConfig{Routes: []BusGatewayRoute{{Unit: 1, Backend: backend}}}
+		if _, err = NewBusGatewayFromConfig(cfg); err == nil {
+			t.Fatalf("backend '%v' expected to fail", backend)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package modbustcp
+
+import (
+	"sync"
+	"time"
+)
+
+// ReadCache keeps the results of reads for TTL, so that reads of the same
+// or a contained range are answered without a request. Writes through the
+// client invalidate the overlapping entries.
+type ReadCache struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries []cacheEntry
+	// generation counts the invalidations by writes, results of reads
+	// overlapped by a write are not cached
+	generation uint64
+}
+
+type cacheEntry struct {
+	r       AddressRange
+	regs    []uint16
+	bits    []bool
+	expires time.Time
+}
+
+// NewReadCache returns a cache keeping reads for ttl.
+func NewReadCache(ttl time.Duration) *ReadCache {
+	return &ReadCache{TTL: ttl}
+}
+
+// Clear drops all entries.
+func (c *ReadCache) Clear() {
+	c.mu.Lock()
+	c.entries = nil
+	c.mu.Unlock()
+}
+
+// get returns the cached values of r, the range using protocol addresses.
+func (c *ReadCache) get(r AddressRange) ([]uint16, []bool, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, e := range c.entries {
+		if !e.r.Contains(r) || !now.Before(e.expires) {
+			continue
+		}
+		offset := int(r.Address - e.r.Address)
+		n := offset + int(r.Quantity)
+		if e.regs != nil {
+			return append([]uint16(nil), e.regs[offset:n]...), nil, true
+		}
+		return nil, append([]bool(nil), e.bits[offset:n]...), true
+	}
+	return nil, nil, false
+}
+
+// begin returns the generation to pass to put for a read sent now.
+func (c *ReadCache) begin() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// put stores the values read for r and drops expired entries, unless a
+// write invalidated the cache since the read began at generation.
+func (c *ReadCache) put(r AddressRange, generation uint64, regs []uint16, bits []bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation != generation {
+		return
+	}
+	now := time.Now()
+	entries := c.entries[:0]
+	for _, e := range c.entries {
+		if now.Before(e.expires) && !r.Contains(e.r) {
+			entries = append(entries, e)
+		}
+	}
+	c.entries = append(entries, cacheEntry{
+		r:       r,
+		regs:    append([]uint16(nil), regs...),
+		bits:    append([]bool(nil), bits...),
+		expires: now.Add(c.TTL),
+	})
+}
+
+// invalidate drops the entries overlapping r.
+func (c *ReadCache) invalidate(r AddressRange) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	entries := c.entries[:0]
+	for _, e := range c.entries {
+		if !e.r.overlaps(r) {
+			entries = append(entries, e)
+		}
+	}
+	c.entries = entries
+}
+
+// cacheKey returns the range read by a request.
+func cacheKey(unit byte, functionCode byte, address, quantity uint16) AddressRange {
+	return AddressRange{UnitId: unit, Table: functionTable(functionCode), Address: address, Quantity: quantity}
+}
+
+// functionTable returns the table read by a function code.
+func functionTable(functionCode byte) Table {
+	switch functionCode {
+	case FunctionReadCoil:
+		return TableCoils
+	case FunctionReadDiscreteInputs:
+		return TableDiscreteInputs
+	case FunctionReadInputRegister:
+		return TableInputRegisters
+	}
+	return TableHoldingRegisters
+}
+
+// ReadFresh reads r from the device bypassing the cache, which is updated
+// with the result. A zero unit id reads from SlaveId.
+func (c *ModbusTcpClient) ReadFresh(r AddressRange) ([]uint16, []bool, error) {
+	if r.UnitId == 0 {
+		r.UnitId = c.SlaveId
+	}
+	address, err := c.protocolAddress(r.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = checkRange(address, int(r.Quantity)); err != nil {
+		return nil, nil, err
+	}
+	if r.Table.IsBit() {
+		bits, err := c.fetchBits(PriorityNormal, r.UnitId, r.Table.ReadFunction(), address, r.Quantity)
+		return nil, bits, err
+	}
+	regs, err := c.fetchRegisters(PriorityNormal, r.UnitId, r.Table.ReadFunction(), address, r.Quantity)
+	return regs, nil, err
+}
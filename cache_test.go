@@ -0,0 +1,66 @@
+package modbustcp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReadCache(t *testing.T) {
+	var reads int
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		if request.FunctionCode == FunctionWriteSingleRegister {
+			return request
+		}
+		reads++
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{6, 0, 1, 0, 2, 0, byte(reads)}}
+	})
+	c.Cache = NewReadCache(time.Minute)
+	for i := 0; i < 2; i++ {
+		if _, err := c.ReadHoldingRegisters(10, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	regs, err := c.ReadHoldingRegisters(11, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(regs, []uint16{2, 1}) || reads != 1 {
+		t.Fatalf("cached registers expected [2 1] after 1 read, actual %v after %v", regs, reads)
+	}
+	if _, _, err = c.ReadFresh(AddressRange{Table: TableHoldingRegisters, Address: 10, Quantity: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.WriteSingleRegister(12, 7); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.ReadHoldingRegisters(10, 3); err != nil {
+		t.Fatal(err)
+	}
+	if reads != 3 {
+		t.Fatalf("reads expected %v, actual %v", 3, reads)
+	}
+}
+
+func TestReadCacheWriteDuringRead(t *testing.T) {
+	var c *ModbusTcpClient
+	var reads int
+	c = newTestClient(t, func(request *Pdu) *Pdu {
+		reads++
+		if reads == 1 {
+			// a write completing while the first read is in flight
+			c.Cache.invalidate(AddressRange{UnitId: c.SlaveId, Table: TableHoldingRegisters, Address: 10, Quantity: 1})
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0, byte(reads)}}
+	})
+	c.Cache = NewReadCache(time.Minute)
+	for i := 1; i <= 2; i++ {
+		regs, err := c.ReadHoldingRegisters(10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if regs[0] != uint16(i) {
+			t.Fatalf("read %v expected register %v, actual %v", i, i, regs[0])
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package modbustcp
+
+// CachingProxy answers Modbus TCP masters on behalf of a backend, reads
+// are answered from a cache, e.g. to protect a device from a legacy
+// master polling faster than it tolerates:
+//
+//	p := NewCachingProxy(NewModbusTcpClient("192.168.1.10", 502))
+//	p.Cache.TTL = time.Second
+//	p.Cache.Ranges = []RangeTTL{{Range: AddressRange{Table: TableCoils, Quantity: 100}, TTL: 0}}
+//	p.ListenAndServe(":502")
+//
+// Writes are always forwarded and invalidate the cached reads they
+// overlap. The requests of all units are forwarded.
+type CachingProxy struct {
+	// Server answers the masters.
+	*Server
+	Cache *CoalescingBackend
+}
+
+// NewCachingProxy creates a proxy to backend without TTL, so that only
+// concurrent identical reads are combined until the TTL is set.
+func NewCachingProxy(backend Backend) *CachingProxy {
+	cache := NewCoalescingBackend(backend, 0)
+	g := NewBusGateway()
+	for unit := 0; unit <= 255; unit++ {
+		g.Route(byte(unit), GatewayRoute{Backend: cache})
+	}
+	s := NewServer()
+	s.Use(g.Middleware)
+	return &CachingProxy{Server: s, Cache: cache}
+}
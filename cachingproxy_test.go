@@ -0,0 +1,41 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCachingProxy(t *testing.T) {
+	device := NewServer()
+	device.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{1})
+	device.Store.SetRegisters(TableHoldingRegisters, 100, []uint16{1})
+	p := NewCachingProxy(startServer(t, device))
+	p.Cache.TTL = time.Minute
+	p.Cache.Ranges = []RangeTTL{{Range: AddressRange{Table: TableHoldingRegisters, Address: 100, Quantity: 10}}}
+	c := startServer(t, p.Server)
+
+	read := func(address uint16) uint16 {
+		t.Helper()
+		regs, err := c.ReadHoldingRegisters(address, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return regs[0]
+	}
+	read(0)
+	read(100)
+	device.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{2})
+	device.Store.SetRegisters(TableHoldingRegisters, 100, []uint16{2})
+	if v := read(0); v != 1 {
+		t.Fatalf("cached register expected 1, actual %v", v)
+	}
+	if v := read(100); v != 2 {
+		t.Fatalf("uncached register expected 2, actual %v", v)
+	}
+	if err := c.WriteSingleRegister(0, 3); err != nil {
+		t.Fatal(err)
+	}
+	if v := read(0); v != 3 {
+		t.Fatalf("register expected 3 after write, actual %v", v)
+	}
+}
@@ -0,0 +1,91 @@
+// Command modbus-sim runs simulated Modbus TCP devices as a standalone
+// process, e.g. in docker-compose test environments:
+//
+//	modbus-sim -config devices.yaml [-listen :1502] [-http :8080] [-scenario faults.yaml] [-profiles pump.yaml]
+//
+// The configuration and scenario files are described by package
+// simulator. The built in profiles "power_meter", "vfd" and
+// "sunspec_inverter" can be complemented by profile files. The web
+// endpoint views and edits the data tables at runtime. The process
+// serves until it is interrupted or terminated.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/patdhlk/modbustcp"
+	"github.com/patdhlk/modbustcp/simulator"
+)
+
+func main() {
+	config := flag.String("config", "simulator.yaml", "configuration `file` of the devices")
+	listen := flag.String("listen", "", "listen `address`, overrides the configuration")
+	web := flag.String("http", "", "`address` of the web endpoint, overrides the configuration")
+	scenario := flag.String("scenario", "", "scenario `file` played after the start")
+	profiles := flag.String("profiles", "", "comma separated profile `files`")
+	flag.Parse()
+	if err := run(*config, *listen, *web, *scenario, *profiles); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(config, listen, web, scenario, profiles string) error {
+	if profiles != "" {
+		for _, path := range strings.Split(profiles, ",") {
+			p, err := simulator.LoadProfile(path)
+			if err != nil {
+				return err
+			}
+			simulator.RegisterProfile(p)
+		}
+	}
+	cfg, err := simulator.LoadConfig(config)
+	if err != nil {
+		return err
+	}
+	if listen != "" {
+		cfg.Listen = listen
+	}
+	if web != "" {
+		cfg.HTTP = web
+	}
+	fleet, err := simulator.NewFleet(cfg)
+	if err != nil {
+		return err
+	}
+	for _, sim := range fleet.Simulators {
+		sim.ErrorHandler = func(err error) { log.Println(err) }
+	}
+	var sc *simulator.Scenario
+	if scenario != "" {
+		if sc, err = simulator.LoadScenario(scenario); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		fleet.Close()
+	}()
+	if sc != nil {
+		go func() {
+			if err := fleet.Play(ctx, sc); err != nil && !errors.Is(err, context.Canceled) {
+				log.Println(err)
+			}
+		}()
+	}
+	log.Printf("modbus-sim: serving units %v on %v instances", fleet.Simulators[0].Units(), len(fleet.Simulators))
+	if err = fleet.ListenAndServe(); errors.Is(err, modbustcp.ErrorServerClosed) {
+		return nil
+	}
+	return err
+}
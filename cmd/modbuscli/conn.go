@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// connection holds the flags selecting a device.
+type connection struct {
+	fs      *flag.FlagSet
+	address string
+	unit    uint
+	timeout time.Duration
+	// endpoint is the endpoint of the profiles file selected by the
+	// arguments, explicit are the flags given on the command line.
+	endpoint *endpoint
+	explicit map[string]bool
+}
+
+func connectionFlags(fs *flag.FlagSet) *connection {
+	c := &connection{fs: fs}
+	fs.StringVar(&c.address, "a", "localhost:502", "`address` of the device, host or host:port")
+	fs.UintVar(&c.unit, "u", 1, "`unit` id")
+	fs.DurationVar(&c.timeout, "timeout", 5*time.Second, "response `timeout`")
+	return c
+}
+
+// open returns a client connected to the device and the function
+// releasing it. Commands of the shell share its connection unless they
+// select another device.
+func (c *connection) open() (*modbustcp.ModbusTcpClient, func(), error) {
+	c.inherit()
+	if s := current; s != nil && c.address == s.conn.address && c.timeout == s.conn.timeout {
+		if c.unit > 255 {
+			return nil, nil, usagef("invalid unit id '%v'", c.unit)
+		}
+		client, err := s.connect()
+		if err != nil {
+			return nil, nil, err
+		}
+		client.SlaveId = byte(c.unit)
+		return client, func() {}, nil
+	}
+	client, err := c.dial()
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, func() { client.Disconnect() }, nil
+}
+
+// use selects the endpoint of the profiles file named by a leading
+// "@name" argument and returns the other arguments. The settings of the
+// endpoint apply unless the flags are given.
+func (c *connection) use(args []string) ([]string, error) {
+	c.explicit = make(map[string]bool)
+	c.fs.Visit(func(f *flag.Flag) { c.explicit[f.Name] = true })
+	// the endpoint of the shell names the tags
+	c.inherit()
+	if len(args) == 0 || !strings.HasPrefix(args[0], "@") {
+		return args, nil
+	}
+	e, err := lookupEndpoint(args[0][1:])
+	if err != nil {
+		return nil, err
+	}
+	c.endpoint = e
+	// set the flags to take precedence over the shell
+	if !c.explicit["a"] {
+		c.fs.Set("a", e.Address)
+	}
+	if e.Unit != nil && !c.explicit["u"] {
+		c.fs.Set("u", fmt.Sprint(*e.Unit))
+	}
+	if e.Timeout > 0 && !c.explicit["timeout"] {
+		c.fs.Set("timeout", time.Duration(e.Timeout).String())
+	}
+	return args[1:], nil
+}
+
+// location parses the address of args like parseLocation, or a tag name
+// of the endpoint. The types of the tag or the default types of the
+// endpoint apply to the register values of types if not nil.
+func (c *connection) location(args []string, types *valueFlags) (modbustcp.Address, []string, error) {
+	e := c.endpoint
+	if e == nil {
+		return parseLocation(args)
+	}
+	if len(args) > 0 {
+		if tag := e.tag(args[0]); tag != nil {
+			a, err := modbustcp.ParseAddress(string(tag.Address))
+			if err != nil {
+				return a, nil, fmt.Errorf("endpoint '%v': tag '%v': %v", e.name, tag.Name, err)
+			}
+			if tag.UnitId != 0 && !c.explicit["u"] {
+				c.unit = uint(tag.UnitId)
+			}
+			if types != nil && !a.Table.IsBit() {
+				typ, order := tag.Type, tag.WordOrder
+				if typ == "" {
+					typ = "uint16"
+				}
+				if order == "" {
+					order = e.Order
+				}
+				types.defaults(typ, order, tag.Gain, tag.Offset)
+			}
+			return a, args[1:], nil
+		}
+	}
+	a, rest, err := parseLocation(args)
+	if err == nil && types != nil && !a.Table.IsBit() {
+		types.defaults(e.Type, e.Order, 0, 0)
+	}
+	return a, rest, err
+}
+
+// inherit takes the connection flags of a command in the shell which
+// are not set on its command line from the shell.
+func (c *connection) inherit() {
+	s := current
+	if s == nil || s.conn == c {
+		return
+	}
+	set := make(map[string]bool)
+	c.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["a"] {
+		c.address = s.conn.address
+		c.endpoint = s.conn.endpoint
+	}
+	if !set["u"] {
+		c.unit = s.conn.unit
+	}
+	if !set["timeout"] {
+		c.timeout = s.conn.timeout
+	}
+}
+
+// dial connects to the device.
+func (c *connection) dial() (*modbustcp.ModbusTcpClient, error) {
+	client, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	if err = client.Connect(); err != nil {
+		return nil, fmt.Errorf("connecting %v: %w", c.address, err)
+	}
+	return client, nil
+}
+
+// client creates a client of the device without connecting it.
+func (c *connection) client() (*modbustcp.ModbusTcpClient, error) {
+	c.inherit()
+	if c.unit > 255 {
+		return nil, usagef("invalid unit id '%v'", c.unit)
+	}
+	var config *tls.Config
+	if c.endpoint != nil {
+		config = c.endpoint.config
+	}
+	host, port, err := net.SplitHostPort(c.address)
+	if err != nil {
+		// the default port
+		host, port = strings.Trim(c.address, "[]"), "502"
+		if config != nil {
+			port = strconv.Itoa(modbustcp.TlsPort)
+		}
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return nil, usagef("invalid port '%v'", port)
+	}
+	client := modbustcp.NewModbusTcpClient(host, p)
+	client.SlaveId = byte(c.unit)
+	client.Timeout = c.timeout
+	client.TLSConfig = config
+	return client, nil
+}
+
+// parseLocation parses the leading table name and protocol offset or
+// address in Modicon notation of args and returns the other arguments.
+func parseLocation(args []string) (modbustcp.Address, []string, error) {
+	if len(args) == 0 {
+		return modbustcp.Address{}, nil, usagef("missing address")
+	}
+	if table, err := modbustcp.ParseTable(args[0]); err == nil {
+		if len(args) < 2 {
+			return modbustcp.Address{}, nil, usagef("missing address of table %v", table)
+		}
+		offset, err := parseNumber(args[1], 16)
+		if err != nil {
+			return modbustcp.Address{}, nil, usagef("invalid address '%v'", args[1])
+		}
+		return modbustcp.Address{Table: table, Offset: uint16(offset)}, args[2:], nil
+	}
+	a, err := modbustcp.ParseAddress(args[0])
+	if err != nil {
+		return a, nil, usageError{err}
+	}
+	return a, args[1:], nil
+}
+
+// parseNumber parses a decimal or 0x prefixed hexadecimal number of up to
+// bits bits. Leading zeros are decimal, "0100" is 100 and not octal.
+func parseNumber(s string, bits int) (uint64, error) {
+	if hex, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		return strconv.ParseUint(hex, 16, bits)
+	}
+	return strconv.ParseUint(s, 10, bits)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+var decodeCommand = &command{
+	name:    "decode",
+	args:    "type [word_order] register...",
+	summary: "Decode register values as a data type or string without a device",
+	setup:   setupDecode,
+}
+
+func setupDecode(fs *flag.FlagSet) execFunc {
+	output := outputFlag(fs)
+	return func(args []string, stdout, _ io.Writer) error {
+		if len(args) == 0 {
+			return usagef("missing type")
+		}
+		var codec modbustcp.Codec
+		text := strings.EqualFold(args[0], "string")
+		if !text {
+			var err error
+			if codec.Type, err = modbustcp.ParseDataType(args[0]); err != nil {
+				return usageError{err}
+			}
+		}
+		args = args[1:]
+		if len(args) > 0 {
+			if order, err := modbustcp.ParseWordOrder(args[0]); err == nil {
+				codec.Order = order
+				args = args[1:]
+			}
+		}
+		regs := make([]uint16, len(args))
+		for i, s := range args {
+			r, err := parseRegister(s)
+			if err != nil {
+				return err
+			}
+			regs[i] = r
+		}
+		w := output.newRecordWriter(stdout, "value")
+		if text {
+			if err := w.write(modbustcp.DecodeString(regs, modbustcp.StringOptions{})); err != nil {
+				return err
+			}
+			return w.flush()
+		}
+		n := codec.Registers()
+		if len(regs) == 0 || len(regs)%n != 0 {
+			return usagef("%v registers expected per %v value, actual %v", n, codec.Type, len(regs))
+		}
+		for i := 0; i < len(regs); i += n {
+			v, err := codec.Raw(regs[i : i+n])
+			if err != nil {
+				return err
+			}
+			if err := w.write(v); err != nil {
+				return err
+			}
+		}
+		return w.flush()
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var expectCommand = &command{
+	name:    "expect",
+	args:    "table address value... | reference value...",
+	summary: "Fail unless coils, discrete inputs or registers have the values",
+	setup:   setupExpect,
+}
+
+// mismatchError is a failed expectation.
+type mismatchError struct {
+	mismatches []string
+}
+
+func (e mismatchError) Error() string {
+	return strings.Join(e.mismatches, ", ")
+}
+
+func setupExpect(fs *flag.FlagSet) execFunc {
+	conn := connectionFlags(fs)
+	return func(args []string, _, _ io.Writer) error {
+		args, err := conn.use(args)
+		if err != nil {
+			return err
+		}
+		a, values, err := conn.location(args, nil)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return usagef("missing values")
+		}
+		var (
+			want   []interface{}
+			actual []interface{}
+		)
+		for _, s := range values {
+			if a.Table.IsBit() {
+				b, err := parseBit(s)
+				if err != nil {
+					return err
+				}
+				want = append(want, b)
+			} else {
+				r, err := parseRegister(s)
+				if err != nil {
+					return err
+				}
+				want = append(want, r)
+			}
+		}
+		client, done, err := conn.open()
+		if err != nil {
+			return err
+		}
+		defer done()
+		if a.Table.IsBit() {
+			bits, err := readBits(client, a, uint16(len(want)))
+			if err != nil {
+				return err
+			}
+			for _, b := range bits {
+				actual = append(actual, b)
+			}
+		} else {
+			regs, err := readRegisters(client, a, uint16(len(want)))
+			if err != nil {
+				return err
+			}
+			for _, r := range regs {
+				actual = append(actual, r)
+			}
+		}
+		var e mismatchError
+		for i := range want {
+			if want[i] != actual[i] {
+				e.mismatches = append(e.mismatches, fmt.Sprintf("%v %v expected %v, actual %v", a.Table, int(a.Offset)+i, want[i], actual[i]))
+			}
+		}
+		if e.mismatches != nil {
+			return e
+		}
+		return nil
+	}
+}
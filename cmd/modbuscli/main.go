@@ -0,0 +1,148 @@
+// Command modbuscli reads and writes the data tables of Modbus TCP
+// devices from the command line:
+//
+//	modbuscli read -a 10.0.0.5:502 -u 1 holding 100 8
+//	modbuscli read -a 10.0.0.5 30001 2
+//	modbuscli write -a 10.0.0.5:502 -u 1 holding 100 1 2 0x1f
+//	modbuscli write -a 10.0.0.5:502 coils 3 on
+//	modbuscli read -a 10.0.0.5 -type int32 -order cdab -scale 0.1 holding 100 2
+//	modbuscli write -a 10.0.0.5 -type float32 holding 200 21.5
+//	modbuscli read @boiler1 temperature
+//	modbuscli expect -a 10.0.0.5:502 holding 100 1 2
+//	modbuscli scan -a 10.0.0.1 units 1 32
+//	modbuscli scan -a 10.0.0.5 -u 1 holding 0 9999
+//	modbuscli watch -a 10.0.0.5 -i 500ms input 0 4
+//	modbuscli decode float32 cdab 0x0000 0x4049
+//	modbuscli shell -a 10.0.0.5 -u 1
+//	modbuscli run -a 10.0.0.5 commissioning.txt
+//	modbuscli serve -config sim.yaml -listen :1502
+//	modbuscli proxy -listen :1502 -w traffic.pcap 10.0.0.5
+//	tcpdump -U -w - tcp port 502 | modbuscli sniff
+//
+// Addresses are given as table name and protocol offset, or in Modicon
+// notation. Registers are read and written raw, or as engineering values
+// of the data type, word order and scale selected by the -type, -order
+// and -scale flags. The results are printed as text, json, csv, table or
+// hex selected by the -output flag, e.g. "modbuscli read -o json holding
+// 0 4 | jq .value".
+//
+// Endpoints of the profiles file ~/.modbuscli.yaml named by "@name"
+// supply the connection, TLS settings, default types and tag names.
+//
+// The shell runs the commands interactively on one connection, with a
+// history of the lines, and run executes scripts of them. Serve covers
+// the other side of bench tests, serving a data store or the simulated
+// devices of a configuration as described by package simulator. Proxy
+// and sniff print the decoded transactions of the traffic passed through
+// or captured. Run "modbuscli help command" for the flags of a command.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// command is a subcommand of the tool.
+type command struct {
+	name string
+	// args describes the arguments following the flags
+	args    string
+	summary string
+	// setup defines the flags of the command on fs and returns the
+	// function executing it.
+	setup func(fs *flag.FlagSet) execFunc
+}
+
+// execFunc executes a command with the arguments following the flags. It
+// writes its results to stdout and progress and diagnostics to stderr.
+type execFunc func(args []string, stdout, stderr io.Writer) error
+
+var commands []*command
+
+func init() {
+	// the shell and scripts refer to commands
+	commands = []*command{readCommand, writeCommand, expectCommand, scanCommand, watchCommand, decodeCommand, shellCommand, runCommand, serveCommand, proxyCommand, sniffCommand}
+}
+
+// usageError is a failure due to invalid arguments.
+type usageError struct {
+	error
+}
+
+func usagef(format string, args ...interface{}) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit code: 1 for
+// failures and 2 for invalid arguments.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	code, _ := dispatch(args, stdout, stderr)
+	return code
+}
+
+// dispatch executes the command of args and returns the exit code and
+// the failure of the command, which is reported to stderr.
+func dispatch(args []string, stdout, stderr io.Writer) (int, error) {
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		if len(args) < 2 {
+			usage(stdout)
+			return 0, nil
+		}
+		name, args = args[1], []string{args[1], "-h"}
+	}
+	var cmd *command
+	for _, c := range commands {
+		if c.name == name {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		err := usagef("unknown command '%v'", name)
+		fmt.Fprintf(stderr, "modbuscli: %v\n", err)
+		usage(stderr)
+		return 2, err
+	}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: modbuscli %v [flags] %v\n\n%v.\n\n", cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	exec := cmd.setup(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0, nil
+		}
+		return 2, usageError{err}
+	}
+	err := exec(fs.Args(), stdout, stderr)
+	if err == nil {
+		return 0, nil
+	}
+	fmt.Fprintf(stderr, "modbuscli: %v\n", err)
+	if errors.As(err, &usageError{}) {
+		fs.Usage()
+		return 2, err
+	}
+	return 1, err
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: modbuscli command [flags] [arguments]\n\ncommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8v %v\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"modbuscli help command\" for the flags of a command.\n")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// serve starts s and returns its address.
+func serve(t *testing.T, s *modbustcp.Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return l.Addr().String()
+}
+
+// execute runs the command line and returns the exit code and outputs.
+func execute(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestReadWrite(t *testing.T) {
+	s := modbustcp.NewServer()
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 100, []uint16{1, 2, 3})
+	s.Store.SetBits(modbustcp.TableDiscreteInputs, 0, []bool{true, false})
+	addr := serve(t, s)
+
+	code, out, errOut := execute("read", "-a", addr, "-u", "1", "holding", "100", "3")
+	if code != 0 || out != "100\t1\n101\t2\n102\t3\n" {
+		t.Fatalf("registers expected, actual %v %q %q", code, out, errOut)
+	}
+	if code, out, _ = execute("read", "-a", addr, "holding", "0100"); code != 0 || out != "100\t1\n" {
+		t.Fatalf("register 100 expected, actual %v %q", code, out)
+	}
+	if code, out, _ = execute("read", "-a", addr, "holding", "0x66"); code != 0 || out != "102\t3\n" {
+		t.Fatalf("register 102 expected, actual %v %q", code, out)
+	}
+	if code, out, _ = execute("read", "-a", addr, "10001", "2"); code != 0 || out != "0\t1\n1\t0\n" {
+		t.Fatalf("discrete inputs expected, actual %v %q", code, out)
+	}
+	if code, _, errOut = execute("write", "-a", addr, "holding", "101", "0x10", "-1"); code != 0 {
+		t.Fatalf("write expected to succeed, actual %v %q", code, errOut)
+	}
+	if regs, _ := s.Store.GetRegisters(modbustcp.TableHoldingRegisters, 101, 2); regs[0] != 16 || regs[1] != 0xffff {
+		t.Fatalf("registers expected [16 65535], actual %v", regs)
+	}
+	if code, _, errOut = execute("write", "-a", addr, "holding", "0100", "0100", "-32768"); code != 0 {
+		t.Fatalf("write expected to succeed, actual %v %q", code, errOut)
+	}
+	if regs, _ := s.Store.GetRegisters(modbustcp.TableHoldingRegisters, 100, 2); regs[0] != 100 || regs[1] != 0x8000 {
+		t.Fatalf("registers expected [100 32768], actual %v", regs)
+	}
+	if code, _, errOut = execute("write", "-a", addr, "coils", "3", "on"); code != 0 {
+		t.Fatalf("write expected to succeed, actual %v %q", code, errOut)
+	}
+	if bits, _ := s.Store.GetBits(modbustcp.TableCoils, 3, 1); !bits[0] {
+		t.Fatal("coil expected on")
+	}
+
+	for _, c := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"read", "-a", addr, "holding", "65535", "2"}, 1},
+		{[]string{"read", "-a", addr, "holding"}, 2},
+		{[]string{"read", "-a", addr, "holding", "1", "2", "3"}, 2},
+		{[]string{"write", "-a", addr, "input", "1", "2"}, 2},
+		{[]string{"write", "-a", addr, "coils", "1", "maybe"}, 2},
+		{[]string{"write", "-a", addr, "holding", "1", "0b1"}, 2},
+		{[]string{"write", "-a", addr, "holding", "1", "-32769"}, 2},
+		{[]string{"read", "-x"}, 2},
+		{[]string{"erase"}, 2},
+		{nil, 2},
+	} {
+		if code, _, errOut = execute(c.args...); code != c.code || errOut == "" {
+			t.Fatalf("%v expected exit code %v, actual %v %q", c.args, c.code, code, errOut)
+		}
+	}
+	if code, out, _ = execute("help"); code != 0 || !strings.Contains(out, "write") {
+		t.Fatalf("usage expected, actual %v %q", code, out)
+	}
+	if code, _, errOut = execute("help", "read"); code != 0 || !strings.Contains(errOut, "-timeout") {
+		t.Fatalf("usage of read expected, actual %v %q", code, errOut)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// outputFormat is the format of results selected by the output flag:
+//
+//	text   tab separated values, the default
+//	json   an object per line keyed by the column names
+//	csv    comma separated values with a header line
+//	table  aligned columns with a header line
+//	hex    text with integers in hexadecimal
+type outputFormat string
+
+func outputFlag(fs *flag.FlagSet) *outputFormat {
+	f := outputFormat("text")
+	fs.Var(&f, "output", "output `format`: text, json, csv, table or hex")
+	fs.Var(&f, "o", "shorthand for -output")
+	return &f
+}
+
+func (f *outputFormat) String() string {
+	return string(*f)
+}
+
+// Set implements flag.Value.
+func (f *outputFormat) Set(s string) error {
+	switch s {
+	case "text", "json", "csv", "table", "hex":
+		*f = outputFormat(s)
+		return nil
+	}
+	return fmt.Errorf("unknown output format '%v'", s)
+}
+
+// recordWriter writes result records of the named columns.
+type recordWriter struct {
+	format  outputFormat
+	columns []string
+	out     io.Writer
+	csv     *csv.Writer
+	tab     *tabwriter.Writer
+	// err is the first failed write, which stops writing
+	err error
+}
+
+// newRecordWriter writes records to out, starting with the header line of
+// the csv and table formats.
+func (f outputFormat) newRecordWriter(out io.Writer, columns ...string) *recordWriter {
+	w := &recordWriter{format: f, columns: columns, out: out}
+	switch f {
+	case "csv":
+		w.csv = csv.NewWriter(out)
+		w.csv.Write(columns)
+	case "table":
+		w.tab = tabwriter.NewWriter(out, 8, 0, 2, ' ', 0)
+		fmt.Fprintln(w.tab, strings.Join(columns, "\t"))
+	}
+	return w
+}
+
+// write writes a record of a value per column. Values are integers,
+// booleans, strings and times. Once a write failed its error is
+// returned by all further writes and flushes.
+func (w *recordWriter) write(values ...interface{}) error {
+	if w.err == nil {
+		w.err = w.writeRecord(values)
+	}
+	return w.err
+}
+
+func (w *recordWriter) writeRecord(values []interface{}) error {
+	switch w.format {
+	case "json":
+		var b strings.Builder
+		b.WriteByte('{')
+		for i, v := range values {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			key, _ := json.Marshal(w.columns[i])
+			value, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			b.Write(key)
+			b.WriteByte(':')
+			b.Write(value)
+		}
+		b.WriteString("}\n")
+		_, err := io.WriteString(w.out, b.String())
+		return err
+	case "csv":
+		cells := make([]string, len(values))
+		for i, v := range values {
+			cells[i] = w.cell(v)
+		}
+		w.csv.Write(cells)
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	cells := make([]string, len(values))
+	for i, v := range values {
+		cells[i] = w.cell(v)
+	}
+	if w.tab != nil {
+		fmt.Fprintln(w.tab, strings.Join(cells, "\t"))
+		return nil
+	}
+	_, err := fmt.Fprintln(w.out, strings.Join(cells, "\t"))
+	return err
+}
+
+// cell formats a value of a text record.
+func (w *recordWriter) cell(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case int, uint16, byte:
+		if w.format == "hex" {
+			return fmt.Sprintf("0x%04x", v)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// summary writes a closing line of human readable formats.
+func (w *recordWriter) summary(format string, args ...interface{}) {
+	if !w.format.human() {
+		return
+	}
+	w.flush()
+	fmt.Fprintf(w.out, format+"\n", args...)
+}
+
+// flush writes buffered records.
+func (w *recordWriter) flush() error {
+	if w.tab != nil && w.err == nil {
+		w.err = w.tab.Flush()
+	}
+	return w.err
+}
+
+// human reports whether the format is meant to be read rather than
+// parsed.
+func (f outputFormat) human() bool {
+	return f == "text" || f == "table" || f == "hex"
+}
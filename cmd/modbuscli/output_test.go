@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestOutputFormats(t *testing.T) {
+	s := modbustcp.NewServer()
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 8, []uint16{10, 255})
+	s.Store.SetBits(modbustcp.TableCoils, 0, []bool{true})
+	addr := serve(t, s)
+	for format, expected := range map[string]string{
+		"text":  "8\t10\n9\t255\n",
+		"json":  "{\"address\":8,\"value\":10}\n{\"address\":9,\"value\":255}\n",
+		"csv":   "address,value\n8,10\n9,255\n",
+		"table": "address  value\n8        10\n9        255\n",
+		"hex":   "0x0008\t0x000a\n0x0009\t0x00ff\n",
+	} {
+		code, out, errOut := execute("read", "-a", addr, "--output", format, "holding", "8", "2")
+		if code != 0 || out != expected {
+			t.Fatalf("%v output expected %q, actual %v %q %q", format, expected, code, out, errOut)
+		}
+	}
+	if code, out, _ := execute("read", "-a", addr, "-o", "json", "coils", "0"); code != 0 || out != "{\"address\":0,\"value\":true}\n" {
+		t.Fatalf("json coil expected, actual %v %q", code, out)
+	}
+	code, out, _ := execute("watch", "-a", addr, "-n", "1", "-o", "csv", "holding", "9")
+	if code != 0 || !regexp.MustCompile(`^time,address,value,changed\n[^,]+,9,255,0\n$`).MatchString(out) {
+		t.Fatalf("csv poll expected, actual %v %q", code, out)
+	}
+	if code, _, _ := execute("read", "-a", addr, "-o", "xml", "holding", "8"); code != 2 {
+		t.Fatalf("exit code expected 2, actual %v", code)
+	}
+}
+
+// failingWriter fails after accepting n bytes, like a closed pipe.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, io.ErrClosedPipe
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestOutputErrors(t *testing.T) {
+	s := modbustcp.NewServer()
+	addr := serve(t, s)
+	var stderr io.Writer = io.Discard
+	for _, format := range []string{"text", "json", "csv", "table"} {
+		if code := run([]string{"read", "-a", addr, "-o", format, "holding", "0", "100"}, &failingWriter{n: 20}, stderr); code != 1 {
+			t.Fatalf("%v exit code of failed output expected 1, actual %v", format, code)
+		}
+	}
+	w := outputFormat("json").newRecordWriter(&failingWriter{}, "value")
+	if err := w.write(1); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("error expected %v, actual %v", io.ErrClosedPipe, err)
+	}
+	if err := w.flush(); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("flush error expected %v, actual %v", io.ErrClosedPipe, err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/patdhlk/modbustcp"
+	"github.com/patdhlk/modbustcp/internal/yaml"
+)
+
+// profiles is the profiles file naming endpoints, ~/.modbuscli.yaml or
+// the file of the environment variable MODBUSCLI_PROFILES, .yaml, .yml
+// or .json:
+//
+//	endpoints:
+//	  boiler1:
+//	    address: 10.0.0.5
+//	    unit: 3
+//	    timeout: 2s
+//	    order: cdab
+//	    tls:
+//	      ca: ca.pem
+//	      cert: client.pem
+//	      key: client.key
+//	    tags:
+//	      - name: temperature
+//	        address: 40101
+//	        type: int16
+//	        gain: 0.1
+//
+// Commands select an endpoint by its name with a leading @ before the
+// other arguments, e.g. "modbuscli read @boiler1 temperature". Its
+// settings apply unless the flags are given, its tags name addresses.
+type profiles struct {
+	Endpoints map[string]*endpoint `json:"endpoints"`
+}
+
+// endpoint is a named device of the profiles file.
+type endpoint struct {
+	// Address is host or host:port, the port is 502, or 802 with TLS,
+	// if omitted.
+	Address string             `json:"address"`
+	Unit    *uint              `json:"unit,omitempty"`
+	Timeout modbustcp.Duration `json:"timeout,omitempty"`
+	// Type and Order are the defaults of the -type and -order flags.
+	Type  string                `json:"type,omitempty"`
+	Order string                `json:"order,omitempty"`
+	TLS   *tlsSettings          `json:"tls,omitempty"`
+	Tags  []modbustcp.TagConfig `json:"tags,omitempty"`
+
+	name   string
+	config *tls.Config
+}
+
+// tlsSettings configure Modbus/TCP Security. Relative paths are resolved
+// against the directory of the profiles file.
+type tlsSettings struct {
+	// CA is the PEM file of the certificate authorities of the server,
+	// the system roots if empty.
+	CA string `json:"ca,omitempty"`
+	// Cert and Key are the PEM files of the client certificate.
+	Cert               string `json:"cert,omitempty"`
+	Key                string `json:"key,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// profilesPath returns the path of the profiles file, empty if there is
+// no home directory.
+func profilesPath() string {
+	if path := os.Getenv("MODBUSCLI_PROFILES"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".modbuscli.yaml")
+}
+
+// lookupEndpoint returns the endpoint of the profiles file named name.
+func lookupEndpoint(name string) (*endpoint, error) {
+	path := profilesPath()
+	if path == "" {
+		return nil, fmt.Errorf("no profiles file for endpoint '%v'", name)
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("unknown endpoint '%v', no profiles file %v", name, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &profiles{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, p)
+	default:
+		err = yaml.Unmarshal(data, p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	e := p.Endpoints[name]
+	if e == nil {
+		return nil, fmt.Errorf("unknown endpoint '%v' in %v", name, path)
+	}
+	e.name = name
+	if e.Address == "" {
+		return nil, fmt.Errorf("%v: endpoint '%v' has no address", path, name)
+	}
+	if e.TLS != nil {
+		if e.config, err = e.TLS.config(filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("%v: endpoint '%v': %v", path, name, err)
+		}
+	}
+	return e, nil
+}
+
+// config loads the certificates of the settings.
+func (s *tlsSettings) config(dir string) (*tls.Config, error) {
+	resolve := func(path string) string {
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				return filepath.Join(home, rest)
+			}
+		}
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	config := &tls.Config{ServerName: s.ServerName, InsecureSkipVerify: s.InsecureSkipVerify}
+	if s.CA != "" {
+		data, err := os.ReadFile(resolve(s.CA))
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates in %v", s.CA)
+		}
+	}
+	if s.Cert != "" || s.Key != "" {
+		cert, err := tls.LoadX509KeyPair(resolve(s.Cert), resolve(s.Key))
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// tag returns the tag of the endpoint named name.
+func (e *endpoint) tag(name string) *modbustcp.TagConfig {
+	for i := range e.Tags {
+		if e.Tags[i].Name == name {
+			return &e.Tags[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestProfiles(t *testing.T) {
+	s := modbustcp.NewServer()
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 100, []uint16{234})
+	addr := serve(t, s)
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	profiles := "endpoints:\n" +
+		"  boiler1:\n    address: " + addr + "\n    unit: 3\n    timeout: 2s\n    order: cdab\n    tags:\n" +
+		"      - {name: temperature, address: 40101, type: int16, gain: 0.1}\n" +
+		"      - {name: setpoint, address: \"holding:200\", type: float32}\n" +
+		"  secure:\n    address: boiler2.plant\n    tls:\n      insecure_skip_verify: true\n" +
+		"  broken:\n    address: boiler3\n    tls:\n      ca: missing.pem\n"
+	if err := os.WriteFile(path, []byte(profiles), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MODBUSCLI_PROFILES", path)
+
+	for _, c := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"read", "@boiler1", "temperature"}, "100\t23.4\n"},
+		{[]string{"read", "-scale", "1", "@boiler1", "temperature"}, "100\t234\n"},
+		{[]string{"read", "@boiler1", "holding", "100"}, "100\t234\n"},
+	} {
+		if code, out, errOut := execute(c.args...); code != 0 || out != c.expected {
+			t.Fatalf("%v expected %q, actual %v %q %q", c.args, c.expected, code, out, errOut)
+		}
+	}
+	if code, _, errOut := execute("write", "@boiler1", "setpoint", "21.5"); code != 0 {
+		t.Fatalf("write expected to succeed, actual %v %q", code, errOut)
+	}
+	// the word order of the endpoint
+	if regs, _ := s.Store.GetRegisters(modbustcp.TableHoldingRegisters, 200, 2); regs[0] != 0 || regs[1] != 0x41ac {
+		t.Fatalf("registers expected [0 41ac], actual %x", regs)
+	}
+
+	stdin = strings.NewReader("read temperature\nread -u 1 holding 200 2\n")
+	defer func() { stdin = os.Stdin }()
+	if code, out, errOut := execute("shell", "-history", "", "@boiler1"); code != 0 || out != "100\t23.4\n200\t0\n201\t16812\n" {
+		t.Fatalf("shell of the endpoint expected, actual %v %q %q", code, out, errOut)
+	}
+
+	for _, args := range [][]string{
+		{"read", "@boiler9", "holding", "0"},
+		{"read", "@broken", "holding", "0"},
+	} {
+		if code, _, _ := execute(args...); code != 1 {
+			t.Fatalf("%v exit code expected 1, actual %v", args, code)
+		}
+	}
+	conn := connectionFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+	if _, err := conn.use([]string{"@secure"}); err != nil {
+		t.Fatal(err)
+	}
+	client, err := conn.client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Port != modbustcp.TlsPort || client.TLSConfig == nil || !client.TLSConfig.InsecureSkipVerify {
+		t.Fatalf("tls client on port %v expected, actual %v %v", modbustcp.TlsPort, client.Port, client.TLSConfig)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+var proxyCommand = &command{
+	name:    "proxy",
+	args:    "target",
+	summary: "Pass connections through to a device, printing the transactions",
+	setup:   setupProxy,
+}
+
+func setupProxy(fs *flag.FlagSet) execFunc {
+	listen := fs.String("listen", ":1502", "listen `address`")
+	capture := fs.String("w", "", "write the traffic to a pcap `file`")
+	timeout := fs.Duration("timeout", 5*time.Second, "connect `timeout` of the target")
+	output := outputFlag(fs)
+	return func(args []string, stdout, stderr io.Writer) error {
+		if len(args) != 1 {
+			return usagef("expected a target, host or host:port")
+		}
+		target := args[0]
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			target = net.JoinHostPort(target, "502")
+		}
+		p := modbustcp.NewProxy(target)
+		p.DialTimeout = *timeout
+		records := output.newTransactionWriter(stdout)
+		p.Observe = records.observe
+		p.ErrorHandler = func(err error) { fmt.Fprintf(stderr, "modbuscli: %v\n", err) }
+		if *capture != "" {
+			f, err := os.Create(*capture)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			p.Capture = modbustcp.NewPcapWriter(f)
+		}
+		l, err := net.Listen("tcp", *listen)
+		if err != nil {
+			return err
+		}
+		ctx, stop := interrupted()
+		defer stop()
+		go func() {
+			// failing output ends proxying like an interrupt
+			select {
+			case <-ctx.Done():
+			case <-records.failed:
+			}
+			p.Close()
+		}()
+		fmt.Fprintf(stderr, "modbuscli: proxying %v to %v\n", l.Addr(), target)
+		err = p.Serve(l)
+		if werr := records.error(); werr != nil {
+			return werr
+		}
+		if errors.Is(err, modbustcp.ErrorServerClosed) && ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strconv"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+var readCommand = &command{
+	name:    "read",
+	args:    "table address [count] | reference [count]",
+	summary: "Read coils, discrete inputs or registers",
+	setup:   setupRead,
+}
+
+func setupRead(fs *flag.FlagSet) execFunc {
+	conn := connectionFlags(fs)
+	output := outputFlag(fs)
+	types := typeFlags(fs)
+	return func(args []string, out, _ io.Writer) error {
+		args, err := conn.use(args)
+		if err != nil {
+			return err
+		}
+		a, rest, err := conn.location(args, types)
+		if err != nil {
+			return err
+		}
+		codec, err := types.codec(a.Table)
+		if err != nil {
+			return err
+		}
+		count := uint64(1)
+		switch {
+		case len(rest) > 1:
+			return usagef("unexpected arguments %v", rest[1:])
+		case len(rest) == 1:
+			if count, err = strconv.ParseUint(rest[0], 10, 16); err != nil || count == 0 {
+				return usagef("invalid count '%v'", rest[0])
+			}
+		}
+		client, done, err := conn.open()
+		if err != nil {
+			return err
+		}
+		defer done()
+		w := output.newRecordWriter(out, "address", "value")
+		if a.Table.IsBit() {
+			bits, err := readBits(client, a, uint16(count))
+			if err != nil {
+				return err
+			}
+			for i, b := range bits {
+				if err := w.write(int(a.Offset)+i, b); err != nil {
+					return err
+				}
+			}
+			return w.flush()
+		}
+		if codec != nil {
+			n := codec.registers(int(count))
+			if n > 0xffff {
+				return usagef("invalid count '%v' of %v values", count, types.typ)
+			}
+			regs, err := readRegisters(client, a, uint16(n))
+			if err != nil {
+				return err
+			}
+			values, err := codec.decode(regs)
+			if err != nil {
+				return err
+			}
+			for i, v := range values {
+				if err := w.write(int(a.Offset)+i*codec.Registers(), v); err != nil {
+					return err
+				}
+			}
+			return w.flush()
+		}
+		regs, err := readRegisters(client, a, uint16(count))
+		if err != nil {
+			return err
+		}
+		for i, r := range regs {
+			if err := w.write(int(a.Offset)+i, r); err != nil {
+				return err
+			}
+		}
+		return w.flush()
+	}
+}
+
+// readBits reads count coils or discrete inputs from a.
+func readBits(c *modbustcp.ModbusTcpClient, a modbustcp.Address, count uint16) ([]bool, error) {
+	if a.Table == modbustcp.TableCoils {
+		return c.ReadCoils(a.Offset, count)
+	}
+	return c.ReadDiscreteInputs(a.Offset, count)
+}
+
+// readRegisters reads count holding or input registers from a.
+func readRegisters(c *modbustcp.ModbusTcpClient, a modbustcp.Address, count uint16) ([]uint16, error) {
+	if a.Table == modbustcp.TableHoldingRegisters {
+		return c.ReadHoldingRegisters(a.Offset, count)
+	}
+	return c.ReadInputRegisters(a.Offset, count)
+}
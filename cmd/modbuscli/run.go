@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/patdhlk/modbustcp/internal/yaml"
+)
+
+var runCommand = &command{
+	name:    "run",
+	args:    "script...",
+	summary: "Run scripts of commands, e.g. commissioning checklists",
+	setup:   setupRun,
+}
+
+// script is a sequence of command lines. Scripts are text files with a
+// command per line and # comments:
+//
+//	# pump 1 starts and reports running
+//	write coils 0 on
+//	sleep 2s
+//	expect discrete 0 on
+//	unit 2
+//	expect holding 10 0x0100
+//
+// or .yaml, .yml or .json files with the steps and optionally the device,
+// which the connection flags override:
+//
+//	address: 10.0.0.5:502
+//	unit: 1
+//	steps:
+//	  - write coils 0 on
+//	  - sleep 2s
+//	  - expect discrete 0 on
+type script struct {
+	Address string   `json:"address,omitempty"`
+	Unit    *uint    `json:"unit,omitempty"`
+	Steps   []string `json:"steps"`
+	// lines are the line numbers of the steps in text files.
+	lines []int
+}
+
+func loadScript(path string) (*script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc := &script{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, sc)
+	case ".json":
+		err = json.Unmarshal(data, sc)
+	default:
+		for i, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				sc.Steps = append(sc.Steps, line)
+				sc.lines = append(sc.lines, i+1)
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return sc, nil
+}
+
+// position returns the location of step i for failures.
+func (sc *script) position(path string, i int) string {
+	if sc.lines != nil {
+		return fmt.Sprintf("%v:%v", path, sc.lines[i])
+	}
+	return fmt.Sprintf("%v: step %v", path, i+1)
+}
+
+func setupRun(fs *flag.FlagSet) execFunc {
+	conn := connectionFlags(fs)
+	keepGoing := fs.Bool("k", false, "continue after failed steps")
+	verbose := fs.Bool("v", false, "print the steps to stderr")
+	return func(args []string, stdout, stderr io.Writer) error {
+		args, err := conn.use(args)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return usagef("missing script")
+		}
+		scripts := make([]*script, len(args))
+		for i, path := range args {
+			sc, err := loadScript(path)
+			if err != nil {
+				return err
+			}
+			scripts[i] = sc
+		}
+		set := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		failed, steps := 0, 0
+		for i, sc := range scripts {
+			if sc.Address != "" && !set["a"] {
+				conn.address = sc.Address
+				conn.endpoint = nil
+			}
+			if sc.Unit != nil && !set["u"] {
+				conn.unit = *sc.Unit
+			}
+			s, err := startSession(conn)
+			if err != nil {
+				return err
+			}
+			for j, step := range sc.Steps {
+				fields := strings.Fields(step)
+				if len(fields) == 0 {
+					continue
+				}
+				steps++
+				if *verbose {
+					fmt.Fprintf(stderr, "%v: %v\n", sc.position(args[i], j), step)
+				}
+				if err := s.execute(fields, stdout, stderr); err != nil {
+					failed++
+					fmt.Fprintf(stderr, "modbuscli: %v: failed: %v\n", sc.position(args[i], j), step)
+					if !*keepGoing {
+						s.close()
+						return fmt.Errorf("%v: stopped after failure", args[i])
+					}
+				}
+			}
+			s.close()
+		}
+		if failed > 0 {
+			return fmt.Errorf("%v of %v steps failed", failed, steps)
+		}
+		return nil
+	}
+}
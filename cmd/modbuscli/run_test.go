@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestRun(t *testing.T) {
+	s := modbustcp.NewServer()
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 0, []uint16{1, 2})
+	addr := serve(t, s)
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	text := write("check.txt", "# commissioning\nwrite holding 0 7 8\n\nsleep 1ms\nexpect holding 0 7 8\nwrite coils 0 on\nexpect 00001 1\nread holding 1\n")
+	code, out, errOut := execute("run", "-a", addr, text)
+	if code != 0 || out != "1\t8\n" {
+		t.Fatalf("script expected to succeed, actual %v %q %q", code, out, errOut)
+	}
+	if bits, _ := s.Store.GetBits(modbustcp.TableCoils, 0, 1); !bits[0] {
+		t.Fatal("coil expected on")
+	}
+
+	failing := write("failing.txt", "expect holding 0 7 9\nexpect holding 1 0x0008\nbogus\n")
+	code, _, errOut = execute("run", "-a", addr, failing)
+	if code != 1 || !strings.Contains(errOut, "holding 1 expected 9, actual 8") || !strings.Contains(errOut, "failing.txt:1: failed") || strings.Contains(errOut, "bogus") {
+		t.Fatalf("script expected to stop at line 1, actual %v %q", code, errOut)
+	}
+	code, _, errOut = execute("run", "-a", addr, "-k", failing)
+	if code != 1 || !strings.Contains(errOut, "failing.txt:3: failed: bogus") || !strings.Contains(errOut, "2 of 3 steps failed") {
+		t.Fatalf("script expected to continue, actual %v %q", code, errOut)
+	}
+
+	yaml := write("check.yaml", "address: "+addr+"\nunit: 3\nsteps:\n  - write holding 1 0x10\n  - expect holding 1 16\n  - unit 4\n")
+	if code, _, errOut = execute("run", "-v", yaml); code != 0 || !strings.Contains(errOut, "check.yaml: step 2: expect holding 1 16") {
+		t.Fatalf("yaml script expected to succeed, actual %v %q", code, errOut)
+	}
+	if code, _, _ = execute("run", "-a", addr, filepath.Join(dir, "missing.txt")); code != 1 {
+		t.Fatalf("exit code of missing script expected 1, actual %v", code)
+	}
+	if code, _, _ = execute("run"); code != 2 {
+		t.Fatalf("exit code without script expected 2, actual %v", code)
+	}
+}
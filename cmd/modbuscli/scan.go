@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+var scanCommand = &command{
+	name:    "scan",
+	args:    "units [from] [to] | table [from] [to]",
+	summary: "Scan a gateway for responding units or a table of a device for readable addresses",
+	setup:   setupScan,
+}
+
+func setupScan(fs *flag.FlagSet) execFunc {
+	conn := connectionFlags(fs)
+	parallel := fs.Int("parallel", 4, "concurrent `connections` of a unit scan")
+	probe := fs.Duration("probe-timeout", 200*time.Millisecond, "`timeout` per unit of a unit scan")
+	block := fs.Uint("block", 0, "`size` of the blocks tried first by a table scan, the maximum of the table if zero")
+	quiet := fs.Bool("q", false, "no progress output")
+	output := outputFlag(fs)
+	return func(args []string, stdout, stderr io.Writer) error {
+		args, err := conn.use(args)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return usagef("missing units or table")
+		}
+		progress := stderr
+		if *quiet {
+			progress = io.Discard
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if args[0] == "units" {
+			from, to, err := parseRange(args[1:], 1, 247, 255)
+			if err != nil {
+				return err
+			}
+			client, err := conn.client()
+			if err != nil {
+				return err
+			}
+			w := output.newRecordWriter(stdout, "unit")
+			return scanUnits(ctx, client, byte(from), byte(to), modbustcp.ScanOptions{Timeout: *probe, Parallel: *parallel}, w, progress)
+		}
+		table, err := modbustcp.ParseTable(args[0])
+		if err != nil {
+			return usagef("unknown scan '%v'", args[0])
+		}
+		from, to, err := parseRange(args[1:], 0, 0xffff, 0xffff)
+		if err != nil {
+			return err
+		}
+		if *block > 0xffff {
+			return usagef("invalid block size '%v'", *block)
+		}
+		client, done, err := conn.open()
+		if err != nil {
+			return err
+		}
+		defer done()
+		w := output.newRecordWriter(stdout, "from", "to", "quantity")
+		return scanTable(ctx, client, table, uint16(from), uint16(to), uint16(*block), w, progress)
+	}
+}
+
+// parseRange parses the optional first and last value of args.
+func parseRange(args []string, from, to, limit uint64) (uint64, uint64, error) {
+	if len(args) > 2 {
+		return 0, 0, usagef("unexpected arguments %v", args[2:])
+	}
+	for i, s := range args {
+		n, err := parseNumber(s, 64)
+		if err != nil || n > limit {
+			return 0, 0, usagef("invalid range '%v'", s)
+		}
+		if i == 0 {
+			from = n
+		} else {
+			to = n
+		}
+	}
+	if from > to {
+		return 0, 0, usagef("empty range %v to %v", from, to)
+	}
+	return from, to, nil
+}
+
+func scanUnits(ctx context.Context, client *modbustcp.ModbusTcpClient, from, to byte, opts modbustcp.ScanOptions, w *recordWriter, progress io.Writer) error {
+	total, probed, found := int(to)-int(from)+1, 0, 0
+	opts.Progress = func(unit byte, present bool) {
+		probed++
+		if present {
+			found++
+			fmt.Fprintf(progress, "\runit %v responded\n", unit)
+		}
+		fmt.Fprintf(progress, "\rscanning units %v/%v, %v found", probed, total, found)
+	}
+	units, err := client.ScanUnits(ctx, from, to, opts)
+	fmt.Fprintln(progress)
+	for _, unit := range units {
+		if werr := w.write(unit); werr != nil {
+			return werr
+		}
+	}
+	w.summary("%v of %v units responded", len(units), probed)
+	return err
+}
+
+func scanTable(ctx context.Context, client *modbustcp.ModbusTcpClient, table modbustcp.Table, from, to, block uint16, w *recordWriter, progress io.Writer) error {
+	// the table is scanned in chunks of the maximum quantity to report
+	// the progress
+	chunk := modbustcp.MaxReadRegisters
+	if table.IsBit() {
+		chunk = modbustcp.MaxReadBits
+	}
+	var regions []modbustcp.AddressRange
+	var err error
+	for address := int(from); address <= int(to) && err == nil; address += chunk {
+		last := min(address+chunk-1, int(to))
+		var found []modbustcp.AddressRange
+		found, err = client.ScanTable(ctx, table, uint16(address), uint16(last), block)
+		for _, r := range found {
+			if n := len(regions); n > 0 && int(regions[n-1].Address)+int(regions[n-1].Quantity) == int(r.Address) && int(regions[n-1].Quantity)+int(r.Quantity) <= 0xffff {
+				regions[n-1].Quantity += r.Quantity
+			} else {
+				regions = append(regions, r)
+			}
+		}
+		fmt.Fprintf(progress, "\rscanning %v %v/%v, %v regions", table, last-int(from)+1, int(to)-int(from)+1, len(regions))
+	}
+	fmt.Fprintln(progress)
+	readable := 0
+	for _, r := range regions {
+		if werr := w.write(int(r.Address), int(r.Address)+int(r.Quantity)-1, r.Quantity); werr != nil {
+			return werr
+		}
+		readable += int(r.Quantity)
+	}
+	w.summary("%v of %v addresses readable in %v regions", readable, int(to)-int(from)+1, len(regions))
+	return err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+	"github.com/patdhlk/modbustcp/simulator"
+)
+
+func TestScan(t *testing.T) {
+	sim := simulator.New()
+	for _, unit := range []byte{3, 7} {
+		d, err := simulator.NewDevice(simulator.DeviceConfig{Unit: unit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sim.Add(d)
+	}
+	addr := serve(t, sim.Server)
+	code, out, errOut := execute("scan", "-a", addr, "units", "1", "10")
+	if code != 0 || out != "3\n7\n2 of 10 units responded\n" || !strings.Contains(errOut, "unit 7 responded") {
+		t.Fatalf("units 3 and 7 expected, actual %v %q %q", code, out, errOut)
+	}
+
+	s := modbustcp.NewServer()
+	s.Store = modbustcp.NewDataStore(0, 0, 300, 0)
+	addr = serve(t, s)
+	code, out, errOut = execute("scan", "-a", addr, "-q", "holding", "100", "499")
+	if code != 0 || out != "100\t299\t200\n200 of 400 addresses readable in 1 regions\n" || errOut != "" {
+		t.Fatalf("holding registers 100-299 expected, actual %v %q %q", code, out, errOut)
+	}
+	for _, args := range [][]string{{"scan"}, {"scan", "units", "300"}, {"scan", "holding", "9", "1"}, {"scan", "files"}} {
+		if code, _, _ = execute(args...); code != 2 {
+			t.Fatalf("%v expected exit code 2, actual %v", args, code)
+		}
+	}
+}
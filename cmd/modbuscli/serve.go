@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/patdhlk/modbustcp"
+	"github.com/patdhlk/modbustcp/simulator"
+)
+
+var serveCommand = &command{
+	name:    "serve",
+	args:    "",
+	summary: "Serve a data store or simulated devices",
+	setup:   setupServe,
+}
+
+// interrupted returns the context of serve, done once the process is
+// interrupted or terminated.
+var interrupted = func() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+func setupServe(fs *flag.FlagSet) execFunc {
+	config := fs.String("config", "", "simulator configuration `file`, a data store of all addresses if empty")
+	listen := fs.String("listen", "", "listen `address`, overrides the configuration, :502 by default")
+	web := fs.String("http", "", "`address` of the web endpoint of the simulator, overrides the configuration")
+	scenario := fs.String("scenario", "", "scenario `file` played after the start")
+	profiles := fs.String("profiles", "", "comma separated simulator profile `files`")
+	return func(args []string, _, stderr io.Writer) error {
+		if len(args) > 0 {
+			return usagef("unexpected arguments %v", args)
+		}
+		ctx, stop := interrupted()
+		defer stop()
+		if *config == "" {
+			if *web != "" || *scenario != "" || *profiles != "" {
+				return usagef("-http, -scenario and -profiles require -config")
+			}
+			address := *listen
+			if address == "" {
+				address = ":502"
+			}
+			fmt.Fprintf(stderr, "modbuscli: serving a data store on %v\n", address)
+			return modbustcp.NewServer().ListenAndServeContext(ctx, address)
+		}
+		return serveSimulator(ctx, *config, *listen, *web, *scenario, *profiles, stderr)
+	}
+}
+
+// serveSimulator serves the simulator configured by the config file
+// until ctx is done.
+func serveSimulator(ctx context.Context, config, listen, web, scenario, profiles string, stderr io.Writer) error {
+	if profiles != "" {
+		for _, path := range strings.Split(profiles, ",") {
+			p, err := simulator.LoadProfile(path)
+			if err != nil {
+				return err
+			}
+			simulator.RegisterProfile(p)
+		}
+	}
+	cfg, err := simulator.LoadConfig(config)
+	if err != nil {
+		return err
+	}
+	if listen != "" {
+		cfg.Listen = listen
+	}
+	if web != "" {
+		cfg.HTTP = web
+	}
+	fleet, err := simulator.NewFleet(cfg)
+	if err != nil {
+		return err
+	}
+	for _, sim := range fleet.Simulators {
+		sim.ErrorHandler = func(err error) { fmt.Fprintf(stderr, "modbuscli: %v\n", err) }
+	}
+	var sc *simulator.Scenario
+	if scenario != "" {
+		if sc, err = simulator.LoadScenario(scenario); err != nil {
+			return err
+		}
+	}
+	go func() {
+		<-ctx.Done()
+		fleet.Close()
+	}()
+	if sc != nil {
+		go func() {
+			if err := fleet.Play(ctx, sc); err != nil && !errors.Is(err, context.Canceled) {
+				fmt.Fprintf(stderr, "modbuscli: %v\n", err)
+			}
+		}()
+	}
+	fmt.Fprintf(stderr, "modbuscli: serving units %v on %v instances\n", fleet.Simulators[0].Units(), len(fleet.Simulators))
+	if err = fleet.ListenAndServe(); errors.Is(err, modbustcp.ErrorServerClosed) {
+		return nil
+	}
+	return err
+}
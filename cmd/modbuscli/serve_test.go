@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// freeAddress returns a local address with a port which is not in use.
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestServe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sim.yaml")
+	config := "devices:\n  - unit: 5\n    blocks:\n      - address: \"40001\"\n        values: [42]\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func(f func() (context.Context, context.CancelFunc)) { interrupted = f }(interrupted)
+	for _, c := range []struct {
+		args  []string
+		check []string
+	}{
+		{[]string{"serve"}, []string{"expect", "holding", "0", "0"}},
+		{[]string{"serve", "-config", path}, []string{"expect", "-u", "5", "holding", "0", "42"}},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		interrupted = func() (context.Context, context.CancelFunc) { return ctx, cancel }
+		addr := freeAddress(t)
+		done := make(chan int)
+		go func() {
+			code, _, _ := execute(append(c.args, "-listen", addr)...)
+			done <- code
+		}()
+		var code int
+		for i := 0; i < 100; i++ {
+			if code, _, _ = execute(append([]string{c.check[0], "-a", addr}, c.check[1:]...)...); code == 0 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+		if code != 0 {
+			t.Fatalf("%v expected to serve, actual %v", c.args, code)
+		}
+		if code = <-done; code != 0 {
+			t.Fatalf("%v exit code expected 0, actual %v", c.args, code)
+		}
+	}
+	if code, _, _ := execute("serve", "-http", ":8080"); code != 2 {
+		t.Fatalf("exit code of -http without -config expected 2, actual %v", code)
+	}
+}
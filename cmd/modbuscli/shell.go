@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+var shellCommand = &command{
+	name:    "shell",
+	args:    "",
+	summary: "Run commands interactively on one connection",
+	setup:   setupShell,
+}
+
+// stdin is read by the shell.
+var stdin io.Reader = os.Stdin
+
+// maxHistory bounds the lines kept in the history file.
+const maxHistory = 1000
+
+// current is the session of the running shell, nil outside of it.
+var current *session
+
+// session is the connection shared by the commands of the shell.
+type session struct {
+	conn   *connection
+	client *modbustcp.ModbusTcpClient
+}
+
+// startSession connects to the device of conn and makes the session
+// current until it is closed.
+func startSession(conn *connection) (*session, error) {
+	if current != nil {
+		return nil, errors.New("a shell or script is already running")
+	}
+	client, err := conn.dial()
+	if err != nil {
+		return nil, err
+	}
+	current = &session{conn: conn, client: client}
+	return current, nil
+}
+
+func (s *session) close() {
+	current = nil
+	s.client.Disconnect()
+}
+
+// execute executes a command of the session, or one of its built-in
+// commands unit and sleep, and returns its failure, which is reported to
+// stderr.
+func (s *session) execute(args []string, stdout, stderr io.Writer) error {
+	var err error
+	switch args[0] {
+	case "unit":
+		unit := uint64(0)
+		if len(args) == 2 {
+			unit, err = strconv.ParseUint(args[1], 10, 8)
+		}
+		if len(args) != 2 || err != nil {
+			err = usagef("usage: unit id")
+			break
+		}
+		s.conn.unit = uint(unit)
+		return nil
+	case "sleep":
+		d := time.Duration(0)
+		if len(args) == 2 {
+			d, err = time.ParseDuration(args[1])
+		}
+		if len(args) != 2 || err != nil || d < 0 {
+			err = usagef("usage: sleep duration")
+			break
+		}
+		time.Sleep(d)
+		return nil
+	default:
+		_, err = dispatch(args, stdout, stderr)
+		if err != nil && !errors.As(err, &usageError{}) && !modbustcp.IsException(err) && !errors.As(err, &mismatchError{}) {
+			// reconnect after transport failures
+			s.client.Disconnect()
+		}
+		return err
+	}
+	fmt.Fprintf(stderr, "modbuscli: %v\n", err)
+	return err
+}
+
+// connect returns the client of the session, reconnecting if necessary.
+func (s *session) connect() (*modbustcp.ModbusTcpClient, error) {
+	if s.client.Conn == nil {
+		if err := s.client.Connect(); err != nil {
+			s.client.Conn = nil
+			return nil, fmt.Errorf("connecting %v: %w", s.conn.address, err)
+		}
+	}
+	return s.client, nil
+}
+
+func setupShell(fs *flag.FlagSet) execFunc {
+	conn := connectionFlags(fs)
+	history := fs.String("history", defaultHistory(), "history `file`, none if empty")
+	return func(args []string, stdout, stderr io.Writer) error {
+		args, err := conn.use(args)
+		if err != nil {
+			return err
+		}
+		if len(args) > 0 {
+			return usagef("unexpected arguments %v", args)
+		}
+		s, err := startSession(conn)
+		if err != nil {
+			return err
+		}
+		defer s.close()
+		h := loadHistory(*history)
+		in := bufio.NewScanner(stdin)
+		for {
+			fmt.Fprintf(stderr, "%v/%v> ", conn.address, conn.unit)
+			if !in.Scan() {
+				fmt.Fprintln(stderr)
+				return in.Err()
+			}
+			line := strings.TrimSpace(in.Text())
+			if strings.HasPrefix(line, "!") {
+				if line, err = h.recall(line); err != nil {
+					fmt.Fprintf(stderr, "modbuscli: %v\n", err)
+					continue
+				}
+				fmt.Fprintln(stderr, line)
+			}
+			args := strings.Fields(line)
+			if len(args) == 0 {
+				continue
+			}
+			h.add(line)
+			switch args[0] {
+			case "exit", "quit":
+				return nil
+			case "history":
+				h.list(stdout)
+			case "help":
+				if len(args) == 1 {
+					usage(stdout)
+					fmt.Fprintf(stdout, "\nIn the shell commands take the connection flags of the shell by default:\n")
+					fmt.Fprintf(stdout, "  unit id   select the unit id\n  sleep d   pause for the duration d\n  history   list the commands, !n repeats command n and !! the last\n  exit      leave the shell\n")
+					continue
+				}
+				dispatch(args, stdout, stderr)
+			default:
+				s.execute(args, stdout, stderr)
+			}
+		}
+	}
+}
+
+// defaultHistory returns the path of the history file in the home
+// directory, empty if there is none.
+func defaultHistory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".modbuscli_history")
+}
+
+// history holds the lines entered in the shell, appending them to a file
+// if path is not empty.
+type history struct {
+	path  string
+	lines []string
+}
+
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	if path == "" {
+		return h
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		h.lines = strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		if len(h.lines) == 1 && h.lines[0] == "" {
+			h.lines = nil
+		}
+		if len(h.lines) > maxHistory {
+			h.lines = h.lines[len(h.lines)-maxHistory:]
+			os.WriteFile(path, []byte(strings.Join(h.lines, "\n")+"\n"), 0o600)
+		}
+	}
+	return h
+}
+
+func (h *history) add(line string) {
+	h.lines = append(h.lines, line)
+	if h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// recall returns the line referenced by "!!" or "!n".
+func (h *history) recall(ref string) (string, error) {
+	n := len(h.lines)
+	if ref != "!!" {
+		var err error
+		if n, err = strconv.Atoi(ref[1:]); err != nil {
+			return "", fmt.Errorf("invalid history reference '%v'", ref)
+		}
+	}
+	if n < 1 || n > len(h.lines) {
+		return "", fmt.Errorf("no command %v in the history", ref)
+	}
+	return h.lines[n-1], nil
+}
+
+func (h *history) list(w io.Writer) {
+	for i, line := range h.lines {
+		fmt.Fprintf(w, "%5d  %v\n", i+1, line)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// countingListener counts the accepted connections.
+type countingListener struct {
+	net.Listener
+	accepted atomic.Int32
+}
+
+func (l *countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+func TestShell(t *testing.T) {
+	s := modbustcp.NewServer()
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 0, []uint16{1, 2})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counting := &countingListener{Listener: l}
+	go s.Serve(counting)
+	defer s.Close()
+
+	path := filepath.Join(t.TempDir(), "history")
+	os.WriteFile(path, []byte("read holding 1\n"), 0o600)
+	stdin = strings.NewReader("read holding 0 2\nwrite holding 0 7\n!2\n!1\n\nunit 2\nread -o json holding 0\nbogus\nhistory\nexit\nread holding 0\n")
+	defer func() { stdin = os.Stdin }()
+	code, out, errOut := execute("shell", "-a", l.Addr().String(), "-history", path)
+	if code != 0 {
+		t.Fatalf("exit code expected 0, actual %v %q", code, errOut)
+	}
+	expected := "0\t1\n1\t2\n0\t7\n1\t2\n1\t2\n{\"address\":0,\"value\":7}\n" +
+		"    1  read holding 1\n    2  read holding 0 2\n    3  write holding 0 7\n    4  read holding 0 2\n    5  read holding 1\n" +
+		"    6  unit 2\n    7  read -o json holding 0\n    8  bogus\n    9  history\n"
+	if out != expected {
+		t.Fatalf("output expected %q, actual %q", expected, out)
+	}
+	if !strings.Contains(errOut, "unknown command 'bogus'") || !strings.Contains(errOut, "/2> ") {
+		t.Fatalf("error of bogus and prompt of unit 2 expected, actual %q", errOut)
+	}
+	if n := counting.accepted.Load(); n != 1 {
+		t.Fatalf("1 connection expected, actual %v", n)
+	}
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 10 {
+		t.Fatalf("history of 10 lines expected, actual %q", data)
+	}
+	if code, _, _ = execute("decode", "float32", "cdab", "0", "0x4049"); code != 0 {
+		t.Fatalf("exit code expected 0, actual %v", code)
+	}
+}
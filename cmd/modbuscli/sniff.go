@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+var sniffCommand = &command{
+	name:    "sniff",
+	args:    "[capture]",
+	summary: "Print the transactions of a pcap capture, e.g. piped from tcpdump",
+	setup:   setupSniff,
+}
+
+func setupSniff(fs *flag.FlagSet) execFunc {
+	port := fs.Int("port", 502, "TCP `port` of the servers")
+	capture := fs.String("w", "", "write the packets of the port to a pcap `file`")
+	output := outputFlag(fs)
+	return func(args []string, stdout, stderr io.Writer) error {
+		if len(args) > 1 {
+			return usagef("unexpected arguments %v", args[1:])
+		}
+		if *port <= 0 || *port > 65535 {
+			return usagef("invalid port '%v'", *port)
+		}
+		in := stdin
+		if len(args) == 1 && args[0] != "-" {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		r, err := modbustcp.NewPcapReader(in)
+		if err != nil {
+			return err
+		}
+		var w *modbustcp.PcapWriter
+		if *capture != "" {
+			f, err := os.Create(*capture)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = modbustcp.NewPcapWriter(f)
+		}
+		records := output.newTransactionWriter(stdout)
+		m := &modbustcp.Monitor{
+			Port:         *port,
+			Observe:      records.observe,
+			ErrorHandler: func(err error) { fmt.Fprintf(stderr, "modbuscli: %v\n", err) },
+		}
+		defer m.Flush()
+		for {
+			t, src, dst, payload, err := r.ReadPacket()
+			if errors.Is(err, io.EOF) {
+				m.Flush()
+				return records.error()
+			}
+			if err != nil {
+				return err
+			}
+			if w != nil && (src.(*net.TCPAddr).Port == *port || dst.(*net.TCPAddr).Port == *port) {
+				if err = w.WritePacket(t, src, dst, payload); err != nil {
+					return err
+				}
+			}
+			m.Packet(t, src, dst, payload)
+			if err = records.error(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// transactionWriter writes the decoded transactions of proxy and sniff
+// as records, it is safe for concurrent use.
+type transactionWriter struct {
+	mu sync.Mutex
+	w  *recordWriter
+	// failed is closed by the first failed write
+	failed chan struct{}
+}
+
+func (f outputFormat) newTransactionWriter(out io.Writer) *transactionWriter {
+	return &transactionWriter{
+		w:      f.newRecordWriter(out, "time", "client", "tid", "unit", "function", "request", "response", "duration"),
+		failed: make(chan struct{}),
+	}
+}
+
+// error returns the first failed write.
+func (t *transactionWriter) error() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.w.err
+}
+
+func (t *transactionWriter) observe(tx *modbustcp.Transaction) {
+	duration := ""
+	if tx.Response != nil {
+		duration = tx.Duration.String()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.w.err != nil {
+		return
+	}
+	err := t.w.write(tx.Time, tx.Client.String(), int(tx.TransactionId), int(tx.Unit),
+		functionName(tx.Request.FunctionCode), describeRequest(tx.Request), describeResponse(tx.Request, tx.Response), duration)
+	if err == nil {
+		err = t.w.flush()
+	}
+	if err != nil {
+		close(t.failed)
+	}
+}
+
+var functionNames = map[byte]string{
+	modbustcp.FunctionReadCoil:                  "read coils",
+	modbustcp.FunctionReadDiscreteInputs:        "read discrete",
+	modbustcp.FunctionReadHoldingRegister:       "read holding",
+	modbustcp.FunctionReadInputRegister:         "read input",
+	modbustcp.FunctionWriteSingleCoil:           "write coil",
+	modbustcp.FunctionWriteSingleRegister:       "write register",
+	modbustcp.FunctionDiagnostics:               "diagnostics",
+	modbustcp.FunctionGetCommEventCounter:       "comm event counter",
+	modbustcp.FunctionGetCommEventLog:           "comm event log",
+	modbustcp.FunctionWriteMultipleCoils:        "write coils",
+	modbustcp.FunctionWriteMultipleRegister:     "write registers",
+	modbustcp.FunctionReadFileRecord:            "read file record",
+	modbustcp.FunctionWriteFileRecord:           "write file record",
+	modbustcp.FunctionMaskWriteRegister:         "mask write register",
+	modbustcp.FunctionReadWriteMultipleRegister: "read write registers",
+	modbustcp.FunctionEncapsulatedInterface:     "encapsulated interface",
+}
+
+func functionName(code byte) string {
+	if name, ok := functionNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("function %v", code)
+}
+
+// describeRequest summarizes the arguments of the common requests and
+// dumps the data of others.
+func describeRequest(p *modbustcp.Pdu) string {
+	d := p.Data
+	switch p.FunctionCode {
+	case modbustcp.FunctionReadCoil, modbustcp.FunctionReadDiscreteInputs, modbustcp.FunctionReadHoldingRegister, modbustcp.FunctionReadInputRegister:
+		if len(d) == 4 {
+			return fmt.Sprintf("address %v count %v", binary.BigEndian.Uint16(d), binary.BigEndian.Uint16(d[2:]))
+		}
+	case modbustcp.FunctionWriteSingleCoil:
+		if len(d) == 4 {
+			return fmt.Sprintf("address %v %v", binary.BigEndian.Uint16(d), onOff(d[2] == 0xff))
+		}
+	case modbustcp.FunctionWriteSingleRegister:
+		if len(d) == 4 {
+			return fmt.Sprintf("address %v value %v", binary.BigEndian.Uint16(d), binary.BigEndian.Uint16(d[2:]))
+		}
+	case modbustcp.FunctionWriteMultipleCoils:
+		if len(d) >= 5 && len(d) == 5+int(d[4]) {
+			n := int(binary.BigEndian.Uint16(d[2:]))
+			return fmt.Sprintf("address %v values %v", binary.BigEndian.Uint16(d), formatBits(d[5:], n))
+		}
+	case modbustcp.FunctionWriteMultipleRegister:
+		if len(d) >= 5 && len(d) == 5+int(d[4]) {
+			return fmt.Sprintf("address %v values %v", binary.BigEndian.Uint16(d), formatRegisters(d[5:]))
+		}
+	}
+	return fmt.Sprintf("% x", d)
+}
+
+// describeResponse summarizes the values read by the common requests,
+// the acknowledgement of writes and exceptions.
+func describeResponse(request, p *modbustcp.Pdu) string {
+	if p == nil {
+		return "no response"
+	}
+	d := p.Data
+	if p.FunctionCode == request.FunctionCode|modbustcp.ExcExceptionOffset && len(d) == 1 {
+		return modbustcp.FailureCodeToError(int(d[0])).Error()
+	}
+	if p.FunctionCode != request.FunctionCode {
+		return fmt.Sprintf("function %v: % x", p.FunctionCode, d)
+	}
+	switch p.FunctionCode {
+	case modbustcp.FunctionReadCoil, modbustcp.FunctionReadDiscreteInputs:
+		if len(d) >= 1 && len(d) == 1+int(d[0]) && len(request.Data) == 4 {
+			return formatBits(d[1:], int(binary.BigEndian.Uint16(request.Data[2:])))
+		}
+	case modbustcp.FunctionReadHoldingRegister, modbustcp.FunctionReadInputRegister, modbustcp.FunctionReadWriteMultipleRegister:
+		if len(d) >= 1 && len(d) == 1+int(d[0]) {
+			return formatRegisters(d[1:])
+		}
+	case modbustcp.FunctionWriteSingleCoil, modbustcp.FunctionWriteSingleRegister, modbustcp.FunctionWriteMultipleCoils, modbustcp.FunctionWriteMultipleRegister:
+		return "ok"
+	}
+	return fmt.Sprintf("% x", d)
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// formatBits formats the first n packed bits of data like [1 0 1].
+func formatBits(data []byte, n int) string {
+	bits := make([]string, 0, n)
+	for i := 0; i < n && i/8 < len(data); i++ {
+		bits = append(bits, fmt.Sprint(data[i/8]>>(i%8)&1))
+	}
+	return "[" + strings.Join(bits, " ") + "]"
+}
+
+// formatRegisters formats big endian registers like [1 2 3].
+func formatRegisters(data []byte) string {
+	regs := make([]string, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		regs = append(regs, fmt.Sprint(binary.BigEndian.Uint16(data[i:])))
+	}
+	return "[" + strings.Join(regs, " ") + "]"
+}
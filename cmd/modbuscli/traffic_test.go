@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestProxySniff(t *testing.T) {
+	s := modbustcp.NewServer()
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 0, []uint16{7})
+	addr := serve(t, s)
+	_, port, _ := net.SplitHostPort(addr)
+	capture := filepath.Join(t.TempDir(), "capture.pcap")
+
+	defer func(f func() (context.Context, context.CancelFunc)) { interrupted = f }(interrupted)
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted = func() (context.Context, context.CancelFunc) { return ctx, cancel }
+	listen := freeAddress(t)
+	type result struct {
+		code int
+		out  string
+	}
+	done := make(chan result)
+	go func() {
+		code, out, _ := execute("proxy", "-listen", listen, "-w", capture, "-o", "json", addr)
+		done <- result{code, out}
+	}()
+	var code int
+	for i := 0; i < 100; i++ {
+		if code, _, _ = execute("read", "-a", listen, "holding", "0"); code == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if code != 0 {
+		t.Fatalf("read by the proxy expected to succeed, actual %v", code)
+	}
+	if code, _, _ = execute("write", "-a", listen, "holding", "1", "5"); code != 0 {
+		t.Fatalf("write by the proxy expected to succeed, actual %v", code)
+	}
+	cancel()
+	r := <-done
+	if r.code != 0 || !strings.Contains(r.out, `"function":"read holding","request":"address 0 count 1","response":"[7]"`) ||
+		!strings.Contains(r.out, `"function":"write register","request":"address 1 value 5","response":"ok"`) {
+		t.Fatalf("transactions expected, actual %v %q", r.code, r.out)
+	}
+
+	code, out, errOut := execute("sniff", "-port", port, capture)
+	if code != 0 || strings.Count(out, "\n") != 2 || !strings.Contains(out, "\tread holding\taddress 0 count 1\t[7]\t") {
+		t.Fatalf("transactions of the capture expected, actual %v %q %q", code, out, errOut)
+	}
+	if code, out, _ = execute("sniff", capture); code != 0 || out != "" {
+		t.Fatalf("no transactions of port 502 expected, actual %v %q", code, out)
+	}
+	f, err := os.Open(capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdin = f
+	defer func() { stdin = os.Stdin }()
+	filtered := filepath.Join(t.TempDir(), "filtered.pcap")
+	if code, out, _ = execute("sniff", "-port", port, "-o", "csv", "-w", filtered, "-"); code != 0 || !strings.HasPrefix(out, "time,client,tid,unit,function,request,response,duration\n") {
+		t.Fatalf("csv expected, actual %v %q", code, out)
+	}
+	if code, out, _ = execute("sniff", "-port", port, filtered); code != 0 || strings.Count(out, "\n") != 2 {
+		t.Fatalf("transactions of the filtered capture expected, actual %v %q", code, out)
+	}
+	if code, _, _ = execute("sniff", "-port", strconv.Itoa(70000)); code != 2 {
+		t.Fatalf("exit code of invalid port expected 2, actual %v", code)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	request := &modbustcp.Pdu{FunctionCode: modbustcp.FunctionReadCoil, Data: []byte{0, 10, 0, 3}}
+	for _, c := range []struct {
+		request, response *modbustcp.Pdu
+		expected          string
+	}{
+		{request, &modbustcp.Pdu{FunctionCode: modbustcp.FunctionReadCoil, Data: []byte{1, 5}}, "[1 0 1]"},
+		{request, &modbustcp.Pdu{FunctionCode: modbustcp.FunctionReadCoil | modbustcp.ExcExceptionOffset, Data: []byte{2}}, modbustcp.ErrorIllegalDataAddress.Error()},
+		{request, nil, "no response"},
+		{&modbustcp.Pdu{FunctionCode: 65, Data: []byte{1, 2}}, &modbustcp.Pdu{FunctionCode: 65, Data: []byte{3}}, "03"},
+	} {
+		if s := describeResponse(c.request, c.response); s != c.expected {
+			t.Fatalf("response expected %q, actual %q", c.expected, s)
+		}
+	}
+	if s := describeRequest(&modbustcp.Pdu{FunctionCode: modbustcp.FunctionWriteMultipleCoils, Data: []byte{0, 1, 0, 2, 1, 2}}); s != "address 1 values [0 1]" {
+		t.Fatalf("request expected, actual %q", s)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// valueFlags holds the flags converting registers to engineering values.
+type valueFlags struct {
+	fs     *flag.FlagSet
+	typ    string
+	order  string
+	scale  float64
+	offset float64
+}
+
+func typeFlags(fs *flag.FlagSet) *valueFlags {
+	v := &valueFlags{fs: fs}
+	fs.StringVar(&v.typ, "type", "", "data `type` of register values: uint16, int16, uint32, int32, uint64, int64, float32, float64 or string of count registers, raw registers if empty")
+	fs.StringVar(&v.order, "order", "abcd", "word `order` of multi-register values, badc swaps the bytes of strings")
+	fs.Float64Var(&v.scale, "scale", 1, "`factor` of the engineering values, e.g. 0.1")
+	fs.Float64Var(&v.offset, "offset", 0, "`offset` added to the scaled values")
+	return v
+}
+
+// defaults sets the flags not given on the command line, a zero gain
+// keeps the scale. The order applies to typed values only.
+func (v *valueFlags) defaults(typ, order string, gain, offset float64) {
+	set := make(map[string]bool)
+	v.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if typ != "" && !set["type"] {
+		v.typ = typ
+	}
+	if order != "" && !set["order"] && v.typ != "" {
+		v.order = order
+	}
+	if gain != 0 && !set["scale"] {
+		v.scale = gain
+	}
+	if !set["offset"] {
+		v.offset = offset
+	}
+}
+
+// valueCodec converts register values, text selects strings.
+type valueCodec struct {
+	modbustcp.Codec
+	text    bool
+	options modbustcp.StringOptions
+}
+
+// codec returns the codec of the flags, nil without a type. Types
+// apply to registers only.
+func (v *valueFlags) codec(table modbustcp.Table) (*valueCodec, error) {
+	order, err := modbustcp.ParseWordOrder(v.order)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	if v.typ == "" {
+		if order != modbustcp.OrderABCD || v.scale != 1 || v.offset != 0 {
+			return nil, usagef("-order, -scale and -offset require -type")
+		}
+		return nil, nil
+	}
+	if table.IsBit() {
+		return nil, usagef("-type applies to registers, not to %v", table)
+	}
+	if v.scale == 0 {
+		return nil, usagef("invalid scale 0")
+	}
+	c := &valueCodec{}
+	if strings.EqualFold(v.typ, "string") {
+		if v.scale != 1 || v.offset != 0 {
+			return nil, usagef("strings are not scaled")
+		}
+		c.text = true
+		c.options.ByteSwap = order == modbustcp.OrderBADC || order == modbustcp.OrderDCBA
+		return c, nil
+	}
+	if c.Type, err = modbustcp.ParseDataType(v.typ); err != nil {
+		return nil, usageError{err}
+	}
+	c.Order = order
+	c.Scale = modbustcp.Scale{Gain: v.scale, Offset: v.offset}
+	return c, nil
+}
+
+// registers returns the number of registers of count values, strings
+// are read as one value of count registers.
+func (c *valueCodec) registers(count int) int {
+	if c.text {
+		return count
+	}
+	return count * c.Registers()
+}
+
+// decode decodes the values of regs as numbers or a string.
+func (c *valueCodec) decode(regs []uint16) ([]interface{}, error) {
+	if c.text {
+		return []interface{}{modbustcp.DecodeString(regs, c.options)}, nil
+	}
+	n := c.Registers()
+	values := make([]interface{}, 0, len(regs)/n)
+	for i := 0; i+n <= len(regs); i += n {
+		v, err := c.Decode(regs[i : i+n])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, c.number(v))
+	}
+	return values, nil
+}
+
+// number formats v as a number of the json format in decimal notation,
+// without the artifacts of binary floating point numbers like
+// 23.400000000000002. NaN and infinities, which json has no numbers for,
+// are formatted as strings.
+func (c *valueCodec) number(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	switch {
+	case c.Type == modbustcp.TypeFloat32:
+		v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 7, 64), 64)
+	case c.Scale.Gain != 1 || c.Scale.Offset != 0:
+		v, _ = strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	}
+	return json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+}
+
+// encode encodes the values, which are joined to one string for
+// strings.
+func (c *valueCodec) encode(values []string) ([]uint16, error) {
+	if c.text {
+		s := strings.Join(values, " ")
+		return modbustcp.EncodeString(s, (len(s)+1)/2, c.options)
+	}
+	var regs []uint16
+	for _, s := range values {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, usagef("invalid %v value '%v'", c.Type, s)
+		}
+		encoded, err := c.Encode(v)
+		if err != nil {
+			return nil, err
+		}
+		regs = append(regs, encoded...)
+	}
+	return regs, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestTypes(t *testing.T) {
+	s := modbustcp.NewServer()
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 0, []uint16{0, 0x41ac, 234, 0xfff6})
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 40, []uint16{0x7fc0, 0, 0xff80, 0})
+	addr := serve(t, s)
+
+	for _, c := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-type", "float32", "-order", "cdab", "holding", "0"}, "0\t21.5\n"},
+		{[]string{"-type", "uint16", "-scale", "0.1", "holding", "2", "2"}, "2\t23.4\n3\t6552.6\n"},
+		{[]string{"-type", "int16", "-scale", "0.1", "-offset", "1", "holding", "3"}, "3\t0\n"},
+		{[]string{"-type", "int32", "-o", "json", "holding", "2"}, "{\"address\":2,\"value\":15400950}\n"},
+		{[]string{"-type", "float32", "-o", "json", "holding", "40", "2"}, "{\"address\":40,\"value\":\"NaN\"}\n{\"address\":42,\"value\":\"-Inf\"}\n"},
+	} {
+		code, out, errOut := execute(append([]string{"read", "-a", addr}, c.args...)...)
+		if code != 0 || out != c.expected {
+			t.Fatalf("%v expected %q, actual %v %q %q", c.args, c.expected, code, out, errOut)
+		}
+	}
+
+	if code, _, errOut := execute("write", "-a", addr, "-type", "float32", "holding", "10", "-2.25", "1"); code != 0 {
+		t.Fatalf("write expected to succeed, actual %v %q", code, errOut)
+	}
+	if regs, _ := s.Store.GetRegisters(modbustcp.TableHoldingRegisters, 10, 4); regs[0] != 0xc010 || regs[1] != 0 || regs[2] != 0x3f80 {
+		t.Fatalf("registers expected [c010 0 3f80 0], actual %x", regs)
+	}
+	if code, _, errOut := execute("write", "-a", addr, "-type", "int16", "-scale", "0.1", "holding", "20", "-1.5"); code != 0 {
+		t.Fatalf("write expected to succeed, actual %v %q", code, errOut)
+	}
+	if regs, _ := s.Store.GetRegisters(modbustcp.TableHoldingRegisters, 20, 1); regs[0] != 0xfff1 {
+		t.Fatalf("register expected fff1, actual %x", regs)
+	}
+	if code, _, errOut := execute("write", "-a", addr, "-type", "string", "-order", "badc", "holding", "30", "pump", "1"); code != 0 {
+		t.Fatalf("write expected to succeed, actual %v %q", code, errOut)
+	}
+	if code, out, _ := execute("read", "-a", addr, "-type", "string", "-order", "badc", "holding", "30", "4"); code != 0 || out != "30\tpump 1\n" {
+		t.Fatalf("string expected, actual %v %q", code, out)
+	}
+
+	for _, args := range [][]string{
+		{"read", "-a", addr, "-type", "float32", "coils", "0"},
+		{"read", "-a", addr, "-type", "float16", "holding", "0"},
+		{"read", "-a", addr, "-scale", "2", "holding", "0"},
+		{"write", "-a", addr, "-type", "uint16", "holding", "0", "x"},
+	} {
+		if code, _, _ := execute(args...); code != 2 {
+			t.Fatalf("%v exit code expected 2, actual %v", args, code)
+		}
+	}
+	if code, _, _ := execute("write", "-a", addr, "-type", "uint16", "holding", "0", "70000"); code != 1 {
+		t.Fatalf("exit code of value out of range expected 1, actual %v", code)
+	}
+}
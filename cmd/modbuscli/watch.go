@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+var watchCommand = &command{
+	name:    "watch",
+	args:    "table address [count] | reference [count]",
+	summary: "Read values repeatedly and print them with the changes highlighted",
+	setup:   setupWatch,
+}
+
+func setupWatch(fs *flag.FlagSet) execFunc {
+	conn := connectionFlags(fs)
+	interval := fs.Duration("i", time.Second, "polling `interval`")
+	polls := fs.Int("n", 0, "`number` of polls, unlimited if zero")
+	changes := fs.Bool("changes", false, "print only polls with changed values")
+	color := fs.String("color", "auto", "highlight changes by color: auto, always or never, changes are marked by * without color")
+	output := outputFlag(fs)
+	return func(args []string, stdout, stderr io.Writer) error {
+		args, err := conn.use(args)
+		if err != nil {
+			return err
+		}
+		a, rest, err := conn.location(args, nil)
+		if err != nil {
+			return err
+		}
+		count := uint64(1)
+		switch {
+		case len(rest) > 1:
+			return usagef("unexpected arguments %v", rest[1:])
+		case len(rest) == 1:
+			if count, err = strconv.ParseUint(rest[0], 10, 16); err != nil || count == 0 {
+				return usagef("invalid count '%v'", rest[0])
+			}
+		}
+		if *interval <= 0 {
+			return usagef("invalid interval '%v'", *interval)
+		}
+		var colored bool
+		switch *color {
+		case "auto":
+			colored = isTerminal(stdout)
+		case "always":
+			colored = true
+		case "never":
+		default:
+			return usagef("invalid color '%v'", *color)
+		}
+		client, done, err := conn.open()
+		if err != nil {
+			return err
+		}
+		defer done()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		w := &watcher{client: client, address: a, count: uint16(count), changes: *changes, color: colored, format: *output}
+		if !output.human() || *output == "table" {
+			w.records = output.newRecordWriter(stdout, "time", "address", "value", "changed")
+		}
+		t := time.NewTicker(*interval)
+		defer t.Stop()
+		for n := 0; *polls == 0 || n < *polls; n++ {
+			if n > 0 {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-t.C:
+				}
+			}
+			if err := w.poll(stdout); err != nil {
+				fmt.Fprintf(stderr, "%v %v\n", time.Now().Format(timeFormat), err)
+			}
+		}
+		return nil
+	}
+}
+
+// timeFormat is the format of the timestamps of watched values.
+const timeFormat = "15:04:05.000"
+
+// watcher polls values and prints their changes.
+type watcher struct {
+	client  *modbustcp.ModbusTcpClient
+	address modbustcp.Address
+	count   uint16
+	changes bool
+	color   bool
+	format  outputFormat
+	// records receives a record per value unless the polls are printed
+	// as lines of text
+	records *recordWriter
+	// last are the values of the previous poll, nil before the first
+	last []uint16
+}
+
+// poll reads the values and prints them if the watcher does not print
+// only changes or some of them changed. The connection is reestablished
+// after transport failures.
+func (w *watcher) poll(out io.Writer) error {
+	if w.client.Conn == nil {
+		if err := w.client.Connect(); err != nil {
+			w.client.Conn = nil
+			return err
+		}
+	}
+	var values []uint16
+	if w.address.Table.IsBit() {
+		bits, err := readBits(w.client, w.address, w.count)
+		if err != nil {
+			return w.failed(err)
+		}
+		values = make([]uint16, len(bits))
+		for i, b := range bits {
+			if b {
+				values[i] = 1
+			}
+		}
+	} else {
+		var err error
+		if values, err = readRegisters(w.client, w.address, w.count); err != nil {
+			return w.failed(err)
+		}
+	}
+	now := time.Now()
+	changed := w.last == nil
+	for i, v := range values {
+		changed = changed || w.last[i] != v
+	}
+	last := w.last
+	w.last = values
+	if !changed && w.changes {
+		return nil
+	}
+	if w.records != nil {
+		for i, v := range values {
+			var value interface{} = v
+			if w.address.Table.IsBit() {
+				value = v != 0
+			}
+			if err := w.records.write(now, int(w.address.Offset)+i, value, last != nil && last[i] != v); err != nil {
+				return err
+			}
+		}
+		return w.records.flush()
+	}
+	var b strings.Builder
+	b.WriteString(now.Format(timeFormat))
+	for i, v := range values {
+		s := fmt.Sprintf("%v: %v", int(w.address.Offset)+i, v)
+		if w.format == "hex" {
+			s = fmt.Sprintf("%v: 0x%04x", int(w.address.Offset)+i, v)
+		}
+		if last != nil && last[i] != v {
+			if w.color {
+				// reverse video
+				s = "\x1b[7m" + s + "\x1b[0m"
+			} else {
+				s += "*"
+			}
+		}
+		b.WriteString("  ")
+		b.WriteString(s)
+	}
+	fmt.Fprintln(out, b.String())
+	return nil
+}
+
+// failed drops the connection after transport failures and returns err.
+func (w *watcher) failed(err error) error {
+	if !modbustcp.IsException(err) {
+		w.client.Disconnect()
+	}
+	return err
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
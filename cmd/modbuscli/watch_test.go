@@ -0,0 +1,39 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestWatch(t *testing.T) {
+	s := modbustcp.NewServer()
+	s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 10, []uint16{0, 5})
+	// each read increments register 10
+	reads := uint16(0)
+	s.Use(func(next modbustcp.RequestHandler) modbustcp.RequestHandler {
+		return func(r *modbustcp.Request) *modbustcp.Pdu {
+			reads++
+			s.Store.SetRegisters(modbustcp.TableHoldingRegisters, 10, []uint16{reads})
+			return next(r)
+		}
+	})
+	addr := serve(t, s)
+	code, out, errOut := execute("watch", "-a", addr, "-i", "10ms", "-n", "2", "-color", "never", "holding", "10", "2")
+	expected := regexp.MustCompile(`^\d\d:\d\d:\d\d\.\d{3}  10: 1  11: 5\n\d\d:\d\d:\d\d\.\d{3}  10: 2\*  11: 5\n$`)
+	if code != 0 || !expected.MatchString(out) {
+		t.Fatalf("2 polls expected, actual %v %q %q", code, out, errOut)
+	}
+	code, out, _ = execute("watch", "-a", addr, "-i", "10ms", "-n", "2", "-changes", "-color", "always", "holding", "11")
+	if code != 0 || regexp.MustCompile(`\n.`).MatchString(out) {
+		t.Fatalf("only the first poll expected, actual %v %q", code, out)
+	}
+	code, out, _ = execute("watch", "-a", addr, "-i", "10ms", "-n", "2", "-color", "always", "holding", "10")
+	if code != 0 || !regexp.MustCompile("\x1b\\[7m10: \\d+\x1b\\[0m\n$").MatchString(out) {
+		t.Fatalf("highlighted change expected, actual %v %q", code, out)
+	}
+	if code, _, _ = execute("watch", "-a", addr, "-color", "blue", "holding", "10"); code != 2 {
+		t.Fatalf("exit code expected 2, actual %v", code)
+	}
+}
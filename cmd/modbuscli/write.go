@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+var writeCommand = &command{
+	name:    "write",
+	args:    "table address value... | reference value...",
+	summary: "Write coils or holding registers",
+	setup:   setupWrite,
+}
+
+func setupWrite(fs *flag.FlagSet) execFunc {
+	conn := connectionFlags(fs)
+	multiple := fs.Bool("multiple", false, "write a single value by function 15 or 16 instead of 5 or 6")
+	types := typeFlags(fs)
+	return func(args []string, _, _ io.Writer) error {
+		args, err := conn.use(args)
+		if err != nil {
+			return err
+		}
+		a, values, err := conn.location(args, types)
+		if err != nil {
+			return err
+		}
+		if !a.Table.Writable() {
+			return usagef("table %v is not writable", a.Table)
+		}
+		if len(values) == 0 {
+			return usagef("missing values")
+		}
+		codec, err := types.codec(a.Table)
+		if err != nil {
+			return err
+		}
+		var (
+			bits []bool
+			regs []uint16
+		)
+		if codec != nil {
+			regs, err = codec.encode(values)
+		} else {
+			bits, regs, err = parseValues(a.Table, values)
+		}
+		if err != nil {
+			return err
+		}
+		client, done, err := conn.open()
+		if err != nil {
+			return err
+		}
+		defer done()
+		switch {
+		case bits != nil && len(bits) == 1 && !*multiple:
+			return client.WriteSingleCoil(a.Offset, bits[0])
+		case bits != nil:
+			return client.WriteMultipleCoils(a.Offset, bits)
+		case len(regs) == 1 && !*multiple:
+			return client.WriteSingleRegister(a.Offset, regs[0])
+		}
+		return client.WriteMultipleRegisters(a.Offset, regs)
+	}
+}
+
+// parseValues parses the raw values of coils or registers.
+func parseValues(table modbustcp.Table, values []string) ([]bool, []uint16, error) {
+	var (
+		bits []bool
+		regs []uint16
+	)
+	for _, s := range values {
+		if table.IsBit() {
+			b, err := parseBit(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			bits = append(bits, b)
+		} else {
+			r, err := parseRegister(s)
+			if err != nil {
+				return nil, nil, err
+			}
+			regs = append(regs, r)
+		}
+	}
+	return bits, regs, nil
+}
+
+// parseBit parses a coil value, 1, 0, on, off, true or false.
+func parseBit(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "1", "on", "true":
+		return true, nil
+	case "0", "off", "false":
+		return false, nil
+	}
+	return false, usagef("invalid coil value '%v'", s)
+}
+
+// parseRegister parses a register value, unsigned or negative and decimal
+// or 0x prefixed hexadecimal like parseNumber.
+func parseRegister(s string) (uint16, error) {
+	if n, err := parseNumber(s, 16); err == nil {
+		return uint16(n), nil
+	}
+	if abs, ok := strings.CutPrefix(s, "-"); ok {
+		if n, err := parseNumber(abs, 16); err == nil && n <= -math.MinInt16 {
+			return uint16(-int64(n)), nil
+		}
+	}
+	return 0, usagef("invalid register value '%v'", s)
+}
@@ -0,0 +1,155 @@
+package modbustcp
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCoalescedEntries bounds the cached responses of a CoalescingBackend.
+const maxCoalescedEntries = 1024
+
+// CoalescingBackend protects a slow backend from concurrent masters. It
+// executes identical concurrent reads of coils, inputs and registers once
+// and passes the response to all of them, and optionally answers reads
+// from a cache. Writes are always forwarded and invalidate the cached
+// responses they overlap. Coalesced requests share the response pdu,
+// which must not be modified.
+type CoalescingBackend struct {
+	Backend Backend
+	// TTL is the lifetime of cached responses, zero disables the cache.
+	TTL time.Duration
+	// Ranges override the TTL of reads overlapping them, a read is cached
+	// for the shortest TTL of the ranges it overlaps.
+	Ranges []RangeTTL
+
+	mu       sync.Mutex
+	inflight map[string]*coalescedCall
+	cache    map[string]coalescedResponse
+	// generation counts the invalidations by writes, responses of reads
+	// overlapped by a write are not cached
+	generation uint64
+}
+
+// RangeTTL is the lifetime of cached reads of a range, a zero unit id
+// applies to all units.
+type RangeTTL struct {
+	Range AddressRange
+	TTL   time.Duration
+}
+
+type coalescedCall struct {
+	r        AddressRange
+	done     chan struct{}
+	response *Pdu
+	err      error
+}
+
+type coalescedResponse struct {
+	r        AddressRange
+	response *Pdu
+	expires  time.Time
+}
+
+// NewCoalescingBackend wraps backend, caching responses for ttl.
+func NewCoalescingBackend(backend Backend, ttl time.Duration) *CoalescingBackend {
+	return &CoalescingBackend{
+		Backend:  backend,
+		TTL:      ttl,
+		inflight: make(map[string]*coalescedCall),
+		cache:    make(map[string]coalescedResponse),
+	}
+}
+
+// Transact implements Backend.
+func (b *CoalescingBackend) Transact(unit byte, request *Pdu) (*Pdu, error) {
+	r, ok := readRange(unit, request)
+	switch request.FunctionCode {
+	case FunctionReadCoil, FunctionReadDiscreteInputs, FunctionReadHoldingRegister, FunctionReadInputRegister:
+	default:
+		ok = false
+	}
+	if !ok || unit == 0 {
+		if w, write := writeRange(unit, request); write {
+			b.invalidate(w)
+			defer b.invalidate(w)
+		}
+		return b.Backend.Transact(unit, request)
+	}
+	key := string(append([]byte{unit, request.FunctionCode}, request.Data...))
+	now := time.Now()
+	b.mu.Lock()
+	if cached, ok := b.cache[key]; ok && now.Before(cached.expires) {
+		b.mu.Unlock()
+		return cached.response, nil
+	}
+	if call, ok := b.inflight[key]; ok {
+		b.mu.Unlock()
+		<-call.done
+		return call.response, call.err
+	}
+	call := &coalescedCall{r: r, done: make(chan struct{})}
+	b.inflight[key] = call
+	generation := b.generation
+	b.mu.Unlock()
+
+	call.response, call.err = b.Backend.Transact(unit, request)
+	b.mu.Lock()
+	if b.inflight[key] == call {
+		delete(b.inflight, key)
+	}
+	// exceptions and responses possibly preceding a write are not cached
+	if ttl := b.ttl(r); ttl > 0 && call.err == nil && call.response.FunctionCode&ExcExceptionOffset == 0 && b.generation == generation {
+		b.store(key, coalescedResponse{r: r, response: call.response, expires: time.Now().Add(ttl)})
+	}
+	b.mu.Unlock()
+	close(call.done)
+	return call.response, call.err
+}
+
+// ttl returns the lifetime of the cached response of a read of r.
+func (b *CoalescingBackend) ttl(r AddressRange) time.Duration {
+	ttl, matched := b.TTL, false
+	for _, rt := range b.Ranges {
+		if rt.Range.overlaps(r) && (!matched || rt.TTL < ttl) {
+			ttl, matched = rt.TTL, true
+		}
+	}
+	return ttl
+}
+
+// store caches c unless the cache is full of unexpired responses, the
+// caller holds mu.
+func (b *CoalescingBackend) store(key string, c coalescedResponse) {
+	if len(b.cache) >= maxCoalescedEntries {
+		now := time.Now()
+		for k, cached := range b.cache {
+			if !now.Before(cached.expires) {
+				delete(b.cache, k)
+			}
+		}
+		if len(b.cache) >= maxCoalescedEntries {
+			return
+		}
+	}
+	b.cache[key] = c
+}
+
+// invalidate drops the cached responses overlapping the written range w,
+// broadcasts to unit 0 invalidate all units. Later reads no longer join
+// the overlapping reads in flight, which may return the value before the
+// write.
+func (b *CoalescingBackend) invalidate(w AddressRange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.generation++
+	for k, cached := range b.cache {
+		if w.overlaps(cached.r) {
+			delete(b.cache, k)
+		}
+	}
+	for k, call := range b.inflight {
+		if w.overlaps(call.r) {
+			delete(b.inflight, k)
+		}
+	}
+}
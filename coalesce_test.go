@@ -0,0 +1,112 @@
+package modbustcp
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingBackend answers reads after delay with the number of executed
+// transactions.
+type countingBackend struct {
+	delay time.Duration
+	calls atomic.Int32
+}
+
+func (b *countingBackend) Transact(unit byte, request *Pdu) (*Pdu, error) {
+	n := b.calls.Add(1)
+	time.Sleep(b.delay)
+	if request.FunctionCode == FunctionReadInputRegister {
+		return Exception(request, ExcIllegalDataAdr), nil
+	}
+	return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0, byte(n)}}, nil
+}
+
+func TestCoalescingBackend(t *testing.T) {
+	backend := &countingBackend{delay: 50 * time.Millisecond}
+	b := NewCoalescingBackend(backend, time.Minute)
+	read := &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(10, 2)}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := b.Transact(1, read); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := backend.calls.Load(); n != 1 {
+		t.Fatalf("concurrent reads expected 1 transaction, actual %v", n)
+	}
+	response, _ := b.Transact(1, read)
+	if n := backend.calls.Load(); n != 1 || response.Data[2] != 1 {
+		t.Fatalf("cached response expected, actual %v transactions", n)
+	}
+	if response, _ = b.Transact(2, read); response.Data[2] != 2 {
+		t.Fatalf("read of another unit expected to be executed, actual %+v", response)
+	}
+
+	b.Transact(1, &Pdu{FunctionCode: FunctionWriteSingleRegister, Data: dataBlock(11, 5)})
+	if response, _ = b.Transact(1, read); response.Data[2] != 4 {
+		t.Fatalf("write expected to invalidate the cache, actual %+v", response)
+	}
+	b.Transact(1, &Pdu{FunctionCode: FunctionWriteSingleRegister, Data: dataBlock(12, 5)})
+	if n := backend.calls.Load(); n != 5 {
+		t.Fatalf("transactions expected 5, actual %v", n)
+	}
+	if response, _ = b.Transact(1, read); response.Data[2] != 4 {
+		t.Fatalf("write of another range expected to keep the cache, actual %+v", response)
+	}
+	b.Transact(0, &Pdu{FunctionCode: FunctionWriteSingleRegister, Data: dataBlock(10, 5)})
+	if response, _ = b.Transact(2, read); response.Data[2] != 7 {
+		t.Fatalf("broadcast expected to invalidate the cache, actual %+v", response)
+	}
+
+	exception := &Pdu{FunctionCode: FunctionReadInputRegister, Data: dataBlock(0, 1)}
+	b.Transact(1, exception)
+	b.Transact(1, exception)
+	if n := backend.calls.Load(); n != 9 {
+		t.Fatalf("exceptions expected not to be cached, actual %v transactions", n)
+	}
+}
+
+// slowReadBackend delays reads but not writes.
+type slowReadBackend struct {
+	countingBackend
+}
+
+func (b *slowReadBackend) Transact(unit byte, request *Pdu) (*Pdu, error) {
+	if request.FunctionCode == FunctionWriteSingleRegister {
+		return request, nil
+	}
+	return b.countingBackend.Transact(unit, request)
+}
+
+func TestCoalescingBackendWriteDuringRead(t *testing.T) {
+	backend := &slowReadBackend{countingBackend{delay: 100 * time.Millisecond}}
+	b := NewCoalescingBackend(backend, time.Minute)
+	read := &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(10, 2)}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Transact(1, read)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if _, err := b.Transact(1, &Pdu{FunctionCode: FunctionWriteSingleRegister, Data: dataBlock(11, 5)}); err != nil {
+		t.Fatal(err)
+	}
+	// the read following the write does not join the read in flight
+	if response, _ := b.Transact(1, read); response.Data[2] != 2 {
+		t.Fatalf("read after the write expected to be executed, actual %+v", response)
+	}
+	<-done
+	if response, _ := b.Transact(1, read); response.Data[2] != 2 {
+		t.Fatalf("response of the read after the write expected, actual %+v", response)
+	}
+	if n := backend.calls.Load(); n != 2 {
+		t.Fatalf("transactions expected 2, actual %v", n)
+	}
+}
@@ -0,0 +1,129 @@
+package modbustcp
+
+// RegistersToInt16s interprets each register as a two's complement int16.
+func RegistersToInt16s(regs []uint16) []int16 {
+	values := make([]int16, len(regs))
+	for i, r := range regs {
+		values[i] = int16(r)
+	}
+	return values
+}
+
+// RegistersToUint32 combines the first two registers into an uint32,
+// the high word being transmitted first.
+func RegistersToUint32(regs []uint16) uint32 {
+	return uint32(regs[0])<<16 | uint32(regs[1])
+}
+
+// Uint32ToRegisters splits v into two registers, high word first.
+func Uint32ToRegisters(v uint32) []uint16 {
+	return []uint16{uint16(v >> 16), uint16(v)}
+}
+
+// RegistersToInt32 combines the first two registers into an int32.
+func RegistersToInt32(regs []uint16) int32 {
+	return int32(RegistersToUint32(regs))
+}
+
+// Int32ToRegisters splits v into two registers, high word first.
+func Int32ToRegisters(v int32) []uint16 {
+	return Uint32ToRegisters(uint32(v))
+}
+
+// RegistersToUint64 combines the first four registers into an uint64,
+// the most significant word being transmitted first.
+func RegistersToUint64(regs []uint16) uint64 {
+	return uint64(regs[0])<<48 | uint64(regs[1])<<32 | uint64(regs[2])<<16 | uint64(regs[3])
+}
+
+// Uint64ToRegisters splits v into four registers, most significant word first.
+func Uint64ToRegisters(v uint64) []uint16 {
+	return []uint16{uint16(v >> 48), uint16(v >> 32), uint16(v >> 16), uint16(v)}
+}
+
+// RegistersToInt64 combines the first four registers into an int64.
+func RegistersToInt64(regs []uint16) int64 {
+	return int64(RegistersToUint64(regs))
+}
+
+// Int64ToRegisters splits v into four registers, most significant word first.
+func Int64ToRegisters(v int64) []uint16 {
+	return Uint64ToRegisters(uint64(v))
+}
+
+// ReadInt16s reads quantity holding registers as signed 16-bit integers.
+func (c *ModbusTcpClient) ReadInt16s(address, quantity uint16) ([]int16, error) {
+	regs, err := c.ReadHoldingRegisters(address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	return RegistersToInt16s(regs), nil
+}
+
+// ReadInt16 reads a signed 16-bit integer from a holding register.
+func (c *ModbusTcpClient) ReadInt16(address uint16) (int16, error) {
+	values, err := c.ReadInt16s(address, 1)
+	if err != nil {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// WriteInt16 writes a signed 16-bit integer to a holding register.
+func (c *ModbusTcpClient) WriteInt16(address uint16, value int16) error {
+	return c.WriteSingleRegister(address, uint16(value))
+}
+
+// ReadUint32 reads an unsigned 32-bit integer from two holding registers
+// using the word order of the client unless overridden.
+func (c *ModbusTcpClient) ReadUint32(address uint16, order ...WordOrder) (uint32, error) {
+	regs, err := c.ReadHoldingRegisters(address, 2)
+	if err != nil {
+		return 0, err
+	}
+	return c.wordOrder(order).Uint32(regs), nil
+}
+
+// ReadInt32 reads a signed 32-bit integer from two holding registers
+// using the word order of the client unless overridden.
+func (c *ModbusTcpClient) ReadInt32(address uint16, order ...WordOrder) (int32, error) {
+	v, err := c.ReadUint32(address, order...)
+	return int32(v), err
+}
+
+// WriteUint32 writes an unsigned 32-bit integer to two holding registers.
+func (c *ModbusTcpClient) WriteUint32(address uint16, value uint32, order ...WordOrder) error {
+	return c.WriteMultipleRegisters(address, c.wordOrder(order).PutUint32(value))
+}
+
+// WriteInt32 writes a signed 32-bit integer to two holding registers.
+func (c *ModbusTcpClient) WriteInt32(address uint16, value int32, order ...WordOrder) error {
+	return c.WriteUint32(address, uint32(value), order...)
+}
+
+// ReadUint64 reads an unsigned 64-bit integer from four holding registers
+// using the word order of the client unless overridden.
+func (c *ModbusTcpClient) ReadUint64(address uint16, order ...WordOrder) (uint64, error) {
+	regs, err := c.ReadHoldingRegisters(address, 4)
+	if err != nil {
+		return 0, err
+	}
+	return c.wordOrder(order).Uint64(regs), nil
+}
+
+// ReadInt64 reads a signed 64-bit integer from four holding registers
+// using the word order of the client unless overridden.
+func (c *ModbusTcpClient) ReadInt64(address uint16, order ...WordOrder) (int64, error) {
+	v, err := c.ReadUint64(address, order...)
+	return int64(v), err
+}
+
+// WriteUint64 writes an unsigned 64-bit integer to four holding registers.
+func (c *ModbusTcpClient) WriteUint64(address uint16, value uint64, order ...WordOrder) error {
+	return c.WriteMultipleRegisters(address, c.wordOrder(order).PutUint64(value))
+}
+
+// WriteInt64 writes a signed 64-bit integer to four holding registers.
+func (c *ModbusTcpClient) WriteInt64(address uint16, value int64, order ...WordOrder) error {
+	return c.WriteUint64(address, uint64(value), order...)
+}
@@ -0,0 +1,28 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestInt32Registers(t *testing.T) {
+	regs := Int32ToRegisters(-2)
+	if regs[0] != 0xFFFF || regs[1] != 0xFFFE {
+		t.Fatalf("registers expected [65535 65534], actual %v", regs)
+	}
+	if v := RegistersToInt32(regs); v != -2 {
+		t.Fatalf("value expected %v, actual %v", -2, v)
+	}
+	if v := RegistersToUint32([]uint16{0x1234, 0x5678}); v != 0x12345678 {
+		t.Fatalf("value expected %v, actual %v", 0x12345678, v)
+	}
+}
+
+func TestInt64Registers(t *testing.T) {
+	regs := Uint64ToRegisters(0x0102030405060708)
+	if regs[0] != 0x0102 || regs[3] != 0x0708 {
+		t.Fatalf("registers expected [258 772 1286 1800], actual %v", regs)
+	}
+	if v := RegistersToInt64(Int64ToRegisters(-123456789012)); v != -123456789012 {
+		t.Fatalf("value expected %v, actual %v", -123456789012, v)
+	}
+}
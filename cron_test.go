@@ -0,0 +1,35 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	base := time.Date(2024, 1, 31, 23, 59, 30, 0, time.UTC) // Wednesday
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"*/15 8-17 * * 1-5", time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC)},
+		{"30 2 29 2 *", time.Date(2024, 2, 29, 2, 30, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2024, 2, 4, 12, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		c, err := ParseCron(test.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := c.Next(base); !next.Equal(test.expected) {
+			t.Errorf("%v: next expected %v, actual %v", test.spec, test.expected, next)
+		}
+	}
+	for _, spec := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *"} {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("%v: expected error", spec)
+		}
+	}
+}
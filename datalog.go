@@ -0,0 +1,167 @@
+package modbustcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LogFormat selects the file format of a DataLogger.
+type LogFormat int
+
+const (
+	// LogCSV writes comma separated values with a header line.
+	LogCSV LogFormat = iota
+	// LogJSONL writes one JSON object per line.
+	LogJSONL
+)
+
+func (f LogFormat) extension() string {
+	if f == LogJSONL {
+		return ".jsonl"
+	}
+	return ".csv"
+}
+
+var csvHeader = []string{"time", "group", "tag", "value", "label", "unit"}
+
+// logRecord is the JSON Lines representation of an update.
+type logRecord struct {
+	Time  time.Time `json:"time"`
+	Group string    `json:"group,omitempty"`
+	Tag   string    `json:"tag"`
+	Value float64   `json:"value"`
+	Label string    `json:"label,omitempty"`
+	Unit  string    `json:"unit,omitempty"`
+}
+
+// DataLogger appends updates to files in Dir named after Prefix and the
+// time of their first record, starting a new file when MaxSize or MaxAge
+// is exceeded. Its Record method can be used as Handler of a Poller.
+type DataLogger struct {
+	Dir    string
+	Prefix string
+	Format LogFormat
+	// MaxSize in bytes after which a new file is started, unlimited if zero.
+	MaxSize int64
+	// MaxAge after which a new file is started, unlimited if zero.
+	MaxAge time.Duration
+	// ErrorHandler is invoked for write errors of Record.
+	ErrorHandler func(err error)
+
+	mu     sync.Mutex
+	file   *os.File
+	w      *bufio.Writer
+	size   int64
+	opened time.Time
+}
+
+// NewDataLogger creates a logger writing files of format to dir.
+func NewDataLogger(dir, prefix string, format LogFormat) *DataLogger {
+	return &DataLogger{Dir: dir, Prefix: prefix, Format: format}
+}
+
+// Record writes u and reports failures to ErrorHandler.
+func (l *DataLogger) Record(u TagUpdate) {
+	if err := l.Write(u); err != nil && l.ErrorHandler != nil {
+		l.ErrorHandler(err)
+	}
+}
+
+// Write appends u to the current file, rotating it if necessary.
+func (l *DataLogger) Write(u TagUpdate) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil && (l.MaxSize > 0 && l.size >= l.MaxSize ||
+		l.MaxAge > 0 && u.Time.Sub(l.opened) >= l.MaxAge) {
+		if err := l.close(); err != nil {
+			return err
+		}
+	}
+	if l.file == nil {
+		if err := l.open(u.Time); err != nil {
+			return err
+		}
+	}
+	line, err := l.encode(u)
+	if err != nil {
+		return err
+	}
+	n, err := l.w.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return l.w.Flush()
+}
+
+func (l *DataLogger) encode(u TagUpdate) ([]byte, error) {
+	if l.Format == LogJSONL {
+		b, err := json.Marshal(logRecord{Time: u.Time, Group: u.Group, Tag: u.Tag, Value: u.Value, Label: u.Label, Unit: u.Unit})
+		return append(b, '\n'), err
+	}
+	return csvLine([]string{
+		u.Time.Format(time.RFC3339Nano), u.Group, u.Tag,
+		strconv.FormatFloat(u.Value, 'g', -1, 64), u.Label, u.Unit,
+	})
+}
+
+func csvLine(fields []string) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(fields); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// open starts a new file for records beginning at t.
+func (l *DataLogger) open(t time.Time) error {
+	base := filepath.Join(l.Dir, l.Prefix+t.UTC().Format("20060102T150405"))
+	name := base + l.Format.extension()
+	for i := 1; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%v-%v%v", base, i, l.Format.extension())
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	l.file, l.w, l.size, l.opened = f, bufio.NewWriter(f), 0, t
+	if l.Format == LogCSV {
+		header, _ := csvLine(csvHeader)
+		n, err := l.w.Write(header)
+		l.size += int64(n)
+		return err
+	}
+	return nil
+}
+
+func (l *DataLogger) close() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.w.Flush()
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	l.file, l.w = nil, nil
+	return err
+}
+
+// Close closes the current file. A following Write starts a new one.
+func (l *DataLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.close()
+}
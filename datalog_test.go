@@ -0,0 +1,56 @@
+package modbustcp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDataLogger(t *testing.T) {
+	dir := t.TempDir()
+	l := NewDataLogger(dir, "plant-", LogCSV)
+	l.MaxAge = time.Minute
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		u := TagUpdate{Group: "g", Tag: "temp", Time: base.Add(time.Duration(i) * 40 * time.Second), Reading: Reading{Value: 20.5, Unit: "°C"}}
+		if err := l.Write(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	first, err := os.ReadFile(filepath.Join(dir, "plant-20240101T120000.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(first)), "\n")
+	if len(lines) != 3 || lines[1] != "2024-01-01T12:00:00Z,g,temp,20.5,,°C" {
+		t.Fatalf("first file expected header and 2 records, actual %q", lines)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "plant-20240101T120120.csv")); err != nil {
+		t.Fatalf("rotated file expected, %v", err)
+	}
+}
+
+func TestDataLoggerJSONL(t *testing.T) {
+	dir := t.TempDir()
+	l := NewDataLogger(dir, "", LogJSONL)
+	l.MaxSize = 1
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if err := l.Write(TagUpdate{Tag: "flow", Time: base, Reading: Reading{Value: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+	data, err := os.ReadFile(filepath.Join(dir, "20240101T120000-1.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"time":"2024-01-01T12:00:00Z","tag":"flow","value":1}` + "\n"; string(data) != expected {
+		t.Fatalf("record expected %q, actual %q", expected, data)
+	}
+}
@@ -0,0 +1,237 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DataStore holds the four data tables served by a Server. The host
+// application publishes its data through the Set methods, which are
+// safe for concurrent use with the requests of masters.
+type DataStore struct {
+	mu                          sync.RWMutex
+	coils, discreteInputs       []bool
+	holdingRegisters, inputRegs []uint16
+	// version counts the writes, notify is invoked for each of them with
+	// mu held.
+	version uint64
+	notify  func()
+	// watchers receive the changes written by masters
+	watchers map[*storeWatcher]bool
+}
+
+// NewDataStore creates a data store holding the given number of coils,
+// discrete inputs, holding and input registers, each at most 65536.
+// Addresses beyond the size of a table are answered with an illegal data
+// address exception.
+func NewDataStore(coils, discreteInputs, holdingRegisters, inputRegisters int) *DataStore {
+	size := func(n int) int {
+		if n < 0 {
+			return 0
+		}
+		if n > 0x10000 {
+			return 0x10000
+		}
+		return n
+	}
+	return &DataStore{
+		coils:            make([]bool, size(coils)),
+		discreteInputs:   make([]bool, size(discreteInputs)),
+		holdingRegisters: make([]uint16, size(holdingRegisters)),
+		inputRegs:        make([]uint16, size(inputRegisters)),
+	}
+}
+
+// Size returns the number of values of table.
+func (s *DataStore) Size(table Table) int {
+	switch table {
+	case TableCoils:
+		return len(s.coils)
+	case TableDiscreteInputs:
+		return len(s.discreteInputs)
+	case TableHoldingRegisters:
+		return len(s.holdingRegisters)
+	case TableInputRegisters:
+		return len(s.inputRegs)
+	}
+	return 0
+}
+
+func (s *DataStore) bits(table Table) ([]bool, error) {
+	switch table {
+	case TableCoils:
+		return s.coils, nil
+	case TableDiscreteInputs:
+		return s.discreteInputs, nil
+	}
+	return nil, fmt.Errorf("modbus: table '%v' does not hold bits", table)
+}
+
+func (s *DataStore) registers(table Table) ([]uint16, error) {
+	switch table {
+	case TableHoldingRegisters:
+		return s.holdingRegisters, nil
+	case TableInputRegisters:
+		return s.inputRegs, nil
+	}
+	return nil, fmt.Errorf("modbus: table '%v' does not hold registers", table)
+}
+
+// checkStore validates quantity values at address of a table of size
+// values.
+func checkStore(table Table, address uint16, quantity, size int) error {
+	if int(address)+quantity > size {
+		return fmt.Errorf("%w: %v %v+%v exceeds the table size '%v'", ErrorIllegalDataAddress, table, address, quantity, size)
+	}
+	return nil
+}
+
+// GetBits returns quantity coils or discrete inputs starting at the
+// protocol address.
+func (s *DataStore) GetBits(table Table, address uint16, quantity int) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	bits, err := s.bits(table)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStore(table, address, quantity, len(bits)); err != nil {
+		return nil, err
+	}
+	return append([]bool(nil), bits[address:int(address)+quantity]...), nil
+}
+
+// SetBits sets coils or discrete inputs starting at the protocol address.
+func (s *DataStore) SetBits(table Table, address uint16, values []bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.setBits(table, address, values)
+	return err
+}
+
+// setBits sets bits and returns their previous values, the caller holds
+// mu.
+func (s *DataStore) setBits(table Table, address uint16, values []bool) ([]bool, error) {
+	bits, err := s.bits(table)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStore(table, address, len(values), len(bits)); err != nil {
+		return nil, err
+	}
+	old := append([]bool(nil), bits[address:int(address)+len(values)]...)
+	copy(bits[address:], values)
+	s.written()
+	return old, nil
+}
+
+// GetRegisters returns quantity holding or input registers starting at
+// the protocol address.
+func (s *DataStore) GetRegisters(table Table, address uint16, quantity int) ([]uint16, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	regs, err := s.registers(table)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStore(table, address, quantity, len(regs)); err != nil {
+		return nil, err
+	}
+	return append([]uint16(nil), regs[address:int(address)+quantity]...), nil
+}
+
+// SetRegisters sets holding or input registers starting at the protocol
+// address.
+func (s *DataStore) SetRegisters(table Table, address uint16, values []uint16) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.setRegisters(table, address, values)
+	return err
+}
+
+// setRegisters sets registers and returns their previous values, the
+// caller holds mu.
+func (s *DataStore) setRegisters(table Table, address uint16, values []uint16) ([]uint16, error) {
+	regs, err := s.registers(table)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkStore(table, address, len(values), len(regs)); err != nil {
+		return nil, err
+	}
+	old := append([]uint16(nil), regs[address:int(address)+len(values)]...)
+	copy(regs[address:], values)
+	s.written()
+	return old, nil
+}
+
+// maskRegister applies a mask write of unit to a holding register
+// atomically.
+func (s *DataStore) maskRegister(unit byte, address, andMask, orMask uint16) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := checkStore(TableHoldingRegisters, address, 1, len(s.holdingRegisters)); err != nil {
+		return err
+	}
+	old := s.holdingRegisters[address]
+	s.holdingRegisters[address] = old&andMask | orMask&^andMask
+	s.written()
+	s.publish(StoreChange{Unit: unit, Table: TableHoldingRegisters, Address: address, Old: old, New: s.holdingRegisters[address]})
+	return nil
+}
+
+// written records a write, the caller holds mu.
+func (s *DataStore) written() {
+	s.version++
+	if s.notify != nil {
+		s.notify()
+	}
+}
+
+// StoreChange is a value of a data store written by a master, coils are
+// 0 or 1.
+type StoreChange struct {
+	Unit     byte
+	Table    Table
+	Address  uint16
+	Old, New uint16
+}
+
+// storeWatcher receives the changes of a data store.
+type storeWatcher struct {
+	ch chan StoreChange
+}
+
+// Watch returns a channel receiving a change for each coil or holding
+// register written by a master, whether or not its value changed. Writes
+// of the host application through the Set methods are not reported.
+// Changes are dropped while the buffer of the channel is full, it holds
+// the changes of the largest write. The channel is closed by cancel.
+func (s *DataStore) Watch() (<-chan StoreChange, CancelFunc) {
+	w := &storeWatcher{ch: make(chan StoreChange, MaxWriteCoils)}
+	s.mu.Lock()
+	if s.watchers == nil {
+		s.watchers = make(map[*storeWatcher]bool)
+	}
+	s.watchers[w] = true
+	s.mu.Unlock()
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.watchers[w] {
+			close(w.ch)
+			delete(s.watchers, w)
+		}
+	}
+	return w.ch, cancel
+}
+
+// publish passes c to the watchers, the caller holds mu.
+func (s *DataStore) publish(c StoreChange) {
+	for w := range s.watchers {
+		select {
+		case w.ch <- c:
+		default:
+		}
+	}
+}
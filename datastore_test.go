@@ -0,0 +1,37 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestDataStoreWatch(t *testing.T) {
+	s := NewServer()
+	s.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{1, 2, 0xff})
+	changes, cancel := s.Store.Watch()
+	c := startServer(t, s)
+	if err := c.WriteMultipleRegisters(0, []uint16{1, 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteSingleCoil(7, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MaskWriteRegister(2, 0x0f, 0x30); err != nil {
+		t.Fatal(err)
+	}
+	s.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{9})
+	expected := []StoreChange{
+		{Unit: 1, Table: TableHoldingRegisters, Address: 0, Old: 1, New: 1},
+		{Unit: 1, Table: TableHoldingRegisters, Address: 1, Old: 2, New: 5},
+		{Unit: 1, Table: TableCoils, Address: 7, Old: 0, New: 1},
+		{Unit: 1, Table: TableHoldingRegisters, Address: 2, Old: 0xff, New: 0x3f},
+	}
+	for i, e := range expected {
+		if a := <-changes; a != e {
+			t.Fatalf("change %v expected %+v, actual %+v", i, e, a)
+		}
+	}
+	cancel()
+	if a, ok := <-changes; ok {
+		t.Fatalf("closed channel expected, actual %+v", a)
+	}
+}
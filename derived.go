@@ -0,0 +1,138 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DerivedTag is a tag computed from other tags, e.g. "power" defined as
+// "voltage * current".
+type DerivedTag struct {
+	Name string
+	Expr string
+	// Unit of the computed value.
+	Unit string
+}
+
+type derivedTag struct {
+	DerivedTag
+	expr *Expr
+}
+
+// DerivedTags computes derived tags whenever one of their inputs updates.
+// A derived tag may reference derived tags defined before it, and is
+// computed once all of its inputs have been reported.
+type DerivedTags struct {
+	// Handler receives the computed updates.
+	Handler func(TagUpdate)
+	// ErrorHandler is invoked when an expression fails to evaluate.
+	ErrorHandler func(tag string, err error)
+
+	mu     sync.Mutex
+	tags   []derivedTag
+	values map[string]float64
+}
+
+// NewDerivedTags parses the expressions of tags.
+func NewDerivedTags(tags ...DerivedTag) (*DerivedTags, error) {
+	d := &DerivedTags{values: make(map[string]float64)}
+	defined := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag.Name == "" {
+			return nil, fmt.Errorf("modbus: tag name must not be empty")
+		}
+		if defined[tag.Name] {
+			return nil, fmt.Errorf("modbus: duplicate tag '%v'", tag.Name)
+		}
+		expr, err := ParseExpr(tag.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("modbus: tag '%v': %v", tag.Name, err)
+		}
+		for _, name := range expr.Vars() {
+			if name == tag.Name {
+				return nil, fmt.Errorf("modbus: tag '%v' references itself", tag.Name)
+			}
+		}
+		defined[tag.Name] = true
+		d.tags = append(d.tags, derivedTag{tag, expr})
+	}
+	// a reference to a later derived tag would allow cycles
+	for i, tag := range d.tags {
+		for _, name := range tag.expr.Vars() {
+			for _, later := range d.tags[i+1:] {
+				if later.Name == name {
+					return nil, fmt.Errorf("modbus: tag '%v' references '%v' defined after it", tag.Name, name)
+				}
+			}
+		}
+	}
+	return d, nil
+}
+
+// Record updates an input value and delivers the derived tags depending
+// on it to the Handler.
+func (d *DerivedTags) Record(u TagUpdate) {
+	for _, derived := range d.Update(u) {
+		if d.Handler != nil {
+			d.Handler(derived)
+		}
+	}
+}
+
+// Update updates an input value and returns the updates of the derived
+// tags depending on it, directly or through other derived tags.
+func (d *DerivedTags) Update(u TagUpdate) []TagUpdate {
+	d.mu.Lock()
+	var updates []TagUpdate
+	failed := make(map[string]error)
+	d.values[u.Tag] = u.Value
+	changed := map[string]bool{u.Tag: true}
+	for i := range d.tags {
+		tag := &d.tags[i]
+		if !tag.dependsOn(changed) {
+			continue
+		}
+		value, err := tag.expr.Eval(d.values)
+		if err != nil {
+			if tag.complete(d.values) {
+				failed[tag.Name] = err
+			}
+			continue
+		}
+		d.values[tag.Name] = value
+		changed[tag.Name] = true
+		updates = append(updates, TagUpdate{
+			Group:   u.Group,
+			Tag:     tag.Name,
+			Time:    u.Time,
+			Reading: Reading{Value: value, Unit: tag.Unit},
+		})
+	}
+	d.mu.Unlock()
+	if d.ErrorHandler != nil {
+		for name, err := range failed {
+			d.ErrorHandler(name, err)
+		}
+	}
+	return updates
+}
+
+func (t *derivedTag) dependsOn(changed map[string]bool) bool {
+	for _, name := range t.expr.Vars() {
+		if changed[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// complete reports whether all inputs of t have values, so that an error
+// is caused by the expression and not by a missing input.
+func (t *derivedTag) complete(values map[string]float64) bool {
+	for _, name := range t.expr.Vars() {
+		if _, ok := values[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
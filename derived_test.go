@@ -0,0 +1,55 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDerivedTags(t *testing.T) {
+	d, err := NewDerivedTags(
+		DerivedTag{Name: "power", Expr: "voltage * current", Unit: "W"},
+		DerivedTag{Name: "power_kw", Expr: "power / 1000", Unit: "kW"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updates := d.Update(TagUpdate{Tag: "voltage", Reading: Reading{Value: 230}}); len(updates) != 0 {
+		t.Fatalf("updates expected none before all inputs are known, actual %v", updates)
+	}
+	updates := d.Update(TagUpdate{Group: "fast", Tag: "current", Reading: Reading{Value: 10}})
+	if len(updates) != 2 || updates[0].Tag != "power" || updates[0].Value != 2300 ||
+		updates[1].Tag != "power_kw" || updates[1].Value != 2.3 || updates[1].Group != "fast" {
+		t.Fatalf("updates expected power/2300 and power_kw/2.3, actual %+v", updates)
+	}
+	if _, err := NewDerivedTags(
+		DerivedTag{Name: "a", Expr: "b + 1"},
+		DerivedTag{Name: "b", Expr: "a + 1"},
+	); err == nil {
+		t.Fatal("cyclic tags expected to fail")
+	}
+}
+
+func TestPollerDerived(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{4, 0x00, 0xE6, 0x00, 0x02}}
+	})
+	p := NewPoller(c, PollGroup{
+		Name:     "fast",
+		Interval: 10 * time.Millisecond,
+		Tags: []Tag{
+			{Name: "voltage", Table: TableHoldingRegisters, Address: 1},
+			{Name: "current", Table: TableHoldingRegisters, Address: 2},
+		},
+	})
+	p.Derived, _ = NewDerivedTags(DerivedTag{Name: "power", Expr: "voltage * current"})
+	updates, cancel := p.Subscribe("power")
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	u := <-updates
+	cancel()
+	p.Stop()
+	if u.Value != 460 {
+		t.Fatalf("power expected 460, actual %v", u.Value)
+	}
+}
@@ -0,0 +1,256 @@
+package modbustcp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// MEI type of Read Device Identification within function code 43.
+const MEIReadDeviceIdentification = 14
+
+// Read device identification codes.
+const (
+	DeviceIdBasic      = 1
+	DeviceIdRegular    = 2
+	DeviceIdExtended   = 3
+	DeviceIdIndividual = 4
+)
+
+// Object ids of the standard identification objects.
+const (
+	ObjectVendorName          = 0
+	ObjectProductCode         = 1
+	ObjectMajorMinorRevision  = 2
+	ObjectVendorUrl           = 3
+	ObjectProductName         = 4
+	ObjectModelName           = 5
+	ObjectUserApplicationName = 6
+)
+
+// DeviceInfo is the identification of a device.
+type DeviceInfo struct {
+	VendorName          string
+	ProductCode         string
+	Revision            string
+	VendorUrl           string
+	ProductName         string
+	ModelName           string
+	UserApplicationName string
+	// Extended holds the vendor specific objects 0x80 to 0xFF.
+	Extended map[byte]string
+	// Conformity is the conformity level reported by the device.
+	Conformity byte
+}
+
+// deviceInfoCache holds the identification of the units reachable over
+// conn, it is discarded when the client connects again.
+type deviceInfoCache struct {
+	mu    sync.Mutex
+	conn  net.Conn
+	units map[byte]DeviceInfo
+}
+
+// ReadDeviceIdentification reads the identification objects of the
+// category selected by code starting at objectId and returns them by
+// object id together with the conformity level of the device. Responses
+// split by the device are followed up until all objects are read.
+func (c *ModbusTcpClient) ReadDeviceIdentification(code, objectId byte) (map[byte]string, byte, error) {
+	return c.readDeviceIdentification(c.SlaveId, code, objectId)
+}
+
+func (c *ModbusTcpClient) readDeviceIdentification(unit, code, objectId byte) (map[byte]string, byte, error) {
+	if code < DeviceIdBasic || code > DeviceIdIndividual {
+		return nil, 0, fmt.Errorf("modbus: read device id code '%v' must be between '%v' and '%v'", code, DeviceIdBasic, DeviceIdIndividual)
+	}
+	objects := make(map[byte]string)
+	var conformity byte
+	for {
+		request := &Pdu{FunctionCode: FunctionEncapsulatedInterface, Data: []byte{MEIReadDeviceIdentification, code, objectId}}
+		response, err := c.ExecuteUnit(unit, request)
+		if err != nil {
+			return nil, 0, err
+		}
+		d := response.Data
+		if len(d) < 6 || d[0] != MEIReadDeviceIdentification {
+			return nil, 0, fmt.Errorf("modbus: invalid device identification response '% x'", d)
+		}
+		conformity = d[2]
+		more, next, count := d[3] == 0xFF, d[4], int(d[5])
+		d = d[6:]
+		for i := 0; i < count; i++ {
+			if len(d) < 2 || len(d) < 2+int(d[1]) {
+				return nil, 0, fmt.Errorf("modbus: device identification object '%v' truncated", i)
+			}
+			objects[d[0]] = string(d[2 : 2+int(d[1])])
+			d = d[2+int(d[1]):]
+		}
+		if !more || code == DeviceIdIndividual {
+			return objects, conformity, nil
+		}
+		if next <= objectId {
+			return nil, 0, fmt.Errorf("modbus: device identification does not advance at object '%v'", next)
+		}
+		objectId = next
+	}
+}
+
+// DeviceInfo returns the identification of the slave. It is read once
+// per connection, falling back to lower categories for devices rejecting
+// the extended one.
+func (c *ModbusTcpClient) DeviceInfo() (DeviceInfo, error) {
+	return c.deviceInfo(c.SlaveId)
+}
+
+func (c *ModbusTcpClient) deviceInfo(unit byte) (DeviceInfo, error) {
+	cache := &c.info
+	cache.mu.Lock()
+	if cache.conn == c.Conn && cache.conn != nil {
+		if info, ok := cache.units[unit]; ok {
+			cache.mu.Unlock()
+			return info, nil
+		}
+	}
+	cache.mu.Unlock()
+	var objects map[byte]string
+	var conformity byte
+	var err error
+	for code := byte(DeviceIdExtended); code >= DeviceIdBasic; code-- {
+		objects, conformity, err = c.readDeviceIdentification(unit, code, 0)
+		if !errors.Is(err, ErrorIllegalDataValue) {
+			break
+		}
+	}
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+	info := DeviceInfo{
+		VendorName:          objects[ObjectVendorName],
+		ProductCode:         objects[ObjectProductCode],
+		Revision:            objects[ObjectMajorMinorRevision],
+		VendorUrl:           objects[ObjectVendorUrl],
+		ProductName:         objects[ObjectProductName],
+		ModelName:           objects[ObjectModelName],
+		UserApplicationName: objects[ObjectUserApplicationName],
+		Conformity:          conformity,
+	}
+	for id, value := range objects {
+		if id >= 0x80 {
+			if info.Extended == nil {
+				info.Extended = make(map[byte]string)
+			}
+			info.Extended[id] = value
+		}
+	}
+	cache.mu.Lock()
+	if cache.conn != c.Conn {
+		cache.conn, cache.units = c.Conn, nil
+	}
+	if c.Conn != nil {
+		if cache.units == nil {
+			cache.units = make(map[byte]DeviceInfo)
+		}
+		cache.units[unit] = info
+	}
+	cache.mu.Unlock()
+	return info, nil
+}
+
+// objects returns the identification objects set in info by id, ignoring
+// extended objects outside 0x80 to 0xFF, and the conformity level
+// including individual access.
+func (info *DeviceInfo) objects() (map[byte]string, byte) {
+	objects := map[byte]string{
+		ObjectVendorName:         info.VendorName,
+		ObjectProductCode:        info.ProductCode,
+		ObjectMajorMinorRevision: info.Revision,
+	}
+	level := byte(DeviceIdBasic)
+	for id, value := range map[byte]string{
+		ObjectVendorUrl:           info.VendorUrl,
+		ObjectProductName:         info.ProductName,
+		ObjectModelName:           info.ModelName,
+		ObjectUserApplicationName: info.UserApplicationName,
+	} {
+		if value != "" {
+			objects[id] = value
+			level = DeviceIdRegular
+		}
+	}
+	for id, value := range info.Extended {
+		if id >= 0x80 {
+			objects[id] = value
+			level = DeviceIdExtended
+		}
+	}
+	return objects, 0x80 | level
+}
+
+// Identifier is implemented by a Handler serving units which identify
+// differently, e.g. simulated devices.
+type Identifier interface {
+	// Identify returns the identification of unit, ok is false to
+	// answer with the Identity of the server.
+	Identify(unit byte) (info DeviceInfo, ok bool)
+}
+
+// maxObjectLength is the length of the longest object value fitting into
+// a read device identification response.
+const maxObjectLength = 244
+
+// identification answers a read device identification request of unit
+// from the Identity of the server. Optional objects which are not set are
+// left out, and categories beyond the conformity level are rejected.
+// Objects not fitting into one response are continued in the next one.
+func (s *Server) identification(unit, code, objectId byte) ([]byte, byte) {
+	info := s.Identity
+	if h, ok := s.handler().(Identifier); ok {
+		if id, ok := h.Identify(unit); ok {
+			info = id
+		}
+	}
+	objects, conformity := info.objects()
+	if code == DeviceIdIndividual {
+		value, ok := objects[objectId]
+		if !ok {
+			return nil, ExcIllegalDataAdr
+		}
+		if len(value) > maxObjectLength {
+			value = value[:maxObjectLength]
+		}
+		return append([]byte{MEIReadDeviceIdentification, code, conformity, 0, 0, 1, objectId, byte(len(value))}, value...), 0
+	}
+	if code < DeviceIdBasic || code > conformity&0x7F {
+		return nil, ExcIllegalDataVal
+	}
+	var ids []byte
+	for id := 0; id <= 0xFF; id++ {
+		_, ok := objects[byte(id)]
+		if ok && (code == DeviceIdExtended || code == DeviceIdRegular && id < 0x80 || id <= ObjectMajorMinorRevision) {
+			ids = append(ids, byte(id))
+		}
+	}
+	start := 0
+	for i, id := range ids {
+		// an unknown start object restarts at the first object
+		if id == objectId {
+			start = i
+		}
+	}
+	data := []byte{MEIReadDeviceIdentification, code, conformity, 0, 0, 0}
+	for _, id := range ids[start:] {
+		value := objects[id]
+		if len(value) > maxObjectLength {
+			value = value[:maxObjectLength]
+		}
+		// function code and data must fit into 253 bytes
+		if len(data)+2+len(value) > 252 {
+			data[3], data[4] = 0xFF, id
+			break
+		}
+		data = append(append(data, id, byte(len(value))), value...)
+		data[5]++
+	}
+	return data, 0
+}
@@ -0,0 +1,38 @@
+package modbustcp
+
+import "testing"
+
+func TestDeviceInfo(t *testing.T) {
+	requests := 0
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		requests++
+		code, object := request.Data[1], request.Data[2]
+		switch {
+		case code == DeviceIdExtended:
+			return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{ExcIllegalDataVal}}
+		case object == 0:
+			// split response, more follows at object 2
+			return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{
+				14, code, 0x82, 0xFF, 2, 2,
+				0, 4, 'A', 'C', 'M', 'E',
+				1, 3, 'X', '4', '2',
+			}}
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{
+			14, code, 0x82, 0x00, 0, 2,
+			2, 4, 'v', '1', '.', '2',
+			5, 2, 'M', '1',
+		}}
+	})
+	info, err := c.DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.VendorName != "ACME" || info.ProductCode != "X42" || info.Revision != "v1.2" ||
+		info.ModelName != "M1" || info.Conformity != 0x82 {
+		t.Fatalf("device info expected ACME/X42/v1.2/M1, actual %+v", info)
+	}
+	if _, err = c.DeviceInfo(); err != nil || requests != 3 {
+		t.Fatalf("requests expected %v, actual %v (%v)", 3, requests, err)
+	}
+}
@@ -0,0 +1,229 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// Sub-function codes of the diagnostics function code 8 served by Server.
+const (
+	DiagReturnQueryData            = 0x00
+	DiagRestartCommunications      = 0x01
+	DiagReturnDiagnosticRegister   = 0x02
+	DiagForceListenOnly            = 0x04
+	DiagClearCounters              = 0x0A
+	DiagBusMessageCount            = 0x0B
+	DiagBusCommErrorCount          = 0x0C
+	DiagBusExceptionCount          = 0x0D
+	DiagServerMessageCount         = 0x0E
+	DiagServerNoResponseCount      = 0x0F
+	DiagServerNAKCount             = 0x10
+	DiagServerBusyCount            = 0x11
+	DiagBusCharacterOverrunCount   = 0x12
+	DiagClearOverrunCounterAndFlag = 0x14
+)
+
+// Events of the comm event log besides the receive and send events.
+const (
+	eventReceive         = 0x80
+	eventSend            = 0x40
+	eventListenOnly      = 0x20
+	eventEnterListenOnly = 0x04
+	eventRestart         = 0x00
+	eventReadException   = 0x01
+	eventAbortException  = 0x02
+	eventBusyException   = 0x04
+	eventNAKException    = 0x08
+	commEventLogSize     = 64
+)
+
+// diagnostics holds the counters and the event log of the diagnostics
+// function codes. Unlike the ServerStats they are 16 bits wide and reset
+// by the master.
+type diagnostics struct {
+	mu             sync.Mutex
+	listenOnly     bool
+	busMessages    uint16
+	busErrors      uint16
+	busExceptions  uint16
+	serverMessages uint16
+	noResponses    uint16
+	naks           uint16
+	busy           uint16
+	events         uint16
+	// log is a ring of the last events, next is the position of the
+	// next event
+	log  []byte
+	next int
+}
+
+func (d *diagnostics) busMessage() {
+	d.mu.Lock()
+	d.busMessages++
+	d.mu.Unlock()
+}
+
+func (d *diagnostics) busError() {
+	d.mu.Lock()
+	d.busErrors++
+	d.mu.Unlock()
+}
+
+func (d *diagnostics) noResponse() {
+	d.mu.Lock()
+	d.noResponses++
+	d.mu.Unlock()
+}
+
+// event adds an event to the log, the caller holds mu.
+func (d *diagnostics) event(e byte) {
+	if len(d.log) < commEventLogSize {
+		d.log = append(d.log, e)
+		d.next = len(d.log) % commEventLogSize
+		return
+	}
+	d.log[d.next] = e
+	d.next = (d.next + 1) % commEventLogSize
+}
+
+// clear resets the counters, the caller holds mu.
+func (d *diagnostics) clear() {
+	d.busMessages, d.busErrors, d.busExceptions, d.serverMessages = 0, 0, 0, 0
+	d.noResponses, d.naks, d.busy, d.events = 0, 0, 0, 0
+}
+
+// restart ends the listen only mode and clears the counters, and the
+// event log if requested. The caller holds mu.
+func (d *diagnostics) restart(clearLog bool) {
+	d.listenOnly = false
+	d.clear()
+	if clearLog {
+		d.log, d.next = nil, 0
+	}
+	d.event(eventRestart)
+}
+
+// receive logs a request addressed to the server and reports whether it
+// is to be processed. In listen only mode only a restart of the
+// communications is processed, without a response.
+func (d *diagnostics) receive(request *Pdu) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.serverMessages++
+	if !d.listenOnly {
+		d.event(eventReceive)
+		return true
+	}
+	d.event(eventReceive | eventListenOnly)
+	if request.FunctionCode == FunctionDiagnostics && len(request.Data) == 4 &&
+		binary.BigEndian.Uint16(request.Data) == DiagRestartCommunications {
+		d.restart(binary.BigEndian.Uint16(request.Data[2:]) == 0xFF00)
+	}
+	return false
+}
+
+// listenOnlyRequest enters the listen only mode if data requests it.
+func (d *diagnostics) listenOnlyRequest(data []byte) bool {
+	if len(data) != 4 || binary.BigEndian.Uint16(data) != DiagForceListenOnly {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listenOnly = true
+	d.event(eventEnterListenOnly)
+	return true
+}
+
+// send logs response to a request of functionCode.
+func (d *diagnostics) send(functionCode byte, response *Pdu) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var e byte = eventSend
+	if response.FunctionCode&ExcExceptionOffset != 0 && len(response.Data) > 0 {
+		d.busExceptions++
+		switch code := response.Data[0]; {
+		case code <= ExcIllegalDataVal:
+			e |= eventReadException
+		case code == ExcSlaveDeviceFailure:
+			e |= eventAbortException
+		case code == ExcAcknowledge || code == ExcSlaveIsBusy:
+			e |= eventBusyException
+			if code == ExcSlaveIsBusy {
+				d.busy++
+			}
+		case code == 7: // negative acknowledge
+			e |= eventNAKException
+			d.naks++
+		}
+	} else if functionCode != FunctionGetCommEventCounter && functionCode != FunctionGetCommEventLog {
+		d.events++
+	}
+	d.event(e)
+}
+
+// diagnose answers the diagnostics function code.
+func (d *diagnostics) diagnose(data []byte) ([]byte, byte) {
+	if len(data) < 2 {
+		return nil, ExcIllegalDataVal
+	}
+	sub := binary.BigEndian.Uint16(data)
+	if sub == DiagReturnQueryData {
+		return data, 0
+	}
+	if len(data) != 4 {
+		return nil, ExcIllegalDataVal
+	}
+	value := binary.BigEndian.Uint16(data[2:])
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var counter uint16
+	switch sub {
+	case DiagRestartCommunications:
+		if value != 0 && value != 0xFF00 {
+			return nil, ExcIllegalDataVal
+		}
+		d.restart(value == 0xFF00)
+		return data, 0
+	case DiagClearCounters, DiagClearOverrunCounterAndFlag:
+		if sub == DiagClearCounters {
+			d.clear()
+		}
+		return data, 0
+	case DiagReturnDiagnosticRegister, DiagBusCharacterOverrunCount:
+	case DiagBusMessageCount:
+		counter = d.busMessages
+	case DiagBusCommErrorCount:
+		counter = d.busErrors
+	case DiagBusExceptionCount:
+		counter = d.busExceptions
+	case DiagServerMessageCount:
+		counter = d.serverMessages
+	case DiagServerNoResponseCount:
+		counter = d.noResponses
+	case DiagServerNAKCount:
+		counter = d.naks
+	case DiagServerBusyCount:
+		counter = d.busy
+	default:
+		return nil, ExcIllegalFunction
+	}
+	return dataBlock(sub, counter), 0
+}
+
+// eventCounter answers Get Comm Event Counter with a ready status.
+func (d *diagnostics) eventCounter() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return dataBlock(0, d.events)
+}
+
+// eventLog answers Get Comm Event Log with the events most recent first.
+func (d *diagnostics) eventLog() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data := append([]byte{byte(6 + len(d.log))}, dataBlock(0, d.events, d.busMessages)...)
+	for i := 1; i <= len(d.log); i++ {
+		data = append(data, d.log[(d.next-i+len(d.log))%len(d.log)])
+	}
+	return data
+}
@@ -0,0 +1,62 @@
+package modbustcp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// diagnose sends a diagnostics request and returns the response data.
+func diagnose(t *testing.T, c *ModbusTcpClient, sub, value uint16) []byte {
+	response, err := c.Execute(&Pdu{FunctionCode: FunctionDiagnostics, Data: dataBlock(sub, value)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.Data
+}
+
+func TestServerDiagnostics(t *testing.T) {
+	s := NewServer()
+	c := startServer(t, s)
+	if data := diagnose(t, c, DiagReturnQueryData, 0xA537); !bytes.Equal(data, dataBlock(0, 0xA537)) {
+		t.Fatalf("query data expected to be echoed, actual % x", data)
+	}
+	c.Execute(&Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0, 200)})
+	if data := diagnose(t, c, DiagBusExceptionCount, 0); !bytes.Equal(data, dataBlock(DiagBusExceptionCount, 1)) {
+		t.Fatalf("exception count expected 1, actual % x", data)
+	}
+	if data := diagnose(t, c, DiagBusMessageCount, 0); !bytes.Equal(data, dataBlock(DiagBusMessageCount, 4)) {
+		t.Fatalf("message count expected 4, actual % x", data)
+	}
+	response, err := c.Execute(&Pdu{FunctionCode: FunctionGetCommEventLog})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 successful requests, 5 messages, the events most recent first
+	// starting with the receipt of this request
+	expected := []byte{15, 0, 0, 0, 3, 0, 5, 0x80, 0x40, 0x80, 0x40, 0x80, 0x41, 0x80, 0x40, 0x80}
+	if !bytes.Equal(response.Data, expected) {
+		t.Fatalf("event log expected % x, actual % x", expected, response.Data)
+	}
+
+	c.Timeout = 50 * time.Millisecond
+	if _, err := c.Execute(&Pdu{FunctionCode: FunctionDiagnostics, Data: dataBlock(DiagForceListenOnly, 0)}); err == nil {
+		t.Fatal("listen only request expected no response")
+	}
+	c.Disconnect()
+	c.Connect()
+	if _, err := c.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("read in listen only mode expected no response")
+	}
+	c.Disconnect()
+	c.Connect()
+	c.Execute(&Pdu{FunctionCode: FunctionDiagnostics, Data: dataBlock(DiagRestartCommunications, 0)})
+	c.Disconnect()
+	c.Connect()
+	if _, err := c.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatalf("read after restart expected to succeed, actual %v", err)
+	}
+	if data := diagnose(t, c, DiagServerMessageCount, 0); !bytes.Equal(data, dataBlock(DiagServerMessageCount, 2)) {
+		t.Fatalf("server message count expected 2 after restart, actual % x", data)
+	}
+}
@@ -0,0 +1,24 @@
+package modbustcp
+
+import (
+	"fmt"
+)
+
+// DryRunError is returned instead of sending a request modifying the
+// device when the client is in dry-run mode. Writes split into several
+// requests stop after the first frame.
+type DryRunError struct {
+	// Frame is the complete ADU which would have been sent
+	Frame []byte
+}
+
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("modbus: dry run, frame '% x' not sent", e.Frame)
+}
+
+// IsDryRun reports whether err was returned for a request not sent in
+// dry-run mode.
+func IsDryRun(err error) bool {
+	_, ok := err.(*DryRunError)
+	return ok
+}
@@ -0,0 +1,27 @@
+package modbustcp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		t.Errorf("request %v sent in dry-run mode", request.FunctionCode)
+		return request
+	})
+	c.DryRun = true
+	c.SlaveId = 1
+	err := c.WriteSingleRegister(0x10, 0x1234)
+	dry, ok := err.(*DryRunError)
+	if !ok {
+		t.Fatalf("error expected *DryRunError, actual %v", err)
+	}
+	expected := []byte{0, 1, 0, 0, 0, 6, 1, FunctionWriteSingleRegister, 0, 0x10, 0x12, 0x34}
+	if !bytes.Equal(dry.Frame, expected) {
+		t.Fatalf("frame expected % x, actual % x", expected, dry.Frame)
+	}
+	if err := c.WriteMultipleCoils(0, nil); err == nil || IsDryRun(err) {
+		t.Fatalf("invalid write expected validation error, actual %v", err)
+	}
+}
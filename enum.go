@@ -0,0 +1,60 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Enum maps the numeric values of a register to labels,
+// e.g. Enum{0: "Off", 1: "Hand", 2: "Auto"}.
+type Enum map[int64]string
+
+// Label returns the label of value.
+func (e Enum) Label(value int64) (string, bool) {
+	label, ok := e[value]
+	return label, ok
+}
+
+// Value returns the numeric value of label.
+func (e Enum) Value(label string) (int64, bool) {
+	for v, l := range e {
+		if l == label {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Labels returns the labels ordered by value.
+func (e Enum) Labels() []string {
+	values := make([]int64, 0, len(e))
+	for v := range e {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	labels := make([]string, len(values))
+	for i, v := range values {
+		labels[i] = e[v]
+	}
+	return labels
+}
+
+// EncodeLabel encodes the value of label. Labels not declared by the
+// enumeration of the codec are rejected.
+func (c Codec) EncodeLabel(label string) ([]uint16, error) {
+	value, ok := c.Enum.Value(label)
+	if !ok {
+		return nil, fmt.Errorf("modbus: label '%v' is not one of %q", label, c.Enum.Labels())
+	}
+	return c.Encode(float64(value))
+}
+
+// WriteLabel writes the value of label to holding registers as described
+// by codec.
+func (c *ModbusTcpClient) WriteLabel(address uint16, codec Codec, label string) error {
+	regs, err := codec.EncodeLabel(label)
+	if err != nil {
+		return err
+	}
+	return c.WriteMultipleRegisters(address, regs)
+}
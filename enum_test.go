@@ -0,0 +1,26 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestEnumCodec(t *testing.T) {
+	codec := Codec{Enum: Enum{0: "Off", 1: "Hand", 2: "Auto"}}
+	r, err := codec.Reading([]uint16{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Value != 2 || r.Label != "Auto" {
+		t.Fatalf("reading expected 2/Auto, actual %v/%v", r.Value, r.Label)
+	}
+	regs, err := codec.EncodeLabel("Hand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 1 {
+		t.Fatalf("register expected %v, actual %v", 1, regs[0])
+	}
+	if _, err := codec.EncodeLabel("Remote"); err == nil {
+		t.Fatal("error expected for undeclared label")
+	}
+}
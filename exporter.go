@@ -0,0 +1,246 @@
+package modbustcp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricConfig maps a register or tag to a Prometheus metric.
+type MetricConfig struct {
+	TagConfig
+	// Metric is the metric name, the sanitized tag name if empty.
+	Metric string `json:"metric,omitempty"`
+	Help   string `json:"help,omitempty"`
+	// MetricType is "gauge" or "counter", "gauge" if empty.
+	MetricType string            `json:"metric_type,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// ExporterTarget is a device scraped by an Exporter. Its samples carry
+// the label device with the target name in addition to Labels.
+type ExporterTarget struct {
+	Name      string            `json:"name"`
+	Host      string            `json:"host"`
+	Port      int               `json:"port,omitempty"`
+	UnitId    byte              `json:"unit_id,omitempty"`
+	Timeout   Duration          `json:"timeout,omitempty"`
+	WordOrder string            `json:"word_order,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Metrics   []MetricConfig    `json:"metrics"`
+}
+
+// ExporterConfig configures an Exporter.
+type ExporterConfig struct {
+	// Listen is the address of the HTTP server, ":9602" if empty.
+	Listen  string           `json:"listen,omitempty"`
+	Targets []ExporterTarget `json:"targets"`
+}
+
+// LoadExporterConfig reads an exporter configuration from a .json, .yaml
+// or .yml file.
+func LoadExporterConfig(path string) (*ExporterConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &ExporterConfig{}
+	if err = unmarshalConfig(data, configFormat(path), cfg); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return cfg, nil
+}
+
+var (
+	metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	metricNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+	labelEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+type exporterMetric struct {
+	name, help, kind string
+	labels           map[string]string
+	tag              Tag
+}
+
+type exporterTarget struct {
+	name    string
+	client  *ModbusTcpClient
+	metrics []exporterMetric
+}
+
+// Exporter serves the values of configured registers in the Prometheus
+// text format, reading all targets on each scrape. Connections are kept
+// open between scrapes and reestablished after failures. The metric
+// modbus_up reports per target whether the last scrape succeeded.
+type Exporter struct {
+	targets []*exporterTarget
+}
+
+// NewExporter creates the clients and metrics of cfg without connecting.
+func NewExporter(cfg *ExporterConfig) (*Exporter, error) {
+	e := &Exporter{}
+	for _, t := range cfg.Targets {
+		var order WordOrder
+		if t.WordOrder != "" {
+			var err error
+			if order, err = ParseWordOrder(t.WordOrder); err != nil {
+				return nil, fmt.Errorf("modbus: target '%v': %v", t.Name, err)
+			}
+		}
+		c := NewModbusTcpClient(t.Host, t.Port)
+		c.SlaveId, c.Timeout, c.WordOrder = t.UnitId, time.Duration(t.Timeout), order
+		target := &exporterTarget{name: t.Name, client: c}
+		for _, mc := range t.Metrics {
+			tag, err := mc.Tag(order, false)
+			if err != nil {
+				return nil, fmt.Errorf("modbus: target '%v': %v", t.Name, err)
+			}
+			m := exporterMetric{name: mc.Metric, help: mc.Help, kind: mc.MetricType, tag: tag, labels: map[string]string{}}
+			if m.name == "" {
+				m.name = metricNameInvalid.ReplaceAllString(mc.Name, "_")
+			}
+			if !metricNamePattern.MatchString(m.name) {
+				return nil, fmt.Errorf("modbus: target '%v': invalid metric name '%v'", t.Name, m.name)
+			}
+			switch m.kind {
+			case "":
+				m.kind = "gauge"
+			case "gauge", "counter":
+			default:
+				return nil, fmt.Errorf("modbus: metric '%v': unknown type '%v'", m.name, m.kind)
+			}
+			for k, v := range t.Labels {
+				m.labels[k] = v
+			}
+			for k, v := range mc.Labels {
+				m.labels[k] = v
+			}
+			m.labels["device"] = t.Name
+			target.metrics = append(target.metrics, m)
+		}
+		e.targets = append(e.targets, target)
+	}
+	return e, nil
+}
+
+type exporterSample struct {
+	metric *exporterMetric
+	value  float64
+}
+
+// scrape reads the metrics of t, reconnecting if necessary.
+func (t *exporterTarget) scrape() ([]exporterSample, error) {
+	c := t.client
+	if c.Conn == nil {
+		if err := c.Connect(); err != nil {
+			c.Conn = nil
+			return nil, err
+		}
+	}
+	ranges := make([]AddressRange, len(t.metrics))
+	for i := range t.metrics {
+		ranges[i] = c.tagRange(&t.metrics[i].tag)
+	}
+	var samples []exporterSample
+	for _, block := range PlanReads(ranges, PlanOptions{}) {
+		regs, bits, err := c.readRange(PriorityNormal, block)
+		if err != nil {
+			if !IsException(err) {
+				c.Disconnect()
+			}
+			return nil, err
+		}
+		for i := range t.metrics {
+			m := &t.metrics[i]
+			if !block.Contains(ranges[i]) {
+				continue
+			}
+			r, err := decodeTag(&m.tag, block, regs, bits)
+			if err != nil {
+				return nil, err
+			}
+			samples = append(samples, exporterSample{m, r.Value})
+		}
+	}
+	return samples, nil
+}
+
+// ServeHTTP scrapes all targets concurrently and writes the metrics.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	results := make([][]exporterSample, len(e.targets))
+	up := make([]bool, len(e.targets))
+	var wg sync.WaitGroup
+	for i, t := range e.targets {
+		wg.Add(1)
+		go func(i int, t *exporterTarget) {
+			defer wg.Done()
+			samples, err := t.scrape()
+			results[i], up[i] = samples, err == nil
+		}(i, t)
+	}
+	wg.Wait()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.write(w, results, up)
+}
+
+func (e *Exporter) write(w io.Writer, results [][]exporterSample, up []bool) {
+	families := map[string][]exporterSample{}
+	for _, samples := range results {
+		for _, s := range samples {
+			families[s.metric.name] = append(families[s.metric.name], s)
+		}
+	}
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		first := families[name][0].metric
+		if first.help != "" {
+			fmt.Fprintf(w, "# HELP %v %v\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(first.help))
+		}
+		fmt.Fprintf(w, "# TYPE %v %v\n", name, first.kind)
+		for _, s := range families[name] {
+			fmt.Fprintf(w, "%v%v %v\n", name, formatLabels(s.metric.labels), strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+	fmt.Fprintf(w, "# HELP modbus_up Whether the last scrape of the device succeeded.\n# TYPE modbus_up gauge\n")
+	for i, t := range e.targets {
+		v := 0
+		if up[i] {
+			v = 1
+		}
+		fmt.Fprintf(w, "modbus_up%v %v\n", formatLabels(map[string]string{"device": t.name}), v)
+	}
+}
+
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := sortedKeys(labels)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k + `="` + labelEscaper.Replace(labels[k]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// ListenAndServe serves the metrics on /metrics of addr, ":9602" if empty.
+func (e *Exporter) ListenAndServe(addr string) error {
+	if addr == "" {
+		addr = ":9602"
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	return http.ListenAndServe(addr, mux)
+}
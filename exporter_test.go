@@ -0,0 +1,44 @@
+package modbustcp
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExporter(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{4, 0x00, 0x64, 0x00, 0x0A}}
+	})
+	e, err := NewExporter(&ExporterConfig{Targets: []ExporterTarget{{
+		Name:   "meter1",
+		Labels: map[string]string{"site": "north"},
+		Metrics: []MetricConfig{
+			{TagConfig: TagConfig{Name: "power", Address: "30001", Gain: 10}, Help: "Active power.", Metric: "meter_power_watts"},
+			{TagConfig: TagConfig{Name: "energy.total", Address: "30002"}, MetricType: "counter", Labels: map[string]string{"phase": "L1"}},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.targets[0].client = c
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	expected := `# TYPE energy_total counter
+energy_total{device="meter1",phase="L1",site="north"} 10
+# HELP meter_power_watts Active power.
+# TYPE meter_power_watts gauge
+meter_power_watts{device="meter1",site="north"} 1000
+# HELP modbus_up Whether the last scrape of the device succeeded.
+# TYPE modbus_up gauge
+modbus_up{device="meter1"} 1
+`
+	if body := w.Body.String(); body != expected {
+		t.Fatalf("metrics expected\n%v\nactual\n%v", expected, body)
+	}
+	if _, err = NewExporter(&ExporterConfig{Targets: []ExporterTarget{{Metrics: []MetricConfig{
+		{TagConfig: TagConfig{Name: "x", Address: "40001"}, MetricType: "histogram"},
+	}}}}); err == nil || !strings.Contains(err.Error(), "histogram") {
+		t.Fatalf("unknown metric type expected error, actual %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package modbustcp
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is an arithmetic expression over named values, e.g.
+// "voltage * current / 1000". It supports + - * /, parentheses, unary
+// minus, numbers and the functions abs, sqrt, round, min and max.
+type Expr struct {
+	src  string
+	root exprNode
+	vars []string
+}
+
+type exprNode interface {
+	eval(vars map[string]float64) (float64, error)
+}
+
+type exprNum float64
+
+func (n exprNum) eval(map[string]float64) (float64, error) { return float64(n), nil }
+
+type exprVar string
+
+func (v exprVar) eval(vars map[string]float64) (float64, error) {
+	x, ok := vars[string(v)]
+	if !ok {
+		return 0, fmt.Errorf("modbus: undefined value '%v'", string(v))
+	}
+	return x, nil
+}
+
+type exprBinary struct {
+	op   byte
+	l, r exprNode
+}
+
+func (b *exprBinary) eval(vars map[string]float64) (float64, error) {
+	l, err := b.l.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	r, err := b.r.eval(vars)
+	if err != nil {
+		return 0, err
+	}
+	switch b.op {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	}
+	if r == 0 {
+		return 0, fmt.Errorf("modbus: division by zero")
+	}
+	return l / r, nil
+}
+
+type exprCall struct {
+	name string
+	args []exprNode
+}
+
+// exprFuncs maps the function names to their arity and implementation.
+var exprFuncs = map[string]struct {
+	arity int
+	f     func(args []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"round": {1, func(a []float64) float64 { return math.Round(a[0]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+}
+
+func (c *exprCall) eval(vars map[string]float64) (float64, error) {
+	args := make([]float64, len(c.args))
+	for i, arg := range c.args {
+		v, err := arg.eval(vars)
+		if err != nil {
+			return 0, err
+		}
+		args[i] = v
+	}
+	return exprFuncs[c.name].f(args), nil
+}
+
+// ParseExpr parses an expression. Names start with a letter or '_' and
+// may contain letters, digits, '_' and '.'.
+func ParseExpr(s string) (*Expr, error) {
+	p := &exprParser{src: s, seen: make(map[string]bool)}
+	root, err := p.sum()
+	if err == nil && p.skip() < len(s) {
+		err = fmt.Errorf("unexpected '%c'", s[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("modbus: expression '%v': %v", s, err)
+	}
+	return &Expr{src: s, root: root, vars: p.vars}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Vars returns the names referenced by the expression in order of
+// their first occurrence.
+func (e *Expr) Vars() []string {
+	return append([]string(nil), e.vars...)
+}
+
+// Eval evaluates the expression with the values of vars.
+func (e *Expr) Eval(vars map[string]float64) (float64, error) {
+	return e.root.eval(vars)
+}
+
+type exprParser struct {
+	src  string
+	pos  int
+	vars []string
+	seen map[string]bool
+}
+
+// skip advances past white space and returns the position.
+func (p *exprParser) skip() int {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	return p.pos
+}
+
+// accept consumes c if it is the next character.
+func (p *exprParser) accept(c byte) bool {
+	if p.skip() < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) sum() (exprNode, error) {
+	l, err := p.product()
+	for err == nil {
+		var op byte
+		switch {
+		case p.accept('+'):
+			op = '+'
+		case p.accept('-'):
+			op = '-'
+		default:
+			return l, nil
+		}
+		var r exprNode
+		if r, err = p.product(); err == nil {
+			l = &exprBinary{op, l, r}
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) product() (exprNode, error) {
+	l, err := p.unary()
+	for err == nil {
+		var op byte
+		switch {
+		case p.accept('*'):
+			op = '*'
+		case p.accept('/'):
+			op = '/'
+		default:
+			return l, nil
+		}
+		var r exprNode
+		if r, err = p.unary(); err == nil {
+			l = &exprBinary{op, l, r}
+		}
+	}
+	return nil, err
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	if p.accept('-') {
+		n, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprBinary{'-', exprNum(0), n}, nil
+	}
+	return p.operand()
+}
+
+func (p *exprParser) operand() (exprNode, error) {
+	if p.accept('(') {
+		n, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		return n, nil
+	}
+	start := p.skip()
+	if start == len(p.src) {
+		return nil, fmt.Errorf("unexpected end")
+	}
+	c := rune(p.src[start])
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE", p.src[p.pos]) >= 0 {
+			if (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') && p.pos+1 < len(p.src) &&
+				(p.src[p.pos+1] == '-' || p.src[p.pos+1] == '+') {
+				p.pos++
+			}
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%v'", p.src[start:p.pos])
+		}
+		return exprNum(v), nil
+	case unicode.IsLetter(c) || c == '_':
+		for p.pos < len(p.src) {
+			r := rune(p.src[p.pos])
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '.' {
+				break
+			}
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if p.accept('(') {
+			return p.call(name)
+		}
+		if !p.seen[name] {
+			p.seen[name] = true
+			p.vars = append(p.vars, name)
+		}
+		return exprVar(name), nil
+	}
+	return nil, fmt.Errorf("unexpected '%c'", c)
+}
+
+// call parses the arguments of a function call after the '('.
+func (p *exprParser) call(name string) (exprNode, error) {
+	fn, ok := exprFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%v'", name)
+	}
+	c := &exprCall{name: name}
+	for !p.accept(')') {
+		if len(c.args) > 0 && !p.accept(',') {
+			return nil, fmt.Errorf("missing ',' or ')'")
+		}
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		c.args = append(c.args, arg)
+	}
+	if len(c.args) != fn.arity {
+		return nil, fmt.Errorf("function '%v' expects %v arguments", name, fn.arity)
+	}
+	return c, nil
+}
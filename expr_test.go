@@ -0,0 +1,46 @@
+package modbustcp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpr(t *testing.T) {
+	vars := map[string]float64{"voltage": 230, "current": 2, "l1.power": -50}
+	tests := []struct {
+		expr  string
+		value float64
+	}{
+		{"voltage * current", 460},
+		{"voltage * current / 1000", 0.46},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"-voltage + 30", -200},
+		{"10 - 4 - 3", 3},
+		{"abs(l1.power)", 50},
+		{"max(current, 3) * 2e1", 60},
+		{"sqrt(16) + round(1.6)", 6},
+	}
+	for _, test := range tests {
+		e, err := ParseExpr(test.expr)
+		if err != nil {
+			t.Errorf("%v: %v", test.expr, err)
+			continue
+		}
+		if v, err := e.Eval(vars); err != nil || math.Abs(v-test.value) > 1e-9 {
+			t.Errorf("%v: value expected %v, actual %v (%v)", test.expr, test.value, v, err)
+		}
+	}
+	for _, s := range []string{"", "1 +", "(1", "1 2", "foo(1)", "min(1)", "2 $ 3"} {
+		if _, err := ParseExpr(s); err == nil {
+			t.Errorf("%v: error expected", s)
+		}
+	}
+	e, _ := ParseExpr("a / b + a")
+	if vars := e.Vars(); len(vars) != 2 || vars[0] != "a" || vars[1] != "b" {
+		t.Fatalf("vars expected [a b], actual %v", vars)
+	}
+	if _, err := e.Eval(map[string]float64{"a": 1, "b": 0}); err == nil {
+		t.Fatal("division by zero expected to fail")
+	}
+}
@@ -0,0 +1,99 @@
+package modbustcp
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Fault makes a Server misbehave for matching requests, e.g. to test the
+// retry and backoff logic of clients.
+type Fault struct {
+	// Function restricts the fault to a function code, zero matches all.
+	Function byte
+	// Range restricts the fault to requests reading or writing addresses
+	// within the range if its Quantity is not zero. A unit id of zero
+	// matches all units.
+	Range AddressRange
+	// Probability of the fault per matching request, zero always injects
+	// it.
+	Probability float64
+	// Exceptions answers the request with one of the exception codes
+	// chosen at random instead of handling it.
+	Exceptions []byte
+	// Delay postpones the handling of the request by Delay plus a random
+	// duration of up to Jitter.
+	Delay  time.Duration
+	Jitter time.Duration
+	// Drop handles the request without sending a response.
+	Drop bool
+	// Truncate sends only the first Truncate bytes of the response frame
+	// if not zero.
+	Truncate int
+}
+
+// matches reports whether f applies to request for unit.
+func (f *Fault) matches(unit byte, request *Pdu) bool {
+	if f.Function != 0 && f.Function != request.FunctionCode {
+		return false
+	}
+	if f.Range.Quantity > 0 {
+		read, isRead := readRange(unit, request)
+		write, isWrite := writeRange(unit, request)
+		if !(isRead && f.Range.overlaps(read) || isWrite && f.Range.overlaps(write)) {
+			return false
+		}
+	}
+	return f.Probability <= 0 || rand.Float64() < f.Probability
+}
+
+// SetFaults replaces the Faults while the server is serving, e.g. to
+// script a sequence of failures.
+func (s *Server) SetFaults(faults ...Fault) {
+	s.mu.Lock()
+	s.Faults = append([]Fault(nil), faults...)
+	s.mu.Unlock()
+}
+
+// fault returns the first fault of the server applying to r, nil if
+// there is none.
+func (s *Server) fault(r *Request) *Fault {
+	s.mu.Lock()
+	faults := s.Faults
+	s.mu.Unlock()
+	for i := range faults {
+		if faults[i].matches(r.Unit, r.Pdu) {
+			return &faults[i]
+		}
+	}
+	return nil
+}
+
+// inject applies f to the request r and returns the response to send
+// in place of serving it, ok is false if r has to be served.
+func (s *Server) inject(sess *session, f *Fault, r *Request) (response *Pdu, ok bool) {
+	delay := f.Delay
+	if f.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(f.Jitter) + 1))
+	}
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	sess.truncate = f.Truncate
+	if len(f.Exceptions) > 0 {
+		return Exception(r.Pdu, f.Exceptions[rand.Intn(len(f.Exceptions))]), true
+	}
+	if f.Drop {
+		s.serveRequest(sess, r)
+		return nil, true
+	}
+	return nil, false
+}
+
+// truncated cuts frame to the length of an injected truncation.
+func (sess *session) truncated(frame []byte) []byte {
+	if n := sess.truncate; n > 0 && n < len(frame) {
+		frame = frame[:n]
+	}
+	sess.truncate = 0
+	return frame
+}
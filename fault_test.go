@@ -0,0 +1,61 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServerFaults(t *testing.T) {
+	s := NewServer()
+	s.Faults = []Fault{
+		{Function: FunctionReadHoldingRegister, Range: AddressRange{Table: TableHoldingRegisters, Address: 100, Quantity: 10}, Exceptions: []byte{ExcSlaveIsBusy}},
+		{Function: FunctionReadInputRegister, Delay: 50 * time.Millisecond},
+		{Function: FunctionWriteSingleRegister, Drop: true},
+		{Function: FunctionReadCoil, Truncate: HeaderSize + 1},
+	}
+	c := startServer(t, s)
+	c.Timeout = 200 * time.Millisecond
+	if _, err := c.ReadHoldingRegisters(0, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadHoldingRegisters(95, 10); err != ErrorSlaveIsBusy {
+		t.Fatalf("error expected %v, actual %v", ErrorSlaveIsBusy, err)
+	}
+	start := time.Now()
+	if _, err := c.ReadInputRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("response expected to be delayed by 50ms, actual %v", elapsed)
+	}
+	if err := c.WriteSingleRegister(3, 9); err == nil {
+		t.Fatal("dropped response expected to time out")
+	}
+	if regs, _ := s.Store.GetRegisters(TableHoldingRegisters, 3, 1); regs[0] != 9 {
+		t.Fatalf("dropped write expected to be applied, actual %v", regs[0])
+	}
+	c.Disconnect()
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadCoils(0, 8); err == nil {
+		t.Fatal("truncated response expected to fail")
+	}
+}
+
+func TestServerSetFaults(t *testing.T) {
+	s := NewServer()
+	c := startServer(t, s)
+	s.SetFaults(Fault{Exceptions: []byte{ExcSlaveDeviceFailure}})
+	if _, err := c.ReadHoldingRegisters(0, 1); err != ErrorSlaveDeviceFailure {
+		t.Fatalf("error expected %v, actual %v", ErrorSlaveDeviceFailure, err)
+	}
+	s.SetFaults()
+	if _, err := c.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	s.CloseConnections()
+	if _, err := c.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("request on a closed connection expected to fail")
+	}
+}
@@ -0,0 +1,212 @@
+package modbustcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// fileReferenceType is the only reference type defined for file records.
+const fileReferenceType = 6
+
+// ReadFileRecord reads length registers of a file starting at record
+// using function code 20.
+func (c *ModbusTcpClient) ReadFileRecord(file, record, length uint16) ([]uint16, error) {
+	return c.readFileRecord(c.SlaveId, file, record, length)
+}
+
+// WriteFileRecord writes values to a file starting at record using
+// function code 21.
+func (c *ModbusTcpClient) WriteFileRecord(file, record uint16, values []uint16) error {
+	return c.writeFileRecord(c.SlaveId, file, record, values)
+}
+
+// checkFileRecord validates a request of length registers.
+func checkFileRecord(file, record uint16, length, max int) error {
+	if file == 0 {
+		return fmt.Errorf("modbus: file number must not be zero")
+	}
+	if length < 1 || length > max {
+		return fmt.Errorf("modbus: record length '%v' must be between '%v' and '%v'", length, 1, max)
+	}
+	if int(record)+length > MaxFileRecords {
+		return fmt.Errorf("modbus: records '%v' to '%v' exceed '%v'", record, int(record)+length-1, MaxFileRecords-1)
+	}
+	return nil
+}
+
+func (c *ModbusTcpClient) readFileRecord(unit byte, file, record, length uint16) ([]uint16, error) {
+	if err := checkFileRecord(file, record, int(length), MaxReadFileRecord); err != nil {
+		return nil, err
+	}
+	data := append([]byte{7, fileReferenceType}, dataBlock(file, record, length)...)
+	response, err := c.ExecuteUnit(unit, &Pdu{FunctionCode: FunctionReadFileRecord, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	count := 2 * int(length)
+	d := response.Data
+	if len(d) != count+3 || int(d[0]) != count+2 || int(d[1]) != count+1 {
+		return nil, fmt.Errorf("modbus: response byte count '%v' does not match expected '%v'", len(d)-1, count+2)
+	}
+	if d[2] != fileReferenceType {
+		return nil, fmt.Errorf("modbus: response reference type '%v' does not match '%v'", d[2], fileReferenceType)
+	}
+	values := make([]uint16, length)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(d[3+2*i:])
+	}
+	return values, nil
+}
+
+func (c *ModbusTcpClient) writeFileRecord(unit byte, file, record uint16, values []uint16) error {
+	if err := checkFileRecord(file, record, len(values), MaxWriteFileRecord); err != nil {
+		return err
+	}
+	data := make([]byte, 8+2*len(values))
+	data[0] = byte(7 + 2*len(values))
+	data[1] = fileReferenceType
+	putRegisters(data[2:], []uint16{file, record, uint16(len(values))})
+	putRegisters(data[8:], values)
+	request := &Pdu{FunctionCode: FunctionWriteFileRecord, Data: data}
+	response, err := c.ExecuteUnit(unit, request)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(response.Data, request.Data) {
+		return fmt.Errorf("modbus: response '% x' does not echo request '% x'", response.Data, request.Data)
+	}
+	return nil
+}
+
+// FileTransfer moves files such as firmware images or configurations to
+// and from a device in chunks of file records. A transfer larger than the
+// 10000 records of a file continues in record 0 of the next file number.
+type FileTransfer struct {
+	Client *ModbusTcpClient
+	// UnitId of the device, zero selects the slave of the client.
+	UnitId byte
+	// File is the number of the first file.
+	File uint16
+	// ChunkSize is the number of registers per request, limited to the
+	// maximum of the function code if zero.
+	ChunkSize int
+	// Verify reads back each uploaded chunk and fails on a mismatch.
+	Verify bool
+	// Progress is called after each chunk with the bytes transferred.
+	Progress func(done, total int)
+}
+
+func (t *FileTransfer) unit() byte {
+	if t.UnitId != 0 {
+		return t.UnitId
+	}
+	return t.Client.SlaveId
+}
+
+// chunk returns the file and record at register position pos and the
+// number of registers of the next chunk of at most max registers.
+func (t *FileTransfer) chunk(pos, remaining, max int) (file, record uint16, n int, err error) {
+	if t.ChunkSize > 0 && t.ChunkSize < max {
+		max = t.ChunkSize
+	}
+	f := int(t.File) + pos/MaxFileRecords
+	if f > 0xFFFF {
+		return 0, 0, 0, fmt.Errorf("modbus: transfer exceeds the last file")
+	}
+	record = uint16(pos % MaxFileRecords)
+	n = remaining
+	if n > max {
+		n = max
+	}
+	if left := MaxFileRecords - int(record); n > left {
+		n = left
+	}
+	return uint16(f), record, n, nil
+}
+
+// Upload writes data starting at byte offset, which is zero for a new
+// transfer and the returned count to resume a failed one. An odd length
+// is padded with a zero byte. It returns the number of bytes of data
+// transferred so far.
+func (t *FileTransfer) Upload(ctx context.Context, data []byte, offset int) (int, error) {
+	if offset%2 != 0 || offset < 0 || offset > len(data) {
+		return offset, fmt.Errorf("modbus: invalid transfer offset '%v'", offset)
+	}
+	for offset < len(data) {
+		if err := ctx.Err(); err != nil {
+			return offset, err
+		}
+		pos := offset / 2
+		file, record, n, err := t.chunk(pos, (len(data)-offset+1)/2, MaxWriteFileRecord)
+		if err != nil {
+			return offset, err
+		}
+		values := make([]uint16, n)
+		for i := range values {
+			b := data[offset+2*i:]
+			values[i] = uint16(b[0]) << 8
+			if len(b) > 1 {
+				values[i] |= uint16(b[1])
+			}
+		}
+		if err := t.Client.writeFileRecord(t.unit(), file, record, values); err != nil {
+			return offset, err
+		}
+		if t.Verify {
+			read, err := t.Client.readFileRecord(t.unit(), file, record, uint16(n))
+			if err != nil {
+				return offset, err
+			}
+			for i := range values {
+				if read[i] != values[i] {
+					return offset, fmt.Errorf("modbus: file '%v' record '%v' reads back '%v' instead of '%v'",
+						file, int(record)+i, read[i], values[i])
+				}
+			}
+		}
+		offset += 2 * n
+		if offset > len(data) {
+			offset = len(data)
+		}
+		if t.Progress != nil {
+			t.Progress(offset, len(data))
+		}
+	}
+	return offset, nil
+}
+
+// Download reads size bytes starting at byte offset and writes them to w.
+// A failed download is resumed by passing the returned count as offset.
+func (t *FileTransfer) Download(ctx context.Context, w io.Writer, size, offset int) (int, error) {
+	if offset%2 != 0 || offset < 0 || offset > size {
+		return offset, fmt.Errorf("modbus: invalid transfer offset '%v'", offset)
+	}
+	for offset < size {
+		if err := ctx.Err(); err != nil {
+			return offset, err
+		}
+		file, record, n, err := t.chunk(offset/2, (size-offset+1)/2, MaxReadFileRecord)
+		if err != nil {
+			return offset, err
+		}
+		values, err := t.Client.readFileRecord(t.unit(), file, record, uint16(n))
+		if err != nil {
+			return offset, err
+		}
+		b := dataBlock(values...)
+		if len(b) > size-offset {
+			b = b[:size-offset]
+		}
+		if _, err := w.Write(b); err != nil {
+			return offset, err
+		}
+		offset += len(b)
+		if t.Progress != nil {
+			t.Progress(offset, size)
+		}
+	}
+	return offset, nil
+}
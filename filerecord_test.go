@@ -0,0 +1,83 @@
+package modbustcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+)
+
+// fileSlave serves function codes 20 and 21 from files in memory and
+// fails the write request with index failAt with a busy exception.
+func fileSlave(files map[uint16][]uint16, failAt int) func(request *Pdu) *Pdu {
+	writes := 0
+	return func(request *Pdu) *Pdu {
+		d := request.Data
+		file := binary.BigEndian.Uint16(d[2:])
+		record := binary.BigEndian.Uint16(d[4:])
+		length := binary.BigEndian.Uint16(d[6:])
+		if files[file] == nil {
+			files[file] = make([]uint16, MaxFileRecords)
+		}
+		if request.FunctionCode == FunctionWriteFileRecord {
+			if writes++; writes == failAt {
+				return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{ExcSlaveIsBusy}}
+			}
+			for i := 0; i < int(length); i++ {
+				files[file][int(record)+i] = binary.BigEndian.Uint16(d[8+2*i:])
+			}
+			return request
+		}
+		data := []byte{byte(2*length + 2), byte(2*length + 1), fileReferenceType}
+		data = append(data, dataBlock(files[file][record:record+length]...)...)
+		return &Pdu{FunctionCode: request.FunctionCode, Data: data}
+	}
+}
+
+func TestFileRecord(t *testing.T) {
+	files := map[uint16][]uint16{}
+	c := newTestClient(t, fileSlave(files, 0))
+	if err := c.WriteFileRecord(4, 1, []uint16{0x1234, 0x5678}); err != nil {
+		t.Fatal(err)
+	}
+	values, err := c.ReadFileRecord(4, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != 0 || values[1] != 0x1234 || values[2] != 0x5678 {
+		t.Fatalf("records expected [0 1234 5678], actual %x", values)
+	}
+	if _, err := c.ReadFileRecord(4, 9999, 2); err == nil {
+		t.Fatal("records beyond 9999 expected to fail")
+	}
+}
+
+func TestFileTransfer(t *testing.T) {
+	files := map[uint16][]uint16{}
+	c := newTestClient(t, fileSlave(files, 2))
+	image := make([]byte, 2*MaxFileRecords+301)
+	for i := range image {
+		image[i] = byte(i * 7)
+	}
+	var progress int
+	ft := &FileTransfer{Client: c, File: 1, ChunkSize: 100, Verify: true, Progress: func(done, total int) {
+		progress = done
+	}}
+	n, err := ft.Upload(context.Background(), image, 0)
+	if err == nil || n != 200 {
+		t.Fatalf("upload expected to fail after 200 bytes, actual %v (%v)", n, err)
+	}
+	if n, err = ft.Upload(context.Background(), image, n); err != nil || n != len(image) {
+		t.Fatalf("resumed upload expected %v bytes, actual %v (%v)", len(image), n, err)
+	}
+	if progress != len(image) || files[2][150] != uint16(image[2*MaxFileRecords+300])<<8 {
+		t.Fatalf("upload expected to continue in file 2, progress %v", progress)
+	}
+	var buf bytes.Buffer
+	if n, err = ft.Download(context.Background(), &buf, len(image), 0); err != nil || n != len(image) {
+		t.Fatalf("download expected %v bytes, actual %v (%v)", len(image), n, err)
+	}
+	if !bytes.Equal(buf.Bytes(), image) {
+		t.Fatal("downloaded data differs from upload")
+	}
+}
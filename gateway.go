@@ -0,0 +1,283 @@
+package modbustcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Gateway exposes the tags and data tables of named devices over HTTP
+// with JSON bodies:
+//
+//	GET /devices
+//	GET /devices/{device}/tags
+//	GET /devices/{device}/tags/{tag}
+//	PUT /devices/{device}/tags/{tag}            {"value": 21.5}
+//	GET /devices/{device}/{table}/{address}?count=n
+//	PUT /devices/{device}/{table}/{address}     {"values": [1, 2]}
+//
+// Tables are named as by ParseTable, addresses follow the address mode
+// of the client. Failures are answered with {"error": "..."}.
+type Gateway struct {
+	mu      sync.RWMutex
+	devices map[string]*ModbusTcpClient
+}
+
+// NewGateway creates a gateway without devices.
+func NewGateway() *Gateway {
+	return &Gateway{devices: make(map[string]*ModbusTcpClient)}
+}
+
+// Add registers client under name, replacing a device of the same name.
+func (g *Gateway) Add(name string, client *ModbusTcpClient) {
+	g.mu.Lock()
+	g.devices[name] = client
+	g.mu.Unlock()
+}
+
+// ServeHTTP implements http.Handler.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if path[0] != "devices" || len(path) > 4 {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("modbus: unknown path '%v'", r.URL.Path))
+		return
+	}
+	var handler func(http.ResponseWriter, *http.Request, []string)
+	switch {
+	case len(path) == 1 && r.Method == http.MethodGet:
+		handler = g.listDevices
+	case len(path) == 3 && path[2] == "tags" && r.Method == http.MethodGet:
+		handler = g.listTags
+	case len(path) == 4 && path[2] == "tags" && r.Method == http.MethodGet:
+		handler = g.readTag
+	case len(path) == 4 && path[2] == "tags" && r.Method == http.MethodPut:
+		handler = g.writeTag
+	case len(path) == 4 && r.Method == http.MethodGet:
+		handler = g.readTable
+	case len(path) == 4 && r.Method == http.MethodPut:
+		handler = g.writeTable
+	case len(path) == 4 || len(path) == 3 && path[2] == "tags" || len(path) == 1:
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("modbus: method '%v' not allowed", r.Method))
+		return
+	default:
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("modbus: unknown path '%v'", r.URL.Path))
+		return
+	}
+	handler(w, r, path)
+}
+
+type tagResponse struct {
+	Tag   string   `json:"tag"`
+	Value float64  `json:"value"`
+	Label string   `json:"label,omitempty"`
+	Unit  string   `json:"unit,omitempty"`
+	Raw   []uint16 `json:"raw"`
+}
+
+type tableResponse struct {
+	Table   Table       `json:"table"`
+	Address uint16      `json:"address"`
+	Values  interface{} `json:"values"`
+}
+
+type valueRequest struct {
+	Value  interface{}       `json:"value"`
+	Values []json.RawMessage `json:"values"`
+}
+
+func (g *Gateway) device(w http.ResponseWriter, path []string) *ModbusTcpClient {
+	name := path[1]
+	g.mu.RLock()
+	c := g.devices[name]
+	g.mu.RUnlock()
+	if c == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("modbus: unknown device '%v'", name))
+	}
+	return c
+}
+
+func (g *Gateway) listDevices(w http.ResponseWriter, r *http.Request, path []string) {
+	g.mu.RLock()
+	names := make([]string, 0, len(g.devices))
+	for name := range g.devices {
+		names = append(names, name)
+	}
+	g.mu.RUnlock()
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+func (g *Gateway) listTags(w http.ResponseWriter, r *http.Request, path []string) {
+	c := g.device(w, path)
+	if c == nil {
+		return
+	}
+	names := []string{}
+	if c.Tags != nil {
+		names = c.Tags.Names()
+	}
+	writeJSON(w, http.StatusOK, names)
+}
+
+func (g *Gateway) readTag(w http.ResponseWriter, r *http.Request, path []string) {
+	c := g.device(w, path)
+	if c == nil {
+		return
+	}
+	name := path[3]
+	reading, err := c.ReadTag(name)
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tagResponse{Tag: name, Value: reading.Value, Label: reading.Label, Unit: reading.Unit, Raw: reading.Raw})
+}
+
+func (g *Gateway) writeTag(w http.ResponseWriter, r *http.Request, path []string) {
+	c := g.device(w, path)
+	if c == nil {
+		return
+	}
+	var req valueRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := c.WriteTag(path[3], req.Value); err != nil {
+		writeJSONError(w, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tableAddress parses the table and address of the request path.
+func tableAddress(path []string) (Table, uint16, error) {
+	table, err := ParseTable(path[2])
+	if err != nil {
+		return table, 0, err
+	}
+	address, err := strconv.ParseUint(path[3], 10, 16)
+	if err != nil {
+		return table, 0, fmt.Errorf("modbus: invalid address '%v'", path[3])
+	}
+	return table, uint16(address), nil
+}
+
+func (g *Gateway) readTable(w http.ResponseWriter, r *http.Request, path []string) {
+	c := g.device(w, path)
+	if c == nil {
+		return
+	}
+	table, address, err := tableAddress(path)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	count := uint64(1)
+	if s := r.URL.Query().Get("count"); s != "" {
+		if count, err = strconv.ParseUint(s, 10, 16); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("modbus: invalid count '%v'", s))
+			return
+		}
+	}
+	regs, bits, err := c.readRange(PriorityNormal, AddressRange{UnitId: c.SlaveId, Table: table, Address: address, Quantity: uint16(count)})
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err)
+		return
+	}
+	var values interface{} = regs
+	if table.IsBit() {
+		values = bits
+	}
+	writeJSON(w, http.StatusOK, tableResponse{Table: table, Address: address, Values: values})
+}
+
+func (g *Gateway) writeTable(w http.ResponseWriter, r *http.Request, path []string) {
+	c := g.device(w, path)
+	if c == nil {
+		return
+	}
+	table, address, err := tableAddress(path)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	if !table.Writable() {
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("modbus: table '%v' is not writable", table))
+		return
+	}
+	var req valueRequest
+	if err = decodeJSONBody(r, &req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if table == TableCoils {
+		bits := make([]bool, len(req.Values))
+		for i, v := range req.Values {
+			if err = json.Unmarshal(v, &bits[i]); err != nil {
+				var n uint16
+				if err = json.Unmarshal(v, &n); err != nil {
+					writeJSONError(w, http.StatusBadRequest, fmt.Errorf("modbus: invalid coil value '%s'", v))
+					return
+				}
+				bits[i] = n != 0
+			}
+		}
+		err = c.WriteMultipleCoils(address, bits)
+	} else {
+		regs := make([]uint16, len(req.Values))
+		for i, v := range req.Values {
+			if err = json.Unmarshal(v, &regs[i]); err != nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Errorf("modbus: invalid register value '%s'", v))
+				return
+			}
+		}
+		err = c.WriteMultipleRegisters(address, regs)
+	}
+	if err != nil {
+		writeJSONError(w, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// errorStatus maps errors of the client to HTTP status codes.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrorUnknownTag):
+		return http.StatusNotFound
+	case errors.Is(err, ErrorTagNotWritable), errors.Is(err, ErrorReadOnly), errors.Is(err, ErrorWriteProtected):
+		return http.StatusForbidden
+	case err == ErrorIllegalDataAddress, err == ErrorIllegalDataValue, err == ErrorIllegalFunction:
+		return http.StatusUnprocessableEntity
+	case IsException(err):
+		return http.StatusBadGateway
+	}
+	if _, ok := err.(*VerifyError); ok {
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
+
+func decodeJSONBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("modbus: invalid request body: %v", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,43 @@
+package modbustcp
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGateway(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		switch request.FunctionCode {
+		case FunctionReadHoldingRegister:
+			return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{4, 0, 1, 0, 2}}
+		case FunctionWriteSingleRegister:
+			return request
+		}
+		return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{ExcIllegalDataAdr}}
+	})
+	c.Tags, _ = NewTagDatabase(Tag{Name: "setpoint", Table: TableHoldingRegisters, Address: 3, Writable: true})
+	g := NewGateway()
+	g.Add("boiler", c)
+	tests := []struct {
+		method, path, body string
+		status             int
+		response           string
+	}{
+		{"GET", "/devices", "", 200, `["boiler"]`},
+		{"GET", "/devices/boiler/tags", "", 200, `["setpoint"]`},
+		{"GET", "/devices/boiler/holding/10?count=2", "", 200, `{"table":"holding","address":10,"values":[1,2]}`},
+		{"GET", "/devices/boiler/input/10", "", 422, `{"error":"Illegal Data Address"}`},
+		{"PUT", "/devices/boiler/tags/setpoint", `{"value": 7}`, 204, ``},
+		{"GET", "/devices/boiler/tags/unknown", "", 404, `{"error":"modbus: unknown tag 'unknown'"}`},
+		{"GET", "/devices/pump/tags", "", 404, `{"error":"modbus: unknown device 'pump'"}`},
+		{"PUT", "/devices/boiler/input/10", `{"values": [1]}`, 405, `{"error":"modbus: table 'input' is not writable"}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if body := strings.TrimSpace(w.Body.String()); w.Code != test.status || body != test.response {
+			t.Errorf("%v %v: expected %v %v, actual %v %v", test.method, test.path, test.status, test.response, w.Code, body)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package modbustcp
+
+import (
+	"math"
+)
+
+// Scalar is the set of types supported by ReadValue and WriteValue.
+type Scalar interface {
+	bool | int16 | uint16 | int32 | uint32 | int64 | uint64 | float32 | float64
+}
+
+// scalarType returns the register data type of the numeric type of v.
+func scalarType(v interface{}) DataType {
+	switch v.(type) {
+	case int16:
+		return TypeInt16
+	case int32:
+		return TypeInt32
+	case uint32:
+		return TypeUint32
+	case int64:
+		return TypeInt64
+	case uint64:
+		return TypeUint64
+	case float32:
+		return TypeFloat32
+	case float64:
+		return TypeFloat64
+	}
+	return TypeUint16
+}
+
+// ReadValue reads a single value of type T at address. Booleans are read
+// from coils, numbers from as many holding registers as the type occupies
+// using the word order of the client.
+func ReadValue[T Scalar](c *ModbusTcpClient, address uint16) (T, error) {
+	var v T
+	if p, ok := interface{}(&v).(*bool); ok {
+		bits, err := c.ReadCoils(address, 1)
+		if err != nil {
+			return v, err
+		}
+		*p = bits[0]
+		return v, nil
+	}
+	codec := Codec{Type: scalarType(v), Order: c.WordOrder}
+	regs, err := c.ReadHoldingRegisters(address, uint16(codec.Registers()))
+	if err != nil {
+		return v, err
+	}
+	bits := codec.bits(regs)
+	switch p := interface{}(&v).(type) {
+	case *int16:
+		*p = int16(bits)
+	case *uint16:
+		*p = uint16(bits)
+	case *int32:
+		*p = int32(bits)
+	case *uint32:
+		*p = uint32(bits)
+	case *int64:
+		*p = int64(bits)
+	case *uint64:
+		*p = bits
+	case *float32:
+		*p = math.Float32frombits(uint32(bits))
+	case *float64:
+		*p = math.Float64frombits(bits)
+	}
+	return v, nil
+}
+
+// WriteValue writes a single value of type T at address. Booleans are
+// written to a coil, numbers to holding registers.
+func WriteValue[T Scalar](c *ModbusTcpClient, address uint16, value T) error {
+	var bits uint64
+	switch v := interface{}(value).(type) {
+	case bool:
+		return c.WriteSingleCoil(address, v)
+	case int16:
+		bits = uint64(uint16(v))
+	case uint16:
+		bits = uint64(v)
+	case int32:
+		bits = uint64(uint32(v))
+	case uint32:
+		bits = uint64(v)
+	case int64:
+		bits = uint64(v)
+	case uint64:
+		bits = v
+	case float32:
+		bits = uint64(math.Float32bits(v))
+	case float64:
+		bits = math.Float64bits(v)
+	}
+	codec := Codec{Type: scalarType(value), Order: c.WordOrder}
+	return c.WriteMultipleRegisters(address, codec.putBits(bits))
+}
@@ -0,0 +1,30 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestReadValue(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		if request.FunctionCode == FunctionReadCoil {
+			return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{1, 1}}
+		}
+		// 1.5 as float32 with low word first
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{4, 0x00, 0x00, 0x3F, 0xC0}}
+	})
+	c.WordOrder = OrderCDAB
+	f, err := ReadValue[float32](c, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != 1.5 {
+		t.Fatalf("value expected %v, actual %v", 1.5, f)
+	}
+	b, err := ReadValue[bool](c, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b {
+		t.Fatal("coil expected to be set")
+	}
+}
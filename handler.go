@@ -0,0 +1,165 @@
+package modbustcp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Handler serves the data tables of a Server. Errors are answered with
+// the exception code of an error returned by FailureCodeToError, e.g.
+// ErrorIllegalDataAddress, or a slave device failure for other errors.
+// Addresses are protocol addresses. Handlers must be safe for concurrent
+// use by the connections of the server.
+type Handler interface {
+	// ReadBits reads coils or discrete inputs.
+	ReadBits(unit byte, table Table, address uint16, quantity int) ([]bool, error)
+	// ReadRegisters reads holding or input registers.
+	ReadRegisters(unit byte, table Table, address uint16, quantity int) ([]uint16, error)
+	WriteCoils(unit byte, address uint16, values []bool) error
+	WriteHoldingRegisters(unit byte, address uint16, values []uint16) error
+}
+
+// FunctionHandler serves the request data of a function code and returns
+// the response data.
+type FunctionHandler func(unit byte, data []byte) ([]byte, error)
+
+// HandleFunc serves function code with f instead of the built in
+// handling, e.g. for user defined function codes to emulate a vendor
+// device. Function codes without handler or built in handling are
+// answered with an illegal function exception. A nil f restores the
+// built in handling. HandleFunc panics for function code 0 and codes of
+// exception responses, 128 and above.
+func (s *Server) HandleFunc(functionCode byte, f FunctionHandler) {
+	if functionCode == 0 || functionCode&ExcExceptionOffset != 0 {
+		panic(fmt.Sprintf("modbus: invalid function code '%v'", functionCode))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f == nil {
+		delete(s.functions, functionCode)
+		return
+	}
+	if s.functions == nil {
+		s.functions = make(map[byte]FunctionHandler)
+	}
+	s.functions[functionCode] = f
+}
+
+// WriteFunctionCodes are the standard function codes modifying data,
+// e.g. to disable all writes with DisableFunctions.
+var WriteFunctionCodes = []byte{
+	FunctionWriteSingleCoil, FunctionWriteSingleRegister, FunctionWriteMultipleCoils,
+	FunctionWriteMultipleRegister, FunctionWriteFileRecord, FunctionMaskWriteRegister,
+	FunctionReadWriteMultipleRegister,
+}
+
+// isWriteFunction reports whether code is one of WriteFunctionCodes.
+func isWriteFunction(code byte) bool {
+	for _, c := range WriteFunctionCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// DisableFunctions answers requests of the function codes with an illegal
+// function exception, including function codes served by HandleFunc.
+func (s *Server) DisableFunctions(codes ...byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, code := range codes {
+		s.disabled[code] = true
+	}
+}
+
+// EnableFunctions reverts DisableFunctions.
+func (s *Server) EnableFunctions(codes ...byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, code := range codes {
+		s.disabled[code] = false
+	}
+}
+
+func (s *Server) handler() Handler {
+	if s.Handler != nil {
+		return s.Handler
+	}
+	return s.Store
+}
+
+// ErrorToFailureCode returns the exception code answering err, the
+// inverse of FailureCodeToError. Errors which are no exception map to
+// ExcSlaveDeviceFailure.
+func ErrorToFailureCode(err error) byte {
+	for code := byte(ExcIllegalFunction); code <= ExcGateTargetFailed; code++ {
+		if e := FailureCodeToError(int(code)); e != ErrorUnknown && errors.Is(err, e) {
+			return code
+		}
+	}
+	return ExcSlaveDeviceFailure
+}
+
+// maskRegister applies a mask write through h, atomically for a
+// *DataStore.
+func maskRegister(h Handler, unit byte, address, andMask, orMask uint16) error {
+	if store, ok := h.(*DataStore); ok {
+		return store.maskRegister(unit, address, andMask, orMask)
+	}
+	regs, err := h.ReadRegisters(unit, TableHoldingRegisters, address, 1)
+	if err != nil {
+		return err
+	}
+	if len(regs) != 1 {
+		return ErrorSlaveDeviceFailure
+	}
+	return h.WriteHoldingRegisters(unit, address, []uint16{regs[0]&andMask | orMask&^andMask})
+}
+
+// ReadBits implements Handler.
+func (s *DataStore) ReadBits(unit byte, table Table, address uint16, quantity int) ([]bool, error) {
+	return s.GetBits(table, address, quantity)
+}
+
+// ReadRegisters implements Handler.
+func (s *DataStore) ReadRegisters(unit byte, table Table, address uint16, quantity int) ([]uint16, error) {
+	return s.GetRegisters(table, address, quantity)
+}
+
+// WriteCoils implements Handler, the changes are published to the
+// watchers of the store.
+func (s *DataStore) WriteCoils(unit byte, address uint16, values []bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, err := s.setBits(TableCoils, address, values)
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		c := StoreChange{Unit: unit, Table: TableCoils, Address: address + uint16(i)}
+		if old[i] {
+			c.Old = 1
+		}
+		if v {
+			c.New = 1
+		}
+		s.publish(c)
+	}
+	return nil
+}
+
+// WriteHoldingRegisters implements Handler, the changes are published to
+// the watchers of the store.
+func (s *DataStore) WriteHoldingRegisters(unit byte, address uint16, values []uint16) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, err := s.setRegisters(TableHoldingRegisters, address, values)
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		s.publish(StoreChange{Unit: unit, Table: TableHoldingRegisters, Address: address + uint16(i), Old: old[i], New: v})
+	}
+	return nil
+}
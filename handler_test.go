@@ -0,0 +1,141 @@
+package modbustcp
+
+import (
+	"errors"
+	"testing"
+)
+
+// clockHandler computes input registers on demand and rejects writes.
+type clockHandler struct {
+	ticks uint16
+}
+
+func (h *clockHandler) ReadBits(unit byte, table Table, address uint16, quantity int) ([]bool, error) {
+	return nil, ErrorIllegalFunction
+}
+
+func (h *clockHandler) ReadRegisters(unit byte, table Table, address uint16, quantity int) ([]uint16, error) {
+	if table != TableInputRegisters || address != 0 || quantity != 1 {
+		return nil, ErrorIllegalDataAddress
+	}
+	h.ticks++
+	return []uint16{h.ticks + uint16(unit)*100}, nil
+}
+
+func (h *clockHandler) WriteCoils(unit byte, address uint16, values []bool) error {
+	return ErrorIllegalFunction
+}
+
+func (h *clockHandler) WriteHoldingRegisters(unit byte, address uint16, values []uint16) error {
+	return errors.New("read-only")
+}
+
+func TestServerHandler(t *testing.T) {
+	s := NewServer()
+	s.Handler = &clockHandler{}
+	s.HandleFunc(65, func(unit byte, data []byte) ([]byte, error) {
+		return append([]byte{unit}, data...), nil
+	})
+	c := startServer(t, s)
+	for i := uint16(1); i <= 2; i++ {
+		regs, err := c.ReadInputRegisters(0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if regs[0] != 100+i {
+			t.Fatalf("register expected %v, actual %v", 100+i, regs[0])
+		}
+	}
+	if _, err := c.ReadInputRegisters(1, 1); err != ErrorIllegalDataAddress {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+	if err := c.WriteSingleRegister(0, 1); err != ErrorSlaveDeviceFailure {
+		t.Fatalf("error expected %v, actual %v", ErrorSlaveDeviceFailure, err)
+	}
+	response, err := c.Execute(&Pdu{FunctionCode: 65, Data: []byte{7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Data) != 2 || response.Data[0] != 1 || response.Data[1] != 7 {
+		t.Fatalf("response expected [1 7], actual %v", response.Data)
+	}
+	if _, err = c.Execute(&Pdu{FunctionCode: 66}); err != ErrorIllegalFunction {
+		t.Fatalf("unregistered function expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+	s.HandleFunc(65, nil)
+	if _, err = c.Execute(&Pdu{FunctionCode: 65, Data: []byte{7}}); err != ErrorIllegalFunction {
+		t.Fatalf("removed function expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+}
+
+// shortHandler returns fewer registers than requested.
+type shortHandler struct {
+	clockHandler
+}
+
+func (h *shortHandler) ReadRegisters(unit byte, table Table, address uint16, quantity int) ([]uint16, error) {
+	return []uint16{}, nil
+}
+
+func TestServerHandlerShortRead(t *testing.T) {
+	s := NewServer()
+	s.Handler = &shortHandler{}
+	c := startServer(t, s)
+	if err := c.MaskWriteRegister(0, 0xff00, 0x0012); err != ErrorSlaveDeviceFailure {
+		t.Fatalf("mask write expected %v, actual %v", ErrorSlaveDeviceFailure, err)
+	}
+	if _, err := c.ReadHoldingRegisters(0, 2); err != ErrorSlaveDeviceFailure {
+		t.Fatalf("read expected %v, actual %v", ErrorSlaveDeviceFailure, err)
+	}
+}
+
+func TestHandleFuncInvalidCode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("exception function code expected to panic")
+		}
+	}()
+	NewServer().HandleFunc(0x83, func(unit byte, data []byte) ([]byte, error) { return nil, nil })
+}
+
+func TestServerDisableFunctions(t *testing.T) {
+	s := NewServer()
+	s.DisableFunctions(WriteFunctionCodes...)
+	c := startServer(t, s)
+	if err := c.WriteSingleRegister(0, 1); err != ErrorIllegalFunction {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+	if _, err := c.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	s.EnableFunctions(FunctionWriteSingleRegister)
+	if err := c.WriteSingleRegister(0, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// unitIdentifier identifies unit 2 as another device.
+type unitIdentifier struct {
+	*DataStore
+}
+
+func (h unitIdentifier) Identify(unit byte) (DeviceInfo, bool) {
+	return DeviceInfo{VendorName: "ACME", ProductCode: "U2", Revision: "2"}, unit == 2
+}
+
+func TestServerIdentifier(t *testing.T) {
+	s := NewServer()
+	s.Identity = DeviceInfo{VendorName: "ACME", ProductCode: "U1", Revision: "1"}
+	s.Handler = unitIdentifier{s.Store}
+	c := startServer(t, s)
+	for unit, code := range map[byte]string{1: "U1", 2: "U2"} {
+		c.SlaveId = unit
+		info, err := c.DeviceInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.ProductCode != code {
+			t.Fatalf("product code of unit %v expected %v, actual %v", unit, code, info.ProductCode)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package modbustcp
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Quality qualifies a historian sample.
+type Quality int
+
+const (
+	QualityGood Quality = iota
+	// QualityBad marks a sample without valid value, e.g. a failed poll.
+	QualityBad
+)
+
+func (q Quality) String() string {
+	if q == QualityGood {
+		return "good"
+	}
+	return "bad"
+}
+
+// Sample is a value of a tag recorded by a Historian.
+type Sample struct {
+	Time    time.Time
+	Value   float64
+	Quality Quality
+}
+
+// Aggregate summarizes the good samples of a time range.
+type Aggregate struct {
+	Count int
+	Min   float64
+	Max   float64
+	Avg   float64
+	First Sample
+	Last  Sample
+}
+
+// Historian keeps the last samples of each tag in memory, e.g. to bridge
+// uplink outages. Its Record method can be used as Handler of a Poller.
+type Historian struct {
+	size  int
+	mu    sync.Mutex
+	rings map[string]*ring
+}
+
+// ring is a circular buffer of samples ordered by insertion.
+type ring struct {
+	samples []Sample
+	start   int
+}
+
+func (r *ring) add(s Sample, size int) {
+	if len(r.samples) < size {
+		r.samples = append(r.samples, s)
+		return
+	}
+	r.samples[r.start] = s
+	r.start = (r.start + 1) % size
+}
+
+func (r *ring) at(i int) Sample {
+	return r.samples[(r.start+i)%len(r.samples)]
+}
+
+// NewHistorian creates a historian keeping the last size samples per tag.
+func NewHistorian(size int) *Historian {
+	if size < 1 {
+		size = 1
+	}
+	return &Historian{size: size, rings: make(map[string]*ring)}
+}
+
+// Record adds the value of an update as good sample.
+func (h *Historian) Record(u TagUpdate) {
+	h.Add(u.Tag, Sample{Time: u.Time, Value: u.Value, Quality: QualityGood})
+}
+
+// Add adds a sample of the named tag. Samples are expected in ascending
+// time order.
+func (h *Historian) Add(tag string, s Sample) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.rings[tag]
+	if !ok {
+		r = &ring{}
+		h.rings[tag] = r
+	}
+	r.add(s, h.size)
+}
+
+// Tags returns the names of the recorded tags in ascending order.
+func (h *Historian) Tags() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	names := make([]string, 0, len(h.rings))
+	for name := range h.rings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Query returns the samples of the named tag in [from, to). A zero from
+// or to leaves the range open.
+func (h *Historian) Query(tag string, from, to time.Time) []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.rings[tag]
+	if !ok {
+		return nil
+	}
+	var samples []Sample
+	for i := 0; i < len(r.samples); i++ {
+		s := r.at(i)
+		if (from.IsZero() || !s.Time.Before(from)) && (to.IsZero() || s.Time.Before(to)) {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// Aggregate summarizes the good samples of the named tag in [from, to),
+// ok is false if there are none.
+func (h *Historian) Aggregate(tag string, from, to time.Time) (a Aggregate, ok bool) {
+	var sum float64
+	for _, s := range h.Query(tag, from, to) {
+		if s.Quality != QualityGood {
+			continue
+		}
+		if a.Count == 0 {
+			a.Min, a.Max, a.First = s.Value, s.Value, s
+		}
+		if s.Value < a.Min {
+			a.Min = s.Value
+		}
+		if s.Value > a.Max {
+			a.Max = s.Value
+		}
+		a.Last = s
+		sum += s.Value
+		a.Count++
+	}
+	if a.Count == 0 {
+		return a, false
+	}
+	a.Avg = sum / float64(a.Count)
+	return a, true
+}
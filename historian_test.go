@@ -0,0 +1,26 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistorian(t *testing.T) {
+	h := NewHistorian(3)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, v := range []float64{1, 2, 3, 4} {
+		h.Record(TagUpdate{Tag: "temp", Time: base.Add(time.Duration(i) * time.Second), Reading: Reading{Value: v}})
+	}
+	h.Add("temp", Sample{Time: base.Add(4 * time.Second), Quality: QualityBad})
+	samples := h.Query("temp", time.Time{}, time.Time{})
+	if len(samples) != 3 || samples[0].Value != 3 || samples[2].Quality != QualityBad {
+		t.Fatalf("samples expected [3 4 bad], actual %v", samples)
+	}
+	a, ok := h.Aggregate("temp", base.Add(2*time.Second), base.Add(5*time.Second))
+	if !ok || a.Count != 2 || a.Min != 3 || a.Max != 4 || a.Avg != 3.5 || a.Last.Value != 4 {
+		t.Fatalf("aggregate expected 2 3 4 3.5, actual %+v", a)
+	}
+	if _, ok := h.Aggregate("flow", time.Time{}, time.Time{}); ok {
+		t.Fatal("aggregate of unknown tag expected none")
+	}
+}
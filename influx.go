@@ -0,0 +1,295 @@
+package modbustcp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	influxNameEscaper  = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxKeyEscaper   = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	influxValueEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// FormatInfluxLine formats a point in InfluxDB line protocol with a
+// nanosecond timestamp. Tags and fields are sorted by key, field values
+// may be float64, int64, bool or string. NaN and infinite values are not
+// representable and are left out.
+func FormatInfluxLine(measurement string, tags map[string]string, fields map[string]interface{}, t time.Time) string {
+	var b strings.Builder
+	b.WriteString(influxNameEscaper.Replace(measurement))
+	for _, k := range sortedKeys(tags) {
+		if tags[k] == "" {
+			continue
+		}
+		b.WriteString("," + influxKeyEscaper.Replace(k) + "=" + influxKeyEscaper.Replace(tags[k]))
+	}
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sep := byte(' ')
+	for _, k := range keys {
+		if v, ok := fields[k].(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			continue
+		}
+		b.WriteByte(sep)
+		sep = ','
+		b.WriteString(influxKeyEscaper.Replace(k) + "=")
+		switch v := fields[k].(type) {
+		case float64:
+			b.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		case int64:
+			b.WriteString(strconv.FormatInt(v, 10) + "i")
+		case bool:
+			b.WriteString(strconv.FormatBool(v))
+		default:
+			b.WriteString(`"` + influxValueEscaper.Replace(fmt.Sprint(v)) + `"`)
+		}
+	}
+	b.WriteString(" " + strconv.FormatInt(t.UnixNano(), 10))
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// InfluxWriter batches updates as InfluxDB line protocol and posts them to
+// the write endpoint of InfluxDB. Each update becomes a point of
+// Measurement tagged with the tag name, group and unit, with the value and
+// enumeration label as fields. Its Record method can be used as Handler
+// of a Poller, complete batches are written in the background.
+type InfluxWriter struct {
+	// URL of the write endpoint including the query, e.g.
+	// "http://localhost:8086/api/v2/write?org=o&bucket=b&precision=ns".
+	URL string
+	// Token is sent as "Token" authorization if set.
+	Token string
+	// Measurement of the points, "modbus" if empty.
+	Measurement string
+	// Tags are added to all points, e.g. the site.
+	Tags map[string]string
+	// BatchSize is the number of points triggering a write, 1000 if zero.
+	BatchSize int
+	// QueueSize is the number of complete batches waiting to be written,
+	// 10 if zero. Batches completed while the queue is full are dropped.
+	QueueSize int
+	// FlushInterval writes incomplete batches when started, 10s if zero.
+	FlushInterval time.Duration
+	// Retries of a failed write before the batch is dropped.
+	Retries int
+	// RetryDelay is the pause before a retry, doubled on each attempt.
+	RetryDelay time.Duration
+	// ErrorHandler is invoked for batches which could not be written and
+	// for points which were dropped.
+	ErrorHandler func(err error)
+	// HTTPClient posts the batches, a client with a timeout of 10s if nil.
+	HTTPClient *http.Client
+
+	mu    sync.Mutex
+	lines []string
+	stop  chan struct{}
+	wg    sync.WaitGroup
+	// queue passes complete batches to the writing goroutine, nil while
+	// it is not running
+	queue   chan []string
+	written sync.WaitGroup
+}
+
+var influxClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewInfluxWriter creates a writer posting to url.
+func NewInfluxWriter(url string) *InfluxWriter {
+	return &InfluxWriter{URL: url, Retries: 3, RetryDelay: time.Second}
+}
+
+// Line formats u as line protocol.
+func (w *InfluxWriter) Line(u TagUpdate) string {
+	measurement := w.Measurement
+	if measurement == "" {
+		measurement = "modbus"
+	}
+	tags := map[string]string{"tag": u.Tag, "group": u.Group, "unit": u.Unit}
+	for k, v := range w.Tags {
+		tags[k] = v
+	}
+	fields := map[string]interface{}{"value": u.Value}
+	if u.Label != "" {
+		fields["label"] = u.Label
+	}
+	return FormatInfluxLine(measurement, tags, fields, u.Time)
+}
+
+// Record queues u and passes the batch to the writing goroutine once it
+// is complete, without waiting for the write. NaN and infinite values
+// cannot be written and are reported as dropped.
+func (w *InfluxWriter) Record(u TagUpdate) {
+	if math.IsNaN(u.Value) || math.IsInf(u.Value, 0) {
+		w.report(fmt.Errorf("modbus: influx dropped value '%v' of tag '%v'", u.Value, u.Tag))
+		return
+	}
+	size := w.BatchSize
+	if size <= 0 {
+		size = 1000
+	}
+	w.mu.Lock()
+	w.lines = append(w.lines, w.Line(u))
+	if len(w.lines) < size {
+		w.mu.Unlock()
+		return
+	}
+	if w.queue == nil {
+		queueSize := w.QueueSize
+		if queueSize <= 0 {
+			queueSize = 10
+		}
+		w.queue = make(chan []string, queueSize)
+		w.written.Add(1)
+		go w.write(w.queue)
+	}
+	var err error
+	select {
+	case w.queue <- w.lines:
+	default:
+		err = fmt.Errorf("modbus: influx queue full, dropped %v points", len(w.lines))
+	}
+	w.lines = nil
+	w.mu.Unlock()
+	w.report(err)
+}
+
+// write writes the batches received from queue until it is closed.
+func (w *InfluxWriter) write(queue chan []string) {
+	defer w.written.Done()
+	for lines := range queue {
+		w.report(w.post(lines))
+	}
+}
+
+// Flush writes the points of the incomplete batch.
+func (w *InfluxWriter) Flush() error {
+	w.mu.Lock()
+	lines := w.lines
+	w.lines = nil
+	w.mu.Unlock()
+	return w.post(lines)
+}
+
+// post writes lines, retrying failures.
+func (w *InfluxWriter) post(lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	body := []byte(strings.Join(lines, "\n") + "\n")
+	delay := w.RetryDelay
+	var err error
+	for attempt := 0; attempt <= w.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		var retry bool
+		if retry, err = w.send(body); err == nil || !retry {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("modbus: influx write of %v points: %v", len(lines), err)
+	}
+	return nil
+}
+
+// send posts body and reports whether a failure may be retried.
+func (w *InfluxWriter) send(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.Token != "" {
+		req.Header.Set("Authorization", "Token "+w.Token)
+	}
+	client := w.HTTPClient
+	if client == nil {
+		client = influxClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("status '%v': %s", resp.Status, bytes.TrimSpace(msg))
+}
+
+func (w *InfluxWriter) report(err error) {
+	if err != nil && w.ErrorHandler != nil {
+		w.ErrorHandler(err)
+	}
+}
+
+// Start flushes incomplete batches every FlushInterval.
+func (w *InfluxWriter) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return fmt.Errorf("modbus: influx writer already started")
+	}
+	interval := w.FlushInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	w.stop = make(chan struct{})
+	w.wg.Add(1)
+	go func(stop chan struct{}) {
+		defer w.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.report(w.Flush())
+			}
+		}
+	}(w.stop)
+	return nil
+}
+
+// Stop ends the periodic flushing, waits for the complete batches to be
+// written and writes the queued points.
+func (w *InfluxWriter) Stop() error {
+	w.mu.Lock()
+	if w.stop != nil {
+		close(w.stop)
+		w.stop = nil
+	}
+	if w.queue != nil {
+		close(w.queue)
+		w.queue = nil
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+	w.written.Wait()
+	return w.Flush()
+}
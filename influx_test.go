@@ -0,0 +1,94 @@
+package modbustcp
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatInfluxLine(t *testing.T) {
+	line := FormatInfluxLine("my meter",
+		map[string]string{"tag": "a,b", "site": "x=y", "empty": ""},
+		map[string]interface{}{"value": 1.5, "label": `say "hi"`, "count": int64(3), "nan": math.NaN()},
+		time.Unix(1, 5))
+	expected := `my\ meter,site=x\=y,tag=a\,b count=3i,label="say \"hi\"",value=1.5 1000000005`
+	if line != expected {
+		t.Fatalf("line expected %v, actual %v", expected, line)
+	}
+}
+
+func TestInfluxWriter(t *testing.T) {
+	var attempts int
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		if r.Header.Get("Authorization") != "Token secret" {
+			t.Errorf("authorization expected Token secret, actual %v", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	w := NewInfluxWriter(server.URL)
+	w.Token = "secret"
+	w.BatchSize = 2
+	w.RetryDelay = time.Millisecond
+	w.ErrorHandler = func(err error) { t.Error(err) }
+	w.Record(TagUpdate{Tag: "temp", Time: time.Unix(1, 0), Reading: Reading{Value: 20, Unit: "C"}})
+	w.Record(TagUpdate{Tag: "mode", Time: time.Unix(2, 0), Reading: Reading{Value: 1, Label: "auto"}})
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "modbus,tag=temp,unit=C value=20 1000000000\nmodbus,tag=mode label=\"auto\",value=1 2000000000\n"
+	if attempts != 2 || body != expected {
+		t.Fatalf("body expected %q after 2 attempts, actual %q after %v", expected, body, attempts)
+	}
+}
+
+func TestInfluxWriterQueue(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	w := NewInfluxWriter(server.URL)
+	w.BatchSize = 1
+	w.QueueSize = 1
+	errs := make(chan error, 10)
+	w.ErrorHandler = func(err error) { errs <- err }
+	w.Record(TagUpdate{Tag: "nan", Reading: Reading{Value: math.NaN()}})
+	if err := <-errs; !strings.Contains(err.Error(), "nan") {
+		t.Fatalf("dropped NaN value expected to be reported, actual %v", err)
+	}
+	start := time.Now()
+	// the first batch is written, the second queued and the third dropped
+	for i := 0; i < 3; i++ {
+		w.Record(TagUpdate{Tag: "temp", Reading: Reading{Value: float64(i)}})
+		if i == 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("record expected not to wait for the write, took %v", elapsed)
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "dropped 1 points") {
+		t.Fatalf("dropped batch expected to be reported, actual %v", err)
+	}
+	close(release)
+	if err := w.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 0 {
+		t.Fatalf("unexpected error %v", <-errs)
+	}
+}
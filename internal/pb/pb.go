@@ -0,0 +1,119 @@
+// Package pb encodes and decodes the protocol buffers wire format for the
+// hand-written messages of this module, avoiding a dependency on a
+// protobuf runtime.
+package pb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Wire types
+const (
+	Varint  = 0
+	Fixed64 = 1
+	Bytes   = 2
+	Fixed32 = 5
+)
+
+// ErrorTruncated is returned for messages ending within a field.
+var ErrorTruncated = errors.New("pb: truncated message")
+
+// AppendVarint appends a varint field.
+func AppendVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|Varint)
+	return binary.AppendUvarint(b, v)
+}
+
+// AppendBool appends a bool field.
+func AppendBool(b []byte, field int, v bool) []byte {
+	if v {
+		return AppendVarint(b, field, 1)
+	}
+	return AppendVarint(b, field, 0)
+}
+
+// AppendDouble appends a double field.
+func AppendDouble(b []byte, field int, v float64) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|Fixed64)
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+}
+
+// AppendFloat appends a float field.
+func AppendFloat(b []byte, field int, v float32) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|Fixed32)
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+}
+
+// AppendBytes appends a length-delimited field, e.g. a string or an
+// embedded message.
+func AppendBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|Bytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// AppendString appends a string field.
+func AppendString(b []byte, field int, v string) []byte {
+	return AppendBytes(b, field, []byte(v))
+}
+
+// Field is a decoded field. Value holds varints and the bits of fixed
+// size fields, Data the content of length-delimited fields.
+type Field struct {
+	Number int
+	Wire   int
+	Value  uint64
+	Data   []byte
+}
+
+// Double interprets a fixed64 field as double.
+func (f Field) Double() float64 {
+	return math.Float64frombits(f.Value)
+}
+
+// Float interprets a fixed32 field as float.
+func (f Field) Float() float32 {
+	return math.Float32frombits(uint32(f.Value))
+}
+
+// Fields decodes the fields of a message in order of appearance.
+func Fields(b []byte) ([]Field, error) {
+	var fields []Field
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, ErrorTruncated
+		}
+		b = b[n:]
+		f := Field{Number: int(key >> 3), Wire: int(key & 7)}
+		switch f.Wire {
+		case Varint:
+			if f.Value, n = binary.Uvarint(b); n <= 0 {
+				return nil, ErrorTruncated
+			}
+			b = b[n:]
+		case Fixed64:
+			if len(b) < 8 {
+				return nil, ErrorTruncated
+			}
+			f.Value, b = binary.LittleEndian.Uint64(b), b[8:]
+		case Fixed32:
+			if len(b) < 4 {
+				return nil, ErrorTruncated
+			}
+			f.Value, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		case Bytes:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, ErrorTruncated
+			}
+			f.Data, b = b[n:n+int(length)], b[n+int(length):]
+		default:
+			return nil, errors.New("pb: unsupported wire type")
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
@@ -0,0 +1,28 @@
+package pb
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var b []byte
+	b = AppendVarint(b, 1, 150)
+	b = AppendString(b, 2, "testing")
+	b = AppendDouble(b, 13, 2.5)
+	b = AppendFloat(b, 12, 1.5)
+	if !bytes.Equal(b[:3], []byte{0x08, 0x96, 0x01}) {
+		t.Fatalf("varint field expected 08 96 01, actual % x", b[:3])
+	}
+	fields, err := Fields(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 4 || fields[0].Value != 150 || string(fields[1].Data) != "testing" ||
+		fields[2].Double() != 2.5 || fields[3].Float() != 1.5 {
+		t.Fatalf("fields unexpected %+v", fields)
+	}
+	if _, err = Fields(b[:len(b)-1]); err != ErrorTruncated {
+		t.Fatalf("error expected %v, actual %v", ErrorTruncated, err)
+	}
+}
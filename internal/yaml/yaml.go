@@ -0,0 +1,496 @@
+// Package yaml decodes the subset of YAML used by configuration files of
+// this module: block mappings and sequences, flow collections, plain and
+// quoted scalars and comments. Anchors, tags, multi-line scalars and
+// multiple documents are not supported.
+//
+// Decoded documents are mapped onto Go values through encoding/json, so
+// targets are annotated with json struct tags. Plain scalars looking like
+// numbers or booleans decode into string targets with their text, e.g.
+// version: 1.10 as "1.10".
+package yaml
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type line struct {
+	number int
+	indent int
+	text   string
+}
+
+type parser struct {
+	lines []line
+	pos   int
+}
+
+// plain is a plain scalar resolved to a number or boolean, which keeps
+// its text for string targets.
+type plain struct {
+	text  string
+	value interface{}
+}
+
+var (
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Unmarshal decodes the YAML document data into v.
+func Unmarshal(data []byte, v interface{}) error {
+	doc, err := parse(data)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(convert(doc, reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Parse decodes the YAML document data into maps, slices and scalars.
+func Parse(data []byte) (interface{}, error) {
+	doc, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return resolve(doc), nil
+}
+
+// resolve replaces the plain scalars of doc by their values.
+func resolve(doc interface{}) interface{} {
+	return convert(doc, nil)
+}
+
+// convert replaces the plain scalars of doc by their text if they are
+// decoded into a string of the target type t, by their values otherwise.
+// A nil t resolves all of them to values.
+func convert(doc interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && reflect.PointerTo(t).Implements(unmarshalerType) {
+		// the type decodes the value itself
+		t = nil
+	}
+	switch doc := doc.(type) {
+	case plain:
+		if t != nil && (t.Kind() == reflect.String || reflect.PointerTo(t).Implements(textUnmarshalerType)) {
+			return doc.text
+		}
+		return doc.value
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(doc))
+		for k, v := range doc {
+			var elem reflect.Type
+			switch {
+			case t == nil:
+			case t.Kind() == reflect.Map:
+				elem = t.Elem()
+			case t.Kind() == reflect.Struct:
+				elem = fieldType(t, k)
+			}
+			m[k] = convert(v, elem)
+		}
+		return m
+	case []interface{}:
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		items := make([]interface{}, len(doc))
+		for i, v := range doc {
+			items[i] = convert(v, elem)
+		}
+		return items
+	}
+	return doc
+}
+
+// fieldType returns the type of the field of the struct type t which
+// encoding/json decodes the key into, nil if there is none.
+func fieldType(t reflect.Type, key string) reflect.Type {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if ft := fieldType(embedded, key); ft != nil {
+					return ft
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if strings.EqualFold(name, key) {
+			return f.Type
+		}
+	}
+	return nil
+}
+
+func parse(data []byte) (interface{}, error) {
+	p := &parser{}
+	for i, text := range strings.Split(string(data), "\n") {
+		text = strings.TrimRight(stripComment(text), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, line{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+	v, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		return nil, fmt.Errorf("yaml: line %d: unexpected indentation", l.number)
+	}
+	return v, nil
+}
+
+// stripComment removes a comment starting with '#' outside of quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *parser) block(indent int) (interface{}, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *parser) sequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) {
+		l := &p.lines[p.pos]
+		if l.indent != indent || !isSequenceItem(l.text) {
+			break
+		}
+		content := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if content == "" {
+			p.pos++
+			item, err := p.nested(indent, false)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		if _, _, ok := splitKey(content); ok || isSequenceItem(content) {
+			// the item is a block collection starting on the same line
+			l.indent += len(l.text) - len(content)
+			l.text = content
+			item, err := p.block(l.indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		item, err := value(content, l.number)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.pos++
+	}
+	return items, nil
+}
+
+func (p *parser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent != indent || isSequenceItem(l.text) {
+			break
+		}
+		key, rest, ok := splitKey(l.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: expected 'key: value'", l.number)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("yaml: line %d: duplicate key '%v'", l.number, key)
+		}
+		p.pos++
+		if rest == "" {
+			v, err := p.nested(indent, true)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+		v, err := value(rest, l.number)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// nested parses the block following a key or sequence item without inline
+// value. Sequences may be indented at the level of their mapping key.
+func (p *parser) nested(indent int, allowSameIndent bool) (interface{}, error) {
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	if next.indent > indent || (allowSameIndent && next.indent == indent && isSequenceItem(next.text)) {
+		return p.block(next.indent)
+	}
+	return nil, nil
+}
+
+// splitKey splits "key: value" outside of quotes and flow collections.
+func splitKey(s string) (string, string, bool) {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ':' && depth == 0 && (i+1 == len(s) || s[i+1] == ' '):
+			key := strings.TrimSpace(s[:i])
+			if k, err := unquote(key); err == nil {
+				key = k
+			}
+			return key, strings.TrimSpace(s[i+1:]), key != ""
+		}
+	}
+	return "", "", false
+}
+
+func value(s string, number int) (interface{}, error) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		f := &flow{s: s}
+		v, err := f.value()
+		if err == nil {
+			f.skipSpace()
+			if f.pos != len(f.s) {
+				err = fmt.Errorf("unexpected '%v'", f.s[f.pos:])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: %v", number, err)
+		}
+		return v, nil
+	}
+	if s == "|" || s == ">" || strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*") || strings.HasPrefix(s, "!") {
+		return nil, fmt.Errorf("yaml: line %d: unsupported syntax '%v'", number, s)
+	}
+	v, err := scalar(s)
+	if err != nil {
+		return nil, fmt.Errorf("yaml: line %d: %v", number, err)
+	}
+	return v, nil
+}
+
+func unquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, fmt.Errorf("not quoted")
+}
+
+// scalar resolves a plain or quoted scalar.
+func scalar(s string) (interface{}, error) {
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		v, err := unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %v", s)
+		}
+		return v, nil
+	}
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return plain{text: s, value: true}, nil
+	case "false", "False", "FALSE":
+		return plain{text: s, value: false}, nil
+	}
+	if len(s) > 1 && s[0] == '0' && strings.Trim(s, "0123456789") == "" {
+		// leading zeros are significant, e.g. in the Modicon address
+		// 00123, and not octal
+		return s, nil
+	}
+	if i, ok := integer(s); ok {
+		return plain{text: s, value: i}, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "_xX") {
+		return plain{text: s, value: f}, nil
+	}
+	return s, nil
+}
+
+// integer parses a decimal or 0x prefixed hexadecimal integer.
+func integer(s string) (int64, bool) {
+	base, digits := 10, s
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		base, digits = 16, s[2:]
+	}
+	if base == 16 && (digits == "" || digits[0] == '+' || digits[0] == '-') {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(digits, base, 64)
+	return i, err == nil
+}
+
+// flow parses flow collections like [1, 2] and {a: 1, b: [x]}.
+type flow struct {
+	s   string
+	pos int
+}
+
+func (f *flow) skipSpace() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *flow) value() (interface{}, error) {
+	f.skipSpace()
+	if f.pos >= len(f.s) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		items := []interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return items, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := map[string]interface{}{}
+		for {
+			f.skipSpace()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return m, nil
+			}
+			k, err := f.token(":")
+			if err != nil {
+				return nil, err
+			}
+			key, _ := scalar(k)
+			key = resolve(key)
+			f.pos++
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(key)] = v
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	t, err := f.token(",]}")
+	if err != nil {
+		return nil, err
+	}
+	return scalar(t)
+}
+
+// separator consumes a ',' or peeks the closing delimiter.
+func (f *flow) separator(end byte) error {
+	f.skipSpace()
+	if f.pos < len(f.s) && f.s[f.pos] == ',' {
+		f.pos++
+		return nil
+	}
+	if f.pos < len(f.s) && f.s[f.pos] == end {
+		return nil
+	}
+	return fmt.Errorf("expected ',' or '%c'", end)
+}
+
+// token reads a scalar up to one of the delimiters outside of quotes.
+func (f *flow) token(delims string) (string, error) {
+	start := f.pos
+	var quote byte
+	for ; f.pos < len(f.s); f.pos++ {
+		c := f.s[f.pos]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.IndexByte(delims, c) >= 0:
+			return strings.TrimSpace(f.s[start:f.pos]), nil
+		}
+	}
+	return "", fmt.Errorf("unterminated flow collection")
+}
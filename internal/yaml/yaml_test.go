@@ -0,0 +1,95 @@
+package yaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc := `
+# device template
+name: SDM630
+version: "1.2"
+tags:
+- name: voltage # comment
+  address: 30001
+  coil: 00123
+  mask: 0xff
+  gain: 0.1
+  enum: {0: Off, 1: "On"}
+- name: 'it''s'
+  flags: [a, 2, true, 08, -017, 0o17]
+nested:
+  list:
+    - x
+    -
+      y: null
+`
+	v, err := Parse([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name":    "SDM630",
+		"version": "1.2",
+		"tags": []interface{}{
+			map[string]interface{}{
+				"name":    "voltage",
+				"address": int64(30001),
+				"coil":    "00123",
+				"mask":    int64(255),
+				"gain":    0.1,
+				"enum":    map[string]interface{}{"0": "Off", "1": "On"},
+			},
+			map[string]interface{}{
+				"name":  "it's",
+				"flags": []interface{}{"a", int64(2), true, "08", int64(-17), "0o17"},
+			},
+		},
+		"nested": map[string]interface{}{
+			"list": []interface{}{"x", map[string]interface{}{"y": nil}},
+		},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("document expected %#v, actual %#v", expected, v)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	var v struct {
+		Name  string    `json:"name"`
+		Gains []float64 `json:"gains"`
+	}
+	if err := Unmarshal([]byte("name: x\ngains: [1, 0.5]\n"), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "x" || len(v.Gains) != 2 || v.Gains[1] != 0.5 {
+		t.Fatalf("unexpected value %+v", v)
+	}
+	var profile struct {
+		Name    string            `json:"name"`
+		Version *string           `json:"version"`
+		Port    int               `json:"port"`
+		Labels  map[string]string `json:"labels"`
+		Tags    []struct {
+			Name string      `json:"name"`
+			Any  interface{} `json:"any"`
+		} `json:"tags"`
+	}
+	doc := "name: 1234\nversion: 1.10\nport: 502\nlabels: {0: 0x10, on: true}\ntags:\n- name: 7\n  any: 1.10\n"
+	if err := Unmarshal([]byte(doc), &profile); err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "1234" || *profile.Version != "1.10" || profile.Port != 502 {
+		t.Fatalf("unexpected value %+v", profile)
+	}
+	if profile.Labels["0"] != "0x10" || profile.Labels["on"] != "true" {
+		t.Fatalf("labels expected their text, actual %v", profile.Labels)
+	}
+	if profile.Tags[0].Name != "7" || profile.Tags[0].Any != 1.1 {
+		t.Fatalf("unexpected tag %+v", profile.Tags[0])
+	}
+	if _, err := Parse([]byte("a: 1\n  b: 2\n")); err == nil {
+		t.Fatal("error expected for unexpected indentation")
+	}
+}
@@ -0,0 +1,277 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ManagedDevice is a device polled by a Manager.
+type ManagedDevice struct {
+	Name   string
+	Client *ModbusTcpClient
+	Groups []PollGroup
+}
+
+// DeviceStatus aggregates the poll status of the groups of a device.
+type DeviceStatus struct {
+	Name      string
+	Connected bool
+	LastPoll  time.Time
+	LastError error
+	Polls     uint64
+	Errors    uint64
+	// Skipped counts polls dropped because the previous poll of the
+	// group had not completed yet.
+	Skipped uint64
+}
+
+type managedDevice struct {
+	ManagedDevice
+	poller  *Poller
+	connMu  sync.Mutex
+	mu      sync.Mutex
+	busy    map[string]bool
+	skipped uint64
+}
+
+type pollJob struct {
+	device *managedDevice
+	group  *PollGroup
+}
+
+// Manager polls many devices on a bounded pool of workers. Each device
+// keeps its own connection, which is reestablished by the next poll
+// after a failure.
+type Manager struct {
+	// Workers limits the concurrent polls, 8 if zero.
+	Workers int
+	// Handler receives the updates of all devices.
+	Handler func(device string, u TagUpdate)
+	// ErrorHandler is invoked for each failed poll.
+	ErrorHandler func(device, group string, err error)
+	// Plan and ChangeOnly apply to all devices, see Poller.
+	Plan       PlanOptions
+	ChangeOnly bool
+
+	mu      sync.Mutex
+	devices map[string]*managedDevice
+	jobs    chan pollJob
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewManager creates a manager polling on workers goroutines.
+func NewManager(workers int) *Manager {
+	return &Manager{Workers: workers, devices: make(map[string]*managedDevice)}
+}
+
+// Add registers a device, its groups are scheduled immediately if the
+// manager is running.
+func (m *Manager) Add(d ManagedDevice) error {
+	if d.Name == "" || d.Client == nil {
+		return fmt.Errorf("modbus: managed device needs a name and a client")
+	}
+	for _, g := range d.Groups {
+		if g.Interval <= 0 {
+			return fmt.Errorf("modbus: poll group '%v' of device '%v' has no interval", g.Name, d.Name)
+		}
+	}
+	md := &managedDevice{ManagedDevice: d, busy: make(map[string]bool)}
+	p := NewPoller(d.Client, d.Groups...)
+	p.Plan, p.ChangeOnly = m.Plan, m.ChangeOnly
+	p.Handler = func(u TagUpdate) {
+		if m.Handler != nil {
+			m.Handler(d.Name, u)
+		}
+	}
+	p.ErrorHandler = func(group string, err error) {
+		if m.ErrorHandler != nil {
+			m.ErrorHandler(d.Name, group, err)
+		}
+	}
+	for _, g := range p.groups {
+		p.status[g.Name] = &GroupStatus{}
+	}
+	md.poller = p
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.devices[d.Name]; ok {
+		return fmt.Errorf("modbus: duplicate device '%v'", d.Name)
+	}
+	m.devices[d.Name] = md
+	if m.stop != nil {
+		m.schedule(md)
+	}
+	return nil
+}
+
+// Device returns the client of the named device.
+func (m *Manager) Device(name string) (*ModbusTcpClient, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	d, ok := m.devices[name]
+	if !ok {
+		return nil, false
+	}
+	return d.Client, true
+}
+
+// Names returns the device names in ascending order.
+func (m *Manager) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.devices))
+	for name := range m.devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Status returns the status of all devices ordered by name.
+func (m *Manager) Status() []DeviceStatus {
+	var status []DeviceStatus
+	for _, name := range m.Names() {
+		m.mu.Lock()
+		d := m.devices[name]
+		m.mu.Unlock()
+		s := DeviceStatus{Name: name}
+		d.connMu.Lock()
+		s.Connected = d.Client.Conn != nil
+		d.connMu.Unlock()
+		d.mu.Lock()
+		s.Skipped = d.skipped
+		d.mu.Unlock()
+		d.poller.mu.Lock()
+		for _, g := range d.poller.status {
+			s.Polls += g.Polls
+			s.Errors += g.Errors
+			if g.LastPoll.After(s.LastPoll) {
+				s.LastPoll = g.LastPoll
+				s.LastError = g.LastError
+			}
+		}
+		d.poller.mu.Unlock()
+		status = append(status, s)
+	}
+	return status
+}
+
+// Start begins polling all devices.
+func (m *Manager) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		return fmt.Errorf("modbus: manager already started")
+	}
+	workers := m.Workers
+	if workers <= 0 {
+		workers = 8
+	}
+	m.stop = make(chan struct{})
+	m.jobs = make(chan pollJob)
+	for i := 0; i < workers; i++ {
+		m.wg.Add(1)
+		go m.work(m.jobs)
+	}
+	for _, d := range m.devices {
+		m.schedule(d)
+	}
+	return nil
+}
+
+// Stop ends polling, waits for running polls and closes the connections.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	if m.stop == nil {
+		m.mu.Unlock()
+		return
+	}
+	close(m.stop)
+	m.mu.Unlock()
+	m.wg.Wait()
+	m.mu.Lock()
+	m.stop = nil
+	for _, d := range m.devices {
+		d.connMu.Lock()
+		d.Client.Disconnect()
+		d.connMu.Unlock()
+	}
+	m.mu.Unlock()
+}
+
+// schedule starts a goroutine per group of d queueing its polls, the
+// caller holds mu.
+func (m *Manager) schedule(d *managedDevice) {
+	for i := range d.poller.groups {
+		m.wg.Add(1)
+		go m.tick(d, &d.poller.groups[i], m.stop, m.jobs)
+	}
+}
+
+func (m *Manager) tick(d *managedDevice, g *PollGroup, stop chan struct{}, jobs chan pollJob) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+	for {
+		d.mu.Lock()
+		busy := d.busy[g.Name]
+		if busy {
+			d.skipped++
+		} else {
+			d.busy[g.Name] = true
+		}
+		d.mu.Unlock()
+		if !busy {
+			select {
+			case jobs <- pollJob{d, g}:
+			case <-stop:
+				return
+			}
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Manager) work(jobs chan pollJob) {
+	defer m.wg.Done()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case job := <-jobs:
+			m.poll(job)
+		}
+	}
+}
+
+func (m *Manager) poll(job pollJob) {
+	d := job.device
+	defer func() {
+		d.mu.Lock()
+		d.busy[job.group.Name] = false
+		d.mu.Unlock()
+	}()
+	d.connMu.Lock()
+	if d.Client.Conn == nil {
+		if err := d.Client.Connect(); err != nil {
+			d.Client.Conn = nil
+			d.connMu.Unlock()
+			d.poller.fail(job.group, err)
+			return
+		}
+	}
+	d.connMu.Unlock()
+	d.poller.poll(job.group)
+	if s, _ := d.poller.Status(job.group.Name); s.LastError != nil && !IsException(s.LastError) {
+		d.connMu.Lock()
+		d.Client.Disconnect()
+		d.connMu.Unlock()
+	}
+}
@@ -0,0 +1,51 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	m := NewManager(2)
+	updates := make(chan string, 16)
+	m.Handler = func(device string, u TagUpdate) {
+		select {
+		case updates <- device + "/" + u.Tag:
+		default:
+		}
+	}
+	for _, name := range []string{"pump", "boiler"} {
+		c := newTestClient(t, func(request *Pdu) *Pdu {
+			return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0x00, 0x2A}}
+		})
+		err := m.Add(ManagedDevice{Name: name, Client: c, Groups: []PollGroup{{
+			Name:     "fast",
+			Interval: 10 * time.Millisecond,
+			Tags:     []Tag{{Name: "level", Table: TableHoldingRegisters, Address: 1}},
+		}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Add(ManagedDevice{Name: "pump", Client: NewModbusTcpClient("localhost", 502)}); err == nil {
+		t.Fatal("duplicate device expected to fail")
+	}
+	if _, ok := m.Device("boiler"); !ok {
+		t.Fatal("device boiler expected")
+	}
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for len(seen) < 2 {
+		seen[<-updates] = true
+	}
+	m.Stop()
+	if !seen["pump/level"] || !seen["boiler/level"] {
+		t.Fatalf("updates expected pump/level and boiler/level, actual %v", seen)
+	}
+	status := m.Status()
+	if len(status) != 2 || status[0].Name != "boiler" || status[0].Polls == 0 || status[0].Connected {
+		t.Fatalf("status expected polled and disconnected boiler first, actual %+v", status)
+	}
+}
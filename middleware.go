@@ -0,0 +1,53 @@
+package modbustcp
+
+import (
+	"net"
+)
+
+// Request is a request received by a Server.
+type Request struct {
+	Unit       byte
+	Pdu        *Pdu
+	RemoteAddr net.Addr
+}
+
+// RequestHandler answers a request with a response or exception pdu, or
+// nil to send no response.
+type RequestHandler func(r *Request) *Pdu
+
+// Middleware wraps the handling of requests, e.g. to log or count them,
+// to modify requests or responses, or to reject requests by returning
+// an exception without calling next.
+type Middleware func(next RequestHandler) RequestHandler
+
+// Use appends middleware to the chain wrapping the request handling, the
+// first middleware added sees the requests first.
+func (s *Server) Use(middleware ...Middleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// Exception creates the exception response to request.
+func Exception(request *Pdu, code byte) *Pdu {
+	return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{code}}
+}
+
+// serveRequest passes r through the middleware chain to the handling of
+// the server.
+func (s *Server) serveRequest(sess *session, r *Request) *Pdu {
+	h := func(r *Request) *Pdu {
+		return s.handle(sess, r.Unit, r.Pdu)
+	}
+	s.mu.Lock()
+	chain := s.middleware
+	s.mu.Unlock()
+	for i := len(chain) - 1; i >= 0; i-- {
+		h = chain[i](h)
+	}
+	response := h(r)
+	if s.Audit != nil && isWriteFunction(r.Pdu.FunctionCode) {
+		s.audit(r, response)
+	}
+	return response
+}
@@ -0,0 +1,40 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestServerMiddleware(t *testing.T) {
+	s := NewServer()
+	var order []string
+	var responses []byte
+	s.Use(func(next RequestHandler) RequestHandler {
+		return func(r *Request) *Pdu {
+			order = append(order, "log")
+			response := next(r)
+			responses = append(responses, response.FunctionCode)
+			return response
+		}
+	}, func(next RequestHandler) RequestHandler {
+		return func(r *Request) *Pdu {
+			order = append(order, "auth")
+			if r.Pdu.FunctionCode == FunctionWriteSingleRegister && r.RemoteAddr != nil {
+				return Exception(r.Pdu, ExcIllegalFunction)
+			}
+			return next(r)
+		}
+	})
+	c := startServer(t, s)
+	if err := c.WriteSingleRegister(0, 1); err != ErrorIllegalFunction {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+	if _, err := c.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 4 || order[0] != "log" || order[1] != "auth" {
+		t.Fatalf("order expected [log auth log auth], actual %v", order)
+	}
+	if len(responses) != 2 || responses[0] != FunctionWriteSingleRegister|ExcExceptionOffset || responses[1] != FunctionReadHoldingRegister {
+		t.Fatalf("responses expected [134 3], actual %v", responses)
+	}
+}
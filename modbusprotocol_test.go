@@ -1,6 +1,9 @@
 package modbustcp
 
 import (
+	"bytes"
+	"io"
+	"math/rand"
 	"testing"
 )
 
@@ -13,3 +16,73 @@ func TestCRC(t *testing.T) {
 		t.Fatalf("crc expected %v, actual %v", 0x4112, crc.Value())
 	}
 }
+
+// bitwiseCRC computes the crc bit by bit as in the specification.
+func bitwiseCRC(data []byte) uint16 {
+	v := uint16(0xffff)
+	for _, b := range data {
+		v ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if v&1 != 0 {
+				v = v>>1 ^ 0xa001
+			} else {
+				v >>= 1
+			}
+		}
+	}
+	// transmitted low byte first
+	return v<<8 | v>>8
+}
+
+func TestCRCHash(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	for _, n := range []int{0, 1, 7, 256, 1000} {
+		if v, expected := NewCRC().PushBytes(data[:n]).Value(), bitwiseCRC(data[:n]); v != expected {
+			t.Fatalf("crc of %v bytes expected %04x, actual %04x", n, expected, v)
+		}
+	}
+	// streamed in chunks
+	h := NewCRC16Hash()
+	if _, err := io.CopyBuffer(h, bytes.NewReader(data), make([]byte, 33)); err != nil {
+		t.Fatal(err)
+	}
+	sum := h.Sum([]byte{0xaa})
+	if expected := bitwiseCRC(data); len(sum) != 1+h.Size() || uint16(sum[1])<<8|uint16(sum[2]) != expected {
+		t.Fatalf("sum expected aa %04x, actual % x", expected, sum)
+	}
+	h.Reset()
+	if sum = h.Sum(nil); sum[0] != 0xff || sum[1] != 0xff {
+		t.Fatalf("initial sum expected ff ff, actual % x", sum)
+	}
+	crc := NewCRC().PushBytes(data)
+	if v, expected := crc.Reset().PushBytes(data[:7]).Value(), bitwiseCRC(data[:7]); v != expected {
+		t.Fatalf("crc after reset expected %04x, actual %04x", expected, v)
+	}
+}
+
+func TestLRC(t *testing.T) {
+	var lrc LRC
+	// the frame of the request ":010100000003FB"
+	if v := lrc.PushBytes([]byte{0x01, 0x01}).PushBytes([]byte{0, 0, 0, 3}).Value(); v != 0xfb {
+		t.Fatalf("lrc expected fb, actual %02x", v)
+	}
+	if _, err := io.WriteString(&lrc, "\x05"); err != nil {
+		t.Fatal(err)
+	}
+	if sum := lrc.Sum(nil); len(sum) != lrc.Size() || sum[0] != 0xf6 {
+		t.Fatalf("sum expected f6, actual % x", sum)
+	}
+	lrc.Reset()
+	if v := lrc.PushBytes([]byte{0x01, 0x05, 0x00, 0x01, 0xff, 0x00}).Value(); v != 0xfa {
+		t.Fatalf("lrc expected fa, actual %02x", v)
+	}
+}
+
+func BenchmarkCRC(b *testing.B) {
+	frame := make([]byte, MaxSerialAdu-2)
+	b.SetBytes(int64(len(frame)))
+	for i := 0; i < b.N; i++ {
+		NewCRC().PushBytes(frame)
+	}
+}
@@ -1,12 +1,16 @@
 package modbustcp
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -17,9 +21,16 @@ const (
 	FunctionReadInputRegister         = 4
 	FunctionWriteSingleCoil           = 5
 	FunctionWriteSingleRegister       = 6
+	FunctionDiagnostics               = 8
+	FunctionGetCommEventCounter       = 11
+	FunctionGetCommEventLog           = 12
 	FunctionWriteMultipleCoils        = 15
 	FunctionWriteMultipleRegister     = 16
+	FunctionReadFileRecord            = 20
+	FunctionWriteFileRecord           = 21
+	FunctionMaskWriteRegister         = 22
 	FunctionReadWriteMultipleRegister = 23
+	FunctionEncapsulatedInterface     = 43
 )
 
 const (
@@ -30,6 +41,7 @@ const (
 	ExcAcknowledge             = 5
 	ExcSlaveIsBusy             = 6
 	ExcGatePathUnavailable     = 10
+	ExcGateTargetFailed        = 11
 	ExcExceptionNotConnected   = 253
 	ExcExceptionConnectionLost = 254
 	ExcExceptionTimeout        = 255
@@ -43,10 +55,26 @@ const (
 	// Modbus Application Protocol
 	HeaderSize = 7
 	MaxLength  = 260
+	// MaxPduData is the maximum size of the data following the function
+	// code
+	MaxPduData = MaxLength - HeaderSize - 1
 	// Default TCP timeout is not set
 	TimeoutMillis = 5000
 )
 
+const (
+	// Quantity limits of the standard function codes
+	MaxReadBits           = 2000
+	MaxReadRegisters      = 125
+	MaxWriteCoils         = 1968
+	MaxWriteRegisters     = 123
+	MaxReadWriteRegisters = 121
+	MaxReadFileRecord     = 124
+	MaxWriteFileRecord    = 122
+	// Record numbers of a file range from 0 to MaxFileRecords-1
+	MaxFileRecords = 10000
+)
+
 var (
 	// ErrorIllegalFunction The function code received
 	// in the query is not an allowable action for the slave.
@@ -101,6 +129,11 @@ var (
 	// the gateway is misconfigured or overloaded.
 	ErrorGatewayPathUnavailable = errors.New("The gateway path is unavailable")
 
+	// Specialized use in conjunction with gateways,
+	// indicates that no response was obtained from the target device.
+	// Usually means that the device is not present on the network.
+	ErrorGatewayTargetFailed = errors.New("The gateway target device failed to respond")
+
 	//handle unknown error code
 	ErrorUnknown = errors.New("unknown error occured")
 )
@@ -112,8 +145,58 @@ type ModbusTcpClient struct {
 	SlaveId       byte
 	TransactionId uint16
 	Logger        *log.Logger
+	// WordOrder is used by the multi-register helpers
+	WordOrder WordOrder
+	// OneBased makes the client API take addresses counting from one,
+	// they are decremented before transmission
+	OneBased bool
+	// MaskWrite enables function code 22 for bitfield writes instead
+	// of reading and writing back the whole register
+	MaskWrite bool
+	// Tags resolves the names passed to ReadTag and WriteTag
+	Tags *TagDatabase
+	// VerifyWrites reads back the coils and registers written by the
+	// write functions and fails with a *VerifyError on a mismatch, mask
+	// writes verify the bits not kept by the AND mask
+	VerifyWrites bool
+	// ReadOnly rejects all requests except reads locally
+	ReadOnly bool
+	// WriteAllow limits writes to the given ranges if not empty, a
+	// unit id of zero matches any unit. Requests other than reads which
+	// do not write a known range, e.g. file records, are rejected then
+	WriteAllow []AddressRange
+	// WriteDeny rejects writes touching any of the given ranges
+	WriteDeny []AddressRange
+	// DryRun validates, encodes and logs requests other than reads but
+	// returns a *DryRunError with the frame instead of sending them
+	DryRun bool
+	// Cache serves repeated reads from memory if not nil
+	Cache *ReadCache
+	// TLSConfig secures the connections made by Connect if not nil, e.g.
+	// by Modbus/TCP Security on the TlsPort
+	TLSConfig *tls.Config
+	// MaxInFlight pipelines up to the given number of concurrent
+	// requests on the connection if greater than one, instead of waiting
+	// for each response before sending the next request. The responses
+	// are matched by their transaction ids, so a single connection is no
+	// longer limited to one transaction per round trip. The connection is
+	// made by the first request if needed and kept open, a failed
+	// connection is closed and remade by the next request. It must be set
+	// before the first request.
+	MaxInFlight int
 
 	Conn net.Conn
+
+	// lock serializes transactions of concurrent users
+	lock priorityLock
+	// transact serializes Transact including its reconnects
+	transact sync.Mutex
+	// info caches the device identification of the current connection
+	info deviceInfoCache
+	// pipe multiplexes the transactions on Conn if MaxInFlight is set
+	pipe *pipeline
+	// connMu guards Conn against the reader of pipe detaching it
+	connMu sync.Mutex
 }
 
 type Pdu struct {
@@ -133,14 +216,32 @@ func (c *ModbusTcpClient) Connect() error {
 	if c.Timeout <= 0 {
 		c.Timeout = TimeoutMillis * time.Millisecond
 	}
+	address := c.IpAddress
+	if c.Port > 0 {
+		address = net.JoinHostPort(c.IpAddress, strconv.Itoa(c.Port))
+	}
 	dialer := net.Dialer{Timeout: c.Timeout}
-	conn, err := dialer.Dial("tcp", c.IpAddress)
+	var conn net.Conn
+	var err error
+	if c.TLSConfig != nil {
+		conn, err = tls.DialWithDialer(&dialer, "tcp", address, c.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if err != nil {
+		c.Conn = nil
+		return err
+	}
 	c.Conn = conn
-	return err
+	return nil
 }
 
 // Closes the connection
 func (c *ModbusTcpClient) Disconnect() error {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
 	if c.Conn != nil {
 		if err := c.Conn.Close(); err != nil {
 			return err
@@ -151,6 +252,10 @@ func (c *ModbusTcpClient) Disconnect() error {
 }
 
 func (c *ModbusTcpClient) Encode(pdu *Pdu) ([]byte, error) {
+	return c.encode(c.SlaveId, pdu)
+}
+
+func (c *ModbusTcpClient) encode(unit byte, pdu *Pdu) ([]byte, error) {
 	adu := make([]byte, HeaderSize+1+len(pdu.Data))
 
 	// Transaction identifier
@@ -163,7 +268,7 @@ func (c *ModbusTcpClient) Encode(pdu *Pdu) ([]byte, error) {
 	length := uint16(1 + 1 + len(pdu.Data))
 	binary.BigEndian.PutUint16(adu[4:], length)
 
-	adu[6] = c.SlaveId
+	adu[6] = unit
 
 	// PDU
 	adu[HeaderSize] = pdu.FunctionCode
@@ -210,40 +315,407 @@ func (c *ModbusTcpClient) Verify(aduRequest []byte, aduResponse []byte) error {
 	return nil
 }
 
-func (c *ModbusTcpClient) ReadDiscreteInputs(startingAddress, quantity uint16) {
+// Execute sends the request pdu to the slave and returns the response pdu.
+// Exception responses are translated into the corresponding error.
+func (c *ModbusTcpClient) Execute(request *Pdu) (*Pdu, error) {
+	return c.ExecuteUnit(c.SlaveId, request)
+}
+
+// ExecuteUnit sends the request pdu to the given unit instead of the
+// configured slave, e.g. to address devices behind a gateway.
+func (c *ModbusTcpClient) ExecuteUnit(unit byte, request *Pdu) (*Pdu, error) {
+	return c.ExecutePriority(PriorityNormal, unit, request)
+}
+
+// ExecutePriority sends the request pdu to the given unit once no request
+// of a higher priority is waiting.
+func (c *ModbusTcpClient) ExecutePriority(prio Priority, unit byte, request *Pdu) (*Pdu, error) {
+	if err := c.checkWrite(unit, request); err != nil {
+		return nil, err
+	}
+	if r, ok := writeRange(unit, request); ok {
+		defer c.Cache.invalidate(r)
+	}
+	if c.MaxInFlight > 1 {
+		return c.executePipelined(prio, unit, request)
+	}
+	c.lock.Lock(prio)
+	defer c.lock.Unlock()
+	aduRequest, err := c.encode(unit, request)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.dryRun(request, aduRequest); err != nil {
+		return nil, err
+	}
+	aduResponse, err := c.Send(aduRequest)
+	if err != nil {
+		return nil, err
+	}
+	return c.response(request, aduRequest, aduResponse)
+}
+
+// dryRun returns a *DryRunError for requests other than reads in dry run
+// mode.
+func (c *ModbusTcpClient) dryRun(request *Pdu, aduRequest []byte) error {
+	if !c.DryRun || readFunctions[request.FunctionCode] {
+		return nil
+	}
+	if c.Logger != nil {
+		c.Logger.Printf("modbus: dry run % x\n", aduRequest)
+	}
+	return &DryRunError{Frame: aduRequest}
+}
+
+// response verifies and decodes the response adu of request,
+// translating exception responses into errors.
+func (c *ModbusTcpClient) response(request *Pdu, aduRequest, aduResponse []byte) (*Pdu, error) {
+	if err := c.Verify(aduRequest, aduResponse); err != nil {
+		return nil, err
+	}
+	response, err := c.Decode(aduResponse)
+	if err != nil {
+		return nil, err
+	}
+	if response.FunctionCode != request.FunctionCode {
+		if response.FunctionCode == request.FunctionCode|ExcExceptionOffset && len(response.Data) > 0 {
+			return nil, FailureCodeToError(int(response.Data[0]))
+		}
+		err = fmt.Errorf("modbus: response function code '%v' does not match request '%v'", response.FunctionCode, request.FunctionCode)
+		return nil, err
+	}
+	return response, nil
+}
+
+// ReadCoils reads the status of contiguous coils. Quantities beyond 2000
+// are split into several requests.
+func (c *ModbusTcpClient) ReadCoils(address, quantity uint16) ([]bool, error) {
+	return c.readBits(PriorityNormal, c.SlaveId, FunctionReadCoil, address, quantity)
+}
+
+// ReadDiscreteInputs reads the status of contiguous discrete inputs.
+// Quantities beyond 2000 are split into several requests.
+func (c *ModbusTcpClient) ReadDiscreteInputs(address, quantity uint16) ([]bool, error) {
+	return c.readBits(PriorityNormal, c.SlaveId, FunctionReadDiscreteInputs, address, quantity)
+}
+
+// ReadHoldingRegisters reads the contents of contiguous holding registers.
+// Quantities beyond 125 are split into several requests.
+func (c *ModbusTcpClient) ReadHoldingRegisters(address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(PriorityNormal, c.SlaveId, FunctionReadHoldingRegister, address, quantity)
+}
+
+// ReadInputRegisters reads the contents of contiguous input registers.
+// Quantities beyond 125 are split into several requests.
+func (c *ModbusTcpClient) ReadInputRegisters(address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(PriorityNormal, c.SlaveId, FunctionReadInputRegister, address, quantity)
+}
+
+// WriteSingleCoil switches a single coil on or off.
+func (c *ModbusTcpClient) WriteSingleCoil(address uint16, value bool) error {
+	return c.writeSingle(PriorityNormal, c.SlaveId, FunctionWriteSingleCoil, address, coilValue(value))
+}
+
+// WriteSingleRegister writes a single holding register.
+func (c *ModbusTcpClient) WriteSingleRegister(address, value uint16) error {
+	return c.writeSingle(PriorityNormal, c.SlaveId, FunctionWriteSingleRegister, address, value)
+}
 
+// WriteMultipleCoils forces each coil in a sequence of coils. Sequences
+// beyond 1968 coils are split into several requests.
+func (c *ModbusTcpClient) WriteMultipleCoils(address uint16, values []bool) error {
+	return c.writeCoils(c.SlaveId, address, values)
 }
 
-func (c *ModbusTcpClient) ReadCoils(startingAddress, quantity uint16) {
+// WriteMultipleRegisters writes a block of contiguous registers. Blocks
+// beyond 123 registers are split into several requests.
+func (c *ModbusTcpClient) WriteMultipleRegisters(address uint16, values []uint16) error {
+	return c.writeRegisters(c.SlaveId, address, values)
+}
+
+func (c *ModbusTcpClient) writeCoils(unit byte, address uint16, values []bool) error {
+	start, err := c.protocolAddress(address)
+	if err != nil {
+		return err
+	}
+	if err = checkRange(start, len(values)); err != nil {
+		return err
+	}
+	for rest := values; len(rest) > 0; {
+		n := len(rest)
+		if n > MaxWriteCoils {
+			n = MaxWriteCoils
+		}
+		if err = c.writeCoilsBlock(unit, start, rest[:n]); err != nil {
+			return err
+		}
+		start += uint16(n)
+		rest = rest[n:]
+	}
+	if c.VerifyWrites {
+		return c.verifyBits(PriorityNormal, unit, address, values)
+	}
+	return nil
+}
 
+func (c *ModbusTcpClient) writeCoilsBlock(unit byte, address uint16, values []bool) error {
+	quantity := len(values)
+	packed := PackBitsWithCount(values)
+	data := make([]byte, 4+len(packed))
+	binary.BigEndian.PutUint16(data, address)
+	binary.BigEndian.PutUint16(data[2:], uint16(quantity))
+	copy(data[4:], packed)
+	return c.writeMultiple(unit, &Pdu{FunctionCode: FunctionWriteMultipleCoils, Data: data}, address, uint16(quantity))
 }
 
-func (c *ModbusTcpClient) ReadHoldingRegisters() {
+func (c *ModbusTcpClient) writeRegisters(unit byte, address uint16, values []uint16) error {
+	start, err := c.protocolAddress(address)
+	if err != nil {
+		return err
+	}
+	if err = checkRange(start, len(values)); err != nil {
+		return err
+	}
+	for rest := values; len(rest) > 0; {
+		n := len(rest)
+		if n > MaxWriteRegisters {
+			n = MaxWriteRegisters
+		}
+		if err = c.writeRegistersBlock(unit, start, rest[:n]); err != nil {
+			return err
+		}
+		start += uint16(n)
+		rest = rest[n:]
+	}
+	if c.VerifyWrites {
+		return c.verifyRegisters(PriorityNormal, unit, address, values)
+	}
+	return nil
+}
 
+func (c *ModbusTcpClient) writeRegistersBlock(unit byte, address uint16, values []uint16) error {
+	quantity := len(values)
+	data := make([]byte, 5+2*quantity)
+	binary.BigEndian.PutUint16(data, address)
+	binary.BigEndian.PutUint16(data[2:], uint16(quantity))
+	data[4] = byte(2 * quantity)
+	putRegisters(data[5:], values)
+	return c.writeMultiple(unit, &Pdu{FunctionCode: FunctionWriteMultipleRegister, Data: data}, address, uint16(quantity))
 }
 
-func (c *ModbusTcpClient) ReadInputRegisters() {
+// MaskWriteRegister modifies a holding register using a combination of an
+// AND mask, an OR mask and the current register content:
+// result = (current AND andMask) OR (orMask AND (NOT andMask)).
+// With VerifyWrites the bits not kept by andMask are verified.
+func (c *ModbusTcpClient) MaskWriteRegister(address, andMask, orMask uint16) error {
+	start, err := c.protocolAddress(address)
+	if err != nil {
+		return err
+	}
+	request := &Pdu{FunctionCode: FunctionMaskWriteRegister, Data: dataBlock(start, andMask, orMask)}
+	response, err := c.Execute(request)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(response.Data, request.Data) {
+		return fmt.Errorf("modbus: response '% x' does not echo request '% x'", response.Data, request.Data)
+	}
+	if c.VerifyWrites {
+		return c.verifyMask(PriorityNormal, c.SlaveId, address, andMask, orMask)
+	}
+	return nil
+}
 
+// ReadWriteMultipleRegisters performs a write of 1 to 121 registers followed
+// by a read of 1 to 125 registers in a single transaction.
+func (c *ModbusTcpClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress uint16, values []uint16) ([]uint16, error) {
+	readAddress, err := c.protocolAddress(readAddress)
+	if err != nil {
+		return nil, err
+	}
+	writeStart, err := c.protocolAddress(writeAddress)
+	if err != nil {
+		return nil, err
+	}
+	if readQuantity < 1 || readQuantity > MaxReadRegisters {
+		return nil, fmt.Errorf("modbus: quantity to read '%v' must be between '%v' and '%v'", readQuantity, 1, MaxReadRegisters)
+	}
+	writeQuantity := len(values)
+	if writeQuantity < 1 || writeQuantity > MaxReadWriteRegisters {
+		return nil, fmt.Errorf("modbus: quantity to write '%v' must be between '%v' and '%v'", writeQuantity, 1, MaxReadWriteRegisters)
+	}
+	data := make([]byte, 9+2*writeQuantity)
+	binary.BigEndian.PutUint16(data, readAddress)
+	binary.BigEndian.PutUint16(data[2:], readQuantity)
+	binary.BigEndian.PutUint16(data[4:], writeStart)
+	binary.BigEndian.PutUint16(data[6:], uint16(writeQuantity))
+	data[8] = byte(2 * writeQuantity)
+	putRegisters(data[9:], values)
+	response, err := c.Execute(&Pdu{FunctionCode: FunctionReadWriteMultipleRegister, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	regs, err := registersFromResponse(response, readQuantity)
+	if err == nil && c.VerifyWrites {
+		err = c.verifyRegisters(PriorityNormal, c.SlaveId, writeAddress, values)
+	}
+	return regs, err
 }
 
-func (c *ModbusTcpClient) WriteSingleCoil() {
+func (c *ModbusTcpClient) readBits(prio Priority, unit byte, functionCode byte, address, quantity uint16) ([]bool, error) {
+	address, err := c.protocolAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkRange(address, int(quantity)); err != nil {
+		return nil, err
+	}
+	if _, bits, ok := c.Cache.get(cacheKey(unit, functionCode, address, quantity)); ok {
+		return bits, nil
+	}
+	return c.fetchBits(prio, unit, functionCode, address, quantity)
+}
 
+// fetchBits reads bits starting at the protocol address from the device
+// and updates the cache.
+func (c *ModbusTcpClient) fetchBits(prio Priority, unit byte, functionCode byte, address, quantity uint16) ([]bool, error) {
+	generation := c.Cache.begin()
+	values := make([]bool, 0, quantity)
+	for len(values) < int(quantity) {
+		n := int(quantity) - len(values)
+		if n > MaxReadBits {
+			n = MaxReadBits
+		}
+		start := address + uint16(len(values))
+		response, err := c.ExecutePriority(prio, unit, &Pdu{FunctionCode: functionCode, Data: dataBlock(start, uint16(n))})
+		if err != nil {
+			return nil, err
+		}
+		bits, err := UnpackBitsWithCount(response.Data, n)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, bits...)
+	}
+	c.Cache.put(cacheKey(unit, functionCode, address, quantity), generation, nil, values)
+	return values, nil
 }
 
-func (c *ModbusTcpClient) WriteSingleRegister() {
+func (c *ModbusTcpClient) readRegisters(prio Priority, unit byte, functionCode byte, address, quantity uint16) ([]uint16, error) {
+	address, err := c.protocolAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkRange(address, int(quantity)); err != nil {
+		return nil, err
+	}
+	if regs, _, ok := c.Cache.get(cacheKey(unit, functionCode, address, quantity)); ok {
+		return regs, nil
+	}
+	return c.fetchRegisters(prio, unit, functionCode, address, quantity)
+}
 
+// fetchRegisters reads registers starting at the protocol address from
+// the device and updates the cache.
+func (c *ModbusTcpClient) fetchRegisters(prio Priority, unit byte, functionCode byte, address, quantity uint16) ([]uint16, error) {
+	generation := c.Cache.begin()
+	values := make([]uint16, 0, quantity)
+	for len(values) < int(quantity) {
+		n := int(quantity) - len(values)
+		if n > MaxReadRegisters {
+			n = MaxReadRegisters
+		}
+		start := address + uint16(len(values))
+		response, err := c.ExecutePriority(prio, unit, &Pdu{FunctionCode: functionCode, Data: dataBlock(start, uint16(n))})
+		if err != nil {
+			return nil, err
+		}
+		regs, err := registersFromResponse(response, uint16(n))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, regs...)
+	}
+	c.Cache.put(cacheKey(unit, functionCode, address, quantity), generation, values, nil)
+	return values, nil
 }
 
-func (c *ModbusTcpClient) WriteMultipleCoils() {
+func (c *ModbusTcpClient) writeSingle(prio Priority, unit byte, functionCode byte, address, value uint16) error {
+	start, err := c.protocolAddress(address)
+	if err != nil {
+		return err
+	}
+	request := &Pdu{FunctionCode: functionCode, Data: dataBlock(start, value)}
+	response, err := c.ExecutePriority(prio, unit, request)
+	if err != nil {
+		return err
+	}
+	if len(response.Data) != 4 {
+		return fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 4)
+	}
+	if !bytes.Equal(response.Data, request.Data) {
+		return fmt.Errorf("modbus: response '% x' does not echo request '% x'", response.Data, request.Data)
+	}
+	if !c.VerifyWrites {
+		return nil
+	}
+	if functionCode == FunctionWriteSingleCoil {
+		return c.verifyBits(prio, unit, address, []bool{value == coilValue(true)})
+	}
+	return c.verifyRegisters(prio, unit, address, []uint16{value})
+}
 
+func (c *ModbusTcpClient) writeMultiple(unit byte, request *Pdu, address, quantity uint16) error {
+	response, err := c.ExecuteUnit(unit, request)
+	if err != nil {
+		return err
+	}
+	if len(response.Data) != 4 {
+		return fmt.Errorf("modbus: response data size '%v' does not match expected '%v'", len(response.Data), 4)
+	}
+	if v := binary.BigEndian.Uint16(response.Data); v != address {
+		return fmt.Errorf("modbus: response address '%v' does not match request '%v'", v, address)
+	}
+	if v := binary.BigEndian.Uint16(response.Data[2:]); v != quantity {
+		return fmt.Errorf("modbus: response quantity '%v' does not match request '%v'", v, quantity)
+	}
+	return nil
 }
 
-func (c *ModbusTcpClient) WriteMultipleRegisters() {
+// checkRange validates a range of quantity values starting at address.
+func checkRange(address uint16, quantity int) error {
+	if quantity < 1 {
+		return fmt.Errorf("modbus: quantity '%v' must be at least '%v'", quantity, 1)
+	}
+	if int(address)+quantity > 0x10000 {
+		return fmt.Errorf("modbus: range of '%v' starting at '%v' exceeds the address space", quantity, address)
+	}
+	return nil
+}
 
+// dataBlock creates a sequence of uint16 data.
+func dataBlock(value ...uint16) []byte {
+	data := make([]byte, 2*len(value))
+	putRegisters(data, value)
+	return data
 }
 
-func (c *ModbusTcpClient) ReadWriteMultipleRegisters() {
+func putRegisters(b []byte, values []uint16) {
+	for i, v := range values {
+		binary.BigEndian.PutUint16(b[2*i:], v)
+	}
+}
 
+func registersFromResponse(response *Pdu, quantity uint16) ([]uint16, error) {
+	count := 2 * int(quantity)
+	if len(response.Data) != count+1 || int(response.Data[0]) != count {
+		return nil, fmt.Errorf("modbus: response byte count '%v' does not match expected '%v'", len(response.Data)-1, count)
+	}
+	values := make([]uint16, quantity)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint16(response.Data[1+2*i:])
+	}
+	return values, nil
 }
 
 func (c *ModbusTcpClient) Send(request []byte) ([]byte, error) {
@@ -308,11 +780,28 @@ func FailureCodeToError(errorCode int) error {
 		return ErrorSlaveIsBusy
 	case errorCode == ExcGatePathUnavailable:
 		return ErrorGatewayPathUnavailable
+	case errorCode == ExcGateTargetFailed:
+		return ErrorGatewayTargetFailed
 	default:
 		return ErrorUnknown
 	}
 }
 
+// IsException reports whether err is or wraps an exception response of
+// the slave as returned by FailureCodeToError.
+func IsException(err error) bool {
+	for _, e := range []error{
+		ErrorIllegalFunction, ErrorIllegalDataAddress, ErrorIllegalDataValue,
+		ErrorSlaveDeviceFailure, ErrorAcknowledge, ErrorSlaveIsBusy,
+		ErrorGatewayPathUnavailable, ErrorGatewayTargetFailed, ErrorUnknown,
+	} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *ModbusTcpClient) flush(b []byte) error {
 	if err := c.Conn.SetReadDeadline(time.Now()); err != nil {
 		return err
@@ -0,0 +1,90 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client connected through a pipe to a fake slave
+// answering each request with the result of handler.
+func newTestClient(t *testing.T, handler func(request *Pdu) *Pdu) *ModbusTcpClient {
+	clientConn, slaveConn := net.Pipe()
+	t.Cleanup(func() {
+		clientConn.Close()
+		slaveConn.Close()
+	})
+	go func() {
+		for {
+			var header [HeaderSize]byte
+			if _, err := io.ReadFull(slaveConn, header[:]); err != nil {
+				return
+			}
+			body := make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+			if _, err := io.ReadFull(slaveConn, body); err != nil {
+				return
+			}
+			response := handler(&Pdu{FunctionCode: body[0], Data: body[1:]})
+			adu := make([]byte, HeaderSize+1+len(response.Data))
+			copy(adu, header[:])
+			binary.BigEndian.PutUint16(adu[4:], uint16(2+len(response.Data)))
+			adu[HeaderSize] = response.FunctionCode
+			copy(adu[HeaderSize+1:], response.Data)
+			if _, err := slaveConn.Write(adu); err != nil {
+				return
+			}
+		}
+	}()
+	return &ModbusTcpClient{Conn: clientConn, Timeout: time.Second}
+}
+
+func TestReadHoldingRegisters(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		if request.FunctionCode != FunctionReadHoldingRegister {
+			t.Errorf("function code expected %v, actual %v", FunctionReadHoldingRegister, request.FunctionCode)
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{4, 0x12, 0x34, 0xAB, 0xCD}}
+	})
+	regs, err := c.ReadHoldingRegisters(100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 0x1234 || regs[1] != 0xABCD {
+		t.Fatalf("registers expected [4660 43981], actual %v", regs)
+	}
+}
+
+func TestExceptionResponse(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{ExcIllegalDataAdr}}
+	})
+	if _, err := c.ReadCoils(0, 8); err != ErrorIllegalDataAddress {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+}
+
+func TestReadHoldingRegistersChunked(t *testing.T) {
+	var requests int
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		requests++
+		address := binary.BigEndian.Uint16(request.Data)
+		quantity := binary.BigEndian.Uint16(request.Data[2:])
+		data := []byte{byte(2 * quantity)}
+		for i := uint16(0); i < quantity; i++ {
+			data = append(data, 0, byte(address+i))
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: data}
+	})
+	regs, err := c.ReadHoldingRegisters(0, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 3 || len(regs) != 300 || regs[299] != 299&0xFF {
+		t.Fatalf("unexpected result of %v requests: %v registers", requests, len(regs))
+	}
+	if _, err := c.ReadHoldingRegisters(65500, 100); err == nil {
+		t.Fatal("error expected for range beyond the address space")
+	}
+}
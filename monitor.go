@@ -0,0 +1,103 @@
+package modbustcp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Monitor passively decodes the transactions of captured Modbus TCP
+// traffic, e.g. of a mirror port, without taking part in it:
+//
+//	// tcpdump -U -w - tcp port 502 | program
+//	r, err := NewPcapReader(os.Stdin)
+//	m := &Monitor{Observe: func(t *Transaction) { log.Println(t) }}
+//	err = m.ReadPcap(r)
+//
+// The segments of a connection must be passed in order, retransmitted
+// or lost segments stop the decoding of the connection. Connections are
+// kept until Flush.
+type Monitor struct {
+	// Port is the port of the servers, 502 if zero. Packets from and to
+	// other ports are ignored.
+	Port int
+	// Observe is invoked with each transaction, see Proxy.Observe.
+	Observe func(t *Transaction)
+	// ErrorHandler is invoked for undecodable traffic.
+	ErrorHandler func(err error)
+
+	mu    sync.Mutex
+	conns map[string]*proxyTap
+}
+
+// Packet decodes the TCP payload sent from src to dst at t.
+func (m *Monitor) Packet(t time.Time, src, dst net.Addr, payload []byte) {
+	port := m.Port
+	if port == 0 {
+		port = 502
+	}
+	_, srcPort := tcpEndpoint(src)
+	_, dstPort := tcpEndpoint(dst)
+	client, server, decode := src, dst, (*proxyTap).request
+	switch {
+	case int(dstPort) == port:
+	case int(srcPort) == port:
+		client, server, decode = dst, src, (*proxyTap).response
+	default:
+		return
+	}
+	m.mu.Lock()
+	if m.conns == nil {
+		m.conns = make(map[string]*proxyTap)
+	}
+	key := client.String() + ">" + server.String()
+	tap, ok := m.conns[key]
+	if !ok {
+		tap = newProxyTap(client, m.observe, m.error)
+		m.conns[key] = tap
+	}
+	m.mu.Unlock()
+	decode(tap, t, payload)
+}
+
+// ReadPcap decodes the packets of r until its end and then reports the
+// transactions left without response by Flush.
+func (m *Monitor) ReadPcap(r *PcapReader) error {
+	defer m.Flush()
+	for {
+		t, src, dst, payload, err := r.ReadPacket()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m.Packet(t, src, dst, payload)
+	}
+}
+
+// Flush reports the transactions awaiting their response and forgets
+// all connections.
+func (m *Monitor) Flush() {
+	m.mu.Lock()
+	conns := m.conns
+	m.conns = nil
+	m.mu.Unlock()
+	for _, tap := range conns {
+		tap.flush()
+	}
+}
+
+func (m *Monitor) observe(tx *Transaction) {
+	if m.Observe != nil {
+		m.Observe(tx)
+	}
+}
+
+func (m *Monitor) error(err error) {
+	if m.ErrorHandler != nil {
+		m.ErrorHandler(err)
+	}
+}
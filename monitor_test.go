@@ -0,0 +1,89 @@
+package modbustcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	client := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 40000}
+	server := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 502}
+	other := &net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 502}
+	start := time.Unix(1700000000, 0)
+	var capture bytes.Buffer
+	w := NewPcapWriter(&capture)
+	request := []byte{0, 1, 0, 0, 0, 6, 1, 3, 0, 0, 0, 1}
+	// a request split across segments
+	w.WritePacket(start, client, server, request[:5])
+	w.WritePacket(start, client, server, request[5:])
+	w.WritePacket(start.Add(3*time.Millisecond), server, client, []byte{0, 1, 0, 0, 0, 5, 1, 3, 2, 0, 7})
+	w.WritePacket(start, &net.TCPAddr{IP: net.IPv4(10, 0, 0, 3), Port: 80}, server, []byte("GET /"))
+	w.WritePacket(start, other, client, []byte{0, 2, 0, 0, 0, 6, 1, 3, 0, 0, 0, 1})
+	w.WritePacket(start, client, other, []byte{0, 9, 0, 0, 0, 6, 2, 6, 0, 1, 0, 2})
+
+	r, err := NewPcapReader(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var transactions []*Transaction
+	m := &Monitor{Observe: func(tx *Transaction) { transactions = append(transactions, tx) }}
+	if err = m.ReadPcap(r); err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 2 {
+		t.Fatalf("transactions expected 2, actual %v", transactions)
+	}
+	tx := transactions[0]
+	if tx.Client.String() != client.String() || tx.TransactionId != 1 || tx.Response == nil || tx.Duration != 3*time.Millisecond || !tx.Time.Equal(start) {
+		t.Fatalf("transaction expected answered in 3ms, actual %v", tx)
+	}
+	if tx = transactions[1]; tx.TransactionId != 9 || tx.Unit != 2 || tx.Response != nil {
+		t.Fatalf("transaction without response expected, actual %v", tx)
+	}
+}
+
+func TestPcapReaderEthernet(t *testing.T) {
+	var capture bytes.Buffer
+	header := make([]byte, 24)
+	binary.BigEndian.PutUint32(header, 0xa1b23c4d)
+	binary.BigEndian.PutUint32(header[20:], pcapLinkTypeEthernet)
+	capture.Write(header)
+	packet := make([]byte, 14+20+20)
+	binary.BigEndian.PutUint16(packet[12:], 0x0800)
+	ip := packet[14:]
+	ip[0], ip[9] = 0x45, 6
+	binary.BigEndian.PutUint16(ip[2:], 40+2)
+	copy(ip[12:], []byte{192, 168, 0, 1, 192, 168, 0, 2})
+	binary.BigEndian.PutUint16(ip[20:], 1234)
+	binary.BigEndian.PutUint16(ip[22:], 502)
+	ip[32] = 5 << 4
+	// payload and padding of the frame
+	packet = append(packet, 0xab, 0xcd, 0, 0)
+	record := make([]byte, 16)
+	binary.BigEndian.PutUint32(record, 10)
+	binary.BigEndian.PutUint32(record[4:], 500)
+	binary.BigEndian.PutUint32(record[8:], uint32(len(packet)))
+	capture.Write(record)
+	capture.Write(packet)
+
+	r, err := NewPcapReader(&capture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, src, dst, payload, err := r.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ts.Equal(time.Unix(10, 500)) || src.String() != "192.168.0.1:1234" || dst.String() != "192.168.0.2:502" || !bytes.Equal(payload, []byte{0xab, 0xcd}) {
+		t.Fatalf("packet expected, actual %v %v %v % x", ts, src, dst, payload)
+	}
+	if _, _, _, _, err = r.ReadPacket(); err == nil {
+		t.Fatal("end of capture expected")
+	}
+	if _, err = NewPcapReader(bytes.NewReader([]byte{0x0a, 0x0d, 0x0d, 0x0a, 24: 0})); err == nil {
+		t.Fatal("pcapng expected to fail")
+	}
+}
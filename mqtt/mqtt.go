@@ -0,0 +1,423 @@
+// Package mqtt implements a minimal MQTT 3.1.1 client sufficient for
+// publishing tag updates and receiving commands: QoS 0 and 1, retained
+// messages, subscriptions and keep alive. QoS 2 is not supported.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	packetConnect     = 1
+	packetConnAck     = 2
+	packetPublish     = 3
+	packetPubAck      = 4
+	packetSubscribe   = 8
+	packetSubAck      = 9
+	packetPingReq     = 12
+	packetPingResp    = 13
+	packetDisconnect  = 14
+	maxRemainingBytes = 268435455
+)
+
+// ErrorClosed is returned for operations on a closed client.
+var ErrorClosed = errors.New("mqtt: client closed")
+
+// Message is the will of a client, published by the broker when the
+// client disconnects unexpectedly.
+type Message struct {
+	Topic    string
+	Payload  []byte
+	QoS      byte
+	Retained bool
+}
+
+// Options configure the session of a client.
+type Options struct {
+	ClientID string
+	Username string
+	Password string
+	// KeepAlive is the ping interval, 60s if zero.
+	KeepAlive    time.Duration
+	CleanSession bool
+	// Will is published by the broker if the connection is lost.
+	Will *Message
+	// Timeout limits connecting and waiting for acknowledgements,
+	// 10s if zero.
+	Timeout time.Duration
+}
+
+func (o *Options) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return 10 * time.Second
+}
+
+// Handler receives messages of a subscription.
+type Handler func(topic string, payload []byte)
+
+type subscription struct {
+	filter  string
+	handler Handler
+}
+
+type packet struct {
+	header byte
+	body   []byte
+}
+
+// Client is a connection to an MQTT broker.
+type Client struct {
+	conn net.Conn
+	opts Options
+
+	wmu     sync.Mutex
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan packet
+	subs    []subscription
+	done    chan struct{}
+	err     error
+}
+
+// Dial connects to the broker at address, e.g. "localhost:1883".
+func Dial(address string, opts Options) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", address, opts.timeout())
+	if err != nil {
+		return nil, err
+	}
+	c, err := Connect(conn, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Connect establishes a session over conn, e.g. a TLS connection.
+func Connect(conn net.Conn, opts Options) (*Client, error) {
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = time.Minute
+	}
+	c := &Client{conn: conn, opts: opts, pending: make(map[uint16]chan packet), done: make(chan struct{})}
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(opts.timeout()))
+	if err := c.write(packetConnect<<4, c.connectBody()); err != nil {
+		return nil, err
+	}
+	p, err := readPacket(r)
+	if err != nil {
+		return nil, err
+	}
+	if p.header>>4 != packetConnAck || len(p.body) != 2 {
+		return nil, fmt.Errorf("mqtt: expected connack, received packet type '%v'", p.header>>4)
+	}
+	if p.body[1] != 0 {
+		return nil, fmt.Errorf("mqtt: connection refused with return code '%v'", p.body[1])
+	}
+	conn.SetDeadline(time.Time{})
+	go c.read(r)
+	go c.ping()
+	return c, nil
+}
+
+func (c *Client) connectBody() []byte {
+	b := appendString(nil, "MQTT")
+	b = append(b, 4)
+	var flags byte
+	if c.opts.CleanSession {
+		flags |= 0x02
+	}
+	if w := c.opts.Will; w != nil {
+		flags |= 0x04 | w.QoS<<3
+		if w.Retained {
+			flags |= 0x20
+		}
+	}
+	if c.opts.Password != "" {
+		flags |= 0x40
+	}
+	if c.opts.Username != "" {
+		flags |= 0x80
+	}
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint16(b, uint16(c.opts.KeepAlive/time.Second))
+	b = appendString(b, c.opts.ClientID)
+	if w := c.opts.Will; w != nil {
+		b = appendString(b, w.Topic)
+		b = appendString(b, string(w.Payload))
+	}
+	if c.opts.Username != "" {
+		b = appendString(b, c.opts.Username)
+	}
+	if c.opts.Password != "" {
+		b = appendString(b, c.opts.Password)
+	}
+	return b
+}
+
+// Publish sends payload to topic. With QoS 1 it waits for the
+// acknowledgement of the broker.
+func (c *Client) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	if qos > 1 {
+		return fmt.Errorf("mqtt: qos '%v' is not supported", qos)
+	}
+	header := byte(packetPublish<<4) | qos<<1
+	if retained {
+		header |= 1
+	}
+	body := appendString(nil, topic)
+	if qos == 0 {
+		return c.write(header, append(body, payload...))
+	}
+	id, ack := c.register()
+	body = binary.BigEndian.AppendUint16(body, id)
+	if err := c.write(header, append(body, payload...)); err != nil {
+		c.unregister(id)
+		return err
+	}
+	_, err := c.await(id, ack)
+	return err
+}
+
+// Subscribe registers handler for the messages matching filter, which may
+// contain the wildcards '+' and '#'. Handlers are invoked sequentially by
+// the receiving goroutine and must not block.
+func (c *Client) Subscribe(filter string, qos byte, handler Handler) error {
+	if qos > 1 {
+		qos = 1
+	}
+	c.mu.Lock()
+	c.subs = append(c.subs, subscription{filter, handler})
+	c.mu.Unlock()
+	id, ack := c.register()
+	body := binary.BigEndian.AppendUint16(nil, id)
+	body = appendString(body, filter)
+	body = append(body, qos)
+	if err := c.write(packetSubscribe<<4|0x02, body); err != nil {
+		c.unregister(id)
+		return err
+	}
+	p, err := c.await(id, ack)
+	if err != nil {
+		return err
+	}
+	if len(p.body) < 3 || p.body[2] == 0x80 {
+		return fmt.Errorf("mqtt: subscription to '%v' refused", filter)
+	}
+	return nil
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	c.write(packetDisconnect<<4, nil)
+	err := c.conn.Close()
+	c.fail(ErrorClosed)
+	return err
+}
+
+// Done returns a channel closed when the connection is lost or closed.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the reason the connection ended.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) register() (uint16, chan packet) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	if c.nextID == 0 {
+		c.nextID = 1
+	}
+	ack := make(chan packet, 1)
+	c.pending[c.nextID] = ack
+	return c.nextID, ack
+}
+
+func (c *Client) unregister(id uint16) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+func (c *Client) await(id uint16, ack chan packet) (packet, error) {
+	timer := time.NewTimer(c.opts.timeout())
+	defer timer.Stop()
+	select {
+	case p := <-ack:
+		return p, nil
+	case <-c.done:
+		return packet{}, c.Err()
+	case <-timer.C:
+		c.unregister(id)
+		return packet{}, fmt.Errorf("mqtt: no acknowledgement for packet '%v'", id)
+	}
+}
+
+func (c *Client) write(header byte, body []byte) error {
+	if len(body) > maxRemainingBytes {
+		return fmt.Errorf("mqtt: packet of '%v' bytes too large", len(body))
+	}
+	b := []byte{header}
+	for n := len(body); ; {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	b = append(b, body...)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.timeout()))
+	_, err := c.conn.Write(b)
+	return err
+}
+
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
+
+// read dispatches incoming packets until the connection fails.
+func (c *Client) read(r *bufio.Reader) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(c.opts.KeepAlive * 3 / 2))
+		p, err := readPacket(r)
+		if err != nil {
+			c.fail(err)
+			c.conn.Close()
+			return
+		}
+		switch p.header >> 4 {
+		case packetPubAck, packetSubAck:
+			if len(p.body) < 2 {
+				continue
+			}
+			id := binary.BigEndian.Uint16(p.body)
+			c.mu.Lock()
+			ack := c.pending[id]
+			delete(c.pending, id)
+			c.mu.Unlock()
+			if ack != nil {
+				ack <- p
+			}
+		case packetPublish:
+			c.receive(p)
+		}
+	}
+}
+
+func (c *Client) receive(p packet) {
+	qos := p.header >> 1 & 3
+	if len(p.body) < 2 {
+		return
+	}
+	n := int(binary.BigEndian.Uint16(p.body))
+	if len(p.body) < 2+n {
+		return
+	}
+	topic, payload := string(p.body[2:2+n]), p.body[2+n:]
+	if qos > 0 {
+		if len(payload) < 2 {
+			return
+		}
+		id := payload[:2]
+		payload = payload[2:]
+		c.write(packetPubAck<<4, id)
+	}
+	c.mu.Lock()
+	subs := c.subs
+	c.mu.Unlock()
+	for _, s := range subs {
+		if Match(s.filter, topic) {
+			s.handler(topic, payload)
+		}
+	}
+}
+
+func (c *Client) ping() {
+	ticker := time.NewTicker(c.opts.KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.write(packetPingReq<<4, nil)
+		}
+	}
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+	var length, shift int
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length |= int(b&0x7F) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return packet{}, fmt.Errorf("mqtt: malformed remaining length")
+		}
+	}
+	body := make([]byte, length)
+	if _, err = io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{header, body}, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// Match reports whether topic matches the subscription filter.
+func Match(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || level != "+" && level != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
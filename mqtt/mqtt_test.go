@@ -0,0 +1,94 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeBroker acknowledges all packets and answers a subscription with a
+// QoS 1 message on topic "cmd/pump". Published messages are passed to
+// published.
+func fakeBroker(t *testing.T, conn net.Conn, published chan<- string) {
+	r := bufio.NewReader(conn)
+	send := func(header byte, body []byte) {
+		conn.Write(append([]byte{header, byte(len(body))}, body...))
+	}
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.header >> 4 {
+		case packetConnect:
+			if string(p.body[2:6]) != "MQTT" {
+				t.Errorf("protocol expected MQTT, actual %q", p.body[2:6])
+			}
+			send(packetConnAck<<4, []byte{0, 0})
+		case packetPublish:
+			n := int(binary.BigEndian.Uint16(p.body))
+			body := p.body[2+n:]
+			if p.header>>1&3 == 1 {
+				send(packetPubAck<<4, body[:2])
+				body = body[2:]
+			}
+			published <- string(p.body[2:2+n]) + " " + string(body)
+		case packetSubscribe:
+			send(packetSubAck<<4, append(p.body[:2:2], 1))
+			msg := appendString(nil, "cmd/pump")
+			send(packetPublish<<4|0x02, append(append(msg, 0, 9), "on"...))
+		case packetPubAck:
+			published <- "puback"
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	clientConn, brokerConn := net.Pipe()
+	published := make(chan string, 4)
+	go fakeBroker(t, brokerConn, published)
+	c, err := Connect(clientConn, Options{ClientID: "test", Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err = c.Publish("plant/temp", 1, true, []byte("21.5")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-published; msg != "plant/temp 21.5" {
+		t.Fatalf("message expected plant/temp 21.5, actual %v", msg)
+	}
+	received := make(chan string, 1)
+	if err = c.Subscribe("cmd/+", 1, func(topic string, payload []byte) {
+		received <- topic + " " + string(payload)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-received; msg != "cmd/pump on" {
+		t.Fatalf("command expected cmd/pump on, actual %v", msg)
+	}
+	if msg := <-published; msg != "puback" {
+		t.Fatalf("acknowledgement expected, actual %v", msg)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a/b/c", true},
+		{"#", "a", true},
+		{"a/b", "a", false},
+	}
+	for _, test := range tests {
+		if Match(test.filter, test.topic) != test.match {
+			t.Errorf("%v %v: match expected %v", test.filter, test.topic, test.match)
+		}
+	}
+}
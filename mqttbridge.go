@@ -0,0 +1,187 @@
+package modbustcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQTTClient is the part of an MQTT client used by MQTTBridge. It is
+// implemented by the client of package mqtt.
+type MQTTClient interface {
+	Publish(topic string, qos byte, retained bool, payload []byte) error
+	Subscribe(filter string, qos byte, handler func(topic string, payload []byte)) error
+}
+
+// MQTTBridge publishes tag updates to MQTT and writes tags on messages
+// received on command topics. Its Record method can be used as Handler of
+// a Poller.
+type MQTTBridge struct {
+	MQTT MQTTClient
+	// Client executes the writes of commands, resolving the tag names
+	// through its tag database.
+	Client *ModbusTcpClient
+	// Topic of the updates, "{group}" and "{tag}" are replaced by the
+	// names of the update. Defaults to "modbus/{group}/{tag}".
+	Topic    string
+	QoS      byte
+	Retained bool
+	// CommandTopic enables writes if set, e.g. "modbus/{tag}/set". It
+	// must contain "{tag}" as a complete topic level.
+	CommandTopic string
+	// CommandQueue is the number of commands waiting to be written, 100
+	// if zero. Commands received while the queue is full are dropped.
+	CommandQueue int
+	// ErrorHandler is invoked for failed publications and commands.
+	ErrorHandler func(err error)
+
+	commands commandQueue
+}
+
+// commandQueue executes the commands received by MQTT handlers on a
+// worker goroutine in the order of arrival, as handlers must not block
+// the receiving goroutine of the MQTT client.
+type commandQueue struct {
+	mu     sync.Mutex
+	queue  chan func()
+	worker sync.WaitGroup
+}
+
+// start starts the worker with room for size waiting commands, 100 if
+// zero.
+func (q *commandQueue) start(size int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queue != nil {
+		return
+	}
+	if size <= 0 {
+		size = 100
+	}
+	q.queue = make(chan func(), size)
+	q.worker.Add(1)
+	go func(queue chan func()) {
+		defer q.worker.Done()
+		for command := range queue {
+			command()
+		}
+	}(q.queue)
+}
+
+// submit queues command without blocking.
+func (q *commandQueue) submit(command func()) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queue == nil {
+		return fmt.Errorf("modbus: command received while stopped")
+	}
+	select {
+	case q.queue <- command:
+		return nil
+	default:
+		return fmt.Errorf("modbus: command queue full, command dropped")
+	}
+}
+
+// stop waits for the queued commands and ends the worker.
+func (q *commandQueue) stop() {
+	q.mu.Lock()
+	if q.queue != nil {
+		close(q.queue)
+		q.queue = nil
+	}
+	q.mu.Unlock()
+	q.worker.Wait()
+}
+
+type mqttPayload struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Label string    `json:"label,omitempty"`
+	Unit  string    `json:"unit,omitempty"`
+}
+
+// NewMQTTBridge creates a bridge between m and client.
+func NewMQTTBridge(m MQTTClient, client *ModbusTcpClient) *MQTTBridge {
+	return &MQTTBridge{MQTT: m, Client: client, Topic: "modbus/{group}/{tag}"}
+}
+
+// Record publishes u as JSON object with the time, value, label and unit.
+func (b *MQTTBridge) Record(u TagUpdate) {
+	if err := b.Publish(u); err != nil && b.ErrorHandler != nil {
+		b.ErrorHandler(err)
+	}
+}
+
+// Publish publishes u and returns the error of the MQTT client.
+func (b *MQTTBridge) Publish(u TagUpdate) error {
+	topic := b.Topic
+	if topic == "" {
+		topic = "modbus/{group}/{tag}"
+	}
+	topic = strings.NewReplacer("{group}", u.Group, "{tag}", u.Tag).Replace(topic)
+	payload, err := json.Marshal(mqttPayload{Time: u.Time, Value: u.Value, Label: u.Label, Unit: u.Unit})
+	if err != nil {
+		return err
+	}
+	return b.MQTT.Publish(topic, b.QoS, b.Retained, payload)
+}
+
+// Start subscribes to the command topic if configured. Commands are
+// written by a worker goroutine until Stop.
+func (b *MQTTBridge) Start() error {
+	if b.CommandTopic == "" {
+		return nil
+	}
+	levels := strings.Split(b.CommandTopic, "/")
+	index := -1
+	for i, level := range levels {
+		if level == "{tag}" {
+			index = i
+			levels[i] = "+"
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("modbus: command topic '%v' has no {tag} level", b.CommandTopic)
+	}
+	b.commands.start(b.CommandQueue)
+	return b.MQTT.Subscribe(strings.Join(levels, "/"), b.QoS, func(topic string, payload []byte) {
+		tag := strings.Split(topic, "/")[index]
+		payload = append([]byte(nil), payload...)
+		err := b.commands.submit(func() { b.report(b.command(tag, payload)) })
+		if err != nil {
+			b.report(fmt.Errorf("%v for tag '%v'", err, tag))
+		}
+	})
+}
+
+// Stop waits for the commands received before and stops writing
+// commands.
+func (b *MQTTBridge) Stop() {
+	b.commands.stop()
+}
+
+func (b *MQTTBridge) report(err error) {
+	if err != nil && b.ErrorHandler != nil {
+		b.ErrorHandler(err)
+	}
+}
+
+// command writes the value of payload to tag. The payload is a JSON
+// number, bool or label, or an object with a "value" member.
+func (b *MQTTBridge) command(tag string, payload []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		// accept plain labels like on without quotes
+		v = strings.TrimSpace(string(payload))
+	}
+	if obj, ok := v.(map[string]interface{}); ok {
+		v = obj["value"]
+	}
+	if err := b.Client.WriteTag(tag, v); err != nil {
+		return fmt.Errorf("modbus: command for tag '%v': %v", tag, err)
+	}
+	return nil
+}
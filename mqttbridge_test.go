@@ -0,0 +1,88 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeMQTT struct {
+	published map[string]string
+	handlers  map[string]func(topic string, payload []byte)
+}
+
+func (f *fakeMQTT) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	f.published[topic] = string(payload)
+	return nil
+}
+
+func (f *fakeMQTT) Subscribe(filter string, qos byte, handler func(topic string, payload []byte)) error {
+	f.handlers[filter] = handler
+	return nil
+}
+
+func TestMQTTBridge(t *testing.T) {
+	written := make(chan uint16, 1)
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		written <- binary.BigEndian.Uint16(request.Data[2:])
+		return request
+	})
+	tags, err := NewTagDatabase(Tag{Name: "setpoint", Table: TableHoldingRegisters, Address: 3, Writable: true, Codec: Codec{Scale: Scale{Gain: 0.1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Tags = tags
+	m := &fakeMQTT{published: map[string]string{}, handlers: map[string]func(string, []byte){}}
+	b := NewMQTTBridge(m, c)
+	b.CommandTopic = "plant/{tag}/set"
+	b.ErrorHandler = func(err error) { t.Error(err) }
+	if err = b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+	b.Record(TagUpdate{Group: "g", Tag: "temp", Time: time.Unix(0, 0).UTC(), Reading: Reading{Value: 21.5}})
+	if p := m.published["modbus/g/temp"]; p != `{"time":"1970-01-01T00:00:00Z","value":21.5}` {
+		t.Fatalf("payload unexpected %v", p)
+	}
+	m.handlers["plant/+/set"]("plant/setpoint/set", []byte(`{"value": 42}`))
+	if v := <-written; v != 420 {
+		t.Fatalf("written expected %v, actual %v", 420, v)
+	}
+}
+
+func TestMQTTBridgeCommandQueue(t *testing.T) {
+	release := make(chan struct{})
+	written := make(chan uint16, 3)
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		<-release
+		written <- binary.BigEndian.Uint16(request.Data[2:])
+		return request
+	})
+	c.Tags, _ = NewTagDatabase(Tag{Name: "setpoint", Table: TableHoldingRegisters, Address: 3, Writable: true})
+	m := &fakeMQTT{published: map[string]string{}, handlers: map[string]func(string, []byte){}}
+	b := NewMQTTBridge(m, c)
+	b.CommandTopic = "plant/{tag}/set"
+	b.CommandQueue = 1
+	errs := make(chan error, 3)
+	b.ErrorHandler = func(err error) { errs <- err }
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// the first command is written, the second queued and the third
+	// dropped without waiting for the write
+	for i := 1; i <= 3; i++ {
+		m.handlers["plant/+/set"]("plant/setpoint/set", []byte{'0' + byte(i)})
+		if i == 1 {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err := <-errs; !strings.Contains(err.Error(), "queue full") {
+		t.Fatalf("dropped command expected to be reported, actual %v", err)
+	}
+	close(release)
+	b.Stop()
+	if len(written) != 2 || <-written != 1 || <-written != 2 {
+		t.Fatalf("first two commands expected to be written, actual %v", len(written))
+	}
+}
@@ -0,0 +1,255 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Link types of pcap files, pcapLinkTypeRaw is the link type of packets
+// starting with the IP header.
+const (
+	pcapLinkTypeNull     = 0
+	pcapLinkTypeEthernet = 1
+	pcapLinkTypeRaw      = 101
+	pcapLinkTypeLinuxSLL = 113
+	pcapLinkTypeIPv4     = 228
+	pcapLinkTypeIPv6     = 229
+)
+
+// maxPcapPacket bounds the captured length of packets read.
+const maxPcapPacket = 262144
+
+// PcapWriter writes TCP payloads as packets of a pcap capture file, e.g.
+// for the analysis of Modbus TCP traffic by Wireshark. The IP and TCP
+// headers are synthesized, their checksums are not set.
+type PcapWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	header bool
+	// seq is the next sequence number of each direction
+	seq map[string]uint32
+}
+
+// NewPcapWriter creates a writer of a capture file to w.
+func NewPcapWriter(w io.Writer) *PcapWriter {
+	return &PcapWriter{w: w, seq: make(map[string]uint32)}
+}
+
+// WritePacket writes the payload sent from src to dst at t. Addresses
+// other than *net.TCPAddr are written as unspecified IPv4 addresses.
+func (p *PcapWriter) WritePacket(t time.Time, src, dst net.Addr, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.header {
+		var h [24]byte
+		binary.LittleEndian.PutUint32(h[0:], 0xa1b2c3d4)
+		binary.LittleEndian.PutUint16(h[4:], 2)
+		binary.LittleEndian.PutUint16(h[6:], 4)
+		binary.LittleEndian.PutUint32(h[16:], 65535)
+		binary.LittleEndian.PutUint32(h[20:], pcapLinkTypeRaw)
+		if _, err := p.w.Write(h[:]); err != nil {
+			return err
+		}
+		p.header = true
+	}
+	srcIP, srcPort := tcpEndpoint(src)
+	dstIP, dstPort := tcpEndpoint(dst)
+	v4 := srcIP.To4() != nil && dstIP.To4() != nil
+	var packet []byte
+	if v4 {
+		packet = make([]byte, 20, 40+len(payload))
+		packet[0] = 0x45
+		binary.BigEndian.PutUint16(packet[2:], uint16(40+len(payload)))
+		packet[8] = 64
+		packet[9] = 6
+		copy(packet[12:], srcIP.To4())
+		copy(packet[16:], dstIP.To4())
+		binary.BigEndian.PutUint16(packet[10:], ipChecksum(packet))
+	} else {
+		packet = make([]byte, 40, 60+len(payload))
+		packet[0] = 0x60
+		binary.BigEndian.PutUint16(packet[4:], uint16(20+len(payload)))
+		packet[6] = 6
+		packet[7] = 64
+		copy(packet[8:], srcIP.To16())
+		copy(packet[24:], dstIP.To16())
+	}
+	key := src.String() + ">" + dst.String()
+	seq := p.seq[key]
+	p.seq[key] = seq + uint32(len(payload))
+	var tcp [20]byte
+	binary.BigEndian.PutUint16(tcp[0:], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	binary.BigEndian.PutUint32(tcp[8:], p.seq[dst.String()+">"+src.String()])
+	tcp[12] = 5 << 4
+	// PSH and ACK
+	tcp[13] = 0x18
+	binary.BigEndian.PutUint16(tcp[14:], 65535)
+	packet = append(append(packet, tcp[:]...), payload...)
+
+	var record [16]byte
+	binary.LittleEndian.PutUint32(record[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(packet)))
+	if _, err := p.w.Write(record[:]); err != nil {
+		return err
+	}
+	_, err := p.w.Write(packet)
+	return err
+}
+
+// tcpEndpoint returns the ip address and port of addr.
+func tcpEndpoint(addr net.Addr) (net.IP, uint16) {
+	if a, ok := addr.(*net.TCPAddr); ok && a.IP != nil {
+		return a.IP, uint16(a.Port)
+	}
+	return net.IPv4zero, 0
+}
+
+// ipChecksum returns the checksum of an IPv4 header.
+func ipChecksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i:]))
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// PcapReader reads the TCP payloads of a pcap capture file, e.g. written
+// by PcapWriter or tcpdump. Packets captured with the link types raw IP,
+// Ethernet, Linux cooked capture or BSD loopback are decoded, other
+// packets and packets without payload are skipped. The pcapng format is
+// not supported.
+type PcapReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	nano  bool
+	link  uint32
+}
+
+// NewPcapReader reads the file header of a capture file from r.
+func NewPcapReader(r io.Reader) (*PcapReader, error) {
+	var h [24]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		return nil, fmt.Errorf("modbus: pcap header: %w", err)
+	}
+	p := &PcapReader{r: r}
+	switch magic := binary.LittleEndian.Uint32(h[:]); magic {
+	case 0xa1b2c3d4, 0xa1b23c4d:
+		p.order, p.nano = binary.LittleEndian, magic == 0xa1b23c4d
+	case 0xd4c3b2a1, 0x4d3cb2a1:
+		p.order, p.nano = binary.BigEndian, magic == 0x4d3cb2a1
+	case 0x0a0d0d0a:
+		return nil, errors.New("modbus: pcapng captures are not supported, write pcap files, e.g. by tcpdump")
+	default:
+		return nil, fmt.Errorf("modbus: invalid pcap magic number %#x", magic)
+	}
+	p.link = p.order.Uint32(h[20:]) & 0xffff
+	return p, nil
+}
+
+// ReadPacket returns the next TCP payload with the time it was captured
+// and its source and destination *net.TCPAddr. It returns io.EOF at the
+// end of the capture.
+func (p *PcapReader) ReadPacket() (t time.Time, src, dst net.Addr, payload []byte, err error) {
+	for {
+		var record [16]byte
+		if _, err = io.ReadFull(p.r, record[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = fmt.Errorf("modbus: truncated pcap record: %w", err)
+			}
+			return time.Time{}, nil, nil, nil, err
+		}
+		length := p.order.Uint32(record[8:])
+		if length > maxPcapPacket {
+			return t, nil, nil, nil, fmt.Errorf("modbus: pcap packet of %v bytes exceeds %v bytes", length, maxPcapPacket)
+		}
+		packet := make([]byte, length)
+		if _, err = io.ReadFull(p.r, packet); err != nil {
+			return t, nil, nil, nil, fmt.Errorf("modbus: truncated pcap packet: %w", io.ErrUnexpectedEOF)
+		}
+		fraction := time.Duration(p.order.Uint32(record[4:]))
+		if !p.nano {
+			fraction *= time.Microsecond
+		}
+		t = time.Unix(int64(p.order.Uint32(record[0:])), int64(fraction))
+		if src, dst, payload = p.decode(packet); len(payload) > 0 {
+			return t, src, dst, payload, nil
+		}
+	}
+}
+
+// decode returns the endpoints and payload of a TCP packet, no payload
+// for other packets.
+func (p *PcapReader) decode(packet []byte) (src, dst net.Addr, payload []byte) {
+	switch p.link {
+	case pcapLinkTypeNull:
+		packet = packet[min(4, len(packet)):]
+	case pcapLinkTypeEthernet:
+		if len(packet) < 14 {
+			return nil, nil, nil
+		}
+		etherType, n := binary.BigEndian.Uint16(packet[12:]), 14
+		if etherType == 0x8100 && len(packet) >= 18 {
+			// VLAN tag
+			etherType, n = binary.BigEndian.Uint16(packet[16:]), 18
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return nil, nil, nil
+		}
+		packet = packet[n:]
+	case pcapLinkTypeLinuxSLL:
+		packet = packet[min(16, len(packet)):]
+	case pcapLinkTypeRaw, pcapLinkTypeIPv4, pcapLinkTypeIPv6:
+	default:
+		return nil, nil, nil
+	}
+	if len(packet) == 0 {
+		return nil, nil, nil
+	}
+	var srcIP, dstIP net.IP
+	switch packet[0] >> 4 {
+	case 4:
+		n := int(packet[0]&0x0f) * 4
+		if n < 20 || len(packet) < n || packet[9] != 6 {
+			return nil, nil, nil
+		}
+		if binary.BigEndian.Uint16(packet[6:])&0x3fff != 0 {
+			// fragments
+			return nil, nil, nil
+		}
+		if total := int(binary.BigEndian.Uint16(packet[2:])); total >= n && total < len(packet) {
+			// padding of short frames
+			packet = packet[:total]
+		}
+		srcIP, dstIP = net.IP(packet[12:16]), net.IP(packet[16:20])
+		packet = packet[n:]
+	case 6:
+		if len(packet) < 40 || packet[6] != 6 {
+			return nil, nil, nil
+		}
+		if total := 40 + int(binary.BigEndian.Uint16(packet[4:])); total < len(packet) {
+			packet = packet[:total]
+		}
+		srcIP, dstIP = net.IP(packet[8:24]), net.IP(packet[24:40])
+		packet = packet[40:]
+	default:
+		return nil, nil, nil
+	}
+	if len(packet) < 20 || len(packet) < int(packet[12]>>4)*4 {
+		return nil, nil, nil
+	}
+	src = &net.TCPAddr{IP: append(net.IP(nil), srcIP...), Port: int(binary.BigEndian.Uint16(packet))}
+	dst = &net.TCPAddr{IP: append(net.IP(nil), dstIP...), Port: int(binary.BigEndian.Uint16(packet[2:]))}
+	return src, dst, packet[int(packet[12]>>4)*4:]
+}
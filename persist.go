@@ -0,0 +1,194 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// storeFile is the file format of a saved data store, bits are packed
+// and registers big-endian like in the protocol.
+type storeFile struct {
+	Coils            []byte `json:"coils"`
+	CoilCount        int    `json:"coil_count"`
+	DiscreteInputs   []byte `json:"discrete_inputs"`
+	DiscreteCount    int    `json:"discrete_input_count"`
+	HoldingRegisters []byte `json:"holding_registers"`
+	InputRegisters   []byte `json:"input_registers"`
+}
+
+func registerBytes(regs []uint16) []byte {
+	data := make([]byte, 2*len(regs))
+	for i, v := range regs {
+		binary.BigEndian.PutUint16(data[2*i:], v)
+	}
+	return data
+}
+
+// snapshot returns the contents of the store and its version.
+func (s *DataStore) snapshot() (storeFile, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return storeFile{
+		Coils:            PackBits(s.coils),
+		CoilCount:        len(s.coils),
+		DiscreteInputs:   PackBits(s.discreteInputs),
+		DiscreteCount:    len(s.discreteInputs),
+		HoldingRegisters: registerBytes(s.holdingRegisters),
+		InputRegisters:   registerBytes(s.inputRegs),
+	}, s.version
+}
+
+// save writes the snapshot f atomically to path.
+func (f *storeFile) save(path string) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Save writes all tables of the store to the file at path. The file is
+// replaced atomically, so that a crash leaves the previous contents.
+func (s *DataStore) Save(path string) error {
+	f, _ := s.snapshot()
+	return f.save(path)
+}
+
+// Load restores the tables saved to the file at path. Values beyond the
+// size of a table are ignored, values missing in the file are kept. The
+// error wraps os.ErrNotExist if there is no file.
+func (s *DataStore) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f storeFile
+	if err = json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("modbus: data store '%v': %v", path, err)
+	}
+	coils, err := UnpackBits(f.Coils, min(f.CoilCount, 8*len(f.Coils)))
+	if err != nil {
+		return fmt.Errorf("modbus: data store '%v': %v", path, err)
+	}
+	inputs, err := UnpackBits(f.DiscreteInputs, min(f.DiscreteCount, 8*len(f.DiscreteInputs)))
+	if err != nil {
+		return fmt.Errorf("modbus: data store '%v': %v", path, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copy(s.coils, coils)
+	copy(s.discreteInputs, inputs)
+	for i := 0; i < len(s.holdingRegisters) && 2*i+1 < len(f.HoldingRegisters); i++ {
+		s.holdingRegisters[i] = binary.BigEndian.Uint16(f.HoldingRegisters[2*i:])
+	}
+	for i := 0; i < len(s.inputRegs) && 2*i+1 < len(f.InputRegisters); i++ {
+		s.inputRegs[i] = binary.BigEndian.Uint16(f.InputRegisters[2*i:])
+	}
+	return nil
+}
+
+// Persister keeps a data store saved to a file so that its values
+// survive restarts of the process.
+type Persister struct {
+	Store *DataStore
+	Path  string
+	// Interval is the period of saving a changed store. If zero, the
+	// store is saved after each write, bursts of writes are coalesced.
+	Interval time.Duration
+	// ErrorHandler is invoked for saves which failed.
+	ErrorHandler func(err error)
+
+	mu    sync.Mutex
+	saved uint64
+	wake  chan struct{}
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// Start loads the store from Path if the file exists and begins saving
+// it.
+func (p *Persister) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return fmt.Errorf("modbus: persister already started")
+	}
+	if err := p.Store.Load(p.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	p.wake = make(chan struct{}, 1)
+	p.Store.mu.Lock()
+	p.saved = p.Store.version
+	p.Store.notify = func() {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+	}
+	p.Store.mu.Unlock()
+	p.stop = make(chan struct{})
+	p.wg.Add(1)
+	go p.run(p.stop)
+	return nil
+}
+
+// Stop ends saving and saves the store a last time if it changed.
+func (p *Persister) Stop() error {
+	p.mu.Lock()
+	if p.stop == nil {
+		p.mu.Unlock()
+		return nil
+	}
+	close(p.stop)
+	p.stop = nil
+	p.mu.Unlock()
+	p.wg.Wait()
+	p.Store.mu.Lock()
+	p.Store.notify = nil
+	p.Store.mu.Unlock()
+	return p.save()
+}
+
+func (p *Persister) run(stop chan struct{}) {
+	defer p.wg.Done()
+	var tick <-chan time.Time
+	wake := p.wake
+	if p.Interval > 0 {
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		tick, wake = ticker.C, nil
+	}
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick:
+		case <-wake:
+		}
+		if err := p.save(); err != nil && p.ErrorHandler != nil {
+			p.ErrorHandler(err)
+		}
+	}
+}
+
+// save writes the store if it changed since the last save.
+func (p *Persister) save() error {
+	f, version := p.Store.snapshot()
+	if version == p.saved {
+		return nil
+	}
+	if err := f.save(p.Path); err != nil {
+		return fmt.Errorf("modbus: data store '%v': %w", p.Path, err)
+	}
+	p.saved = version
+	return nil
+}
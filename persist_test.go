@@ -0,0 +1,98 @@
+package modbustcp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDataStoreSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	s := NewDataStore(10, 3, 4, 2)
+	s.SetBits(TableCoils, 8, []bool{true, true})
+	s.SetBits(TableDiscreteInputs, 0, []bool{false, true})
+	s.SetRegisters(TableHoldingRegisters, 1, []uint16{0x1234, 0xffff})
+	s.SetRegisters(TableInputRegisters, 1, []uint16{7})
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	// a smaller store takes the values which fit
+	restored := NewDataStore(9, 3, 2, 2)
+	if err := restored.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	coils, _ := restored.GetBits(TableCoils, 7, 2)
+	if coils[0] || !coils[1] {
+		t.Fatalf("coils expected [false true], actual %v", coils)
+	}
+	inputs, _ := restored.GetBits(TableDiscreteInputs, 0, 3)
+	if inputs[0] || !inputs[1] || inputs[2] {
+		t.Fatalf("discrete inputs expected [false true false], actual %v", inputs)
+	}
+	regs, _ := restored.GetRegisters(TableHoldingRegisters, 0, 2)
+	if regs[0] != 0 || regs[1] != 0x1234 {
+		t.Fatalf("holding registers expected [0 4660], actual %v", regs)
+	}
+	regs, _ = restored.GetRegisters(TableInputRegisters, 1, 1)
+	if regs[0] != 7 {
+		t.Fatalf("input register expected 7, actual %v", regs[0])
+	}
+	if err := restored.Load(filepath.Join(t.TempDir(), "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("error expected os.ErrNotExist, actual %v", err)
+	}
+}
+
+func TestPersisterWriteThrough(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	p := &Persister{Store: NewDataStore(0, 0, 4, 0), Path: path}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatalf("unchanged store expected not to be saved")
+	}
+	p.Store.SetRegisters(TableHoldingRegisters, 2, []uint16{42})
+	restored := NewDataStore(0, 0, 4, 0)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if restored.Load(path) == nil {
+			if regs, _ := restored.GetRegisters(TableHoldingRegisters, 2, 1); regs[0] == 42 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("write expected to be saved")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPersisterInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	p := &Persister{Store: NewDataStore(0, 0, 4, 0), Path: path, Interval: time.Hour}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	p.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{1, 2, 3})
+	if _, err := os.Stat(path); err == nil {
+		t.Fatalf("store expected to be saved at the interval")
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	// a restarted persister loads the values saved by Stop
+	p = &Persister{Store: NewDataStore(0, 0, 4, 0), Path: path, Interval: time.Hour}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	regs, _ := p.Store.GetRegisters(TableHoldingRegisters, 0, 4)
+	if regs[0] != 1 || regs[2] != 3 || regs[3] != 0 {
+		t.Fatalf("registers expected [1 2 3 0], actual %v", regs)
+	}
+}
@@ -0,0 +1,318 @@
+package modbustcp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pipeline multiplexes the concurrent transactions of a client on one
+// connection. Requests are written as soon as an in-flight slot is free,
+// a reader goroutine completes the pending transactions by the
+// transaction ids of the responses, which may arrive in any order.
+type pipeline struct {
+	client *ModbusTcpClient
+	conn   net.Conn
+	slots  chan struct{}
+
+	mu sync.Mutex
+	// pending completes the transactions by their ids, exactly once by
+	// whoever removes them
+	pending map[uint16]func(pipelineResult)
+	// err is the failure which stopped the reader, nil while running
+	err error
+}
+
+type pipelineResult struct {
+	adu []byte
+	err error
+}
+
+func newPipeline(client *ModbusTcpClient, conn net.Conn, inFlight int, logger func(format string, v ...interface{})) *pipeline {
+	p := &pipeline{
+		client:  client,
+		conn:    conn,
+		slots:   make(chan struct{}, inFlight),
+		pending: make(map[uint16]func(pipelineResult)),
+	}
+	// a deadline left by the serial transport would stop the reader
+	conn.SetReadDeadline(time.Time{})
+	go p.read(logger)
+	return p
+}
+
+// read completes the pending transactions with the responses received
+// until the connection fails, which fails all of them and detaches the
+// connection from the client, so that the next request reconnects.
+func (p *pipeline) read(logger func(format string, v ...interface{})) {
+	err := p.receive(logger)
+	p.fail(err)
+	// the pending transactions are failed first, as requests waiting
+	// for an in-flight slot hold the lock
+	p.client.lock.Lock(PriorityHigh)
+	p.client.dropPipeline(p)
+	p.client.lock.Unlock()
+}
+
+// receive completes the pending transactions with the responses received
+// until reading fails.
+func (p *pipeline) receive(logger func(format string, v ...interface{})) error {
+	r := bufio.NewReader(p.conn)
+	var header [HeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return err
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if length <= 0 || length > MaxLength-(HeaderSize-1) {
+			// the stream cannot be resynchronized
+			return fmt.Errorf("modbus: length in response header '%v' must be between '%v' and '%v'", length, 1, MaxLength-HeaderSize+1)
+		}
+		adu := make([]byte, HeaderSize-1+length)
+		copy(adu, header[:])
+		if _, err := io.ReadFull(r, adu[HeaderSize:]); err != nil {
+			return err
+		}
+		if logger != nil {
+			logger("modbus: received % x\n", adu)
+		}
+		tid := binary.BigEndian.Uint16(adu)
+		p.mu.Lock()
+		complete, ok := p.pending[tid]
+		delete(p.pending, tid)
+		p.mu.Unlock()
+		if !ok {
+			if logger != nil {
+				logger("modbus: discarding response of unknown transaction '%v'\n", tid)
+			}
+			continue
+		}
+		complete(pipelineResult{adu: adu})
+	}
+}
+
+// fail closes the connection, stops accepting transactions and fails the
+// pending ones.
+func (p *pipeline) fail(err error) {
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	p.conn.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	for tid, complete := range p.pending {
+		delete(p.pending, tid)
+		complete(pipelineResult{err: p.err})
+	}
+}
+
+// failed returns the failure of the connection, nil while running.
+func (p *pipeline) failed() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// send writes the request adu, complete is called with its response
+// unless sending fails. It must not block, as it runs on the reader. The
+// caller holds an in-flight slot and the lock of the client, which
+// serializes writes.
+func (p *pipeline) send(adu []byte, timeout time.Duration, complete func(pipelineResult)) error {
+	tid := binary.BigEndian.Uint16(adu)
+	p.mu.Lock()
+	if p.err != nil {
+		err := p.err
+		p.mu.Unlock()
+		return err
+	}
+	if _, ok := p.pending[tid]; ok {
+		p.mu.Unlock()
+		return fmt.Errorf("modbus: transaction id '%v' is still pending", tid)
+	}
+	p.pending[tid] = complete
+	p.mu.Unlock()
+	err := p.conn.SetWriteDeadline(time.Now().Add(timeout))
+	if err == nil {
+		_, err = p.conn.Write(adu)
+	}
+	if err != nil {
+		// a partial write leaves the stream unusable, the transaction is
+		// removed first so that only the caller sees the failure
+		p.cancel(tid)
+		p.fail(err)
+		return err
+	}
+	return nil
+}
+
+// cancel removes the pending transaction tid and reports whether it was
+// still pending, i.e. whether the caller completes it.
+func (p *pipeline) cancel(tid uint16) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.pending[tid]
+	delete(p.pending, tid)
+	return ok
+}
+
+// timeoutError returns the error of transaction tid receiving no response
+// within timeout.
+func timeoutError(tid uint16, timeout time.Duration) error {
+	return fmt.Errorf("modbus: no response to transaction '%v' within %v: %w", tid, timeout, os.ErrDeadlineExceeded)
+}
+
+// wait returns the response of the transaction tid sent on done, or a
+// timeout error once the timeout expires.
+func (p *pipeline) wait(tid uint16, done chan pipelineResult, timeout time.Duration) ([]byte, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.adu, r.err
+	case <-timer.C:
+	}
+	if !p.cancel(tid) {
+		// completed while timing out
+		r := <-done
+		return r.adu, r.err
+	}
+	return nil, timeoutError(tid, timeout)
+}
+
+// executePipelined sends the request of ExecutePriority without waiting
+// for the responses of other transactions, see MaxInFlight.
+func (c *ModbusTcpClient) executePipelined(prio Priority, unit byte, request *Pdu) (*Pdu, error) {
+	c.lock.Lock(prio)
+	aduRequest, err := c.encode(unit, request)
+	if err == nil {
+		err = c.dryRun(request, aduRequest)
+	}
+	var p *pipeline
+	if err == nil {
+		p, err = c.pipeline()
+	}
+	if err != nil {
+		c.lock.Unlock()
+		return nil, err
+	}
+	// waiting for a slot in the lock grants free slots by priority
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+	if c.Logger != nil {
+		c.Logger.Printf("modbus: sending % x\n", aduRequest)
+	}
+	timeout := c.Timeout
+	done := make(chan pipelineResult, 1)
+	err = p.send(aduRequest, timeout, func(r pipelineResult) { done <- r })
+	if err != nil {
+		c.dropPipeline(p)
+	}
+	c.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	aduResponse, err := p.wait(binary.BigEndian.Uint16(aduRequest), done, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return c.response(request, aduRequest, aduResponse)
+}
+
+// executePipelinedAsync sends the request of ExecuteAsync like
+// executePipelined, but returns once it is written. The future is
+// completed by the reader of the pipeline or the expiry of the timeout.
+func (c *ModbusTcpClient) executePipelinedAsync(prio Priority, unit byte, request *Pdu, done func()) *Future[*Pdu] {
+	future := newFuture[*Pdu]()
+	c.lock.Lock(prio)
+	aduRequest, err := c.encode(unit, request)
+	if err == nil {
+		err = c.dryRun(request, aduRequest)
+	}
+	var p *pipeline
+	if err == nil {
+		p, err = c.pipeline()
+	}
+	if err != nil {
+		c.lock.Unlock()
+		done()
+		future.complete(nil, err)
+		return future
+	}
+	p.slots <- struct{}{}
+	if c.Logger != nil {
+		c.Logger.Printf("modbus: sending % x\n", aduRequest)
+	}
+	timeout := c.Timeout
+	tid := binary.BigEndian.Uint16(aduRequest)
+	var timer *time.Timer
+	complete := func(r pipelineResult) {
+		timer.Stop()
+		<-p.slots
+		done()
+		if r.err != nil {
+			future.complete(nil, r.err)
+			return
+		}
+		future.complete(c.response(request, aduRequest, r.adu))
+	}
+	timer = time.AfterFunc(timeout, func() {
+		if p.cancel(tid) {
+			complete(pipelineResult{err: timeoutError(tid, timeout)})
+		}
+	})
+	if err = p.send(aduRequest, timeout, complete); err != nil {
+		c.dropPipeline(p)
+		complete(pipelineResult{err: err})
+	}
+	c.lock.Unlock()
+	return future
+}
+
+// pipeline returns the pipeline of the current connection, connecting
+// first if there is none. The caller holds the lock of the client.
+func (c *ModbusTcpClient) pipeline() (*pipeline, error) {
+	if c.pipe != nil && c.pipe.failed() != nil {
+		// the reader has not detached the connection yet
+		c.dropPipeline(c.pipe)
+	}
+	if c.Conn == nil {
+		if err := c.Connect(); err != nil {
+			return nil, err
+		}
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = TimeoutMillis * time.Millisecond
+	}
+	if c.pipe != nil && c.pipe.conn == c.Conn {
+		return c.pipe, nil
+	}
+	var logger func(format string, v ...interface{})
+	if c.Logger != nil {
+		logger = c.Logger.Printf
+	}
+	c.pipe = newPipeline(c, c.Conn, c.MaxInFlight, logger)
+	return c.pipe, nil
+}
+
+// dropPipeline forgets the failed pipeline p and its connection, so that
+// the next request reconnects. The caller holds the lock of the client.
+func (c *ModbusTcpClient) dropPipeline(p *pipeline) {
+	if c.pipe != p {
+		return
+	}
+	c.connMu.Lock()
+	if c.Conn == p.conn {
+		c.Conn = nil
+	}
+	c.connMu.Unlock()
+	c.pipe = nil
+}
@@ -0,0 +1,163 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newPipelineClient returns a pipelining client of a fake slave, which
+// is passed the request adus received on each connection and answers
+// them itself.
+func newPipelineClient(t *testing.T, slave func(conn net.Conn, requests <-chan []byte)) *ModbusTcpClient {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			requests := make(chan []byte)
+			go func() {
+				defer close(requests)
+				for {
+					var header [HeaderSize]byte
+					if _, err := io.ReadFull(conn, header[:]); err != nil {
+						return
+					}
+					adu := make([]byte, HeaderSize-1+int(binary.BigEndian.Uint16(header[4:])))
+					copy(adu, header[:])
+					if _, err := io.ReadFull(conn, adu[HeaderSize:]); err != nil {
+						return
+					}
+					requests <- adu
+				}
+			}()
+			go slave(conn, requests)
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	c := NewModbusTcpClient(addr.IP.String(), addr.Port)
+	c.Timeout = time.Second
+	c.MaxInFlight = 8
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+// readResponse answers a read of one holding register with its address.
+func readResponse(request []byte) []byte {
+	adu := append([]byte(nil), request[:HeaderSize+1]...)
+	binary.BigEndian.PutUint16(adu[4:], 5)
+	return append(adu, 2, request[HeaderSize+1], request[HeaderSize+2])
+}
+
+func TestPipelineOutOfOrder(t *testing.T) {
+	c := newPipelineClient(t, func(conn net.Conn, requests <-chan []byte) {
+		// all requests are received before the first response
+		var batch [][]byte
+		for request := range requests {
+			if batch = append(batch, request); len(batch) < 8 {
+				continue
+			}
+			for i := len(batch) - 1; i >= 0; i-- {
+				conn.Write(readResponse(batch[i]))
+			}
+			batch = nil
+		}
+	})
+	var wg sync.WaitGroup
+	for i := uint16(0); i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			regs, err := c.ReadHoldingRegisters(i, 1)
+			if err != nil {
+				t.Error(err)
+			} else if regs[0] != i {
+				t.Errorf("register %v expected %v, actual %v", i, i, regs[0])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPipelineTimeout(t *testing.T) {
+	c := newPipelineClient(t, func(conn net.Conn, requests <-chan []byte) {
+		for request := range requests {
+			// the first request is never answered
+			if binary.BigEndian.Uint16(request[HeaderSize+1:]) != 0 {
+				conn.Write(readResponse(request))
+			}
+		}
+	})
+	c.Timeout = 50 * time.Millisecond
+	if _, err := c.ReadHoldingRegisters(0, 1); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("error expected %v, actual %v", os.ErrDeadlineExceeded, err)
+	}
+	if regs, err := c.ReadHoldingRegisters(1, 1); err != nil || regs[0] != 1 {
+		t.Fatalf("register expected 1, actual %v %v", regs, err)
+	}
+}
+
+func TestPipelineConnectionLost(t *testing.T) {
+	var conns atomic.Int32
+	c := newPipelineClient(t, func(conn net.Conn, requests <-chan []byte) {
+		if conns.Add(1) == 1 {
+			// the first connection is lost after two requests
+			<-requests
+			<-requests
+			conn.Close()
+			return
+		}
+		for request := range requests {
+			conn.Write(readResponse(request))
+		}
+	})
+	var wg sync.WaitGroup
+	for i := uint16(0); i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.ReadHoldingRegisters(i, 1); !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("error expected %v, actual %v", io.ErrUnexpectedEOF, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if regs, err := c.ReadHoldingRegisters(5, 1); err != nil || regs[0] != 5 {
+		t.Fatalf("register after reconnecting expected 5, actual %v %v", regs, err)
+	}
+	if n := conns.Load(); n != 2 {
+		t.Fatalf("connections expected 2, actual %v", n)
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	for _, inFlight := range []int{1, 32} {
+		b.Run(fmt.Sprintf("in-flight-%v", inFlight), func(b *testing.B) {
+			c := startServer(b, NewServer())
+			c.MaxInFlight = inFlight
+			b.SetParallelism(32)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := c.ReadHoldingRegisters(0, 1); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
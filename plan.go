@@ -0,0 +1,183 @@
+package modbustcp
+
+import (
+	"sort"
+)
+
+// AddressRange is a contiguous range of a data table of a unit.
+type AddressRange struct {
+	UnitId   byte
+	Table    Table
+	Address  uint16
+	Quantity uint16
+}
+
+// end returns the address following the range.
+func (r AddressRange) end() int {
+	return int(r.Address) + int(r.Quantity)
+}
+
+// Contains reports whether other lies completely within r.
+func (r AddressRange) Contains(other AddressRange) bool {
+	return r.UnitId == other.UnitId && r.Table == other.Table &&
+		other.Address >= r.Address && other.end() <= r.end()
+}
+
+// PlanOptions tunes the coalescing of ranges by PlanReads.
+type PlanOptions struct {
+	// MaxGap is the number of unrequested registers or bits which may be
+	// read to merge two ranges.
+	MaxGap uint16
+	// MaxRegisters limits merged register blocks, 125 if zero.
+	MaxRegisters uint16
+	// MaxBits limits merged coil and discrete input blocks, 2000 if zero.
+	MaxBits uint16
+}
+
+func (o PlanOptions) limit(t Table) int {
+	if t.IsBit() {
+		if o.MaxBits > 0 {
+			return int(o.MaxBits)
+		}
+		return MaxReadBits
+	}
+	if o.MaxRegisters > 0 {
+		return int(o.MaxRegisters)
+	}
+	return MaxReadRegisters
+}
+
+// PlanReads returns the minimal set of block reads covering ranges. Ranges
+// of the same unit and table are merged when the gap between them does
+// not exceed MaxGap and the merged block stays within the size limit.
+// Ranges exceeding the limit on their own are returned unchanged.
+func PlanReads(ranges []AddressRange, opts PlanOptions) []AddressRange {
+	sorted := append([]AddressRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.UnitId != b.UnitId {
+			return a.UnitId < b.UnitId
+		}
+		if a.Table != b.Table {
+			return a.Table < b.Table
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.Quantity > b.Quantity
+	})
+	var plan []AddressRange
+	for _, r := range sorted {
+		if r.Quantity == 0 {
+			continue
+		}
+		if n := len(plan); n > 0 {
+			last := &plan[n-1]
+			if last.UnitId == r.UnitId && last.Table == r.Table && int(r.Address) <= last.end()+int(opts.MaxGap) {
+				end := last.end()
+				if r.end() > end {
+					end = r.end()
+				}
+				if end-int(last.Address) <= opts.limit(r.Table) {
+					last.Quantity = uint16(end - int(last.Address))
+					continue
+				}
+			}
+		}
+		plan = append(plan, r)
+	}
+	return plan
+}
+
+// readRange reads the registers or bits of r with the given priority.
+func (c *ModbusTcpClient) readRange(prio Priority, r AddressRange) ([]uint16, []bool, error) {
+	if r.Table.IsBit() {
+		bits, err := c.readBits(prio, r.UnitId, r.Table.ReadFunction(), r.Address, r.Quantity)
+		return nil, bits, err
+	}
+	regs, err := c.readRegisters(prio, r.UnitId, r.Table.ReadFunction(), r.Address, r.Quantity)
+	return regs, nil, err
+}
+
+// RangeResult holds the outcome of one range passed to ReadRanges.
+// Registers is set for register tables, Bits for coils and discrete inputs.
+type RangeResult struct {
+	Range     AddressRange
+	Registers []uint16
+	Bits      []bool
+	Err       error
+}
+
+// ReadRanges reads several ranges with as few requests as PlanReads
+// allows. A range with unit id zero is read from SlaveId. When a merged
+// block is rejected with an exception, its ranges are read one by one so
+// that a single invalid range does not fail the others. The returned
+// error is set only if the connection failed, the results then carry
+// the error for all ranges not read.
+func (c *ModbusTcpClient) ReadRanges(ranges []AddressRange, opts ...PlanOptions) ([]RangeResult, error) {
+	var o PlanOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	results := make([]RangeResult, len(ranges))
+	var valid []AddressRange
+	for i, r := range ranges {
+		if r.UnitId == 0 {
+			r.UnitId = c.SlaveId
+		}
+		results[i].Range = r
+		if results[i].Err = c.checkAddressRange(r); results[i].Err == nil {
+			valid = append(valid, r)
+		}
+	}
+	var fatal error
+	read := func(r AddressRange) ([]uint16, []bool, error) {
+		if fatal != nil {
+			return nil, nil, fatal
+		}
+		regs, bits, err := c.readRange(PriorityNormal, r)
+		if err != nil && !IsException(err) {
+			fatal = err
+		}
+		return regs, bits, err
+	}
+	for _, block := range PlanReads(valid, o) {
+		regs, bits, err := read(block)
+		for i := range results {
+			res := &results[i]
+			if res.Err != nil || res.Registers != nil || res.Bits != nil || !block.Contains(res.Range) {
+				continue
+			}
+			switch {
+			case err == nil:
+				res.setFrom(block, regs, bits)
+			case IsException(err) && block != res.Range:
+				res.Registers, res.Bits, res.Err = read(res.Range)
+			default:
+				res.Err = err
+			}
+		}
+	}
+	return results, fatal
+}
+
+// setFrom fills r with its part of the data read for block.
+func (r *RangeResult) setFrom(block AddressRange, regs []uint16, bits []bool) {
+	offset := int(r.Range.Address) - int(block.Address)
+	n := offset + int(r.Range.Quantity)
+	if regs != nil {
+		r.Registers = append([]uint16(nil), regs[offset:n]...)
+	}
+	if bits != nil {
+		r.Bits = append([]bool(nil), bits[offset:n]...)
+	}
+}
+
+// checkAddressRange validates r without contacting the device.
+func (c *ModbusTcpClient) checkAddressRange(r AddressRange) error {
+	address, err := c.protocolAddress(r.Address)
+	if err != nil {
+		return err
+	}
+	return checkRange(address, int(r.Quantity))
+}
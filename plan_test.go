@@ -0,0 +1,73 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestPlanReads(t *testing.T) {
+	ranges := []AddressRange{
+		{Table: TableHoldingRegisters, Address: 10, Quantity: 2},
+		{Table: TableHoldingRegisters, Address: 0, Quantity: 4},
+		{Table: TableHoldingRegisters, Address: 6, Quantity: 1},
+		{Table: TableHoldingRegisters, Address: 100, Quantity: 30},
+		{Table: TableCoils, Address: 6, Quantity: 1},
+		{UnitId: 2, Table: TableHoldingRegisters, Address: 4, Quantity: 1},
+	}
+	plan := PlanReads(ranges, PlanOptions{MaxGap: 3, MaxRegisters: 100})
+	expected := []AddressRange{
+		{Table: TableCoils, Address: 6, Quantity: 1},
+		{Table: TableHoldingRegisters, Address: 0, Quantity: 12},
+		{Table: TableHoldingRegisters, Address: 100, Quantity: 30},
+		{UnitId: 2, Table: TableHoldingRegisters, Address: 4, Quantity: 1},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Fatalf("plan expected %v, actual %v", expected, plan)
+	}
+	plan = PlanReads(ranges[:3], PlanOptions{MaxGap: 3, MaxRegisters: 8})
+	if len(plan) != 2 || plan[0].Quantity != 7 {
+		t.Fatalf("plan limited to 8 registers expected [0+7 10+2], actual %v", plan)
+	}
+}
+
+func TestReadRanges(t *testing.T) {
+	var requests int
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		requests++
+		address := binary.BigEndian.Uint16(request.Data)
+		quantity := binary.BigEndian.Uint16(request.Data[2:])
+		if int(address)+int(quantity) > 20 {
+			return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{ExcIllegalDataAdr}}
+		}
+		data := []byte{byte(2 * quantity)}
+		for i := uint16(0); i < quantity; i++ {
+			data = append(data, 0, byte(address+i))
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: data}
+	})
+	results, err := c.ReadRanges([]AddressRange{
+		{Table: TableHoldingRegisters, Address: 2, Quantity: 2},
+		{Table: TableHoldingRegisters, Address: 18, Quantity: 4},
+		{Table: TableHoldingRegisters, Address: 5, Quantity: 0},
+		{Table: TableHoldingRegisters, Address: 10, Quantity: 1},
+	}, PlanOptions{MaxGap: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results[0].Registers, []uint16{2, 3}) || results[0].Err != nil {
+		t.Fatalf("first range expected [2 3], actual %v %v", results[0].Registers, results[0].Err)
+	}
+	if results[1].Err != ErrorIllegalDataAddress {
+		t.Fatalf("second range error expected %v, actual %v", ErrorIllegalDataAddress, results[1].Err)
+	}
+	if results[2].Err == nil {
+		t.Fatalf("empty range expected error")
+	}
+	if !reflect.DeepEqual(results[3].Registers, []uint16{10}) {
+		t.Fatalf("fourth range expected [10], actual %v", results[3].Registers)
+	}
+	if requests != 4 {
+		t.Fatalf("requests expected %v, actual %v", 4, requests)
+	}
+}
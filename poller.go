@@ -0,0 +1,297 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PollGroup is a set of tags polled at a common interval.
+type PollGroup struct {
+	Name     string
+	Interval time.Duration
+	Tags     []Tag
+}
+
+// TagUpdate carries a polled value of a tag.
+type TagUpdate struct {
+	Group string
+	Tag   string
+	Time  time.Time
+	Reading
+}
+
+// GroupStatus summarizes the polling of a group.
+type GroupStatus struct {
+	LastPoll  time.Time
+	LastError error
+	Polls     uint64
+	Errors    uint64
+}
+
+// Poller continuously polls groups of tags on their schedules and delivers
+// decoded updates over a channel or to a handler.
+type Poller struct {
+	Client *ModbusTcpClient
+	// Handler receives the updates if set, otherwise they are delivered
+	// over the Updates channel.
+	Handler func(TagUpdate)
+	// ErrorHandler is invoked with the group name for each failed poll.
+	ErrorHandler func(group string, err error)
+	// Plan controls how the tags of a group are coalesced into block reads.
+	Plan PlanOptions
+	// ChangeOnly suppresses updates of values which did not change beyond
+	// the deadband of their tag since the last report.
+	ChangeOnly bool
+	// Alarms evaluates every polled value, regardless of ChangeOnly.
+	Alarms *AlarmEngine
+	// Derived computes derived tags from the polled values, their updates
+	// are delivered like polled ones.
+	Derived *DerivedTags
+
+	groups  []PollGroup
+	updates chan TagUpdate
+	status  map[string]*GroupStatus
+	last    map[[2]string]TagUpdate
+	mu      sync.Mutex
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	subs    map[*subscription]bool
+}
+
+// subscription delivers the updates of a set of tags, all if nil.
+type subscription struct {
+	tags map[string]bool
+	ch   chan TagUpdate
+}
+
+// CancelFunc ends a subscription.
+type CancelFunc func()
+
+// NewPoller creates a poller reading the groups through client.
+func NewPoller(client *ModbusTcpClient, groups ...PollGroup) *Poller {
+	return &Poller{
+		Client:  client,
+		groups:  groups,
+		updates: make(chan TagUpdate, 64),
+		status:  make(map[string]*GroupStatus),
+		last:    make(map[[2]string]TagUpdate),
+		subs:    make(map[*subscription]bool),
+	}
+}
+
+// Updates returns the channel delivering updates when no Handler is set
+// and there are no subscriptions. It is closed by Stop.
+func (p *Poller) Updates() <-chan TagUpdate {
+	return p.updates
+}
+
+// Start begins polling each group in its own goroutine.
+func (p *Poller) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return fmt.Errorf("modbus: poller already started")
+	}
+	for i := range p.groups {
+		if p.groups[i].Interval <= 0 {
+			return fmt.Errorf("modbus: poll group '%v' has no interval", p.groups[i].Name)
+		}
+	}
+	p.stop = make(chan struct{})
+	for i := range p.groups {
+		g := &p.groups[i]
+		p.status[g.Name] = &GroupStatus{}
+		p.wg.Add(1)
+		go p.run(g)
+	}
+	return nil
+}
+
+// Stop ends polling, waits for running polls to complete and closes the
+// Updates channel.
+func (p *Poller) Stop() {
+	p.mu.Lock()
+	if p.stop == nil {
+		p.mu.Unlock()
+		return
+	}
+	close(p.stop)
+	p.mu.Unlock()
+	p.wg.Wait()
+	close(p.updates)
+	p.mu.Lock()
+	for s := range p.subs {
+		close(s.ch)
+		delete(p.subs, s)
+	}
+	p.mu.Unlock()
+}
+
+// Subscribe returns a channel receiving the updates of the named tags, of
+// all tags if none are given. Updates are dropped while the buffer of the
+// channel is full so that a slow subscriber does not stall polling.
+// The channel is closed by cancel or Stop.
+func (p *Poller) Subscribe(tags ...string) (<-chan TagUpdate, CancelFunc) {
+	s := &subscription{ch: make(chan TagUpdate, 64)}
+	if len(tags) > 0 {
+		s.tags = make(map[string]bool, len(tags))
+		for _, name := range tags {
+			s.tags[name] = true
+		}
+	}
+	p.mu.Lock()
+	p.subs[s] = true
+	p.mu.Unlock()
+	cancel := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.subs[s] {
+			close(s.ch)
+			delete(p.subs, s)
+		}
+	}
+	return s.ch, cancel
+}
+
+// publish passes u to the matching subscriptions and reports whether
+// there are any subscriptions.
+func (p *Poller) publish(u TagUpdate) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for s := range p.subs {
+		if s.tags != nil && !s.tags[u.Tag] {
+			continue
+		}
+		select {
+		case s.ch <- u:
+		default:
+		}
+	}
+	return len(p.subs) > 0
+}
+
+// Status returns the status of the named group.
+func (p *Poller) Status(group string) (GroupStatus, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.status[group]
+	if !ok {
+		return GroupStatus{}, false
+	}
+	return *s, true
+}
+
+func (p *Poller) run(g *PollGroup) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+	for {
+		p.poll(g)
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads all tags of the group once, coalescing them into as few
+// block reads as the plan options permit.
+func (p *Poller) poll(g *PollGroup) {
+	now := time.Now()
+	var pollErr error
+	ranges := make([]AddressRange, len(g.Tags))
+	for i := range g.Tags {
+		ranges[i] = p.Client.tagRange(&g.Tags[i])
+	}
+	for _, block := range PlanReads(ranges, p.Plan) {
+		regs, bits, readErr := p.Client.readRange(PriorityLow, block)
+		for i := range g.Tags {
+			tag := &g.Tags[i]
+			if !block.Contains(ranges[i]) {
+				continue
+			}
+			r, err := Reading{}, readErr
+			if err == nil {
+				r, err = decodeTag(tag, block, regs, bits)
+			}
+			if err != nil {
+				if pollErr == nil {
+					pollErr = fmt.Errorf("modbus: tag '%v': %w", tag.Name, err)
+				}
+				continue
+			}
+			u := TagUpdate{Group: g.Name, Tag: tag.Name, Time: now, Reading: r}
+			p.report(tag, u)
+			if p.Derived != nil {
+				for _, derived := range p.Derived.Update(u) {
+					p.report(&Tag{Name: derived.Tag}, derived)
+				}
+			}
+		}
+	}
+	p.record(g, now, pollErr)
+}
+
+// report evaluates the alarms of u and delivers it unless it is
+// suppressed as unchanged.
+func (p *Poller) report(tag *Tag, u TagUpdate) {
+	if p.Alarms != nil {
+		p.Alarms.Record(u)
+	}
+	if p.ChangeOnly && !p.changed(tag, u) {
+		return
+	}
+	p.deliver(u)
+}
+
+// fail records a poll of g which could not be attempted.
+func (p *Poller) fail(g *PollGroup, err error) {
+	p.record(g, time.Now(), err)
+}
+
+func (p *Poller) record(g *PollGroup, now time.Time, pollErr error) {
+	p.mu.Lock()
+	s := p.status[g.Name]
+	s.LastPoll = now
+	s.LastError = pollErr
+	s.Polls++
+	if pollErr != nil {
+		s.Errors++
+	}
+	p.mu.Unlock()
+	if pollErr != nil && p.ErrorHandler != nil {
+		p.ErrorHandler(g.Name, pollErr)
+	}
+}
+
+// changed reports whether u has to be reported and records it as the last
+// reported update of the tag if so.
+func (p *Poller) changed(tag *Tag, u TagUpdate) bool {
+	key := [2]string{u.Group, u.Tag}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last, ok := p.last[key]
+	if ok && !tag.exceedsDeadband(last.Value, u.Value) && last.Label == u.Label &&
+		(tag.MaxReportInterval <= 0 || u.Time.Sub(last.Time) < tag.MaxReportInterval) {
+		return false
+	}
+	p.last[key] = u
+	return true
+}
+
+func (p *Poller) deliver(u TagUpdate) {
+	subscribed := p.publish(u)
+	if p.Handler != nil {
+		p.Handler(u)
+		return
+	}
+	if subscribed {
+		return
+	}
+	select {
+	case p.updates <- u:
+	case <-p.stop:
+	}
+}
@@ -0,0 +1,78 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoller(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0x00, 0x2A}}
+	})
+	p := NewPoller(c, PollGroup{
+		Name:     "fast",
+		Interval: 10 * time.Millisecond,
+		Tags:     []Tag{{Name: "level", Table: TableHoldingRegisters, Address: 1}},
+	})
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	u := <-p.Updates()
+	p.Stop()
+	if u.Group != "fast" || u.Tag != "level" || u.Value != 42 {
+		t.Fatalf("update expected fast/level/42, actual %v/%v/%v", u.Group, u.Tag, u.Value)
+	}
+	if s, _ := p.Status("fast"); s.Polls == 0 {
+		t.Fatal("status expected to count polls")
+	}
+}
+
+func TestPollerDeadband(t *testing.T) {
+	p := NewPoller(nil)
+	p.ChangeOnly = true
+	tag := &Tag{Name: "temp", Deadband: 0.5, MaxReportInterval: time.Minute}
+	now := time.Now()
+	steps := []struct {
+		value    float64
+		offset   time.Duration
+		reported bool
+	}{
+		{20, 0, true},
+		{20.3, time.Second, false},
+		{20.6, 2 * time.Second, true},
+		{20.6, time.Minute, false},
+		{20.6, 2*time.Minute + 2*time.Second, true},
+	}
+	for i, step := range steps {
+		u := TagUpdate{Tag: tag.Name, Time: now.Add(step.offset), Reading: Reading{Value: step.value}}
+		if reported := p.changed(tag, u); reported != step.reported {
+			t.Errorf("step %v: reported expected %v, actual %v", i, step.reported, reported)
+		}
+	}
+}
+
+func TestPollerSubscribe(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{4, 0x00, 0x01, 0x00, 0x02}}
+	})
+	p := NewPoller(c, PollGroup{
+		Name:     "fast",
+		Interval: 10 * time.Millisecond,
+		Tags: []Tag{
+			{Name: "a", Table: TableHoldingRegisters, Address: 1},
+			{Name: "b", Table: TableHoldingRegisters, Address: 2},
+		},
+	})
+	updates, cancel := p.Subscribe("b")
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	u := <-updates
+	cancel()
+	p.Stop()
+	if u.Tag != "b" || u.Value != 2 {
+		t.Fatalf("update expected b/2, actual %v/%v", u.Tag, u.Value)
+	}
+	for range updates {
+	}
+}
@@ -0,0 +1,66 @@
+package modbustcp
+
+import (
+	"sync"
+)
+
+// Priority orders the requests waiting for the connection of a client.
+type Priority int
+
+const (
+	// PriorityLow is used for background traffic like polling
+	PriorityLow Priority = iota
+	// PriorityNormal is used by the request methods of the client
+	PriorityNormal
+	// PriorityHigh preempts all other waiting requests
+	PriorityHigh
+
+	numPriorities = int(PriorityHigh) + 1
+)
+
+// priorityLock is a mutex granted to the waiter of the highest priority.
+// A request in progress is never interrupted.
+type priorityLock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	busy    bool
+	waiting [numPriorities]int
+}
+
+func (l *priorityLock) Lock(prio Priority) {
+	if prio < PriorityLow {
+		prio = PriorityLow
+	} else if prio > PriorityHigh {
+		prio = PriorityHigh
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cond == nil {
+		l.cond = sync.NewCond(&l.mu)
+	}
+	l.waiting[prio]++
+	for l.busy || l.preempted(prio) {
+		l.cond.Wait()
+	}
+	l.waiting[prio]--
+	l.busy = true
+}
+
+func (l *priorityLock) Unlock() {
+	l.mu.Lock()
+	l.busy = false
+	if l.cond != nil {
+		l.cond.Broadcast()
+	}
+	l.mu.Unlock()
+}
+
+// preempted reports whether a waiter of a higher priority than prio exists.
+func (l *priorityLock) preempted(prio Priority) bool {
+	for p := int(prio) + 1; p < numPriorities; p++ {
+		if l.waiting[p] > 0 {
+			return true
+		}
+	}
+	return false
+}
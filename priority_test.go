@@ -0,0 +1,32 @@
+package modbustcp
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPriorityLock(t *testing.T) {
+	var l priorityLock
+	l.Lock(PriorityNormal)
+	var mu sync.Mutex
+	var order []Priority
+	var wg sync.WaitGroup
+	for _, prio := range []Priority{PriorityLow, PriorityNormal, PriorityHigh} {
+		wg.Add(1)
+		go func(prio Priority) {
+			defer wg.Done()
+			l.Lock(prio)
+			mu.Lock()
+			order = append(order, prio)
+			mu.Unlock()
+			l.Unlock()
+		}(prio)
+		time.Sleep(10 * time.Millisecond)
+	}
+	l.Unlock()
+	wg.Wait()
+	if order[0] != PriorityHigh || order[1] != PriorityNormal || order[2] != PriorityLow {
+		t.Fatalf("order expected [2 1 0], actual %v", order)
+	}
+}
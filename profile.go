@@ -0,0 +1,360 @@
+package modbustcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patdhlk/modbustcp/internal/yaml"
+)
+
+// Duration is a time.Duration read from configuration files either as a
+// string like "1.5s" or as a number of seconds.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case nil:
+		*d = 0
+	default:
+		return fmt.Errorf("modbus: invalid duration '%s'", b)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// AddressRef is an address in configuration files, accepted as a string
+// or as a number in Modicon notation.
+type AddressRef string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *AddressRef) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("modbus: invalid address '%s'", b)
+		}
+		s = n.String()
+	}
+	*a = AddressRef(s)
+	return nil
+}
+
+// TagConfig is the configuration file representation of a Tag.
+type TagConfig struct {
+	Name    string     `json:"name"`
+	UnitId  byte       `json:"unit_id,omitempty"`
+	Address AddressRef `json:"address"`
+	// Type is a data type name, "uint16" if empty. Bit tables ignore it.
+	Type string `json:"type,omitempty"`
+	// WordOrder overrides the word order of the profile.
+	WordOrder         string            `json:"word_order,omitempty"`
+	Gain              float64           `json:"gain,omitempty"`
+	Offset            float64           `json:"offset,omitempty"`
+	Unit              string            `json:"unit,omitempty"`
+	DisplayUnit       string            `json:"display_unit,omitempty"`
+	Enum              map[string]string `json:"enum,omitempty"`
+	Writable          bool              `json:"writable,omitempty"`
+	Deadband          float64           `json:"deadband,omitempty"`
+	DeadbandPercent   float64           `json:"deadband_percent,omitempty"`
+	MaxReportInterval Duration          `json:"max_report_interval,omitempty"`
+}
+
+// Tag builds the tag. Addresses in the configuration are protocol
+// offsets, they are converted for clients using one-based addressing.
+func (tc *TagConfig) Tag(order WordOrder, oneBased bool) (Tag, error) {
+	a, err := ParseAddress(string(tc.Address))
+	if err != nil {
+		return Tag{}, fmt.Errorf("modbus: tag '%v': %v", tc.Name, err)
+	}
+	address := a.Offset
+	if oneBased {
+		if address == math.MaxUint16 {
+			return Tag{}, fmt.Errorf("modbus: tag '%v': offset '%v' has no one-based address", tc.Name, address)
+		}
+		address++
+	}
+	tag := Tag{
+		Name:              tc.Name,
+		UnitId:            tc.UnitId,
+		Table:             a.Table,
+		Address:           address,
+		Writable:          tc.Writable,
+		Deadband:          tc.Deadband,
+		DeadbandPercent:   tc.DeadbandPercent,
+		MaxReportInterval: time.Duration(tc.MaxReportInterval),
+		Codec: Codec{
+			Order:       order,
+			Scale:       Scale{Gain: tc.Gain, Offset: tc.Offset},
+			Unit:        tc.Unit,
+			DisplayUnit: tc.DisplayUnit,
+		},
+	}
+	if tc.Type != "" {
+		if tag.Codec.Type, err = ParseDataType(tc.Type); err != nil {
+			return Tag{}, fmt.Errorf("modbus: tag '%v': %v", tc.Name, err)
+		}
+	}
+	if tc.WordOrder != "" {
+		if tag.Codec.Order, err = ParseWordOrder(tc.WordOrder); err != nil {
+			return Tag{}, fmt.Errorf("modbus: tag '%v': %v", tc.Name, err)
+		}
+	}
+	if len(tc.Enum) > 0 {
+		tag.Codec.Enum = Enum{}
+		for k, label := range tc.Enum {
+			v, err := strconv.ParseInt(k, 0, 64)
+			if err != nil {
+				return Tag{}, fmt.Errorf("modbus: tag '%v': invalid enum value '%v'", tc.Name, k)
+			}
+			tag.Codec.Enum[v] = label
+		}
+	}
+	return tag, nil
+}
+
+// DeviceProfile is a versioned template of a device type holding its
+// connection settings and tag list, e.g. "SDM630" or "S7-1200 mapping".
+type DeviceProfile struct {
+	Name      string      `json:"name"`
+	Version   string      `json:"version,omitempty"`
+	Port      int         `json:"port,omitempty"`
+	UnitId    byte        `json:"unit_id,omitempty"`
+	Timeout   Duration    `json:"timeout,omitempty"`
+	WordOrder string      `json:"word_order,omitempty"`
+	OneBased  bool        `json:"one_based,omitempty"`
+	MaskWrite bool        `json:"mask_write,omitempty"`
+	Tags      []TagConfig `json:"tags"`
+}
+
+// ParseProfile decodes a profile in "json" or "yaml" format.
+func ParseProfile(data []byte, format string) (*DeviceProfile, error) {
+	p := &DeviceProfile{}
+	if err := unmarshalConfig(data, format, p); err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		return nil, fmt.Errorf("modbus: profile has no name")
+	}
+	// validate the tags early
+	if _, err := p.BuildTags(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadProfile reads a profile from a .json, .yaml or .yml file.
+func LoadProfile(path string) (*DeviceProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := ParseProfile(data, configFormat(path))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return p, nil
+}
+
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return "json"
+}
+
+// unmarshalConfig decodes data in "json" or "yaml" format into v.
+func unmarshalConfig(data []byte, format string, v interface{}) error {
+	switch format {
+	case "json":
+		return json.Unmarshal(data, v)
+	case "yaml":
+		return yaml.Unmarshal(data, v)
+	}
+	return fmt.Errorf("modbus: unknown configuration format '%v'", format)
+}
+
+// BuildTags builds the tags of the profile.
+func (p *DeviceProfile) BuildTags() ([]Tag, error) {
+	var order WordOrder
+	if p.WordOrder != "" {
+		var err error
+		if order, err = ParseWordOrder(p.WordOrder); err != nil {
+			return nil, err
+		}
+	}
+	tags := make([]Tag, 0, len(p.Tags))
+	for i := range p.Tags {
+		tag, err := p.Tags[i].Tag(order, p.OneBased)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// NewClient creates a client for a device at host configured by the
+// profile. A zero unitId selects the unit of the profile.
+func (p *DeviceProfile) NewClient(host string, unitId byte) (*ModbusTcpClient, error) {
+	tags, err := p.BuildTags()
+	if err != nil {
+		return nil, err
+	}
+	db, err := NewTagDatabase(tags...)
+	if err != nil {
+		return nil, err
+	}
+	c := NewModbusTcpClient(host, p.Port)
+	c.SlaveId = p.UnitId
+	if unitId != 0 {
+		c.SlaveId = unitId
+	}
+	c.Timeout = time.Duration(p.Timeout)
+	c.OneBased = p.OneBased
+	c.MaskWrite = p.MaskWrite
+	c.Tags = db
+	if p.WordOrder != "" {
+		c.WordOrder, _ = ParseWordOrder(p.WordOrder)
+	}
+	return c, nil
+}
+
+// ProfileLibrary holds profiles by name and version.
+type ProfileLibrary struct {
+	mu       sync.RWMutex
+	profiles map[string][]*DeviceProfile
+}
+
+// NewProfileLibrary creates an empty profile library.
+func NewProfileLibrary() *ProfileLibrary {
+	return &ProfileLibrary{profiles: make(map[string][]*DeviceProfile)}
+}
+
+// Add adds p to the library, replacing a profile with the same name and version.
+func (l *ProfileLibrary) Add(p *DeviceProfile) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	versions := l.profiles[p.Name]
+	for i, existing := range versions {
+		if existing.Version == p.Version {
+			versions[i] = p
+			return
+		}
+	}
+	l.profiles[p.Name] = append(versions, p)
+}
+
+// LoadDir adds all .json, .yaml and .yml profiles of dir.
+func (l *ProfileLibrary) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+		p, err := LoadProfile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		l.Add(p)
+	}
+	return nil
+}
+
+// Lookup returns the profile referenced by "name@version" or by "name"
+// alone for the latest version.
+func (l *ProfileLibrary) Lookup(ref string) (*DeviceProfile, error) {
+	name, version, pinned := strings.Cut(ref, "@")
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var found *DeviceProfile
+	for _, p := range l.profiles[name] {
+		if pinned {
+			if p.Version == version {
+				return p, nil
+			}
+		} else if found == nil || compareVersions(p.Version, found.Version) > 0 {
+			found = p
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("modbus: unknown profile '%v'", ref)
+	}
+	return found, nil
+}
+
+// compareVersions compares dotted versions numerically where possible.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, errX := strconv.Atoi(x)
+		yn, errY := strconv.Atoi(y)
+		switch {
+		case errX == nil && errY == nil && xn != yn:
+			if xn < yn {
+				return -1
+			}
+			return 1
+		case (errX != nil || errY != nil) && x != y:
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// DeviceConfig configures a device instance by referencing a profile.
+type DeviceConfig struct {
+	Name    string `json:"name"`
+	Host    string `json:"host"`
+	UnitId  byte   `json:"unit_id,omitempty"`
+	Profile string `json:"profile"`
+}
+
+// NewClient creates the client of a device configured by its profile.
+func (l *ProfileLibrary) NewClient(dev DeviceConfig) (*ModbusTcpClient, error) {
+	p, err := l.Lookup(dev.Profile)
+	if err != nil {
+		return nil, err
+	}
+	return p.NewClient(dev.Host, dev.UnitId)
+}
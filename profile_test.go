@@ -0,0 +1,69 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+const testProfile = `
+name: SDM630
+version: "1.2"
+port: 502
+word_order: cdab
+tags:
+  - name: voltage
+    address: 30001
+    type: float32
+    unit: V
+  - name: mode
+    address: "holding:10"
+    writable: true
+    enum: {0: Off, 1: Auto}
+  - name: relay
+    address: 00123
+`
+
+func TestParseProfile(t *testing.T) {
+	p, err := ParseProfile([]byte(testProfile), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lib := NewProfileLibrary()
+	lib.Add(p)
+	lib.Add(&DeviceProfile{Name: "SDM630", Version: "1.10"})
+	lib.Add(&DeviceProfile{Name: "SDM630", Version: "1.9"})
+	if latest, _ := lib.Lookup("SDM630"); latest.Version != "1.10" {
+		t.Fatalf("version expected %v, actual %v", "1.10", latest.Version)
+	}
+	c, err := lib.NewClient(DeviceConfig{Name: "meter1", Host: "10.0.0.5", UnitId: 3, Profile: "SDM630@1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 502 || c.SlaveId != 3 || c.WordOrder != OrderCDAB {
+		t.Fatalf("unexpected client settings %v %v %v", c.Port, c.SlaveId, c.WordOrder)
+	}
+	voltage, _ := c.Tags.Get("voltage")
+	if voltage.Table != TableInputRegisters || voltage.Codec.Type != TypeFloat32 || voltage.Codec.Order != OrderCDAB {
+		t.Fatalf("unexpected tag %+v", voltage)
+	}
+	mode, _ := c.Tags.Get("mode")
+	if mode.Address != 10 || mode.Codec.Enum[1] != "Auto" || !mode.Writable {
+		t.Fatalf("unexpected tag %+v", mode)
+	}
+	if relay, _ := c.Tags.Get("relay"); relay.Table != TableCoils || relay.Address != 122 {
+		t.Fatalf("relay expected coil 122, actual %v %v", relay.Table, relay.Address)
+	}
+	last := TagConfig{Name: "last", Address: "holding:65535"}
+	if _, err := last.Tag(OrderABCD, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := last.Tag(OrderABCD, true); err == nil {
+		t.Fatal("one-based address of offset 65535 expected to fail")
+	}
+	numeric, err := ParseProfile([]byte("name: 1234\nversion: 1.10\ntags: []\n"), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if numeric.Name != "1234" || numeric.Version != "1.10" {
+		t.Fatalf("name and version expected 1234 1.10, actual %v %v", numeric.Name, numeric.Version)
+	}
+}
@@ -0,0 +1,115 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrorReadOnly is returned for requests that modify the device
+	// when the client is read-only.
+	ErrorReadOnly = errors.New("modbus: client is read-only")
+	// ErrorWriteProtected is returned for writes outside WriteAllow or
+	// inside WriteDeny.
+	ErrorWriteProtected = errors.New("modbus: write protected")
+)
+
+// readFunctions are the function codes passed by a read-only client.
+var readFunctions = map[byte]bool{
+	FunctionReadCoil:              true,
+	FunctionReadDiscreteInputs:    true,
+	FunctionReadHoldingRegister:   true,
+	FunctionReadInputRegister:     true,
+	7:                             true, // Read Exception Status
+	FunctionGetCommEventCounter:   true,
+	FunctionGetCommEventLog:       true,
+	17:                            true, // Report Server ID
+	FunctionReadFileRecord:        true,
+	24:                            true, // Read FIFO Queue
+	FunctionEncapsulatedInterface: true, // Read Device Identification
+}
+
+// writeRange returns the range modified by request, ok is false if the
+// request does not write coils or registers.
+func writeRange(unit byte, request *Pdu) (r AddressRange, ok bool) {
+	r.UnitId = unit
+	data := request.Data
+	switch request.FunctionCode {
+	case FunctionWriteSingleCoil, FunctionWriteMultipleCoils:
+		r.Table = TableCoils
+	case FunctionWriteSingleRegister, FunctionWriteMultipleRegister, FunctionMaskWriteRegister:
+		r.Table = TableHoldingRegisters
+	case FunctionReadWriteMultipleRegister:
+		r.Table = TableHoldingRegisters
+		if len(data) < 4 {
+			return r, false
+		}
+		data = data[4:]
+	default:
+		return r, false
+	}
+	if len(data) < 4 {
+		return r, false
+	}
+	r.Address = binary.BigEndian.Uint16(data)
+	r.Quantity = 1
+	switch request.FunctionCode {
+	case FunctionWriteMultipleCoils, FunctionWriteMultipleRegister, FunctionReadWriteMultipleRegister:
+		r.Quantity = binary.BigEndian.Uint16(data[2:])
+	}
+	return r, true
+}
+
+// overlaps reports whether r and other share an address. A zero unit id
+// of r matches any unit.
+func (r AddressRange) overlaps(other AddressRange) bool {
+	return (r.UnitId == 0 || r.UnitId == other.UnitId) && r.Table == other.Table &&
+		int(other.Address) < r.end() && int(r.Address) < other.end()
+}
+
+// permits reports whether r lies within one of allowed. A zero unit id
+// matches any unit.
+func permits(allowed []AddressRange, r AddressRange) bool {
+	for _, a := range allowed {
+		if a.UnitId == 0 {
+			a.UnitId = r.UnitId
+		}
+		if a.Contains(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWrite rejects request locally according to ReadOnly, WriteAllow
+// and WriteDeny.
+func (c *ModbusTcpClient) checkWrite(unit byte, request *Pdu) error {
+	if c.ReadOnly && !readFunctions[request.FunctionCode] {
+		return fmt.Errorf("%w: function code '%v'", ErrorReadOnly, request.FunctionCode)
+	}
+	if len(c.WriteAllow) == 0 && len(c.WriteDeny) == 0 {
+		return nil
+	}
+	r, ok := writeRange(unit, request)
+	if !ok {
+		if len(c.WriteAllow) > 0 && !readFunctions[request.FunctionCode] {
+			return fmt.Errorf("%w: function code '%v' writes no allowed range", ErrorWriteProtected, request.FunctionCode)
+		}
+		return nil
+	}
+	address, err := c.apiAddress(r.Address)
+	if err != nil {
+		return err
+	}
+	r.Address = address
+	if len(c.WriteAllow) > 0 && !permits(c.WriteAllow, r) {
+		return fmt.Errorf("%w: %v %v+%v is not allowed", ErrorWriteProtected, r.Table, r.Address, r.Quantity)
+	}
+	for _, d := range c.WriteDeny {
+		if d.overlaps(r) {
+			return fmt.Errorf("%w: %v %v+%v is denied", ErrorWriteProtected, r.Table, r.Address, r.Quantity)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package modbustcp
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWriteProtection(t *testing.T) {
+	var requests int
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		requests++
+		return request
+	})
+	c.ReadOnly = true
+	if err := c.WriteSingleCoil(1, true); !errors.Is(err, ErrorReadOnly) {
+		t.Fatalf("error expected %v, actual %v", ErrorReadOnly, err)
+	}
+	if _, err := c.Execute(&Pdu{FunctionCode: 8, Data: []byte{0, 1, 0, 0}}); !errors.Is(err, ErrorReadOnly) {
+		t.Fatalf("error expected %v, actual %v", ErrorReadOnly, err)
+	}
+	c.ReadOnly = false
+	c.WriteAllow = []AddressRange{{Table: TableHoldingRegisters, Address: 100, Quantity: 10}}
+	c.WriteDeny = []AddressRange{{Table: TableHoldingRegisters, Address: 105, Quantity: 1}}
+	if err := c.WriteSingleRegister(101, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []uint16{99, 105} {
+		if err := c.WriteSingleRegister(address, 1); !errors.Is(err, ErrorWriteProtected) {
+			t.Fatalf("error at %v expected %v, actual %v", address, ErrorWriteProtected, err)
+		}
+	}
+	if err := c.WriteMultipleRegisters(103, []uint16{1, 2, 3}); !errors.Is(err, ErrorWriteProtected) {
+		t.Fatalf("error expected %v, actual %v", ErrorWriteProtected, err)
+	}
+	if _, err := c.Execute(&Pdu{FunctionCode: FunctionWriteFileRecord, Data: []byte{7, 6, 0, 1, 0, 0, 0, 1, 0, 0}}); !errors.Is(err, ErrorWriteProtected) {
+		t.Fatalf("error expected %v, actual %v", ErrorWriteProtected, err)
+	}
+	if _, err := c.Execute(&Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(1, 1)}); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Fatalf("requests expected %v, actual %v", 2, requests)
+	}
+}
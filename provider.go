@@ -0,0 +1,209 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Provider backs a range of a data table by functions, e.g. to read a
+// sensor at the time of a request or to execute a command when a register
+// is written. The functions are passed the part of a request within
+// Range. A nil function answers the request with an illegal function
+// exception, e.g. writes of a read only value.
+type Provider struct {
+	// Range is the range of protocol addresses, a unit id of zero
+	// provides the range for all units.
+	Range          AddressRange
+	ReadBits       func(unit byte, address uint16, quantity int) ([]bool, error)
+	ReadRegisters  func(unit byte, address uint16, quantity int) ([]uint16, error)
+	WriteBits      func(unit byte, address uint16, values []bool) error
+	WriteRegisters func(unit byte, address uint16, values []uint16) error
+}
+
+// ProviderHandler is a Handler serving the ranges of its providers by
+// their functions and all other addresses by a fallback handler. A
+// request spanning several providers is split, writes are therefore not
+// atomic across providers.
+type ProviderHandler struct {
+	// Handler serves the addresses without provider, e.g. a *DataStore.
+	// If nil, they are answered with an illegal data address exception.
+	Handler Handler
+
+	mu        sync.RWMutex
+	providers []*Provider
+}
+
+// NewProviderHandler creates a handler serving addresses without provider
+// by fallback.
+func NewProviderHandler(fallback Handler) *ProviderHandler {
+	return &ProviderHandler{Handler: fallback}
+}
+
+// Provide adds p, its range must not overlap the range of another
+// provider.
+func (h *ProviderHandler) Provide(p Provider) error {
+	if p.Range.Quantity == 0 || p.Range.end() > 0x10000 {
+		return fmt.Errorf("modbus: invalid provider range %v %v+%v", p.Range.Table, p.Range.Address, p.Range.Quantity)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, other := range h.providers {
+		if other.Range.overlaps(p.Range) || p.Range.overlaps(other.Range) {
+			return fmt.Errorf("modbus: provider range %v %v+%v overlaps %v+%v", p.Range.Table, p.Range.Address, p.Range.Quantity, other.Range.Address, other.Range.Quantity)
+		}
+	}
+	h.providers = append(h.providers, &p)
+	sort.Slice(h.providers, func(i, k int) bool { return h.providers[i].Range.Address < h.providers[k].Range.Address })
+	return nil
+}
+
+// ProvideRegister backs a single register by read and write, either may
+// be nil.
+func (h *ProviderHandler) ProvideRegister(unit byte, table Table, address uint16, read func() (uint16, error), write func(uint16) error) error {
+	p := Provider{Range: AddressRange{UnitId: unit, Table: table, Address: address, Quantity: 1}}
+	if read != nil {
+		p.ReadRegisters = func(byte, uint16, int) ([]uint16, error) {
+			v, err := read()
+			return []uint16{v}, err
+		}
+	}
+	if write != nil {
+		p.WriteRegisters = func(_ byte, _ uint16, values []uint16) error {
+			return write(values[0])
+		}
+	}
+	return h.Provide(p)
+}
+
+// providerSegment is a part of a request served by p, by the fallback
+// handler if p is nil.
+type providerSegment struct {
+	p        *Provider
+	address  uint16
+	quantity int
+}
+
+// segments splits a request into the parts served by the providers and
+// the fallback handler.
+func (h *ProviderHandler) segments(unit byte, table Table, address uint16, quantity int) []providerSegment {
+	r := AddressRange{UnitId: unit, Table: table, Address: address, Quantity: uint16(quantity)}
+	var segs []providerSegment
+	pos, end := int(address), int(address)+quantity
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, p := range h.providers {
+		if !p.Range.overlaps(r) {
+			continue
+		}
+		if start := int(p.Range.Address); start > pos {
+			segs = append(segs, providerSegment{nil, uint16(pos), start - pos})
+			pos = start
+		}
+		next := min(end, p.Range.end())
+		segs = append(segs, providerSegment{p, uint16(pos), next - pos})
+		pos = next
+	}
+	if pos < end {
+		segs = append(segs, providerSegment{nil, uint16(pos), end - pos})
+	}
+	return segs
+}
+
+// readSegments concatenates the values read for each segment.
+func readSegments[T any](segs []providerSegment, read func(s providerSegment) ([]T, error)) ([]T, error) {
+	var values []T
+	for _, s := range segs {
+		v, err := read(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) != s.quantity {
+			return nil, fmt.Errorf("modbus: provider returned %v values at %v, expected %v", len(v), s.address, s.quantity)
+		}
+		values = append(values, v...)
+	}
+	return values, nil
+}
+
+func unserved(table Table, s providerSegment) error {
+	return fmt.Errorf("%w: %v %v+%v", ErrorIllegalDataAddress, table, s.address, s.quantity)
+}
+
+func noProvider(table Table, s providerSegment) error {
+	return fmt.Errorf("%w: %v %v+%v is not provided", ErrorIllegalFunction, table, s.address, s.quantity)
+}
+
+// ReadBits implements Handler.
+func (h *ProviderHandler) ReadBits(unit byte, table Table, address uint16, quantity int) ([]bool, error) {
+	return readSegments(h.segments(unit, table, address, quantity), func(s providerSegment) ([]bool, error) {
+		switch {
+		case s.p == nil && h.Handler == nil:
+			return nil, unserved(table, s)
+		case s.p == nil:
+			return h.Handler.ReadBits(unit, table, s.address, s.quantity)
+		case s.p.ReadBits == nil:
+			return nil, noProvider(table, s)
+		}
+		return s.p.ReadBits(unit, s.address, s.quantity)
+	})
+}
+
+// ReadRegisters implements Handler.
+func (h *ProviderHandler) ReadRegisters(unit byte, table Table, address uint16, quantity int) ([]uint16, error) {
+	return readSegments(h.segments(unit, table, address, quantity), func(s providerSegment) ([]uint16, error) {
+		switch {
+		case s.p == nil && h.Handler == nil:
+			return nil, unserved(table, s)
+		case s.p == nil:
+			return h.Handler.ReadRegisters(unit, table, s.address, s.quantity)
+		case s.p.ReadRegisters == nil:
+			return nil, noProvider(table, s)
+		}
+		return s.p.ReadRegisters(unit, s.address, s.quantity)
+	})
+}
+
+// WriteCoils implements Handler.
+func (h *ProviderHandler) WriteCoils(unit byte, address uint16, values []bool) error {
+	for _, s := range h.segments(unit, TableCoils, address, len(values)) {
+		v := values[int(s.address)-int(address):][:s.quantity]
+		var err error
+		switch {
+		case s.p == nil && h.Handler == nil:
+			err = unserved(TableCoils, s)
+		case s.p == nil:
+			err = h.Handler.WriteCoils(unit, s.address, v)
+		case s.p.WriteBits == nil:
+			err = noProvider(TableCoils, s)
+		default:
+			err = s.p.WriteBits(unit, s.address, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteHoldingRegisters implements Handler.
+func (h *ProviderHandler) WriteHoldingRegisters(unit byte, address uint16, values []uint16) error {
+	for _, s := range h.segments(unit, TableHoldingRegisters, address, len(values)) {
+		v := values[int(s.address)-int(address):][:s.quantity]
+		var err error
+		switch {
+		case s.p == nil && h.Handler == nil:
+			err = unserved(TableHoldingRegisters, s)
+		case s.p == nil:
+			err = h.Handler.WriteHoldingRegisters(unit, s.address, v)
+		case s.p.WriteRegisters == nil:
+			err = noProvider(TableHoldingRegisters, s)
+		default:
+			err = s.p.WriteRegisters(unit, s.address, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestProviderHandler(t *testing.T) {
+	store := NewDataStore(8, 0, 10, 0)
+	store.SetRegisters(TableHoldingRegisters, 0, []uint16{1, 2, 3, 4, 5, 6})
+	h := NewProviderHandler(store)
+	sensor := uint16(100)
+	if err := h.ProvideRegister(0, TableHoldingRegisters, 2, func() (uint16, error) { sensor++; return sensor, nil }, nil); err != nil {
+		t.Fatal(err)
+	}
+	var command []uint16
+	err := h.Provide(Provider{
+		Range: AddressRange{Table: TableHoldingRegisters, Address: 4, Quantity: 2},
+		WriteRegisters: func(unit byte, address uint16, values []uint16) error {
+			command = append([]uint16{address}, values...)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.ProvideRegister(0, TableHoldingRegisters, 5, nil, nil); err == nil {
+		t.Fatalf("overlapping provider expected to fail")
+	}
+	s := NewServer()
+	s.Handler = h
+	c := startServer(t, s)
+
+	regs, err := c.ReadHoldingRegisters(0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 1 || regs[2] != 101 || regs[3] != 4 {
+		t.Fatalf("registers expected [1 2 101 4], actual %v", regs)
+	}
+	if regs, _ = c.ReadHoldingRegisters(2, 1); regs[0] != 102 {
+		t.Fatalf("provider expected to be read per request, actual %v", regs[0])
+	}
+	if err = c.WriteMultipleRegisters(3, []uint16{40, 50}); err != nil {
+		t.Fatal(err)
+	}
+	if len(command) != 2 || command[0] != 4 || command[1] != 50 {
+		t.Fatalf("command expected [4 50], actual %v", command)
+	}
+	if regs, _ = store.GetRegisters(TableHoldingRegisters, 3, 1); regs[0] != 40 {
+		t.Fatalf("store register expected 40, actual %v", regs[0])
+	}
+	if _, err = c.ReadHoldingRegisters(4, 1); err != ErrorIllegalFunction {
+		t.Fatalf("read of write only provider expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+	if err = c.WriteSingleRegister(2, 1); err != ErrorIllegalFunction {
+		t.Fatalf("write of read only provider expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+}
@@ -0,0 +1,326 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxPendingTransactions bounds the requests of a proxied connection
+// awaiting their response.
+const maxPendingTransactions = 256
+
+// Transaction is a request and its response observed by a Proxy.
+type Transaction struct {
+	// Time is the time the request was forwarded.
+	Time          time.Time
+	Duration      time.Duration
+	Client        net.Addr
+	TransactionId uint16
+	Unit          byte
+	Request       *Pdu
+	// Response is nil if the connection was closed before the response.
+	Response *Pdu
+}
+
+// String formats the transaction for logging.
+func (t *Transaction) String() string {
+	s := fmt.Sprintf("%v tid %v unit %v: % x", t.Client, t.TransactionId, t.Unit, append([]byte{t.Request.FunctionCode}, t.Request.Data...))
+	if t.Response == nil {
+		return s + " -> no response"
+	}
+	return fmt.Sprintf("%v -> % x in %v", s, append([]byte{t.Response.FunctionCode}, t.Response.Data...), t.Duration)
+}
+
+// Proxy passes Modbus TCP connections through to a target server without
+// modifying the forwarded bytes, while decoding the transactions for
+// observation:
+//
+//	p := NewProxy("192.168.1.10:502")
+//	p.Observe = func(t *Transaction) { log.Println(t) }
+//	p.ListenAndServe(":502")
+//
+// Each connection is forwarded to its own connection to the target.
+// Traffic which is not Modbus TCP is still forwarded, but no longer
+// decoded.
+type Proxy struct {
+	Target string
+	// DialTimeout bounds the connection to the target, 5s if zero.
+	DialTimeout time.Duration
+	// Observe is invoked with each transaction by the goroutine of its
+	// connection, it must not block.
+	Observe func(t *Transaction)
+	// Capture receives the forwarded payloads if not nil.
+	Capture *PcapWriter
+	// ErrorHandler is invoked for failed connections to the target,
+	// capture errors and undecodable traffic.
+	ErrorHandler func(err error)
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewProxy creates a proxy to the target "host:port".
+func NewProxy(target string) *Proxy {
+	return &Proxy{Target: target, conns: make(map[net.Conn]struct{})}
+}
+
+// ListenAndServe listens on the TCP address, e.g. ":502", and proxies the
+// accepted connections until Close is called.
+func (p *Proxy) ListenAndServe(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return p.Serve(l)
+}
+
+// Serve proxies the connections accepted from l until Close is called.
+func (p *Proxy) Serve(l net.Listener) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		l.Close()
+		return ErrorServerClosed
+	}
+	if p.listener != nil {
+		p.mu.Unlock()
+		l.Close()
+		return fmt.Errorf("modbus: proxy already listening")
+	}
+	p.listener = l
+	p.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return ErrorServerClosed
+			}
+			return err
+		}
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			conn.Close()
+			continue
+		}
+		p.conns[conn] = struct{}{}
+		p.wg.Add(1)
+		p.mu.Unlock()
+		go p.serveConn(conn)
+	}
+}
+
+// Addr returns the address the proxy listens on, nil before
+// ListenAndServe.
+func (p *Proxy) Addr() net.Addr {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.listener == nil {
+		return nil
+	}
+	return p.listener.Addr()
+}
+
+// Close stops listening, closes all connections and waits for their
+// goroutines to return.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	var err error
+	if p.listener != nil {
+		err = p.listener.Close()
+	}
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return err
+}
+
+func (p *Proxy) serveConn(client net.Conn) {
+	defer p.wg.Done()
+	defer func() {
+		client.Close()
+		p.mu.Lock()
+		delete(p.conns, client)
+		p.mu.Unlock()
+	}()
+	timeout := p.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	server, err := net.DialTimeout("tcp", p.Target, timeout)
+	if err != nil {
+		p.error(fmt.Errorf("modbus: proxy to '%v': %w", p.Target, err))
+		return
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		server.Close()
+		return
+	}
+	p.conns[server] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		server.Close()
+		p.mu.Lock()
+		delete(p.conns, server)
+		p.mu.Unlock()
+	}()
+
+	tap := newProxyTap(client.RemoteAddr(), p.observe, p.error)
+	done := make(chan struct{})
+	go func() {
+		p.pipe(server, client, tap.request)
+		// a closed side ends both directions
+		server.Close()
+		client.Close()
+		close(done)
+	}()
+	p.pipe(client, server, tap.response)
+	server.Close()
+	client.Close()
+	<-done
+	tap.flush()
+}
+
+// pipe forwards the bytes received from src to dst and then passes them
+// to the capture and to observe.
+func (p *Proxy) pipe(dst, src net.Conn, observe func(t time.Time, data []byte)) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return
+			}
+			now := time.Now()
+			if p.Capture != nil {
+				if cerr := p.Capture.WritePacket(now, src.RemoteAddr(), dst.RemoteAddr(), buf[:n]); cerr != nil {
+					p.error(fmt.Errorf("modbus: proxy capture: %w", cerr))
+				}
+			}
+			observe(now, buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *Proxy) observe(tx *Transaction) {
+	if p.Observe != nil {
+		p.Observe(tx)
+	}
+}
+
+func (p *Proxy) error(err error) {
+	if p.ErrorHandler != nil {
+		p.ErrorHandler(err)
+	}
+}
+
+// proxyTap decodes the traffic of a connection into transactions, for
+// the Proxy and the Monitor.
+type proxyTap struct {
+	client  net.Addr
+	observe func(tx *Transaction)
+	error   func(err error)
+
+	mu                  sync.Mutex
+	requests, responses aduStream
+	pending             map[uint16]*Transaction
+}
+
+func newProxyTap(client net.Addr, observe func(tx *Transaction), fail func(err error)) *proxyTap {
+	return &proxyTap{client: client, observe: observe, error: fail, pending: make(map[uint16]*Transaction)}
+}
+
+// request decodes the data the client sent at the time at.
+func (t *proxyTap) request(at time.Time, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.decode(&t.requests, data, func(tid uint16, unit byte, pdu *Pdu) {
+		if len(t.pending) >= maxPendingTransactions {
+			return
+		}
+		t.pending[tid] = &Transaction{Time: at, Client: t.client, TransactionId: tid, Unit: unit, Request: pdu}
+	})
+}
+
+// response decodes the data the server sent at the time at.
+func (t *proxyTap) response(at time.Time, data []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.decode(&t.responses, data, func(tid uint16, unit byte, pdu *Pdu) {
+		tx, ok := t.pending[tid]
+		if !ok {
+			return
+		}
+		delete(t.pending, tid)
+		tx.Response = pdu
+		tx.Duration = at.Sub(tx.Time)
+		t.observe(tx)
+	})
+}
+
+// decode passes the adus completed by data to frame.
+func (t *proxyTap) decode(s *aduStream, data []byte, frame func(tid uint16, unit byte, pdu *Pdu)) {
+	if s.invalid {
+		return
+	}
+	if err := s.feed(data, frame); err != nil {
+		t.error(fmt.Errorf("modbus: connection of %v: %w, decoding stopped", t.client, err))
+	}
+}
+
+// flush reports the transactions left without response.
+func (t *proxyTap) flush() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for tid, tx := range t.pending {
+		delete(t.pending, tid)
+		t.observe(tx)
+	}
+}
+
+// aduStream splits a byte stream into Modbus TCP adus.
+type aduStream struct {
+	buf     []byte
+	invalid bool
+}
+
+// feed appends data and passes the completed adus to frame. Once data
+// is not Modbus TCP an error is returned and the stream is invalid.
+func (s *aduStream) feed(data []byte, frame func(tid uint16, unit byte, pdu *Pdu)) error {
+	s.buf = append(s.buf, data...)
+	for len(s.buf) >= HeaderSize+1 {
+		length := int(binary.BigEndian.Uint16(s.buf[4:]))
+		if binary.BigEndian.Uint16(s.buf[2:]) != TcpProtocolIdentifier || length < 2 || length+6 > MaxLength {
+			err := fmt.Errorf("invalid header % x", s.buf[:HeaderSize])
+			s.invalid = true
+			s.buf = nil
+			return err
+		}
+		n := length + 6
+		if len(s.buf) < n {
+			break
+		}
+		pdu := &Pdu{FunctionCode: s.buf[HeaderSize], Data: append([]byte(nil), s.buf[HeaderSize+1:n]...)}
+		frame(binary.BigEndian.Uint16(s.buf), s.buf[6], pdu)
+		s.buf = s.buf[n:]
+	}
+	s.buf = append([]byte(nil), s.buf...)
+	return nil
+}
@@ -0,0 +1,86 @@
+package modbustcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	target := NewServer()
+	target.Store = NewDataStore(0, 0, 10, 0)
+	target.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{7})
+	d := startServer(t, target)
+
+	var capture bytes.Buffer
+	var mu sync.Mutex
+	var transactions []*Transaction
+	p := NewProxy(net.JoinHostPort(d.IpAddress, strconv.Itoa(d.Port)))
+	p.Capture = NewPcapWriter(&capture)
+	p.Observe = func(tx *Transaction) {
+		mu.Lock()
+		transactions = append(transactions, tx)
+		mu.Unlock()
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go p.Serve(l)
+	addr := l.Addr().(*net.TCPAddr)
+	c := NewModbusTcpClient("127.0.0.1", addr.Port)
+	c.SlaveId = 1
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	regs, err := c.ReadHoldingRegisters(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 7 {
+		t.Fatalf("register expected 7, actual %v", regs[0])
+	}
+	if _, err = c.ReadHoldingRegisters(20, 2); err != ErrorIllegalDataAddress {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+	c.Disconnect()
+	p.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(transactions) != 2 {
+		t.Fatalf("transactions expected 2, actual %v", len(transactions))
+	}
+	tx := transactions[1]
+	if tx.Unit != 1 || tx.Request.FunctionCode != FunctionReadHoldingRegister || tx.Response.FunctionCode != FunctionReadHoldingRegister|ExcExceptionOffset {
+		t.Fatalf("transaction expected exception response, actual %v", tx)
+	}
+	// header and 4 records with ip and tcp headers of the request and
+	// response adus
+	data := capture.Bytes()
+	if binary.LittleEndian.Uint32(data) != 0xa1b2c3d4 || len(data) != 24+4*(16+40)+12+11+12+9 {
+		t.Fatalf("capture of 4 packets expected, actual %v bytes", len(data))
+	}
+}
+
+func TestAduStream(t *testing.T) {
+	var s aduStream
+	var pdus []*Pdu
+	frame := func(tid uint16, unit byte, pdu *Pdu) { pdus = append(pdus, pdu) }
+	adu := []byte{0, 1, 0, 0, 0, 6, 1, 3, 0, 0, 0, 1}
+	stream := append(append([]byte(nil), adu...), adu...)
+	for i := range stream {
+		if err := s.feed(stream[i:i+1], frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(pdus) != 2 || !bytes.Equal(pdus[1].Data, adu[8:]) {
+		t.Fatalf("pdus expected 2, actual %v", pdus)
+	}
+	if err := s.feed([]byte("GET / HTTP/1.1\r\n"), frame); err == nil || !s.invalid {
+		t.Fatal("stream of another protocol expected to be invalid")
+	}
+}
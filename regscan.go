@@ -0,0 +1,69 @@
+package modbustcp
+
+import (
+	"context"
+)
+
+// ScanTable sweeps the addresses from to to of table and returns the
+// contiguous regions which can be read, using API addresses. Blocks
+// rejected with Illegal Data Address or Illegal Data Value are bisected
+// down to single addresses. blockSize limits the blocks tried first,
+// the maximum quantity of the table if zero. Other errors end the scan,
+// returning the regions found so far.
+func (c *ModbusTcpClient) ScanTable(ctx context.Context, table Table, from, to uint16, blockSize uint16) ([]AddressRange, error) {
+	max := uint16(MaxReadRegisters)
+	if table.IsBit() {
+		max = MaxReadBits
+	}
+	if blockSize == 0 || blockSize > max {
+		blockSize = max
+	}
+	s := &tableScan{c: c, ctx: ctx, table: table}
+	for address := int(from); address <= int(to); address += int(blockSize) {
+		quantity := int(blockSize)
+		if address+quantity > int(to)+1 {
+			quantity = int(to) + 1 - address
+		}
+		if err := s.scan(uint16(address), uint16(quantity)); err != nil {
+			return s.regions, err
+		}
+	}
+	return s.regions, nil
+}
+
+type tableScan struct {
+	c       *ModbusTcpClient
+	ctx     context.Context
+	table   Table
+	regions []AddressRange
+}
+
+func (s *tableScan) scan(address, quantity uint16) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	_, _, err := s.c.readRange(PriorityNormal, AddressRange{UnitId: s.c.SlaveId, Table: s.table, Address: address, Quantity: quantity})
+	switch {
+	case err == nil:
+		s.add(address, quantity)
+		return nil
+	case err != ErrorIllegalDataAddress && err != ErrorIllegalDataValue:
+		return err
+	case quantity == 1:
+		return nil
+	}
+	half := quantity / 2
+	if err = s.scan(address, half); err != nil {
+		return err
+	}
+	return s.scan(address+half, quantity-half)
+}
+
+// add records a readable range, extending the last region if adjacent.
+func (s *tableScan) add(address, quantity uint16) {
+	if n := len(s.regions); n > 0 && s.regions[n-1].end() == int(address) {
+		s.regions[n-1].Quantity += quantity
+		return
+	}
+	s.regions = append(s.regions, AddressRange{UnitId: s.c.SlaveId, Table: s.table, Address: address, Quantity: quantity})
+}
@@ -0,0 +1,37 @@
+package modbustcp
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestScanTable(t *testing.T) {
+	valid := func(address uint16) bool {
+		return address >= 10 && address < 20 || address == 37
+	}
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		address := binary.BigEndian.Uint16(request.Data)
+		quantity := binary.BigEndian.Uint16(request.Data[2:])
+		data := []byte{byte(2 * quantity)}
+		for a := address; a < address+quantity; a++ {
+			if !valid(a) {
+				return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{ExcIllegalDataAdr}}
+			}
+			data = append(data, 0, 0)
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: data}
+	})
+	regions, err := c.ScanTable(context.Background(), TableHoldingRegisters, 0, 49, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []AddressRange{
+		{Table: TableHoldingRegisters, Address: 10, Quantity: 10},
+		{Table: TableHoldingRegisters, Address: 37, Quantity: 1},
+	}
+	if !reflect.DeepEqual(regions, expected) {
+		t.Fatalf("regions expected %v, actual %v", expected, regions)
+	}
+}
@@ -0,0 +1,42 @@
+package modbustcp
+
+// ReverseGateway presents units of a Modbus TCP server as slaves on a
+// serial line, e.g. to retrofit a legacy RTU master onto Ethernet devices:
+//
+//	g := NewReverseGateway(NewModbusTcpClient("192.168.1.10", 502), 1, 2)
+//	go g.ServeRTU(port, 19200)
+//
+// The requests of the units are forwarded to the backend, requests of
+// other units are not answered, like on a bus without such a slave.
+// Broadcasts are forwarded as writes to each unit. The timeout of the
+// backend should be shorter than the one of the serial master, so that
+// failures are answered by gateway exceptions.
+type ReverseGateway struct {
+	// Server answers the serial master, see ServeRTU and ServeASCII.
+	*Server
+	Gateway *BusGateway
+}
+
+// NewReverseGateway creates a gateway forwarding the requests of units to
+// backend. Without units all units from 1 to 247 are forwarded, but no
+// broadcasts.
+func NewReverseGateway(backend Backend, units ...byte) *ReverseGateway {
+	g := NewBusGateway()
+	s := NewServer()
+	s.BroadcastUnits = units
+	if len(units) == 0 {
+		for unit := 1; unit <= 247; unit++ {
+			g.Route(byte(unit), GatewayRoute{Backend: backend})
+		}
+	}
+	for _, unit := range units {
+		g.Route(unit, GatewayRoute{Backend: backend})
+	}
+	s.Use(g.Middleware, unrouted)
+	return &ReverseGateway{Server: s, Gateway: g}
+}
+
+// unrouted leaves the requests of units without route unanswered.
+func unrouted(RequestHandler) RequestHandler {
+	return func(*Request) *Pdu { return nil }
+}
@@ -0,0 +1,37 @@
+package modbustcp
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReverseGateway(t *testing.T) {
+	downstream := NewServer()
+	downstream.Store.SetRegisters(TableHoldingRegisters, 0, []uint16{5, 6})
+	g := NewReverseGateway(startServer(t, downstream), 1)
+	port, line := net.Pipe()
+	go g.ServeRTU(port, 115200)
+	defer line.Close()
+	master := NewRTUClient(line, 115200)
+	master.Timeout = 100 * time.Millisecond
+
+	response, err := master.Transact(1, &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0, 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.FunctionCode != FunctionReadHoldingRegister || len(response.Data) != 5 || response.Data[4] != 6 {
+		t.Fatalf("registers expected [5 6], actual %+v", response)
+	}
+	if _, err = master.Transact(2, &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0, 1)}); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("request of an unrouted unit expected to time out, actual %v", err)
+	}
+	if _, err = master.Transact(0, &Pdu{FunctionCode: FunctionWriteSingleRegister, Data: dataBlock(1, 9)}); err != nil {
+		t.Fatal(err)
+	}
+	if regs, _ := downstream.Store.GetRegisters(TableHoldingRegisters, 1, 1); regs[0] != 9 {
+		t.Fatalf("broadcast expected to be forwarded, actual %v", regs[0])
+	}
+}
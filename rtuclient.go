@@ -0,0 +1,166 @@
+package modbustcp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// SerialPort is a serial line, e.g. the *os.File of an opened and
+// configured tty device.
+type SerialPort interface {
+	io.ReadWriter
+	SetReadDeadline(t time.Time) error
+}
+
+// Backend executes requests for a unit, e.g. on a serial bus or through
+// a TCP connection. Exception responses are returned as response, the
+// error reports transport failures. Broadcasts to unit 0 return no
+// response.
+type Backend interface {
+	Transact(unit byte, request *Pdu) (*Pdu, error)
+}
+
+// RTUClient is a Modbus RTU master on a serial line. Transactions of
+// concurrent users are serialized.
+type RTUClient struct {
+	Port     SerialPort
+	BaudRate int
+	// Timeout waits for a response, 1s if zero. It is also the delay
+	// after a broadcast before the next request is sent.
+	Timeout time.Duration
+	Logger  *log.Logger
+
+	mu sync.Mutex
+}
+
+// NewRTUClient creates a master on port running at baudRate.
+func NewRTUClient(port SerialPort, baudRate int) *RTUClient {
+	return &RTUClient{Port: port, BaudRate: baudRate}
+}
+
+// Transact implements Backend. Timeouts return an error wrapping
+// os.ErrDeadlineExceeded.
+func (c *RTUClient) Transact(unit byte, request *Pdu) (*Pdu, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = time.Second
+	}
+	silence := rtuSilence(c.BaudRate)
+	// frames are separated by a silent interval, late responses to
+	// requests which timed out are discarded meanwhile
+	if err := c.discard(silence, timeout); err != nil {
+		return nil, err
+	}
+	frame := append([]byte{unit, request.FunctionCode}, request.Data...)
+	frame = NewCRC().PushBytes(frame).Sum(frame)
+	if c.Logger != nil {
+		c.Logger.Printf("modbus: sending % x\n", frame)
+	}
+	if _, err := c.Port.Write(frame); err != nil {
+		return nil, err
+	}
+	if unit == 0 {
+		time.Sleep(timeout)
+		return nil, nil
+	}
+	if err := c.Port.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	response, err := c.readResponse(silence)
+	if err != nil {
+		return nil, fmt.Errorf("modbus: unit %v: %w", unit, err)
+	}
+	if c.Logger != nil {
+		c.Logger.Printf("modbus: received % x\n", response)
+	}
+	n := len(response)
+	if NewCRC().PushBytes(response[:n-2]).Value() != uint16(response[n-2])<<8|uint16(response[n-1]) {
+		return nil, fmt.Errorf("modbus: unit %v: invalid crc of response % x", unit, response)
+	}
+	if response[0] != unit {
+		return nil, fmt.Errorf("modbus: response of unit '%v' does not match request '%v'", response[0], unit)
+	}
+	if fc := response[1]; fc != request.FunctionCode && fc != request.FunctionCode|ExcExceptionOffset {
+		return nil, fmt.Errorf("modbus: response function code '%v' does not match request '%v'", fc, request.FunctionCode)
+	}
+	return &Pdu{FunctionCode: response[1], Data: response[2 : n-2]}, nil
+}
+
+// discard reads and drops the bytes received until the line is silent
+// for the silent interval, which has to happen within timeout.
+func (c *RTUClient) discard(silence, timeout time.Duration) error {
+	buf := make([]byte, MaxSerialAdu)
+	for end := time.Now().Add(timeout); time.Now().Before(end); {
+		if err := c.Port.SetReadDeadline(time.Now().Add(silence)); err != nil {
+			return err
+		}
+		n, err := c.Port.Read(buf)
+		if n > 0 && c.Logger != nil {
+			c.Logger.Printf("modbus: discarding % x\n", buf[:n])
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("modbus: line not silent within %v: %w", timeout, os.ErrDeadlineExceeded)
+}
+
+// readResponse reads a response frame, its length is derived from the
+// function code where possible, otherwise it ends after a silent
+// interval.
+func (c *RTUClient) readResponse(silence time.Duration) ([]byte, error) {
+	frame := make([]byte, 3, MaxSerialAdu)
+	if _, err := io.ReadFull(c.Port, frame); err != nil {
+		return nil, err
+	}
+	var remaining int
+	switch fc := frame[1]; {
+	case fc&ExcExceptionOffset != 0:
+		remaining = 2
+	case fc == FunctionReadCoil, fc == FunctionReadDiscreteInputs, fc == FunctionReadHoldingRegister,
+		fc == FunctionReadInputRegister, fc == FunctionGetCommEventLog, fc == FunctionReadFileRecord,
+		fc == FunctionWriteFileRecord, fc == FunctionReadWriteMultipleRegister, fc == 17:
+		// byte count followed by the data
+		remaining = int(frame[2]) + 2
+	case fc == FunctionWriteSingleCoil, fc == FunctionWriteSingleRegister, fc == FunctionWriteMultipleCoils,
+		fc == FunctionWriteMultipleRegister, fc == FunctionDiagnostics, fc == FunctionGetCommEventCounter:
+		remaining = 5
+	case fc == FunctionMaskWriteRegister:
+		remaining = 7
+	default:
+		return c.readUntilSilence(frame, silence)
+	}
+	if len(frame)+remaining > MaxSerialAdu {
+		return nil, fmt.Errorf("modbus: response length '%v' exceeds '%v'", len(frame)+remaining, MaxSerialAdu)
+	}
+	frame = frame[:len(frame)+remaining]
+	if _, err := io.ReadFull(c.Port, frame[3:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// readUntilSilence appends to frame until the line is silent.
+func (c *RTUClient) readUntilSilence(frame []byte, silence time.Duration) ([]byte, error) {
+	buf := make([]byte, MaxSerialAdu)
+	for len(frame) < MaxSerialAdu {
+		c.Port.SetReadDeadline(time.Now().Add(silence))
+		n, err := c.Port.Read(buf[:MaxSerialAdu-len(frame)])
+		frame = append(frame, buf[:n]...)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return frame, nil
+}
@@ -0,0 +1,279 @@
+package modbustcp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DataType identifies how a numeric value is encoded in registers.
+type DataType int
+
+const (
+	TypeUint16 DataType = iota
+	TypeInt16
+	TypeUint32
+	TypeInt32
+	TypeUint64
+	TypeInt64
+	TypeFloat32
+	TypeFloat64
+)
+
+var dataTypeNames = []string{"uint16", "int16", "uint32", "int32", "uint64", "int64", "float32", "float64"}
+
+func (t DataType) String() string {
+	if t < 0 || int(t) >= len(dataTypeNames) {
+		return fmt.Sprintf("DataType(%d)", int(t))
+	}
+	return dataTypeNames[t]
+}
+
+// ParseDataType parses the case insensitive name of a data type, e.g. "int32".
+func ParseDataType(s string) (DataType, error) {
+	for i, name := range dataTypeNames {
+		if strings.EqualFold(s, name) {
+			return DataType(i), nil
+		}
+	}
+	return TypeUint16, fmt.Errorf("modbus: unknown data type '%v'", s)
+}
+
+// Registers returns the number of registers occupied by the type.
+func (t DataType) Registers() int {
+	switch t {
+	case TypeUint32, TypeInt32, TypeFloat32:
+		return 2
+	case TypeUint64, TypeInt64, TypeFloat64:
+		return 4
+	}
+	return 1
+}
+
+// float reports whether the type is an IEEE 754 floating point number.
+func (t DataType) float() bool {
+	return t == TypeFloat32 || t == TypeFloat64
+}
+
+// Bounds returns the range of raw values representable by the type. The
+// maximum of the 64-bit integers is the largest float64 below 2^64 and
+// 2^63, as float64(math.MaxUint64) and float64(math.MaxInt64) round up
+// to them and are out of range.
+func (t DataType) Bounds() (float64, float64) {
+	switch t {
+	case TypeInt16:
+		return math.MinInt16, math.MaxInt16
+	case TypeUint32:
+		return 0, math.MaxUint32
+	case TypeInt32:
+		return math.MinInt32, math.MaxInt32
+	case TypeUint64:
+		return 0, math.Nextafter(1<<64, 0)
+	case TypeInt64:
+		return math.MinInt64, math.Nextafter(1<<63, 0)
+	case TypeFloat32:
+		return -math.MaxFloat32, math.MaxFloat32
+	case TypeFloat64:
+		return -math.MaxFloat64, math.MaxFloat64
+	}
+	return 0, math.MaxUint16
+}
+
+// Scale converts raw register values to engineering units:
+// value = raw * Gain + Offset. A zero Gain is treated as 1 so the
+// zero Scale leaves values untouched.
+type Scale struct {
+	Gain   float64
+	Offset float64
+}
+
+func (s Scale) gain() float64 {
+	if s.Gain == 0 {
+		return 1
+	}
+	return s.Gain
+}
+
+func (s Scale) identity() bool {
+	return s.gain() == 1 && s.Offset == 0
+}
+
+// Apply converts a raw value to engineering units.
+func (s Scale) Apply(raw float64) float64 {
+	return raw*s.gain() + s.Offset
+}
+
+// Invert converts a value in engineering units back to its raw value.
+func (s Scale) Invert(value float64) float64 {
+	return (value - s.Offset) / s.gain()
+}
+
+// Codec describes the encoding of a numeric value in registers together
+// with its transformation to engineering units.
+type Codec struct {
+	Type  DataType
+	Order WordOrder
+	Scale Scale
+	// Enum optionally labels the values.
+	Enum Enum
+	// Unit of the scaled value, e.g. "°C" or "Wh".
+	Unit string
+	// DisplayUnit optionally converts decoded values from Unit to
+	// another unit, e.g. "°F" or "kWh".
+	DisplayUnit string
+}
+
+// Registers returns the number of registers occupied by the value.
+func (c Codec) Registers() int {
+	return c.Type.Registers()
+}
+
+// Raw decodes the unscaled value from regs. 64-bit values beyond 2^53
+// lose precision.
+func (c Codec) Raw(regs []uint16) (float64, error) {
+	if len(regs) < c.Registers() {
+		return 0, fmt.Errorf("modbus: '%v' requires '%v' registers, got '%v'", c.Type, c.Registers(), len(regs))
+	}
+	switch {
+	case c.Type == TypeFloat32:
+		return float64(math.Float32frombits(uint32(c.bits(regs)))), nil
+	case c.Type == TypeFloat64:
+		return math.Float64frombits(c.bits(regs)), nil
+	case c.signed():
+		return float64(c.integer(regs)), nil
+	}
+	return float64(c.bits(regs)), nil
+}
+
+func (c Codec) signed() bool {
+	return c.Type == TypeInt16 || c.Type == TypeInt32 || c.Type == TypeInt64
+}
+
+// bits returns the raw bit pattern of the value in regs.
+func (c Codec) bits(regs []uint16) uint64 {
+	switch c.Registers() {
+	case 2:
+		return uint64(c.Order.Uint32(regs))
+	case 4:
+		return c.Order.Uint64(regs)
+	}
+	return uint64(regs[0])
+}
+
+// integer returns the value in regs sign extended for signed types.
+func (c Codec) integer(regs []uint16) int64 {
+	v := c.bits(regs)
+	switch c.Type {
+	case TypeInt16:
+		return int64(int16(v))
+	case TypeInt32:
+		return int64(int32(v))
+	}
+	return int64(v)
+}
+
+// putBits encodes the raw bit pattern v into registers.
+func (c Codec) putBits(v uint64) []uint16 {
+	switch c.Registers() {
+	case 2:
+		return c.Order.PutUint32(uint32(v))
+	case 4:
+		return c.Order.PutUint64(v)
+	}
+	return []uint16{uint16(v)}
+}
+
+// Decode decodes the value from regs, applies the scale and converts it
+// to the display unit.
+func (c Codec) Decode(regs []uint16) (float64, error) {
+	raw, err := c.Raw(regs)
+	if err != nil {
+		return 0, err
+	}
+	return ConvertUnit(c.Scale.Apply(raw), c.Unit, c.displayUnit())
+}
+
+// Encode converts value from the display unit, removes the scale, rounds
+// it to the nearest raw value unless the type is a floating point number
+// and encodes it into registers.
+func (c Codec) Encode(value float64) ([]uint16, error) {
+	value, err := ConvertUnit(value, c.displayUnit(), c.Unit)
+	if err != nil {
+		return nil, err
+	}
+	raw := c.Scale.Invert(value)
+	if !c.Type.float() {
+		raw = math.Round(raw)
+	}
+	return c.EncodeRaw(raw)
+}
+
+// EncodeRaw encodes an unscaled value into registers.
+func (c Codec) EncodeRaw(raw float64) ([]uint16, error) {
+	min, max := c.Type.Bounds()
+	if math.IsNaN(raw) || raw < min || raw > max {
+		return nil, fmt.Errorf("modbus: raw value '%v' out of range for '%v'", raw, c.Type)
+	}
+	switch {
+	case c.Type == TypeFloat32:
+		return c.putBits(uint64(math.Float32bits(float32(raw)))), nil
+	case c.Type == TypeFloat64:
+		return c.putBits(math.Float64bits(raw)), nil
+	case raw < 0:
+		return c.putBits(uint64(int64(raw))), nil
+	}
+	return c.putBits(uint64(raw)), nil
+}
+
+// Reading holds a decoded value together with the register words it was
+// decoded from, for diagnosing scaling and word order issues.
+type Reading struct {
+	Raw   []uint16
+	Value float64
+	// Label of the value if the codec declares an enumeration.
+	Label string
+	// Unit of the value, empty if unknown.
+	Unit string
+}
+
+// Reading decodes regs into a Reading.
+func (c Codec) Reading(regs []uint16) (Reading, error) {
+	value, err := c.Decode(regs)
+	if err != nil {
+		return Reading{}, err
+	}
+	raw := make([]uint16, c.Registers())
+	copy(raw, regs)
+	r := Reading{Raw: raw, Value: value, Unit: c.displayUnit()}
+	if c.Enum != nil {
+		r.Label, _ = c.Enum.Label(int64(value))
+	}
+	return r, nil
+}
+
+// ReadScaled reads a value described by codec from holding registers and
+// converts it to engineering units.
+func (c *ModbusTcpClient) ReadScaled(address uint16, codec Codec) (float64, error) {
+	r, err := c.ReadDecoded(address, codec)
+	return r.Value, err
+}
+
+// ReadDecoded reads a value described by codec from holding registers and
+// returns both the register words and the value in engineering units.
+func (c *ModbusTcpClient) ReadDecoded(address uint16, codec Codec) (Reading, error) {
+	regs, err := c.ReadHoldingRegisters(address, uint16(codec.Registers()))
+	if err != nil {
+		return Reading{}, err
+	}
+	return codec.Reading(regs)
+}
+
+// WriteScaled converts value from engineering units and writes it to
+// holding registers as described by codec.
+func (c *ModbusTcpClient) WriteScaled(address uint16, codec Codec, value float64) error {
+	regs, err := codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	return c.WriteMultipleRegisters(address, regs)
+}
@@ -0,0 +1,68 @@
+package modbustcp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCodecScale(t *testing.T) {
+	codec := Codec{Type: TypeInt16, Scale: Scale{Gain: 0.1, Offset: -40}}
+	v, err := codec.Decode([]uint16{625})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(v-22.5) > 1e-9 {
+		t.Fatalf("value expected %v, actual %v", 22.5, v)
+	}
+	regs, err := codec.Encode(22.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 625 {
+		t.Fatalf("register expected %v, actual %v", 625, regs[0])
+	}
+	if _, err := codec.Encode(4000); err == nil {
+		t.Fatal("error expected for value out of range")
+	}
+	codec = Codec{Type: TypeInt32, Order: OrderCDAB}
+	if v, _ := codec.Decode([]uint16{0xFFFE, 0xFFFF}); v != -2 {
+		t.Fatalf("value expected %v, actual %v", -2, v)
+	}
+}
+
+func TestCodecReading(t *testing.T) {
+	codec := Codec{Type: TypeUint16, Scale: Scale{Gain: 0.5}}
+	regs := []uint16{7, 99}
+	r, err := codec.Reading(regs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Raw) != 1 || r.Raw[0] != 7 || r.Value != 3.5 {
+		t.Fatalf("reading expected {[7] 3.5}, actual %v", r)
+	}
+	if v := RegistersToInt16s([]uint16{0xFFFF})[0]; v != -1 {
+		t.Fatalf("value expected %v, actual %v", -1, v)
+	}
+}
+
+func TestCodecBounds(t *testing.T) {
+	for _, typ := range []DataType{TypeUint64, TypeInt64} {
+		c := Codec{Type: typ}
+		low, high := typ.Bounds()
+		for _, raw := range []float64{low, high} {
+			regs, err := c.EncodeRaw(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded, _ := c.Raw(regs); decoded != raw {
+				t.Fatalf("%v value expected %v, actual %v", typ, raw, decoded)
+			}
+		}
+		if _, err := c.EncodeRaw(math.Ldexp(1, 64)); err == nil {
+			t.Fatalf("%v value 2^64 expected to fail", typ)
+		}
+	}
+	if _, err := (Codec{Type: TypeInt64}).EncodeRaw(math.Ldexp(1, 63)); err == nil {
+		t.Fatal("int64 value 2^63 expected to fail")
+	}
+}
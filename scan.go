@@ -0,0 +1,101 @@
+package modbustcp
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScanOptions tune ScanUnits.
+type ScanOptions struct {
+	// Timeout per probe, 200ms if zero.
+	Timeout time.Duration
+	// Parallel is the number of connections probing concurrently, 4 if
+	// zero. Many gateways limit the number of connections.
+	Parallel int
+	// Probe is the request sent to each unit, reading holding register 0
+	// if nil. Any response including exceptions counts as presence,
+	// except the gateway exceptions 10 and 11.
+	Probe *Pdu
+	// Progress is invoked after each probe with the unit id and whether
+	// it responded, one call at a time.
+	Progress func(unit byte, present bool)
+}
+
+// ScanUnits probes the unit ids from to to behind the gateway at the
+// address of the client and returns the ids of the responding units in
+// ascending order. It opens its own connections and returns the units
+// found so far if ctx is canceled.
+func (c *ModbusTcpClient) ScanUnits(ctx context.Context, from, to byte, opts ...ScanOptions) ([]byte, error) {
+	var o ScanOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 200 * time.Millisecond
+	}
+	if o.Parallel <= 0 {
+		o.Parallel = 4
+	}
+	probe := o.Probe
+	if probe == nil {
+		probe = &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0, 1)}
+	}
+	units := make(chan byte)
+	go func() {
+		defer close(units)
+		for id := int(from); id <= int(to); id++ {
+			select {
+			case units <- byte(id):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var mu sync.Mutex
+	var found []byte
+	var firstErr error
+	var wg sync.WaitGroup
+	for i := 0; i < o.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := &ModbusTcpClient{IpAddress: c.IpAddress, Port: c.Port, Timeout: o.Timeout}
+			defer w.Disconnect()
+			for id := range units {
+				if w.Conn == nil {
+					if err := w.Connect(); err != nil {
+						w.Conn = nil
+						mu.Lock()
+						if firstErr == nil {
+							firstErr = err
+						}
+						mu.Unlock()
+						continue
+					}
+				}
+				_, err := w.ExecuteUnit(id, probe)
+				if err != nil && !IsException(err) {
+					// drop late responses with the connection
+					w.Disconnect()
+				}
+				present := (err == nil || IsException(err)) && err != ErrorGatewayPathUnavailable && err != ErrorGatewayTargetFailed
+				mu.Lock()
+				if present {
+					found = append(found, id)
+				}
+				if o.Progress != nil {
+					o.Progress(id, present)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+	if len(found) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return found, ctx.Err()
+}
@@ -0,0 +1,71 @@
+package modbustcp
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// serveUnits accepts connections on l answering requests to the units in
+// present and ignoring the others.
+func serveUnits(l net.Listener, present map[byte]bool) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				var header [HeaderSize]byte
+				if _, err := io.ReadFull(conn, header[:]); err != nil {
+					return
+				}
+				body := make([]byte, binary.BigEndian.Uint16(header[4:])-1)
+				if _, err := io.ReadFull(conn, body); err != nil {
+					return
+				}
+				if !present[header[6]] {
+					continue
+				}
+				response := append(header[:], body[0]|ExcExceptionOffset, ExcIllegalDataAdr)
+				binary.BigEndian.PutUint16(response[4:], 3)
+				conn.Write(response)
+			}
+		}()
+	}
+}
+
+func TestScanUnits(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go serveUnits(l, map[byte]bool{3: true, 7: true, 12: true})
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	p, _ := strconv.Atoi(port)
+	c := NewModbusTcpClient(host, p)
+	probed, present := 0, 0
+	progress := func(unit byte, found bool) {
+		probed++
+		if found {
+			present++
+		}
+	}
+	units, err := c.ScanUnits(context.Background(), 1, 10, ScanOptions{Timeout: 20 * time.Millisecond, Parallel: 3, Progress: progress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(units, []byte{3, 7}) {
+		t.Fatalf("units expected [3 7], actual %v", units)
+	}
+	if probed != 10 || present != 2 {
+		t.Fatalf("progress expected of 10 probes and 2 units, actual %v %v", probed, present)
+	}
+}
@@ -0,0 +1,313 @@
+package modbustcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WriteJob is a write executed by a Scheduler once at a point in time,
+// at a fixed interval or on a cron schedule. Exactly one of At, Every and
+// Cron must be set.
+type WriteJob struct {
+	Name string `json:"name"`
+	// UnitId is the unit written, SlaveId of the client if zero.
+	UnitId byte `json:"unit_id,omitempty"`
+	// Table is TableHoldingRegisters or TableCoils, a non-zero value
+	// switching a coil on.
+	Table   Table    `json:"table"`
+	Address uint16   `json:"address"`
+	Values  []uint16 `json:"values"`
+	// At is the time of a one-shot job.
+	At time.Time `json:"at,omitempty"`
+	// Every is the interval of a recurring job.
+	Every Duration `json:"every,omitempty"`
+	// Cron is a crontab schedule, see ParseCron.
+	Cron string `json:"cron,omitempty"`
+
+	cron *Cron
+	next time.Time
+}
+
+// schedule validates the job and computes its first run after now.
+func (j *WriteJob) schedule(now time.Time) error {
+	if j.Name == "" {
+		return fmt.Errorf("modbus: write job has no name")
+	}
+	if !j.Table.Writable() {
+		return fmt.Errorf("modbus: write job '%v': table '%v' is not writable", j.Name, j.Table)
+	}
+	if len(j.Values) == 0 {
+		return fmt.Errorf("modbus: write job '%v' has no values", j.Name)
+	}
+	n := 0
+	if !j.At.IsZero() {
+		n++
+		j.next = j.At
+	}
+	if j.Every > 0 {
+		n++
+		j.next = now.Add(time.Duration(j.Every))
+	}
+	if j.Cron != "" {
+		n++
+		c, err := ParseCron(j.Cron)
+		if err != nil {
+			return err
+		}
+		j.cron = c
+		j.next = c.Next(now)
+	}
+	if n != 1 {
+		return fmt.Errorf("modbus: write job '%v' must have one of at, every or cron", j.Name)
+	}
+	return nil
+}
+
+// reschedule computes the run following now, it returns false for
+// one-shot jobs.
+func (j *WriteJob) reschedule(now time.Time) bool {
+	switch {
+	case j.Every > 0:
+		for !j.next.After(now) {
+			j.next = j.next.Add(time.Duration(j.Every))
+		}
+	case j.cron != nil:
+		j.next = j.cron.Next(now)
+	default:
+		return false
+	}
+	return !j.next.IsZero()
+}
+
+// Scheduler executes write jobs through a client. Pending jobs are saved
+// to Path if set and restored by Start, one-shot jobs missed while not
+// running are executed immediately.
+type Scheduler struct {
+	Client *ModbusTcpClient
+	// ErrorHandler is invoked with the job name for each failed write.
+	ErrorHandler func(job string, err error)
+	// Path is the JSON file persisting the jobs, optional.
+	Path string
+
+	jobs map[string]*WriteJob
+	mu   sync.Mutex
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler writing through client.
+func NewScheduler(client *ModbusTcpClient) *Scheduler {
+	return &Scheduler{
+		Client: client,
+		jobs:   make(map[string]*WriteJob),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Add schedules job, replacing a job of the same name.
+func (s *Scheduler) Add(job WriteJob) error {
+	if err := job.schedule(time.Now()); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.jobs[job.Name] = &job
+	err := s.save()
+	s.mu.Unlock()
+	s.notify()
+	return err
+}
+
+// Remove cancels the named job and reports whether it existed.
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	_, ok := s.jobs[name]
+	delete(s.jobs, name)
+	if ok {
+		s.save()
+	}
+	s.mu.Unlock()
+	return ok
+}
+
+// Jobs returns the pending jobs ordered by name.
+func (s *Scheduler) Jobs() []WriteJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]WriteJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, *j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+	return jobs
+}
+
+// Next returns the time of the next run of the named job.
+func (s *Scheduler) Next(name string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return time.Time{}, false
+	}
+	return j.next, true
+}
+
+// Start restores the jobs saved to Path and begins executing them.
+func (s *Scheduler) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return fmt.Errorf("modbus: scheduler already started")
+	}
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go s.run(s.stop)
+	return nil
+}
+
+// Stop ends the execution and waits for a running write to complete.
+// The jobs are kept.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.stop == nil {
+		s.mu.Unlock()
+		return
+	}
+	close(s.stop)
+	s.stop = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) run(stop chan struct{}) {
+	defer s.wg.Done()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		now := time.Now()
+		next := now.Add(time.Hour)
+		var due []WriteJob
+		s.mu.Lock()
+		for name, j := range s.jobs {
+			if j.next.After(now) {
+				if j.next.Before(next) {
+					next = j.next
+				}
+				continue
+			}
+			due = append(due, *j)
+			if !j.reschedule(now) {
+				delete(s.jobs, name)
+				s.save()
+			} else if j.next.Before(next) {
+				next = j.next
+			}
+		}
+		s.mu.Unlock()
+		for _, j := range due {
+			if err := s.Client.writeJob(&j); err != nil && s.ErrorHandler != nil {
+				s.ErrorHandler(j.Name, err)
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(time.Until(next))
+		select {
+		case <-stop:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// save writes the jobs to Path, the caller holds mu.
+func (s *Scheduler) save() error {
+	if s.Path == "" {
+		return nil
+	}
+	jobs := make([]*WriteJob, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+// load adds the jobs saved to Path, the caller holds mu.
+func (s *Scheduler) load() error {
+	if s.Path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var jobs []WriteJob
+	if err = json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("modbus: write jobs '%v': %v", s.Path, err)
+	}
+	now := time.Now()
+	for i := range jobs {
+		j := &jobs[i]
+		if _, ok := s.jobs[j.Name]; ok {
+			continue
+		}
+		if err = j.schedule(now); err != nil {
+			return err
+		}
+		s.jobs[j.Name] = j
+	}
+	return nil
+}
+
+// writeJob performs the write of j.
+func (c *ModbusTcpClient) writeJob(j *WriteJob) error {
+	unit := j.UnitId
+	if unit == 0 {
+		unit = c.SlaveId
+	}
+	if j.Table == TableCoils {
+		values := make([]bool, len(j.Values))
+		for i, v := range j.Values {
+			values[i] = v != 0
+		}
+		if len(values) == 1 {
+			return c.writeSingle(PriorityNormal, unit, FunctionWriteSingleCoil, j.Address, coilValue(values[0]))
+		}
+		return c.writeCoils(unit, j.Address, values)
+	}
+	if len(j.Values) == 1 {
+		return c.writeSingle(PriorityNormal, unit, FunctionWriteSingleRegister, j.Address, j.Values[0])
+	}
+	return c.writeRegisters(unit, j.Address, j.Values)
+}
@@ -0,0 +1,55 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	writes := make(chan [2]uint16, 16)
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		select {
+		case writes <- [2]uint16{binary.BigEndian.Uint16(request.Data), binary.BigEndian.Uint16(request.Data[2:])}:
+		default:
+		}
+		return request
+	})
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	s := NewScheduler(c)
+	s.Path = path
+	if err := s.Add(WriteJob{Name: "reset", Table: TableHoldingRegisters, Address: 5, Values: []uint16{0}, At: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(WriteJob{Name: "nightly", Table: TableHoldingRegisters, Address: 6, Values: []uint16{1}, Cron: "0 0 * * *"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(WriteJob{Name: "bad", Table: TableInputRegisters, Values: []uint16{1}, Every: Duration(time.Second)}); err == nil {
+		t.Fatal("job writing input registers expected error")
+	}
+
+	restored := NewScheduler(c)
+	restored.Path = path
+	if err := restored.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Stop()
+	if jobs := restored.Jobs(); len(jobs) != 2 || jobs[0].Name != "nightly" || jobs[1].Name != "reset" {
+		t.Fatalf("restored jobs expected [nightly reset], actual %v", jobs)
+	}
+	if err := restored.Add(WriteJob{Name: "now", Table: TableHoldingRegisters, Address: 7, Values: []uint16{42}, At: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case w := <-writes:
+		if w != [2]uint16{7, 42} {
+			t.Fatalf("write expected [7 42], actual %v", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("one-shot job not executed")
+	}
+	if _, ok := restored.Next("now"); ok {
+		t.Fatal("one-shot job expected to be removed")
+	}
+}
@@ -0,0 +1,283 @@
+package modbustcp
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"strings"
+	"time"
+)
+
+// MaxSerialAdu is the maximum size of a RTU frame, address and crc
+// included.
+const MaxSerialAdu = 256
+
+// serialAddr is the remote address of requests received over a serial
+// line.
+type serialAddr string
+
+func (a serialAddr) Network() string { return "serial" }
+func (a serialAddr) String() string  { return string(a) }
+
+// rtuSilence returns the silent interval of 3.5 characters ending a RTU
+// frame, fixed at 1.75ms above 19200 baud like the specification
+// recommends.
+func rtuSilence(baudRate int) time.Duration {
+	if baudRate <= 0 || baudRate > 19200 {
+		return 1750 * time.Microsecond
+	}
+	// a character is 11 bits including start, parity and stop bits
+	return time.Duration(3.5 * 11 * float64(time.Second) / float64(baudRate))
+}
+
+// readDeadliner is implemented by ports supporting read deadlines, like
+// net.Conn and the *os.File of a terminal.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// interruptRead unblocks a pending read of port by an expired read
+// deadline or else by closing port. It reports false if port supports
+// neither.
+func interruptRead(port io.Reader) bool {
+	if d, ok := port.(readDeadliner); ok && d.SetReadDeadline(time.Now()) == nil {
+		return true
+	}
+	if c, ok := port.(io.Closer); ok {
+		c.Close()
+		return true
+	}
+	return false
+}
+
+// serialPort is a serial line served by ServeRTU or ServeASCII.
+type serialPort struct {
+	port io.ReadWriter
+	sess *session
+}
+
+// addSerial registers port to be interrupted by Close and Shutdown.
+func (s *Server) addSerial(port io.ReadWriter) (*serialPort, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrorServerClosed
+	}
+	if s.serial == nil {
+		s.serial = make(map[*serialPort]struct{})
+	}
+	p := &serialPort{port: port, sess: &session{}}
+	s.serial[p] = struct{}{}
+	return p, nil
+}
+
+// beginSerial marks p busy with a frame, which Close and Shutdown wait
+// for. It returns false if the server is shutting down and the frame must
+// not be processed anymore.
+func (s *Server) beginSerial(p *serialPort) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	p.sess.busy = true
+	s.wg.Add(1)
+	return true
+}
+
+// endSerial marks p idle after the response to its frame was sent, it
+// returns false if the server is shutting down and serving has to stop.
+func (s *Server) endSerial(p *serialPort) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.sess.busy = false
+	s.wg.Done()
+	return !s.closed
+}
+
+// serveFrame answers a frame received on p with serve, which returns the
+// response to write to the port, nil if there is none. It returns
+// ErrorServerClosed if the server is shutting down.
+func (s *Server) serveFrame(p *serialPort, serve func(sess *session) []byte) error {
+	if !s.beginSerial(p) {
+		return ErrorServerClosed
+	}
+	var err error
+	if response := serve(p.sess); response != nil {
+		_, err = p.port.Write(response)
+	}
+	if !s.endSerial(p) {
+		return ErrorServerClosed
+	}
+	return err
+}
+
+// removeSerial unregisters p, clears the read deadline interrupting it
+// and returns the error ending serving, ErrorServerClosed after Close.
+func (s *Server) removeSerial(p *serialPort, err error) error {
+	s.mu.Lock()
+	delete(s.serial, p)
+	closed := s.closed
+	s.mu.Unlock()
+	if d, ok := p.port.(readDeadliner); ok {
+		d.SetReadDeadline(time.Time{})
+	}
+	if closed {
+		return ErrorServerClosed
+	}
+	return err
+}
+
+// ServeRTU serves the requests of a Modbus RTU master on the serial line
+// port, e.g. an opened and configured serial device, with the handlers of
+// the server. Frames end after a silent interval of 3.5 characters at
+// baudRate. Frames with an invalid crc are dropped and requests for
+// other units than UnitId are not answered, writes to unit 0 are
+// broadcasts applied without response. ServeRTU returns the error
+// reading or writing port, e.g. after the caller closed it, or
+// ErrorServerClosed after Close. Close interrupts reading by a read
+// deadline if port supports them, otherwise it closes port, Shutdown
+// does so once the frame in progress is answered. Serving stops reading
+// port before returning.
+func (s *Server) ServeRTU(port io.ReadWriter, baudRate int) (err error) {
+	p, err := s.addSerial(port)
+	if err != nil {
+		return err
+	}
+	chunks := make(chan []byte)
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	// stopped is set once the reader returned an error
+	stopped := false
+	defer func() {
+		close(done)
+		// the reader would take the bytes of the next user of port
+		if !stopped && interruptRead(port) {
+			<-exited
+		}
+		err = s.removeSerial(p, err)
+	}()
+	go func() {
+		defer close(exited)
+		for {
+			buf := make([]byte, MaxSerialAdu)
+			n, err := port.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- buf[:n]:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	silence := rtuSilence(baudRate)
+	timer := time.NewTimer(silence)
+	timer.Stop()
+	var frame []byte
+	for {
+		select {
+		case chunk := <-chunks:
+			// an oversized frame is dropped after the silence
+			if len(frame) <= MaxSerialAdu {
+				frame = append(frame, chunk...)
+			}
+			timer.Reset(silence)
+		case <-timer.C:
+			if err := s.serveFrame(p, func(sess *session) []byte { return s.serveRTUFrame(sess, frame) }); err != nil {
+				return err
+			}
+			frame = nil
+		case err := <-errc:
+			stopped = true
+			return err
+		}
+	}
+}
+
+// serveRTUFrame serves a received frame and returns the response frame,
+// nil if there is none.
+func (s *Server) serveRTUFrame(sess *session, frame []byte) []byte {
+	n := len(frame)
+	if n < 4 || n > MaxSerialAdu || NewCRC().PushBytes(frame[:n-2]).Value() != uint16(frame[n-2])<<8|uint16(frame[n-1]) {
+		s.diag.busError()
+		return nil
+	}
+	response := s.serveSerial(sess, frame[0], &Pdu{FunctionCode: frame[1], Data: frame[2 : n-2]}, "rtu")
+	if response == nil {
+		return nil
+	}
+	adu := append([]byte{frame[0], response.FunctionCode}, response.Data...)
+	return sess.truncated(NewCRC().PushBytes(adu).Sum(adu))
+}
+
+// ServeASCII serves the requests of a Modbus ASCII master on the serial
+// line port like ServeRTU. Frames start with ':' and end with CR LF,
+// frames with an invalid lrc are dropped.
+func (s *Server) ServeASCII(port io.ReadWriter) (err error) {
+	p, err := s.addSerial(port)
+	if err != nil {
+		return err
+	}
+	defer func() { err = s.removeSerial(p, err) }()
+	r := bufio.NewReaderSize(port, 2*MaxSerialAdu+3)
+	overrun := false
+	for {
+		slice, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// drop the oversized frame up to its end
+			overrun = true
+			continue
+		} else if err != nil {
+			return err
+		}
+		line := string(slice)
+		if overrun {
+			overrun = false
+			s.diag.busError()
+			continue
+		}
+		i := strings.LastIndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		encoded := strings.TrimRight(line[i+1:], "\r\n")
+		if err := s.serveFrame(p, func(sess *session) []byte { return s.serveASCIIFrame(sess, encoded) }); err != nil {
+			return err
+		}
+	}
+}
+
+// serveASCIIFrame serves the hex encoded frame and returns the encoded
+// response frame, nil if there is none.
+func (s *Server) serveASCIIFrame(sess *session, encoded string) []byte {
+	frame, err := hex.DecodeString(encoded)
+	n := len(frame)
+	if err != nil || n < 3 || n > MaxSerialAdu-1 || new(LRC).PushBytes(frame[:n-1]).Value() != frame[n-1] {
+		s.diag.busError()
+		return nil
+	}
+	response := s.serveSerial(sess, frame[0], &Pdu{FunctionCode: frame[1], Data: frame[2 : n-1]}, "ascii")
+	if response == nil {
+		return nil
+	}
+	adu := append([]byte{frame[0], response.FunctionCode}, response.Data...)
+	adu = new(LRC).PushBytes(adu).Sum(adu)
+	return sess.truncated([]byte(":" + strings.ToUpper(hex.EncodeToString(adu)) + "\r\n"))
+}
+
+// serveSerial serves a request for unit received over a serial line.
+func (s *Server) serveSerial(sess *session, unit byte, request *Pdu, transport string) *Pdu {
+	s.stats.message(request.FunctionCode)
+	s.diag.busMessage()
+	broadcast := unit == 0
+	if !broadcast && s.UnitId != 0 && unit != s.UnitId {
+		return nil
+	}
+	return s.process(sess, &Request{Unit: unit, Pdu: request, RemoteAddr: serialAddr(transport)}, broadcast)
+}
@@ -0,0 +1,143 @@
+package modbustcp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func rtuFrame(data ...byte) []byte {
+	return NewCRC().PushBytes(data).Sum(data)
+}
+
+func TestServerRTU(t *testing.T) {
+	s := NewServer()
+	s.UnitId = 2
+	s.Store.SetRegisters(TableHoldingRegisters, 1, []uint16{0x1234})
+	port, master := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- s.ServeRTU(port, 38400) }()
+	defer func() {
+		master.Close()
+		if err := <-done; err != io.EOF {
+			t.Errorf("ServeRTU expected %v, actual %v", io.EOF, err)
+		}
+	}()
+	master.SetDeadline(time.Now().Add(5 * time.Second))
+	// a corrupted frame, a request for another unit and a broadcast are
+	// not answered
+	corrupted := rtuFrame(2, FunctionReadHoldingRegister, 0, 1, 0, 1)
+	corrupted[3] ^= 1
+	requests := [][]byte{
+		corrupted,
+		rtuFrame(3, FunctionReadHoldingRegister, 0, 1, 0, 1),
+		rtuFrame(0, FunctionWriteSingleRegister, 0, 2, 0, 7),
+		rtuFrame(2, FunctionReadHoldingRegister, 0, 1, 0, 2),
+	}
+	for _, frame := range requests {
+		if _, err := master.Write(frame); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	expected := rtuFrame(2, FunctionReadHoldingRegister, 4, 0x12, 0x34, 0, 7)
+	response := make([]byte, len(expected))
+	if _, err := io.ReadFull(master, response); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(response, expected) {
+		t.Fatalf("response expected % x, actual % x", expected, response)
+	}
+	if count := s.diag.busErrors; count != 1 {
+		t.Fatalf("bus error count expected 1, actual %v", count)
+	}
+}
+
+// failingPort fails writing responses.
+type failingPort struct {
+	net.Conn
+}
+
+func (p failingPort) Write(b []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// checkPortReleased verifies that port is no longer read by the server.
+func checkPortReleased(t *testing.T, port, master net.Conn) {
+	t.Helper()
+	go master.Write([]byte{1, 2, 3})
+	port.SetReadDeadline(time.Now().Add(5 * time.Second))
+	b := make([]byte, 3)
+	if _, err := io.ReadFull(port, b); err != nil || !bytes.Equal(b, []byte{1, 2, 3}) {
+		t.Fatalf("bytes of the next user expected, actual % x %v", b, err)
+	}
+}
+
+func TestServerRTUStop(t *testing.T) {
+	s := NewServer()
+	port, master := net.Pipe()
+	defer master.Close()
+	done := make(chan error, 1)
+	go func() { done <- s.ServeRTU(failingPort{port}, 38400) }()
+	master.Write(rtuFrame(1, FunctionReadHoldingRegister, 0, 0, 0, 1))
+	if err := <-done; !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("ServeRTU expected %v, actual %v", io.ErrClosedPipe, err)
+	}
+	checkPortReleased(t, port, master)
+
+	port.SetReadDeadline(time.Time{})
+	go func() { done <- s.ServeRTU(port, 38400) }()
+	time.Sleep(10 * time.Millisecond)
+	s.Close()
+	if err := <-done; err != ErrorServerClosed {
+		t.Fatalf("ServeRTU expected %v, actual %v", ErrorServerClosed, err)
+	}
+	checkPortReleased(t, port, master)
+	if err := s.ServeRTU(port, 38400); err != ErrorServerClosed {
+		t.Fatalf("ServeRTU of a closed server expected %v, actual %v", ErrorServerClosed, err)
+	}
+}
+
+func TestServerASCIIClose(t *testing.T) {
+	s := NewServer()
+	port, master := net.Pipe()
+	defer master.Close()
+	done := make(chan error, 1)
+	go func() { done <- s.ServeASCII(port) }()
+	time.Sleep(10 * time.Millisecond)
+	s.Close()
+	if err := <-done; err != ErrorServerClosed {
+		t.Fatalf("ServeASCII expected %v, actual %v", ErrorServerClosed, err)
+	}
+	checkPortReleased(t, port, master)
+}
+
+func TestServerASCII(t *testing.T) {
+	s := NewServer()
+	s.UnitId = 1
+	s.Store.SetBits(TableCoils, 0, []bool{true, false, true})
+	port, master := net.Pipe()
+	go s.ServeASCII(port)
+	defer master.Close()
+	master.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(master)
+	if _, err := io.WriteString(master, ":010100000003FB\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != ":01010105F8\r\n" {
+		t.Fatalf("response expected %q, actual %q", ":01010105F8\r\n", line)
+	}
+	// an invalid lrc is dropped, the next request is answered
+	io.WriteString(master, ":010100000003FC\r\n:01050001FF00FA\r\n")
+	if line, _ = r.ReadString('\n'); line != ":01050001FF00FA\r\n" {
+		t.Fatalf("response expected %q, actual %q", ":01050001FF00FA\r\n", line)
+	}
+}
@@ -0,0 +1,580 @@
+package modbustcp
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrorServerClosed is returned by ListenAndServe after Close or
+// Shutdown.
+var ErrorServerClosed = errors.New("modbus: server closed")
+
+// Server is a Modbus TCP slave exposing the data tables of the host
+// application to masters. Each connection is served by its own goroutine.
+type Server struct {
+	// UnitId restricts the server to one unit, requests for other units
+	// are not answered. Zero serves all units.
+	UnitId byte
+	// Broadcast treats requests for unit 0 like broadcasts on a serial
+	// line, writes are applied to the BroadcastUnits without a response
+	// and other requests are ignored. Otherwise unit 0 is served like any
+	// other unit, as is common for Modbus TCP.
+	Broadcast bool
+	// BroadcastUnits are the hosted units receiving broadcast writes,
+	// UnitId if empty.
+	BroadcastUnits []byte
+	// IdleTimeout closes connections without requests for the duration,
+	// zero keeps them open.
+	IdleTimeout time.Duration
+	// ReadTimeout limits the time to receive the rest of a request once
+	// its first byte arrived, WriteTimeout the time to send a response.
+	// Zero disables the limits.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxConns limits the concurrent connections, further connections
+	// are closed right away. Zero allows any number.
+	MaxConns int
+	// RateLimit limits the requests per second of each connection with
+	// bursts of up to RateBurst requests, at least one. Excess requests
+	// are answered with a slave busy exception. Zero disables the limit.
+	RateLimit float64
+	RateBurst int
+	// ShutdownTimeout bounds the graceful shutdown of
+	// ListenAndServeContext, 5s if zero.
+	ShutdownTimeout time.Duration
+	// Identity is returned by read device identification requests,
+	// unless the Handler implements Identifier.
+	Identity DeviceInfo
+	Logger   *log.Logger
+	// Store holds the data tables served unless a Handler is set.
+	Store *DataStore
+	// Handler serves the data tables instead of the Store, e.g. to
+	// compute values on demand from live application state.
+	Handler Handler
+	// Clients restricts the masters allowed to connect if not empty, the
+	// first rule matching a connection grants its permissions.
+	Clients []ClientRule
+	// Access restricts address ranges to reads or writes, or hides them.
+	Access []AccessRule
+	// Faults are injected into the handling of matching requests, the
+	// first matching fault applies. See SetFaults to change them while
+	// serving.
+	Faults []Fault
+	// Audit receives a record of each write request and whether it was
+	// accepted.
+	Audit AuditSink
+
+	functions  map[byte]FunctionHandler
+	middleware []Middleware
+	stats      serverStats
+	diag       diagnostics
+	disabled   [256]bool
+	mu         sync.Mutex
+	listener   net.Listener
+	packetConn net.PacketConn
+	conns      map[net.Conn]*session
+	serial     map[*serialPort]struct{}
+	closed     bool
+	wg         sync.WaitGroup
+}
+
+// NewServer creates a server with a data store spanning the full address
+// space of all tables.
+func NewServer() *Server {
+	return &Server{Store: NewDataStore(0x10000, 0x10000, 0x10000, 0x10000)}
+}
+
+// ListenAndServe listens on the TCP address, e.g. ":502", and serves
+// connections until Close is called.
+func (s *Server) ListenAndServe(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections from l until Close is called, e.g. from a
+// listener inherited by socket activation. Connections of a listener
+// created by tls.NewListener are served like by ListenAndServeTLS, but
+// the configuration is used as is.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return ErrorServerClosed
+	}
+	if s.listener != nil {
+		s.mu.Unlock()
+		l.Close()
+		return fmt.Errorf("modbus: server already listening")
+	}
+	s.listener = l
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrorServerClosed
+			}
+			return err
+		}
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrorServerClosed
+		}
+		if s.MaxConns > 0 && len(s.conns) >= s.MaxConns {
+			s.mu.Unlock()
+			s.logf("modbus: rejecting connection from %v, limit of %v reached", conn.RemoteAddr(), s.MaxConns)
+			conn.Close()
+			continue
+		}
+		if s.conns == nil {
+			s.conns = make(map[net.Conn]*session)
+		}
+		sess := &session{conn: conn}
+		s.conns[conn] = sess
+		s.stats.connection()
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go s.serveConn(sess)
+	}
+}
+
+// Addr returns the address the server listens on, nil before
+// ListenAndServe or Serve.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Close stops listening, closes all connections and waits for their
+// goroutines to return.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	if s.packetConn != nil {
+		s.packetConn.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	for p := range s.serial {
+		interruptRead(p.port)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// CloseConnections closes the current connections but keeps listening,
+// e.g. to test the reconnection of masters.
+func (s *Server) CloseConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) serveConn(sess *session) {
+	conn := sess.conn
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err := s.handshake(tc); err != nil {
+			s.logf("modbus: tls handshake with %v failed: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+	if len(s.Clients) > 0 {
+		rule := s.authorize(conn)
+		if rule == nil {
+			s.logf("modbus: rejecting connection from %v", conn.RemoteAddr())
+			return
+		}
+		sess.readOnly = rule.ReadOnly
+	}
+	var header [HeaderSize]byte
+	body := make([]byte, MaxLength)
+	for {
+		if s.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+		if _, err := io.ReadFull(conn, header[:1]); err != nil {
+			return
+		}
+		if s.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		}
+		if _, err := io.ReadFull(conn, header[1:]); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:]))
+		if binary.BigEndian.Uint16(header[2:]) != TcpProtocolIdentifier || length < 2 || length > MaxLength-HeaderSize+1 {
+			s.diag.busError()
+			s.logf("modbus: closing connection from %v after invalid header % x", conn.RemoteAddr(), header)
+			return
+		}
+		if _, err := io.ReadFull(conn, body[:length-1]); err != nil {
+			return
+		}
+		s.stats.message(body[0])
+		s.diag.busMessage()
+		unit := header[6]
+		broadcast := s.Broadcast && unit == 0
+		if !broadcast && s.UnitId != 0 && unit != s.UnitId {
+			continue
+		}
+		if !s.begin(sess) {
+			return
+		}
+		request := &Pdu{FunctionCode: body[0], Data: body[1 : length-1]}
+		response := s.process(sess, &Request{Unit: unit, Pdu: request, RemoteAddr: conn.RemoteAddr()}, broadcast)
+		if response != nil {
+			if err := s.respond(conn, sess.truncated(responseAdu(header, response))); err != nil {
+				return
+			}
+		}
+		if !s.end(sess) {
+			return
+		}
+	}
+}
+
+// process serves a request received by the transport of sess and
+// returns the response to send, nil if there is none.
+func (s *Server) process(sess *session, r *Request, broadcast bool) *Pdu {
+	var response *Pdu
+	fault := s.fault(r)
+	switch {
+	case !s.diag.receive(r.Pdu):
+	case broadcast:
+		s.broadcast(sess, r)
+	case fault != nil:
+		var injected bool
+		if response, injected = s.inject(sess, fault, r); !injected {
+			response = s.serveRequest(sess, r)
+		}
+	case sess.allow(s.RateLimit, s.RateBurst, time.Now()):
+		response = s.serveRequest(sess, r)
+	default:
+		response = Exception(r.Pdu, ExcSlaveIsBusy)
+	}
+	if response == nil {
+		s.diag.noResponse()
+	} else {
+		s.stats.response(r.Pdu.FunctionCode, response.FunctionCode)
+		s.diag.send(r.Pdu.FunctionCode, response)
+	}
+	return response
+}
+
+// broadcast applies a write of r to all broadcast units, discarding the
+// responses.
+func (s *Server) broadcast(sess *session, r *Request) {
+	if !isWriteFunction(r.Pdu.FunctionCode) {
+		return
+	}
+	units := s.BroadcastUnits
+	if len(units) == 0 {
+		units = []byte{s.UnitId}
+	}
+	for _, unit := range units {
+		s.serveRequest(sess, &Request{Unit: unit, Pdu: r.Pdu, RemoteAddr: r.RemoteAddr})
+	}
+}
+
+// respond sends the response adu.
+func (s *Server) respond(conn net.Conn, adu []byte) error {
+	if s.WriteTimeout > 0 {
+		conn.SetWriteDeadline(time.Now().Add(s.WriteTimeout))
+	}
+	_, err := conn.Write(adu)
+	return err
+}
+
+// responseAdu frames response with the header of the request.
+func responseAdu(header [HeaderSize]byte, response *Pdu) []byte {
+	adu := make([]byte, HeaderSize+1+len(response.Data))
+	copy(adu, header[:])
+	binary.BigEndian.PutUint16(adu[4:], uint16(2+len(response.Data)))
+	adu[HeaderSize] = response.FunctionCode
+	copy(adu[HeaderSize+1:], response.Data)
+	return adu
+}
+
+func (s *Server) logf(format string, v ...interface{}) {
+	if s.Logger != nil {
+		s.Logger.Printf(format, v...)
+	}
+}
+
+// session is the state of a connection to the server.
+type session struct {
+	conn net.Conn
+	// readOnly rejects all but read function codes
+	readOnly bool
+	// busy is set while a request is processed, guarded by the mutex of
+	// the server
+	busy bool
+	// tokens and last implement the rate limit as token bucket
+	tokens float64
+	last   time.Time
+	// truncate is the length of the next response frame set by an
+	// injected fault
+	truncate int
+}
+
+// allow reports whether a request at now is within the rate limit.
+func (sess *session) allow(rate float64, burst int, now time.Time) bool {
+	if rate <= 0 {
+		return true
+	}
+	max := float64(burst)
+	if max < 1 {
+		max = 1
+	}
+	if sess.last.IsZero() {
+		sess.tokens = max
+	} else {
+		sess.tokens += now.Sub(sess.last).Seconds() * rate
+		if sess.tokens > max {
+			sess.tokens = max
+		}
+	}
+	sess.last = now
+	if sess.tokens < 1 {
+		return false
+	}
+	sess.tokens--
+	return true
+}
+
+// handle dispatches request and returns its response or exception.
+func (s *Server) handle(sess *session, unit byte, request *Pdu) *Pdu {
+	s.mu.Lock()
+	f := s.functions[request.FunctionCode]
+	disabled := s.disabled[request.FunctionCode]
+	s.mu.Unlock()
+	if disabled || sess.readOnly && !readFunctions[request.FunctionCode] {
+		return Exception(request, ExcIllegalFunction)
+	}
+	if request.FunctionCode == FunctionDiagnostics && s.diag.listenOnlyRequest(request.Data) {
+		return nil
+	}
+	var data []byte
+	var code byte
+	if f != nil {
+		var err error
+		if data, err = f(unit, request.Data); err != nil {
+			code = ErrorToFailureCode(err)
+		} else if len(data) > MaxPduData {
+			s.logf("modbus: response of function %v exceeds %v bytes", request.FunctionCode, MaxPduData)
+			code = ExcSlaveDeviceFailure
+		}
+	} else if code = s.checkAccess(unit, request); code == 0 {
+		data, code = s.dispatch(unit, request)
+	}
+	if code != 0 {
+		return Exception(request, code)
+	}
+	return &Pdu{FunctionCode: request.FunctionCode, Data: data}
+}
+
+// inAddressSpace reports whether quantity values at address do not
+// exceed the 16 bit address space.
+func inAddressSpace(address, quantity uint16) bool {
+	return int(address)+int(quantity) <= 0x10000
+}
+
+func (s *Server) dispatch(unit byte, request *Pdu) ([]byte, byte) {
+	h := s.handler()
+	d := request.Data
+	switch request.FunctionCode {
+	case FunctionReadCoil, FunctionReadDiscreteInputs:
+		if len(d) != 4 {
+			return nil, ExcIllegalDataVal
+		}
+		address, quantity := binary.BigEndian.Uint16(d), binary.BigEndian.Uint16(d[2:])
+		if quantity < 1 || quantity > MaxReadBits {
+			return nil, ExcIllegalDataVal
+		}
+		if !inAddressSpace(address, quantity) {
+			return nil, ExcIllegalDataAdr
+		}
+		table := TableCoils
+		if request.FunctionCode == FunctionReadDiscreteInputs {
+			table = TableDiscreteInputs
+		}
+		bits, err := h.ReadBits(unit, table, address, int(quantity))
+		if err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		if len(bits) != int(quantity) {
+			return nil, ExcSlaveDeviceFailure
+		}
+		return PackBitsWithCount(bits), 0
+	case FunctionReadHoldingRegister, FunctionReadInputRegister:
+		if len(d) != 4 {
+			return nil, ExcIllegalDataVal
+		}
+		address, quantity := binary.BigEndian.Uint16(d), binary.BigEndian.Uint16(d[2:])
+		if quantity < 1 || quantity > MaxReadRegisters {
+			return nil, ExcIllegalDataVal
+		}
+		if !inAddressSpace(address, quantity) {
+			return nil, ExcIllegalDataAdr
+		}
+		table := TableHoldingRegisters
+		if request.FunctionCode == FunctionReadInputRegister {
+			table = TableInputRegisters
+		}
+		regs, err := h.ReadRegisters(unit, table, address, int(quantity))
+		if err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		if len(regs) != int(quantity) {
+			return nil, ExcSlaveDeviceFailure
+		}
+		return append([]byte{byte(2 * quantity)}, dataBlock(regs...)...), 0
+	case FunctionWriteSingleCoil:
+		if len(d) != 4 {
+			return nil, ExcIllegalDataVal
+		}
+		value := binary.BigEndian.Uint16(d[2:])
+		if value != 0 && value != 0xFF00 {
+			return nil, ExcIllegalDataVal
+		}
+		if err := h.WriteCoils(unit, binary.BigEndian.Uint16(d), []bool{value == 0xFF00}); err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		return d, 0
+	case FunctionWriteSingleRegister:
+		if len(d) != 4 {
+			return nil, ExcIllegalDataVal
+		}
+		if err := h.WriteHoldingRegisters(unit, binary.BigEndian.Uint16(d), []uint16{binary.BigEndian.Uint16(d[2:])}); err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		return d, 0
+	case FunctionWriteMultipleCoils:
+		if len(d) < 5 {
+			return nil, ExcIllegalDataVal
+		}
+		address, quantity := binary.BigEndian.Uint16(d), binary.BigEndian.Uint16(d[2:])
+		if quantity < 1 || quantity > MaxWriteCoils || int(d[4]) != (int(quantity)+7)/8 || len(d) != 5+int(d[4]) {
+			return nil, ExcIllegalDataVal
+		}
+		if !inAddressSpace(address, quantity) {
+			return nil, ExcIllegalDataAdr
+		}
+		bits, _ := UnpackBits(d[5:], int(quantity))
+		if err := h.WriteCoils(unit, address, bits); err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		return d[:4], 0
+	case FunctionWriteMultipleRegister:
+		if len(d) < 5 {
+			return nil, ExcIllegalDataVal
+		}
+		address, quantity := binary.BigEndian.Uint16(d), binary.BigEndian.Uint16(d[2:])
+		if quantity < 1 || quantity > MaxWriteRegisters || int(d[4]) != 2*int(quantity) || len(d) != 5+int(d[4]) {
+			return nil, ExcIllegalDataVal
+		}
+		if !inAddressSpace(address, quantity) {
+			return nil, ExcIllegalDataAdr
+		}
+		if err := h.WriteHoldingRegisters(unit, address, registers(d[5:])); err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		return d[:4], 0
+	case FunctionMaskWriteRegister:
+		if len(d) != 6 {
+			return nil, ExcIllegalDataVal
+		}
+		address := binary.BigEndian.Uint16(d)
+		if err := maskRegister(h, unit, address, binary.BigEndian.Uint16(d[2:]), binary.BigEndian.Uint16(d[4:])); err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		return d, 0
+	case FunctionReadWriteMultipleRegister:
+		if len(d) < 9 {
+			return nil, ExcIllegalDataVal
+		}
+		readAddress, readQuantity := binary.BigEndian.Uint16(d), binary.BigEndian.Uint16(d[2:])
+		writeAddress, writeQuantity := binary.BigEndian.Uint16(d[4:]), binary.BigEndian.Uint16(d[6:])
+		if readQuantity < 1 || readQuantity > MaxReadRegisters || writeQuantity < 1 || writeQuantity > MaxReadWriteRegisters ||
+			int(d[8]) != 2*int(writeQuantity) || len(d) != 9+int(d[8]) {
+			return nil, ExcIllegalDataVal
+		}
+		if !inAddressSpace(readAddress, readQuantity) || !inAddressSpace(writeAddress, writeQuantity) {
+			return nil, ExcIllegalDataAdr
+		}
+		// the write is performed before the read
+		if err := h.WriteHoldingRegisters(unit, writeAddress, registers(d[9:])); err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		regs, err := h.ReadRegisters(unit, TableHoldingRegisters, readAddress, int(readQuantity))
+		if err != nil {
+			return nil, ErrorToFailureCode(err)
+		}
+		if len(regs) != int(readQuantity) {
+			return nil, ExcSlaveDeviceFailure
+		}
+		return append([]byte{byte(2 * readQuantity)}, dataBlock(regs...)...), 0
+	case FunctionDiagnostics:
+		return s.diag.diagnose(d)
+	case FunctionGetCommEventCounter:
+		if len(d) != 0 {
+			return nil, ExcIllegalDataVal
+		}
+		return s.diag.eventCounter(), 0
+	case FunctionGetCommEventLog:
+		if len(d) != 0 {
+			return nil, ExcIllegalDataVal
+		}
+		return s.diag.eventLog(), 0
+	case FunctionEncapsulatedInterface:
+		if len(d) != 3 || d[0] != MEIReadDeviceIdentification {
+			return nil, ExcIllegalFunction
+		}
+		return s.identification(unit, d[1], d[2])
+	}
+	return nil, ExcIllegalFunction
+}
+
+// registers decodes big endian registers.
+func registers(b []byte) []uint16 {
+	regs := make([]uint16, len(b)/2)
+	for i := range regs {
+		regs[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return regs
+}
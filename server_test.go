@@ -0,0 +1,324 @@
+package modbustcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startServer serves s on a loopback port and returns a connected client.
+func startServer(t testing.TB, s *Server) *ModbusTcpClient {
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAndServe("127.0.0.1:0") }()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; !errors.Is(err, ErrorServerClosed) {
+			t.Errorf("ListenAndServe expected %v, actual %v", ErrorServerClosed, err)
+		}
+	})
+	for s.Addr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	host, port, _ := net.SplitHostPort(s.Addr().String())
+	p, _ := strconv.Atoi(port)
+	c := NewModbusTcpClient(host, p)
+	c.SlaveId = 1
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	s.Identity = DeviceInfo{VendorName: "ACME", ProductCode: "X42", Revision: "1.0"}
+	c := startServer(t, s)
+	if err := c.WriteMultipleRegisters(10, []uint16{1, 0x00F0, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MaskWriteRegister(11, 0xFF0F, 0x0050); err != nil {
+		t.Fatal(err)
+	}
+	regs, err := c.ReadHoldingRegisters(10, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 1 || regs[1] != 0x0050 || regs[2] != 3 {
+		t.Fatalf("registers expected [1 80 3], actual %v", regs)
+	}
+	if err := c.WriteMultipleCoils(3, []bool{true, false, true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.WriteSingleCoil(4, true); err != nil {
+		t.Fatal(err)
+	}
+	bits, err := c.ReadCoils(3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bits[0] || !bits[1] || !bits[2] {
+		t.Fatalf("coils expected [true true true], actual %v", bits)
+	}
+	if _, err := c.Execute(&Pdu{FunctionCode: 99}); err != ErrorIllegalFunction {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalFunction, err)
+	}
+	if _, err := c.Execute(&Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0, 200)}); err != ErrorIllegalDataValue {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataValue, err)
+	}
+	info, err := c.DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.VendorName != "ACME" || info.ProductCode != "X42" || info.Revision != "1.0" {
+		t.Fatalf("device info expected ACME/X42/1.0, actual %+v", info)
+	}
+}
+
+func TestServerIdentificationSplit(t *testing.T) {
+	s := NewServer()
+	s.Identity.Extended = map[byte]string{}
+	for id := 0x80; id < 0x90; id++ {
+		s.Identity.Extended[byte(id)] = string(make([]byte, 40))
+	}
+	c := startServer(t, s)
+	objects, _, err := c.ReadDeviceIdentification(DeviceIdExtended, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 3+16 {
+		t.Fatalf("objects expected %v, actual %v", 3+16, len(objects))
+	}
+	s.Identity.VendorName = strings.Repeat("v", 300)
+	for _, code := range []byte{DeviceIdBasic, DeviceIdIndividual} {
+		objects, _, err = c.ReadDeviceIdentification(code, ObjectVendorName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(objects[ObjectVendorName]); n != 244 {
+			t.Fatalf("vendor name of code %v expected 244 bytes, actual %v", code, n)
+		}
+	}
+}
+
+func TestServerDataStore(t *testing.T) {
+	s := NewServer()
+	s.Store = NewDataStore(8, 8, 16, 16)
+	if err := s.Store.SetRegisters(TableInputRegisters, 2, []uint16{42, 43}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store.SetBits(TableDiscreteInputs, 7, []bool{true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Store.SetRegisters(TableInputRegisters, 15, []uint16{1, 2}); !errors.Is(err, ErrorIllegalDataAddress) {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+	c := startServer(t, s)
+	regs, err := c.ReadInputRegisters(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 42 || regs[1] != 43 {
+		t.Fatalf("registers expected [42 43], actual %v", regs)
+	}
+	bits, err := c.ReadDiscreteInputs(6, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bits[0] || !bits[1] {
+		t.Fatalf("inputs expected [false true], actual %v", bits)
+	}
+	if _, err := c.ReadHoldingRegisters(10, 7); err != ErrorIllegalDataAddress {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+	if err := c.WriteSingleRegister(3, 7); err != nil {
+		t.Fatal(err)
+	}
+	if regs, _ := s.Store.GetRegisters(TableHoldingRegisters, 3, 1); regs[0] != 7 {
+		t.Fatalf("register expected 7, actual %v", regs[0])
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	s := NewServer()
+	s.MaxConns = 1
+	s.RateLimit = 1
+	s.RateBurst = 2
+	c := startServer(t, s)
+	for i := 0; i < 2; i++ {
+		if _, err := c.ReadHoldingRegisters(0, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.ReadHoldingRegisters(0, 1); err != ErrorSlaveIsBusy {
+		t.Fatalf("error expected %v, actual %v", ErrorSlaveIsBusy, err)
+	}
+	second := NewModbusTcpClient(c.IpAddress, c.Port)
+	if err := second.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer second.Disconnect()
+	if _, err := second.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("connection beyond the limit expected to be closed")
+	}
+}
+
+func TestSessionRateLimit(t *testing.T) {
+	var sess session
+	now := time.Now()
+	for i, step := range []struct {
+		offset  time.Duration
+		allowed bool
+	}{
+		{0, true},
+		{0, false},
+		{500 * time.Millisecond, false},
+		{time.Second, true},
+	} {
+		if allowed := sess.allow(1, 1, now.Add(step.offset)); allowed != step.allowed {
+			t.Errorf("request %v: allowed expected %v, actual %v", i, step.allowed, allowed)
+		}
+	}
+}
+
+func TestServerIdentificationConformity(t *testing.T) {
+	s := NewServer()
+	s.Identity = DeviceInfo{VendorName: "ACME", ProductCode: "X42", Revision: "1.0", ModelName: "M1"}
+	c := startServer(t, s)
+	objects, conformity, err := c.ReadDeviceIdentification(DeviceIdRegular, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conformity != 0x82 || len(objects) != 4 || objects[ObjectModelName] != "M1" {
+		t.Fatalf("regular objects expected with conformity 0x82, actual %v %x", objects, conformity)
+	}
+	if _, _, err := c.ReadDeviceIdentification(DeviceIdExtended, 0); err != ErrorIllegalDataValue {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataValue, err)
+	}
+	if _, _, err := c.ReadDeviceIdentification(DeviceIdIndividual, ObjectVendorUrl); err != ErrorIllegalDataAddress {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+	info, err := c.DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ModelName != "M1" || info.Conformity != 0x82 {
+		t.Fatalf("device info expected model M1, actual %+v", info)
+	}
+}
+
+func TestServerBroadcast(t *testing.T) {
+	s := NewServer()
+	s.Broadcast = true
+	s.BroadcastUnits = []byte{1, 2}
+	var units []byte
+	s.Use(func(next RequestHandler) RequestHandler {
+		return func(r *Request) *Pdu {
+			units = append(units, r.Unit)
+			return next(r)
+		}
+	})
+	c := startServer(t, s)
+	broadcasts := [][]byte{
+		{0xff, 0x01, 0, 0, 0, 6, 0, FunctionWriteSingleRegister, 0, 3, 0, 9},
+		{0xff, 0x02, 0, 0, 0, 6, 0, FunctionReadHoldingRegister, 0, 3, 0, 1},
+	}
+	for _, adu := range broadcasts {
+		if _, err := c.Conn.Write(adu); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a response to a broadcast would be taken as the response of the read
+	regs, err := c.ReadHoldingRegisters(3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 9 {
+		t.Fatalf("register expected 9, actual %v", regs[0])
+	}
+	if len(units) != 3 || units[0] != 1 || units[1] != 2 || units[2] != 1 {
+		t.Fatalf("units expected [1 2 1], actual %v", units)
+	}
+}
+
+func TestServerMalformedRequests(t *testing.T) {
+	s := NewServer()
+	s.HandleFunc(100, func(unit byte, data []byte) ([]byte, error) {
+		return make([]byte, MaxPduData+1), nil
+	})
+	tests := []struct {
+		request []byte
+		code    byte
+	}{
+		{[]byte{FunctionReadHoldingRegister, 0, 0}, ExcIllegalDataVal},
+		{[]byte{FunctionReadHoldingRegister, 0, 0, 0, 126}, ExcIllegalDataVal},
+		{[]byte{FunctionReadHoldingRegister, 0xff, 0xff, 0, 2}, ExcIllegalDataAdr},
+		{[]byte{FunctionReadCoil, 0xff, 0xf0, 0x07, 0xd0}, ExcIllegalDataAdr},
+		{[]byte{FunctionWriteMultipleRegister, 0, 0, 0, 2, 4, 0, 1}, ExcIllegalDataVal},
+		{[]byte{FunctionWriteMultipleRegister, 0, 0, 0, 1, 255, 0, 1}, ExcIllegalDataVal},
+		{[]byte{FunctionWriteMultipleRegister, 0xff, 0xff, 0, 2, 4, 0, 1, 0, 2}, ExcIllegalDataAdr},
+		{[]byte{FunctionWriteMultipleCoils, 0, 0, 0, 9, 1, 0xff}, ExcIllegalDataVal},
+		{[]byte{FunctionReadWriteMultipleRegister, 0, 0, 0, 1, 0xff, 0xff, 0, 2, 4, 0, 0, 0, 0}, ExcIllegalDataAdr},
+		{[]byte{FunctionMaskWriteRegister, 0, 0, 0}, ExcIllegalDataVal},
+		{[]byte{100}, ExcSlaveDeviceFailure},
+	}
+	for _, test := range tests {
+		response := s.handle(&session{}, 1, &Pdu{FunctionCode: test.request[0], Data: test.request[1:]})
+		if response.FunctionCode != test.request[0]|ExcExceptionOffset || response.Data[0] != test.code {
+			t.Fatalf("request % x expected exception %v, actual %v % x", test.request, test.code, response.FunctionCode, response.Data)
+		}
+	}
+}
+
+func FuzzServerRequest(f *testing.F) {
+	f.Add([]byte{0, 1, 0, 0, 0, 6, 1, FunctionReadHoldingRegister, 0, 0, 0, 10})
+	f.Add([]byte{0, 1, 0, 0, 0, 11, 1, FunctionWriteMultipleRegister, 0, 0, 0, 2, 4, 0, 1, 0, 2})
+	f.Add([]byte{0, 1, 0, 0, 0, 9, 1, FunctionWriteMultipleCoils, 0, 0, 0, 9, 2, 0xff, 1})
+	f.Add([]byte{0, 1, 0, 0, 0, 15, 1, FunctionReadWriteMultipleRegister, 0, 0, 0, 1, 0, 0, 0, 2, 4, 0, 0, 0, 0})
+	f.Add([]byte{0, 1, 0, 0, 0, 6, 1, FunctionDiagnostics, 0, 0, 0xab, 0xcd})
+	f.Add([]byte{0, 1, 0, 0, 0, 5, 1, FunctionEncapsulatedInterface, MEIReadDeviceIdentification, 1, 0})
+	f.Fuzz(func(t *testing.T, adu []byte) {
+		s := NewServer()
+		s.Store = NewDataStore(16, 16, 16, 16)
+		response := s.serveDatagram(&session{}, adu, &net.UDPAddr{})
+		if response == nil {
+			return
+		}
+		if len(response) > MaxLength || len(response) < HeaderSize+2 {
+			t.Fatalf("response % x has invalid size %v", response, len(response))
+		}
+		if int(binary.BigEndian.Uint16(response[4:])) != len(response)-6 || !bytes.Equal(response[:4], adu[:4]) || response[6] != adu[6] {
+			t.Fatalf("response % x has invalid header for request % x", response, adu)
+		}
+	})
+}
+
+func TestServerServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer()
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	c := NewModbusTcpClient("127.0.0.1", l.Addr().(*net.TCPAddr).Port)
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	if _, err = c.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if s.Addr().String() != l.Addr().String() {
+		t.Fatalf("address expected %v, actual %v", l.Addr(), s.Addr())
+	}
+	s.Close()
+	if err = <-done; !errors.Is(err, ErrorServerClosed) {
+		t.Fatalf("Serve expected %v, actual %v", ErrorServerClosed, err)
+	}
+}
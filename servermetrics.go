@@ -0,0 +1,120 @@
+package modbustcp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ServerStats are the counters of a Server since it was created.
+type ServerStats struct {
+	// Messages counts the requests received, including requests for
+	// units not served.
+	Messages uint64
+	// Exceptions counts the exception responses.
+	Exceptions uint64
+	// Events counts the successfully completed requests except the
+	// retrieval of the comm event counter and log.
+	Events uint64
+	// Requests counts the requests by function code.
+	Requests map[byte]uint64
+	// Connections counts the accepted connections, ActiveConnections
+	// the currently open ones.
+	Connections       uint64
+	ActiveConnections int
+}
+
+type serverStats struct {
+	mu          sync.Mutex
+	messages    uint64
+	exceptions  uint64
+	events      uint64
+	requests    [256]uint64
+	connections uint64
+}
+
+func (st *serverStats) message(functionCode byte) {
+	st.mu.Lock()
+	st.messages++
+	st.requests[functionCode]++
+	st.mu.Unlock()
+}
+
+func (st *serverStats) response(request, response byte) {
+	st.mu.Lock()
+	if response&ExcExceptionOffset != 0 {
+		st.exceptions++
+	} else if request != FunctionGetCommEventCounter && request != FunctionGetCommEventLog {
+		st.events++
+	}
+	st.mu.Unlock()
+}
+
+func (st *serverStats) connection() {
+	st.mu.Lock()
+	st.connections++
+	st.mu.Unlock()
+}
+
+// Stats returns the counters of the server.
+func (s *Server) Stats() ServerStats {
+	s.mu.Lock()
+	active := len(s.conns)
+	s.mu.Unlock()
+	st := &s.stats
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	stats := ServerStats{
+		Messages:          st.messages,
+		Exceptions:        st.exceptions,
+		Events:            st.events,
+		Requests:          make(map[byte]uint64),
+		Connections:       st.connections,
+		ActiveConnections: active,
+	}
+	for code, n := range st.requests {
+		if n > 0 {
+			stats.Requests[byte(code)] = n
+		}
+	}
+	return stats
+}
+
+// WriteMetrics writes the counters of the server in the Prometheus text
+// format.
+func (s *Server) WriteMetrics(w io.Writer) error {
+	stats := s.Stats()
+	_, err := fmt.Fprintf(w, "# HELP modbus_server_messages_total Requests received.\n"+
+		"# TYPE modbus_server_messages_total counter\nmodbus_server_messages_total %v\n"+
+		"# HELP modbus_server_exceptions_total Exception responses sent.\n"+
+		"# TYPE modbus_server_exceptions_total counter\nmodbus_server_exceptions_total %v\n"+
+		"# HELP modbus_server_connections_total Connections accepted.\n"+
+		"# TYPE modbus_server_connections_total counter\nmodbus_server_connections_total %v\n"+
+		"# HELP modbus_server_active_connections Connections currently open.\n"+
+		"# TYPE modbus_server_active_connections gauge\nmodbus_server_active_connections %v\n"+
+		"# HELP modbus_server_requests_total Requests received by function code.\n"+
+		"# TYPE modbus_server_requests_total counter\n",
+		stats.Messages, stats.Exceptions, stats.Connections, stats.ActiveConnections)
+	if err != nil {
+		return err
+	}
+	for code := 0; code < 256; code++ {
+		if n, ok := stats.Requests[byte(code)]; ok {
+			labels := formatLabels(map[string]string{"function": fmt.Sprint(code)})
+			if _, err := fmt.Fprintf(w, "modbus_server_requests_total%v %v\n", labels, n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MetricsHandler returns a handler serving the counters of the server to
+// Prometheus.
+func (s *Server) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		s.WriteMetrics(w)
+	})
+}
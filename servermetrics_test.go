@@ -0,0 +1,35 @@
+package modbustcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestServerStats(t *testing.T) {
+	s := NewServer()
+	c := startServer(t, s)
+	c.ReadHoldingRegisters(0, 2)
+	c.WriteSingleRegister(0, 1)
+	c.Execute(&Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0xFFFF, 2)})
+	response, err := c.Execute(&Pdu{FunctionCode: FunctionGetCommEventCounter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := binary.BigEndian.Uint16(response.Data[2:]); events != 2 {
+		t.Fatalf("event count expected %v, actual %v", 2, events)
+	}
+	stats := s.Stats()
+	if stats.Messages != 4 || stats.Exceptions != 1 || stats.Requests[FunctionReadHoldingRegister] != 2 ||
+		stats.Connections != 1 || stats.ActiveConnections != 1 {
+		t.Fatalf("stats expected 4 messages, 1 exception, 2 reads and 1 connection, actual %+v", stats)
+	}
+	var buf bytes.Buffer
+	if err := s.WriteMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `modbus_server_requests_total{function="3"} 2`) {
+		t.Fatalf("metrics expected to count reads, actual\n%v", buf.String())
+	}
+}
@@ -0,0 +1,71 @@
+package modbustcp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"time"
+)
+
+// TlsPort is the registered port of Modbus/TCP Security.
+const TlsPort = 802
+
+// oidModbusRole identifies the role extension of Modbus/TCP Security
+// client certificates.
+var oidModbusRole = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 50316, 802, 1}
+
+// ListenAndServeTLS listens on the TCP address, ":802" if empty, and
+// serves TLS connections configured by config until Close is called.
+// Client certificates are mandatory as required by Modbus/TCP Security:
+// unless config demands one already, they are verified against
+// config.ClientCAs. Further checks, e.g. of the role, can be added with
+// config.VerifyConnection.
+func (s *Server) ListenAndServeTLS(address string, config *tls.Config) error {
+	if config == nil || (len(config.Certificates) == 0 && config.GetCertificate == nil && config.GetConfigForClient == nil) {
+		return fmt.Errorf("modbus: tls configuration needs a server certificate")
+	}
+	config = config.Clone()
+	if config.ClientAuth != tls.RequireAnyClientCert && config.ClientAuth != tls.RequireAndVerifyClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if config.MinVersion < tls.VersionTLS12 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if address == "" {
+		address = fmt.Sprintf(":%v", TlsPort)
+	}
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(tls.NewListener(l, config))
+}
+
+// handshake completes the TLS handshake of conn within the idle timeout,
+// or ten seconds without one.
+func (s *Server) handshake(conn *tls.Conn) error {
+	timeout := s.IdleTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+	return conn.Handshake()
+}
+
+// CertificateRole returns the Modbus/TCP Security role of a client
+// certificate, ok is false if it has none.
+func CertificateRole(cert *x509.Certificate) (role string, ok bool) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidModbusRole) {
+			continue
+		}
+		if _, err := asn1.UnmarshalWithParams(ext.Value, &role, "utf8"); err != nil {
+			return "", false
+		}
+		return role, true
+	}
+	return "", false
+}
@@ -0,0 +1,118 @@
+package modbustcp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCert issues a certificate signed by parent, self-signed if nil.
+func testCert(t *testing.T, template *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// serveTLS starts a server listening for TLS connections configured by
+// config, which is closed at the end of the test.
+func serveTLS(t *testing.T, config *tls.Config) *Server {
+	s := NewServer()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ListenAndServeTLS("127.0.0.1:0", config)
+	}()
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; !errors.Is(err, ErrorServerClosed) {
+			t.Errorf("ListenAndServeTLS expected %v, actual %v", ErrorServerClosed, err)
+		}
+	})
+	for s.Addr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	return s
+}
+
+func TestServerTLS(t *testing.T) {
+	ca := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "ca"},
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil)
+	serverCert := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	role, _ := asn1.MarshalWithParams("Operator", "utf8")
+	clientCert := testCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "client"},
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		ExtraExtensions: []pkix.Extension{{Id: oidModbusRole, Value: role}},
+	}, &ca)
+	if r, ok := CertificateRole(clientCert.Leaf); !ok || r != "Operator" {
+		t.Fatalf("role expected Operator, actual %v", r)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	s := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientCAs: pool})
+	dial := func(s *Server, certs ...tls.Certificate) *ModbusTcpClient {
+		conn, err := tls.Dial("tcp", s.Addr().String(), &tls.Config{RootCAs: pool, Certificates: certs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return &ModbusTcpClient{Conn: conn, Timeout: time.Second}
+	}
+	c := dial(s, clientCert)
+	if err := c.WriteSingleRegister(1, 42); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dial(s).ReadHoldingRegisters(1, 1); err == nil {
+		t.Fatal("client without certificate expected to be rejected")
+	}
+	optional := serveTLS(t, &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven})
+	if _, err := dial(optional).ReadHoldingRegisters(1, 1); err == nil {
+		t.Fatal("client without certificate expected to be rejected if certificates are optional")
+	}
+	if _, err := dial(optional, clientCert).ReadHoldingRegisters(1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	addr := s.Addr().(*net.TCPAddr)
+	c = NewModbusTcpClient("127.0.0.1", addr.Port)
+	c.Timeout = time.Second
+	c.TLSConfig = &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{clientCert}}
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if regs, err := c.ReadHoldingRegisters(1, 1); err != nil || regs[0] != 42 {
+		t.Fatalf("register expected 42, actual %v %v", regs, err)
+	}
+	c.Disconnect()
+	c.TLSConfig = &tls.Config{}
+	if err := c.Connect(); err == nil || c.Conn != nil {
+		t.Fatalf("connection to an unknown authority expected to fail, actual %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package modbustcp
+
+import (
+	"sync"
+)
+
+// SharedBus gives several Modbus TCP masters access to one backend, e.g.
+// an RTU master on a serial line, which executes one transaction at a
+// time:
+//
+//	b := NewSharedBus(NewRTUClient(port, 9600))
+//	s := NewServer()
+//	s.Broadcast = true
+//	s.Use(b.Middleware)
+//	s.ListenAndServe(":502")
+//
+// The masters, identified by their remote address, take turns, so that
+// a master sending many requests does not delay the others. Requests of
+// all units are forwarded, broadcasts if the server treats unit 0 as
+// broadcast.
+type SharedBus struct {
+	Backend Backend
+	// MaxQueue bounds the waiting requests of each master, further
+	// requests are answered with a slave busy exception. 16 if zero.
+	MaxQueue int
+	// ErrorHandler is invoked for failed transactions with the unit id
+	// of the request.
+	ErrorHandler func(unit byte, err error)
+
+	mu   sync.Mutex
+	busy bool
+	// queues are the waiting requests of each master, ring the masters
+	// with waiting requests in the order of their turns
+	queues map[string][]chan struct{}
+	ring   []string
+}
+
+// NewSharedBus creates a bus shared by the masters of a Server.
+func NewSharedBus(backend Backend) *SharedBus {
+	return &SharedBus{Backend: backend, queues: make(map[string][]chan struct{})}
+}
+
+// Middleware forwards all requests to the backend, it is passed to
+// Server.Use.
+func (b *SharedBus) Middleware(next RequestHandler) RequestHandler {
+	return func(r *Request) *Pdu {
+		var master string
+		if r.RemoteAddr != nil {
+			master = r.RemoteAddr.String()
+		}
+		if !b.acquire(master) {
+			return Exception(r.Pdu, ExcSlaveIsBusy)
+		}
+		defer b.release()
+		response, err := b.Backend.Transact(r.Unit, r.Pdu)
+		if err == nil {
+			return response
+		}
+		if b.ErrorHandler != nil {
+			b.ErrorHandler(r.Unit, err)
+		}
+		return gatewayException(r.Pdu, err)
+	}
+}
+
+// acquire waits for the turn of master, it returns false if the queue of
+// the master is full.
+func (b *SharedBus) acquire(master string) bool {
+	b.mu.Lock()
+	if !b.busy {
+		b.busy = true
+		b.mu.Unlock()
+		return true
+	}
+	max := b.MaxQueue
+	if max <= 0 {
+		max = 16
+	}
+	queue := b.queues[master]
+	if len(queue) >= max {
+		b.mu.Unlock()
+		return false
+	}
+	if len(queue) == 0 {
+		b.ring = append(b.ring, master)
+	}
+	turn := make(chan struct{})
+	b.queues[master] = append(queue, turn)
+	b.mu.Unlock()
+	<-turn
+	return true
+}
+
+// release passes the bus to the first waiting request of the next
+// master.
+func (b *SharedBus) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.ring) == 0 {
+		b.busy = false
+		return
+	}
+	master := b.ring[0]
+	b.ring = b.ring[1:]
+	queue := b.queues[master]
+	turn := queue[0]
+	if len(queue) == 1 {
+		delete(b.queues, master)
+	} else {
+		b.queues[master] = queue[1:]
+		b.ring = append(b.ring, master)
+	}
+	close(turn)
+}
@@ -0,0 +1,76 @@
+package modbustcp
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingBackend executes the transactions once they are released.
+type blockingBackend struct {
+	release chan struct{}
+	mu      sync.Mutex
+	units   []byte
+}
+
+func (b *blockingBackend) Transact(unit byte, request *Pdu) (*Pdu, error) {
+	<-b.release
+	b.mu.Lock()
+	b.units = append(b.units, unit)
+	b.mu.Unlock()
+	return &Pdu{FunctionCode: request.FunctionCode, Data: request.Data}, nil
+}
+
+func TestSharedBus(t *testing.T) {
+	backend := &blockingBackend{release: make(chan struct{})}
+	b := NewSharedBus(backend)
+	b.MaxQueue = 2
+	h := b.Middleware(nil)
+	waiting := func(n int) {
+		t.Helper()
+		for i := 0; ; i++ {
+			b.mu.Lock()
+			queued, busy := 0, b.busy
+			for _, q := range b.queues {
+				queued += len(q)
+			}
+			b.mu.Unlock()
+			if busy && queued == n {
+				return
+			}
+			if i > 1000 {
+				t.Fatalf("waiting requests expected %v, actual %v", n, queued)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	a := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1}
+	c := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 1}
+	var wg sync.WaitGroup
+	send := func(addr net.Addr, unit byte) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h(&Request{Unit: unit, Pdu: &Pdu{FunctionCode: FunctionReadHoldingRegister, Data: dataBlock(0, 1)}, RemoteAddr: addr})
+		}()
+	}
+	// the first master sends 3 requests before the second sends one
+	send(a, 1)
+	waiting(0)
+	send(a, 2)
+	waiting(1)
+	send(a, 3)
+	waiting(2)
+	if response := h(&Request{Unit: 4, Pdu: &Pdu{FunctionCode: FunctionReadHoldingRegister}, RemoteAddr: a}); response.FunctionCode&ExcExceptionOffset == 0 {
+		t.Fatalf("busy exception expected for a full queue, actual %+v", response)
+	}
+	send(c, 5)
+	waiting(3)
+	close(backend.release)
+	wg.Wait()
+	expected := []byte{1, 2, 5, 3}
+	if string(backend.units) != string(expected) {
+		t.Fatalf("order expected %v, actual %v", expected, backend.units)
+	}
+}
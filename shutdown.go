@@ -0,0 +1,98 @@
+package modbustcp
+
+import (
+	"context"
+	"time"
+)
+
+// begin marks sess busy with a request, it returns false if the server
+// is shutting down and the request must not be processed anymore.
+func (s *Server) begin(sess *session) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	sess.busy = true
+	return true
+}
+
+// end marks sess idle after its response was sent, it returns false if
+// the server is shutting down and the connection has to be closed.
+func (s *Server) end(sess *session) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess.busy = false
+	return !s.closed
+}
+
+// Shutdown stops accepting connections, closes idle connections and waits
+// until the requests in progress are answered and their connections are
+// closed. Serial lines served by ServeRTU and ServeASCII stop like
+// connections. If ctx ends first the remaining connections are closed and
+// the error of ctx is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	if s.packetConn != nil {
+		s.packetConn.Close()
+	}
+	for conn, sess := range s.conns {
+		if !sess.busy {
+			conn.Close()
+		}
+	}
+	for p := range s.serial {
+		// busy ports stop after answering
+		if !p.sess.busy {
+			interruptRead(p.port)
+		}
+	}
+	s.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		for p := range s.serial {
+			interruptRead(p.port)
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// ListenAndServeContext is ListenAndServe shutting down gracefully once
+// ctx is done, allowing ShutdownTimeout for requests in progress. It
+// returns the error of Shutdown, nil after a clean shutdown.
+func (s *Server) ListenAndServeContext(ctx context.Context, address string) error {
+	served := make(chan error, 1)
+	go func() { served <- s.ListenAndServe(address) }()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := s.Shutdown(shutdownCtx)
+	<-served
+	return err
+}
@@ -0,0 +1,114 @@
+package modbustcp
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// slowHandler delays register reads until release is closed.
+type slowHandler struct {
+	*DataStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *slowHandler) ReadRegisters(unit byte, table Table, address uint16, quantity int) ([]uint16, error) {
+	close(h.started)
+	<-h.release
+	return h.DataStore.ReadRegisters(unit, table, address, quantity)
+}
+
+func TestServerShutdown(t *testing.T) {
+	s := NewServer()
+	h := &slowHandler{DataStore: s.Store, started: make(chan struct{}), release: make(chan struct{})}
+	s.Handler = h
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- s.ListenAndServeContext(ctx, "127.0.0.1:0") }()
+	for s.Addr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	c := NewModbusTcpClient(s.Addr().String(), 0)
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	read := make(chan error, 1)
+	go func() {
+		_, err := c.ReadHoldingRegisters(0, 1)
+		read <- err
+	}()
+	<-h.started
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	close(h.release)
+	if err := <-read; err != nil {
+		t.Fatalf("request in progress expected to be answered, actual %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("shutdown expected to succeed, actual %v", err)
+	}
+	if _, err := c.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("connection expected to be closed after shutdown")
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	s := NewServer()
+	h := &slowHandler{DataStore: s.Store, started: make(chan struct{}), release: make(chan struct{})}
+	s.Handler = h
+	c := startServer(t, s)
+	go c.ReadHoldingRegisters(0, 1)
+	<-h.started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		close(h.release)
+	}()
+	if err := s.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("error expected %v, actual %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestServerShutdownSerial(t *testing.T) {
+	s := NewServer()
+	h := &slowHandler{DataStore: s.Store, started: make(chan struct{}), release: make(chan struct{})}
+	s.Handler = h
+	rtu, rtuMaster := net.Pipe()
+	defer rtuMaster.Close()
+	ascii, asciiMaster := net.Pipe()
+	defer asciiMaster.Close()
+	rtuDone := make(chan error, 1)
+	go func() { rtuDone <- s.ServeRTU(rtu, 38400) }()
+	asciiDone := make(chan error, 1)
+	go func() { asciiDone <- s.ServeASCII(ascii) }()
+	rtuMaster.SetDeadline(time.Now().Add(5 * time.Second))
+	rtuMaster.Write(rtuFrame(1, FunctionReadHoldingRegister, 0, 0, 0, 1))
+	<-h.started
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	if err := <-asciiDone; err != ErrorServerClosed {
+		t.Fatalf("ServeASCII expected %v, actual %v", ErrorServerClosed, err)
+	}
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown expected to wait for the frame in progress, actual %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(h.release)
+	expected := rtuFrame(1, FunctionReadHoldingRegister, 2, 0, 0)
+	response := make([]byte, len(expected))
+	if _, err := io.ReadFull(rtuMaster, response); err != nil {
+		t.Fatalf("frame in progress expected to be answered, actual %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("shutdown expected to succeed, actual %v", err)
+	}
+	if err := <-rtuDone; err != ErrorServerClosed {
+		t.Fatalf("ServeRTU expected %v, actual %v", ErrorServerClosed, err)
+	}
+}
@@ -0,0 +1,114 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Fleet serves instances of a simulator on consecutive ports, each with
+// its own copies of the devices, e.g. to load test head-end software
+// polling thousands of devices from one machine. The configuration
+//
+//	listen: ":10000"
+//	instances: 1000
+//	devices:
+//	  - unit: 1
+//	    units: [2, 3, 4]
+//	    profile: power_meter
+//
+// serves 4000 power meters on the ports 10000 to 10999. The copies of a
+// device in all instances share its data tables and their generated
+// values until they are written, the tables of a device are copied by its
+// first write. The first instance updates the shared values, the web
+// endpoint serves its devices.
+type Fleet struct {
+	Simulators []*Simulator
+}
+
+// NewFleet creates the instances of cfg. Instances listening on port 0
+// are each assigned a port by the system.
+func NewFleet(cfg *Config) (*Fleet, error) {
+	n := max(cfg.Instances, 1)
+	listen := cfg.Listen
+	if listen == "" {
+		listen = ":502"
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, err
+	}
+	first, err := strconv.Atoi(port)
+	if err != nil || first < 0 || first != 0 && first+n-1 > 65535 {
+		return nil, fmt.Errorf("simulator: invalid port '%v' of %v instances", port, n)
+	}
+	templates, err := newTemplates(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fleet{Simulators: make([]*Simulator, n)}
+	for i := range f.Simulators {
+		s, err := newSimulator(cfg, templates)
+		if err != nil {
+			return nil, err
+		}
+		if first != 0 {
+			s.Listen = net.JoinHostPort(host, strconv.Itoa(first+i))
+		}
+		if i > 0 {
+			s.HTTP = ""
+			s.secondary = true
+		}
+		f.Simulators[i] = s
+	}
+	return f, nil
+}
+
+// ListenAndServe serves all instances until Close is called or one of
+// them fails, which closes the others.
+func (f *Fleet) ListenAndServe() error {
+	errs := make(chan error, len(f.Simulators))
+	for _, s := range f.Simulators {
+		go func(s *Simulator) { errs <- s.ListenAndServe() }(s)
+	}
+	err := <-errs
+	f.Close()
+	for range f.Simulators[1:] {
+		<-errs
+	}
+	return err
+}
+
+// Close stops serving all instances.
+func (f *Fleet) Close() error {
+	var err error
+	for _, s := range f.Simulators {
+		if e := s.Close(); err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Play runs the scenario on all instances, see Simulator.Play, and
+// returns the first failure.
+func (f *Fleet) Play(ctx context.Context, sc *Scenario) error {
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	for _, s := range f.Simulators {
+		wg.Add(1)
+		go func(s *Simulator) {
+			defer wg.Done()
+			if err := s.Play(ctx, sc); err != nil {
+				once.Do(func() { first = err })
+			}
+		}(s)
+	}
+	wg.Wait()
+	return first
+}
@@ -0,0 +1,128 @@
+package simulator
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestSharedDevices(t *testing.T) {
+	sim, err := NewFromConfig(&Config{Devices: []DeviceConfig{{
+		Unit:       1,
+		Units:      []byte{2, 3},
+		Blocks:     []BlockConfig{{Address: "40001", Values: []float64{5}}},
+		Generators: []GeneratorConfig{{Address: "30001", Kind: "counter", Period: modbustcp.Duration(time.Second)}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = sim.WriteHoldingRegisters(1, 0, []uint16{6}); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Device(1).shared() || !sim.Device(2).shared() || sim.Device(2).template != sim.Device(3).template {
+		t.Fatal("only the written device expected with own data tables")
+	}
+	sim.Update(3 * time.Second)
+	for unit, expected := range map[byte][2]uint16{1: {6, 3}, 2: {5, 3}, 3: {5, 3}} {
+		holding, err := sim.ReadRegisters(unit, modbustcp.TableHoldingRegisters, 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input, err := sim.ReadRegisters(unit, modbustcp.TableInputRegisters, 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if holding[0] != expected[0] || input[0] != expected[1] {
+			t.Fatalf("unit %v registers expected %v, actual [%v %v]", unit, expected, holding[0], input[0])
+		}
+	}
+}
+
+func TestFleet(t *testing.T) {
+	cfg := &Config{
+		Listen:    "127.0.0.1:0",
+		Instances: 3,
+		Devices:   []DeviceConfig{{Unit: 1, Units: []byte{2}, Blocks: []BlockConfig{{Address: "40001", Values: []float64{7}}}}},
+	}
+	f, err := NewFleet(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Simulators) != 3 || f.Simulators[0].Device(1).template != f.Simulators[2].Device(2).template {
+		t.Fatalf("3 instances sharing the devices expected, actual %v", len(f.Simulators))
+	}
+	done := make(chan error, 1)
+	go func() { done <- f.ListenAndServe() }()
+	clients := make([]*modbustcp.ModbusTcpClient, len(f.Simulators))
+	for i, s := range f.Simulators {
+		for s.Server.Addr() == nil {
+			time.Sleep(time.Millisecond)
+		}
+		host, port, _ := net.SplitHostPort(s.Server.Addr().String())
+		p, _ := strconv.Atoi(port)
+		clients[i] = modbustcp.NewModbusTcpClient(host, p)
+		clients[i].SlaveId = 2
+		if err = clients[i].Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer clients[i].Disconnect()
+	}
+	if err = clients[0].WriteSingleRegister(0, 8); err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range clients {
+		regs, err := c.ReadHoldingRegisters(0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := map[bool]uint16{true: 8, false: 7}[i == 0]; regs[0] != expected {
+			t.Fatalf("register of instance %v expected %v, actual %v", i, expected, regs[0])
+		}
+	}
+	f.Close()
+	if err = <-done; !errors.Is(err, modbustcp.ErrorServerClosed) {
+		t.Fatalf("ListenAndServe expected %v, actual %v", modbustcp.ErrorServerClosed, err)
+	}
+
+	cfg.Listen = ":65535"
+	if _, err = NewFleet(cfg); err == nil {
+		t.Fatal("instances beyond port 65535 expected to fail")
+	}
+	cfg.Listen = ":1502"
+	if f, err = NewFleet(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if f.Simulators[2].Listen != ":1504" {
+		t.Fatalf("listen address expected :1504, actual %v", f.Simulators[2].Listen)
+	}
+}
+
+func TestFleetUpdate(t *testing.T) {
+	f, err := NewFleet(&Config{
+		Listen:    "127.0.0.1:0",
+		Instances: 2,
+		Devices:   []DeviceConfig{{Unit: 1, Generators: []GeneratorConfig{{Address: "30001", Kind: "counter", Period: modbustcp.Duration(time.Second)}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(s *Simulator) uint16 {
+		regs, err := s.ReadRegisters(1, modbustcp.TableInputRegisters, 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return regs[0]
+	}
+	f.Simulators[1].Update(3 * time.Second)
+	if v := read(f.Simulators[0]); v != 0 {
+		t.Fatalf("shared value updated by the second instance expected 0, actual %v", v)
+	}
+	f.Simulators[0].Update(3 * time.Second)
+	if v := read(f.Simulators[1]); v != 3 {
+		t.Fatalf("shared value updated by the first instance expected 3, actual %v", v)
+	}
+}
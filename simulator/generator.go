@@ -0,0 +1,119 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// GeneratorConfig drives a value of a device by a generator of kind:
+//
+//   - "sine": Offset + Amplitude * sin(2π t / Period)
+//   - "sawtooth": rises from Offset to Offset + Amplitude each Period
+//   - "ramp": rises to Offset + Amplitude and falls back each Period
+//   - "random_walk": changes by up to Step each update, bounded to
+//     Offset ± Amplitude
+//   - "counter": Offset incremented by Step, 1 if zero, each Period,
+//     wrapping after Amplitude if not zero
+//   - "toggle": alternates between 0 and 1 each Period, e.g. for coils
+type GeneratorConfig struct {
+	// Address of the value in Modicon notation or as table and protocol
+	// offset, e.g. "30001" or "input:0".
+	Address modbustcp.AddressRef `json:"address"`
+	// Type is the data type of a register value, "uint16" if empty.
+	Type      string `json:"type,omitempty"`
+	WordOrder string `json:"word_order,omitempty"`
+	Kind      string `json:"kind"`
+	// Period is 1m if zero.
+	Period    modbustcp.Duration `json:"period,omitempty"`
+	Amplitude float64            `json:"amplitude,omitempty"`
+	Offset    float64            `json:"offset,omitempty"`
+	Step      float64            `json:"step,omitempty"`
+}
+
+// generator is a parsed GeneratorConfig.
+type generator struct {
+	cfg     GeneratorConfig
+	address modbustcp.Address
+	codec   modbustcp.Codec
+	period  time.Duration
+	// value is the state of a random walk
+	value float64
+}
+
+func newGenerator(cfg GeneratorConfig) (*generator, error) {
+	b, err := parseBlock(BlockConfig{Address: cfg.Address, Type: cfg.Type, WordOrder: cfg.WordOrder, Quantity: 1})
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.Kind {
+	case "sine", "sawtooth", "ramp", "random_walk", "counter", "toggle":
+	default:
+		return nil, fmt.Errorf("simulator: unknown generator '%v'", cfg.Kind)
+	}
+	g := &generator{cfg: cfg, address: b.address, codec: b.codec, period: time.Duration(cfg.Period), value: cfg.Offset}
+	if g.period <= 0 {
+		g.period = time.Minute
+	}
+	return g, nil
+}
+
+// size returns the number of bits or registers of the value.
+func (g *generator) size() int {
+	if g.address.Table.IsBit() {
+		return 1
+	}
+	return g.codec.Registers()
+}
+
+// next returns the value after elapsed time since the start.
+func (g *generator) next(elapsed time.Duration) float64 {
+	phase := float64(elapsed%g.period) / float64(g.period)
+	periods := float64(elapsed / g.period)
+	c := &g.cfg
+	switch c.Kind {
+	case "sine":
+		return c.Offset + c.Amplitude*math.Sin(2*math.Pi*phase)
+	case "sawtooth":
+		return c.Offset + c.Amplitude*phase
+	case "ramp":
+		return c.Offset + c.Amplitude*(1-math.Abs(2*phase-1))
+	case "random_walk":
+		g.value += (2*rand.Float64() - 1) * c.Step
+		g.value = math.Max(c.Offset-c.Amplitude, math.Min(c.Offset+c.Amplitude, g.value))
+		return g.value
+	case "counter":
+		step := c.Step
+		if step == 0 {
+			step = 1
+		}
+		n := step * periods
+		if c.Amplitude != 0 {
+			n = math.Mod(n, c.Amplitude)
+		}
+		return c.Offset + n
+	}
+	return math.Mod(periods, 2)
+}
+
+// update writes the value after elapsed time to store. Values out of the
+// range of the data type are clamped.
+func (g *generator) update(store *modbustcp.DataStore, elapsed time.Duration) error {
+	v := g.next(elapsed)
+	if g.address.Table.IsBit() {
+		return store.SetBits(g.address.Table, g.address.Offset, []bool{v != 0})
+	}
+	low, high := g.codec.Type.Bounds()
+	v = math.Max(low, math.Min(high, v))
+	if g.codec.Type != modbustcp.TypeFloat32 && g.codec.Type != modbustcp.TypeFloat64 {
+		v = math.Round(v)
+	}
+	regs, err := g.codec.EncodeRaw(v)
+	if err != nil {
+		return err
+	}
+	return store.SetRegisters(g.address.Table, g.address.Offset, regs)
+}
@@ -0,0 +1,81 @@
+package simulator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestGenerators(t *testing.T) {
+	for _, test := range []struct {
+		cfg      GeneratorConfig
+		elapsed  time.Duration
+		expected float64
+	}{
+		{GeneratorConfig{Kind: "sine", Period: modbustcp.Duration(4 * time.Second), Amplitude: 10, Offset: 50}, time.Second, 60},
+		{GeneratorConfig{Kind: "sine", Period: modbustcp.Duration(4 * time.Second), Amplitude: 10, Offset: 50}, 3 * time.Second, 40},
+		{GeneratorConfig{Kind: "sawtooth", Period: modbustcp.Duration(4 * time.Second), Amplitude: 100}, 5 * time.Second, 25},
+		{GeneratorConfig{Kind: "ramp", Period: modbustcp.Duration(4 * time.Second), Amplitude: 100}, 3 * time.Second, 50},
+		{GeneratorConfig{Kind: "counter", Period: modbustcp.Duration(time.Second), Step: 2}, 5 * time.Second, 10},
+		{GeneratorConfig{Kind: "counter", Period: modbustcp.Duration(time.Second), Amplitude: 3, Offset: 1}, 5 * time.Second, 3},
+		{GeneratorConfig{Kind: "toggle", Period: modbustcp.Duration(time.Second)}, 3 * time.Second, 1},
+		{GeneratorConfig{Kind: "toggle"}, 3 * time.Second, 0},
+	} {
+		test.cfg.Address = "30001"
+		g, err := newGenerator(test.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := g.next(test.elapsed); math.Abs(v-test.expected) > 1e-9 {
+			t.Fatalf("%v after %v expected %v, actual %v", test.cfg.Kind, test.elapsed, test.expected, v)
+		}
+	}
+	g, err := newGenerator(GeneratorConfig{Address: "30001", Kind: "random_walk", Offset: 10, Amplitude: 1, Step: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if v := g.next(0); v < 9 || v > 11 {
+			t.Fatalf("random walk expected within [9, 11], actual %v", v)
+		}
+	}
+	if _, err = newGenerator(GeneratorConfig{Address: "30001", Kind: "square"}); err == nil {
+		t.Fatal("unknown generator expected to fail")
+	}
+	// values beyond the range of the type are clamped to its maximum
+	store := modbustcp.NewDataStore(0, 0, 0, 4)
+	g, err = newGenerator(GeneratorConfig{Address: "30001", Type: "uint64", Kind: "counter", Period: modbustcp.Duration(time.Second), Offset: 1e20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.update(store, 0); err != nil {
+		t.Fatal(err)
+	}
+	if regs, _ := store.GetRegisters(modbustcp.TableInputRegisters, 0, 4); regs[0] != 0xffff || regs[3] != 0xf800 {
+		t.Fatalf("registers expected [ffff ffff ffff f800], actual %x", regs)
+	}
+}
+
+func TestDeviceUpdate(t *testing.T) {
+	d, err := NewDevice(DeviceConfig{Unit: 1, Generators: []GeneratorConfig{
+		{Address: "00003", Kind: "toggle", Period: modbustcp.Duration(time.Second)},
+		{Address: "input:4", Type: "int16", Kind: "sine", Period: modbustcp.Duration(4 * time.Second), Amplitude: 100},
+		{Address: "input:0", Kind: "sine", Period: modbustcp.Duration(4 * time.Second), Amplitude: 100},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := d.Store().Size(modbustcp.TableInputRegisters); size != 5 {
+		t.Fatalf("input registers expected 5, actual %v", size)
+	}
+	if err = d.Update(3 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	coils, _ := d.Store().GetBits(modbustcp.TableCoils, 2, 1)
+	regs, _ := d.Store().GetRegisters(modbustcp.TableInputRegisters, 0, 5)
+	if !coils[0] || int16(regs[4]) != -100 || regs[0] != 0 {
+		t.Fatalf("coil expected true and registers -100 and clamped 0, actual %v %v", coils, regs)
+	}
+}
@@ -0,0 +1,91 @@
+package simulator
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// Latency delays the responses of matching requests to mimic the
+// response times of real devices, e.g. slow PLCs behind gateways. The
+// first latency of a simulator matching a request applies.
+type Latency struct {
+	// Unit restricts the latency to a unit, zero matches all.
+	Unit byte `json:"unit,omitempty"`
+	// Function restricts the latency to a function code, zero matches
+	// all.
+	Function byte `json:"function,omitempty"`
+	// Distribution of the delays:
+	//
+	//	fixed    always Delay
+	//	uniform  Delay plus a random duration of up to Jitter, the default
+	//	normal   normally distributed around Delay with the standard
+	//	         deviation Jitter, never below zero
+	Distribution string             `json:"distribution,omitempty"`
+	Delay        modbustcp.Duration `json:"delay"`
+	Jitter       modbustcp.Duration `json:"jitter,omitempty"`
+}
+
+func (l *Latency) validate() error {
+	switch {
+	case l.Delay < 0 || l.Jitter < 0:
+		return fmt.Errorf("simulator: negative latency of unit %v, function %v", l.Unit, l.Function)
+	case l.Distribution == "fixed" && l.Jitter != 0:
+		return fmt.Errorf("simulator: fixed latency with jitter of unit %v, function %v", l.Unit, l.Function)
+	case l.Distribution != "" && l.Distribution != "fixed" && l.Distribution != "uniform" && l.Distribution != "normal":
+		return fmt.Errorf("simulator: unknown latency distribution '%v'", l.Distribution)
+	}
+	return nil
+}
+
+// matches reports whether l applies to request for unit.
+func (l *Latency) matches(unit byte, request *modbustcp.Pdu) bool {
+	return (l.Unit == 0 || l.Unit == unit) && (l.Function == 0 || l.Function == request.FunctionCode)
+}
+
+// sample returns a random delay of the distribution.
+func (l *Latency) sample() time.Duration {
+	delay, jitter := time.Duration(l.Delay), time.Duration(l.Jitter)
+	switch {
+	case jitter == 0:
+		return delay
+	case l.Distribution == "normal":
+		return max(0, delay+time.Duration(rand.NormFloat64()*float64(jitter)))
+	}
+	return delay + time.Duration(rand.Int63n(int64(jitter)+1))
+}
+
+// SetLatencies replaces the latencies of the simulator, it fails for
+// invalid latencies.
+func (s *Simulator) SetLatencies(latencies ...Latency) error {
+	for i := range latencies {
+		if err := latencies[i].validate(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.latencies = append([]Latency(nil), latencies...)
+	s.mu.Unlock()
+	return nil
+}
+
+// delay is the middleware of the server postponing the handling of
+// requests by their latency.
+func (s *Simulator) delay(next modbustcp.RequestHandler) modbustcp.RequestHandler {
+	return func(r *modbustcp.Request) *modbustcp.Pdu {
+		s.mu.RLock()
+		latencies := s.latencies
+		s.mu.RUnlock()
+		for i := range latencies {
+			if latencies[i].matches(r.Unit, r.Pdu) {
+				if d := latencies[i].sample(); d > 0 {
+					time.Sleep(d)
+				}
+				break
+			}
+		}
+		return next(r)
+	}
+}
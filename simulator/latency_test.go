@@ -0,0 +1,78 @@
+package simulator
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestLatencySample(t *testing.T) {
+	ms := modbustcp.Duration(time.Millisecond)
+	fixed := Latency{Distribution: "fixed", Delay: 10 * ms}
+	if d := fixed.sample(); d != 10*time.Millisecond {
+		t.Fatalf("fixed delay expected 10ms, actual %v", d)
+	}
+	uniform := Latency{Delay: 10 * ms, Jitter: 5 * ms}
+	normal := Latency{Distribution: "normal", Delay: 10 * ms, Jitter: 2 * ms}
+	var sum, squares float64
+	const n = 2000
+	for i := 0; i < n; i++ {
+		if d := uniform.sample(); d < 10*time.Millisecond || d > 15*time.Millisecond {
+			t.Fatalf("uniform delay expected within [10ms 15ms], actual %v", d)
+		}
+		d := normal.sample().Seconds() * 1000
+		sum += d
+		squares += d * d
+	}
+	mean := sum / n
+	if stddev := math.Sqrt(squares/n - mean*mean); math.Abs(mean-10) > 0.5 || math.Abs(stddev-2) > 0.5 {
+		t.Fatalf("normal delays expected mean 10ms and deviation 2ms, actual %.2fms %.2fms", mean, stddev)
+	}
+	if d := (&Latency{Distribution: "normal", Jitter: 100 * ms}).sample(); d < 0 {
+		t.Fatalf("delay expected not below zero, actual %v", d)
+	}
+
+	sim := New()
+	for _, l := range []Latency{
+		{Delay: -ms},
+		{Distribution: "fixed", Delay: ms, Jitter: ms},
+		{Distribution: "pareto", Delay: ms},
+	} {
+		if err := sim.SetLatencies(l); err == nil {
+			t.Fatalf("latency %+v expected to fail", l)
+		}
+	}
+}
+
+func TestSimulatorLatency(t *testing.T) {
+	d, err := NewDevice(DeviceConfig{Unit: 1, Blocks: []BlockConfig{{Address: "40001", Values: []float64{1}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := New()
+	sim.Add(d)
+	err = sim.SetLatencies(
+		Latency{Unit: 1, Function: modbustcp.FunctionReadHoldingRegister, Distribution: "fixed", Delay: modbustcp.Duration(100 * time.Millisecond)},
+		Latency{Unit: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := start(t, sim)
+	begin := time.Now()
+	if _, err = c.ReadHoldingRegisters(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(begin); elapsed < 100*time.Millisecond {
+		t.Fatalf("read expected to take 100ms, actual %v", elapsed)
+	}
+	begin = time.Now()
+	if err = c.WriteSingleRegister(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(begin); elapsed >= 100*time.Millisecond {
+		t.Fatalf("write expected without latency, actual %v", elapsed)
+	}
+}
@@ -0,0 +1,126 @@
+package simulator
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/patdhlk/modbustcp"
+	"github.com/patdhlk/modbustcp/internal/yaml"
+)
+
+// IdentityConfig configures the identification objects of a device.
+type IdentityConfig struct {
+	VendorName          string `json:"vendor_name,omitempty"`
+	ProductCode         string `json:"product_code,omitempty"`
+	Revision            string `json:"revision,omitempty"`
+	VendorUrl           string `json:"vendor_url,omitempty"`
+	ProductName         string `json:"product_name,omitempty"`
+	ModelName           string `json:"model_name,omitempty"`
+	UserApplicationName string `json:"user_application_name,omitempty"`
+}
+
+// DeviceInfo returns the identification configured by c.
+func (c *IdentityConfig) DeviceInfo() modbustcp.DeviceInfo {
+	return modbustcp.DeviceInfo{
+		VendorName:          c.VendorName,
+		ProductCode:         c.ProductCode,
+		Revision:            c.Revision,
+		VendorUrl:           c.VendorUrl,
+		ProductName:         c.ProductName,
+		ModelName:           c.ModelName,
+		UserApplicationName: c.UserApplicationName,
+	}
+}
+
+// Profile emulates a class of devices by a register map and
+// identification. The blocks and generators of a device are applied after
+// those of its profile.
+type Profile struct {
+	Name       string            `json:"name"`
+	Identity   IdentityConfig    `json:"identity"`
+	Blocks     []BlockConfig     `json:"blocks"`
+	Generators []GeneratorConfig `json:"generators,omitempty"`
+}
+
+//go:embed profiles/*.yaml
+var builtinProfiles embed.FS
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = make(map[string]*Profile)
+)
+
+// The built in profiles "power_meter", "vfd" and "sunspec_inverter"
+// emulate a three phase power meter, a variable frequency drive and a
+// three phase SunSpec inverter.
+func init() {
+	entries, err := builtinProfiles.ReadDir("profiles")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		data, err := builtinProfiles.ReadFile("profiles/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		p := &Profile{}
+		if err = yaml.Unmarshal(data, p); err != nil {
+			panic(fmt.Sprintf("simulator: profile %v: %v", e.Name(), err))
+		}
+		RegisterProfile(p)
+	}
+}
+
+// findProfile returns the profile of name in profiles or the registered
+// profiles, nil if there is none.
+func findProfile(name string, profiles []Profile) *Profile {
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i]
+		}
+	}
+	p, _ := LookupProfile(name)
+	return p
+}
+
+// LoadProfile reads a profile from a .json, .yaml or .yml file.
+func LoadProfile(path string) (*Profile, error) {
+	p := &Profile{}
+	if err := load(path, p); err != nil {
+		return nil, err
+	}
+	if p.Name == "" {
+		return nil, fmt.Errorf("simulator: profile %v without name", path)
+	}
+	return p, nil
+}
+
+// RegisterProfile makes p available to the devices of all simulators,
+// replacing a profile of the same name.
+func RegisterProfile(p *Profile) {
+	profilesMu.Lock()
+	profiles[p.Name] = p
+	profilesMu.Unlock()
+}
+
+// LookupProfile returns the registered profile of name.
+func LookupProfile(name string) (*Profile, bool) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	p, ok := profiles[name]
+	return p, ok
+}
+
+// Profiles returns the names of the registered profiles.
+func Profiles() []string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
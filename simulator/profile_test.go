@@ -0,0 +1,92 @@
+package simulator
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestBuiltinProfiles(t *testing.T) {
+	for _, name := range []string{"power_meter", "sunspec_inverter", "vfd"} {
+		if _, ok := LookupProfile(name); !ok {
+			t.Fatalf("profile '%v' expected, actual %v", name, Profiles())
+		}
+		d, err := NewDevice(DeviceConfig{Unit: 1, Profile: name})
+		if err != nil {
+			t.Fatalf("profile %v: %v", name, err)
+		}
+		if err = d.Update(0); err != nil {
+			t.Fatalf("profile %v: %v", name, err)
+		}
+	}
+	if _, err := NewDevice(DeviceConfig{Unit: 1, Profile: "toaster"}); err == nil {
+		t.Fatal("unknown profile expected to fail")
+	}
+}
+
+func TestSunSpecInverterProfile(t *testing.T) {
+	sim, err := NewFromConfig(&Config{Devices: []DeviceConfig{{Unit: 1, Profile: "sunspec_inverter"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := start(t, sim)
+	info, err := c.DeviceInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ProductCode != "INV-3" {
+		t.Fatalf("product code expected INV-3, actual %+v", info)
+	}
+	d, err := c.DiscoverSunSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	common, err := d.Common()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Manufacturer != "Simulated" || common.SerialNumber != "SIM0001" {
+		t.Fatalf("common model expected Simulated SIM0001, actual %+v", common)
+	}
+	inverter, err := d.Inverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inverter.ID != modbustcp.SunSpecInverterThree || inverter.Voltage != 230 || inverter.OperatingState != 4 || math.Abs(inverter.DCCurrent-9) > 1e-9 {
+		t.Fatalf("inverter model 103 at 230V expected, actual %+v", inverter)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pump.yaml")
+	profile := "name: pump\nidentity:\n  vendor_name: ACME\nblocks:\n  - address: \"40001\"\n    values: [7]\n"
+	if err := os.WriteFile(path, []byte(profile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := NewFromConfig(&Config{
+		Profiles: []Profile{*p},
+		Devices: []DeviceConfig{
+			{Unit: 1, Profile: "pump", Identity: &IdentityConfig{VendorName: "Other"}},
+			{Unit: 2, Profile: "pump", Blocks: []BlockConfig{{Address: "40001", Values: []float64{8}}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := sim.Identify(1); info.VendorName != "Other" {
+		t.Fatalf("vendor expected Other, actual %v", info.VendorName)
+	}
+	if info, _ := sim.Identify(2); info.VendorName != "ACME" {
+		t.Fatalf("vendor expected ACME, actual %v", info.VendorName)
+	}
+	if regs, _ := sim.Device(2).Store().GetRegisters(modbustcp.TableHoldingRegisters, 0, 1); regs[0] != 8 {
+		t.Fatalf("register expected 8, actual %v", regs[0])
+	}
+}
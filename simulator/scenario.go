@@ -0,0 +1,176 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// FaultConfig configures a modbustcp.Fault injected by a scenario.
+type FaultConfig struct {
+	// Unit restricts the fault to a unit, zero matches all.
+	Unit     byte `json:"unit,omitempty"`
+	Function byte `json:"function,omitempty"`
+	// Address and Quantity restrict the fault to requests of the range if
+	// Quantity is not zero.
+	Address     modbustcp.AddressRef `json:"address,omitempty"`
+	Quantity    uint16               `json:"quantity,omitempty"`
+	Probability float64              `json:"probability,omitempty"`
+	Exceptions  []byte               `json:"exceptions,omitempty"`
+	Delay       modbustcp.Duration   `json:"delay,omitempty"`
+	Jitter      modbustcp.Duration   `json:"jitter,omitempty"`
+	Drop        bool                 `json:"drop,omitempty"`
+	Truncate    int                  `json:"truncate,omitempty"`
+}
+
+// Fault returns the fault configured by c.
+func (c *FaultConfig) Fault() (modbustcp.Fault, error) {
+	f := modbustcp.Fault{
+		Function:    c.Function,
+		Probability: c.Probability,
+		Exceptions:  c.Exceptions,
+		Delay:       time.Duration(c.Delay),
+		Jitter:      time.Duration(c.Jitter),
+		Drop:        c.Drop,
+		Truncate:    c.Truncate,
+	}
+	if c.Quantity > 0 {
+		a, err := modbustcp.ParseAddress(string(c.Address))
+		if err != nil {
+			return f, err
+		}
+		f.Range = modbustcp.AddressRange{UnitId: c.Unit, Table: a.Table, Address: a.Offset, Quantity: c.Quantity}
+	} else if c.Unit != 0 {
+		return f, fmt.Errorf("simulator: fault of unit '%v' without address range", c.Unit)
+	}
+	return f, nil
+}
+
+// Step is an event of a scenario.
+type Step struct {
+	// At is the time of the step since the start of the scenario.
+	At modbustcp.Duration `json:"at"`
+	// Unit is the device of Set.
+	Unit byte `json:"unit,omitempty"`
+	// Set changes values of the device.
+	Set *BlockConfig `json:"set,omitempty"`
+	// Faults replace the injected faults if not nil, an empty list clears
+	// them.
+	Faults []FaultConfig `json:"faults,omitempty"`
+	// Latencies replace the latencies of the simulator if not nil, an
+	// empty list clears them.
+	Latencies []Latency `json:"latencies,omitempty"`
+	// Disconnect closes the connections of all masters.
+	Disconnect bool `json:"disconnect,omitempty"`
+}
+
+// Scenario is a timeline of value changes, injected faults, latencies
+// and dropped connections, e.g. to reproduce a sequence of failures in
+// acceptance tests:
+//
+//	steps:
+//	  - at: 1s
+//	    unit: 1
+//	    set: {address: "40001", values: [42]}
+//	  - at: 2s
+//	    faults: [{function: 3, exceptions: [6]}]
+//	    latencies: [{delay: 500ms, jitter: 100ms}]
+//	  - at: 5s
+//	    faults: []
+//	    disconnect: true
+type Scenario struct {
+	Steps []Step `json:"steps"`
+}
+
+// LoadScenario reads a scenario from a .json, .yaml or .yml file.
+func LoadScenario(path string) (*Scenario, error) {
+	sc := &Scenario{}
+	if err := load(path, sc); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// step is a parsed Step.
+type step struct {
+	at         time.Duration
+	device     *Device
+	set        *block
+	faults     []modbustcp.Fault
+	setFaults  bool
+	latencies  []Latency
+	disconnect bool
+}
+
+// parse validates the steps of sc against the devices of s.
+func (s *Simulator) parse(sc *Scenario) ([]step, error) {
+	steps := make([]step, len(sc.Steps))
+	for i, st := range sc.Steps {
+		p := step{at: time.Duration(st.At), setFaults: st.Faults != nil, disconnect: st.Disconnect}
+		if st.Set != nil {
+			if p.device = s.Device(st.Unit); p.device == nil {
+				return nil, fmt.Errorf("simulator: step %v: unknown unit '%v'", i+1, st.Unit)
+			}
+			b, err := parseBlock(*st.Set)
+			if err != nil {
+				return nil, fmt.Errorf("simulator: step %v: %w", i+1, err)
+			}
+			p.set = &b
+		}
+		for _, l := range st.Latencies {
+			if err := l.validate(); err != nil {
+				return nil, fmt.Errorf("simulator: step %v: %w", i+1, err)
+			}
+		}
+		p.latencies = st.Latencies
+		for _, fc := range st.Faults {
+			f, err := fc.Fault()
+			if err != nil {
+				return nil, fmt.Errorf("simulator: step %v: %w", i+1, err)
+			}
+			p.faults = append(p.faults, f)
+		}
+		steps[i] = p
+	}
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].at < steps[j].at })
+	return steps, nil
+}
+
+// Play runs the scenario until its last step or until ctx is done. The
+// faults of the scenario remain injected after its end.
+func (s *Simulator) Play(ctx context.Context, sc *Scenario) error {
+	steps, err := s.parse(sc)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	for i, st := range steps {
+		if wait := time.Until(start.Add(st.at)); wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			case <-t.C:
+			}
+		}
+		if st.set != nil {
+			if err = set(st.device.Store(), *st.set); err != nil {
+				return fmt.Errorf("simulator: step %v: %w", i+1, err)
+			}
+		}
+		if st.setFaults {
+			s.Server.SetFaults(st.faults...)
+		}
+		if st.latencies != nil {
+			s.SetLatencies(st.latencies...)
+		}
+		if st.disconnect {
+			s.Server.CloseConnections()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package simulator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+const testScenario = `steps:
+  - at: 0
+    unit: 1
+    set: {address: "40001", values: [42]}
+  - at: 20ms
+    faults:
+      - function: 3
+        exceptions: [6]
+`
+
+func TestScenario(t *testing.T) {
+	sim, err := NewFromConfig(&Config{Devices: []DeviceConfig{{Unit: 1, Blocks: []BlockConfig{{Address: "40001", Quantity: 10}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := start(t, sim)
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err = os.WriteFile(path, []byte(testScenario), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sc, err := LoadScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	begin := time.Now()
+	if err = sim.Play(context.Background(), sc); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(begin); elapsed < 20*time.Millisecond {
+		t.Fatalf("scenario expected to last 20ms, actual %v", elapsed)
+	}
+	if regs, _ := sim.Device(1).Store().GetRegisters(modbustcp.TableHoldingRegisters, 0, 1); regs[0] != 42 {
+		t.Fatalf("register expected 42, actual %v", regs[0])
+	}
+	if _, err = c.ReadHoldingRegisters(0, 1); err != modbustcp.ErrorSlaveIsBusy {
+		t.Fatalf("error expected %v, actual %v", modbustcp.ErrorSlaveIsBusy, err)
+	}
+
+	if err = sim.Play(context.Background(), &Scenario{Steps: []Step{{Faults: []FaultConfig{}, Disconnect: true}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.ReadHoldingRegisters(0, 1); err == nil {
+		t.Fatal("request on a closed connection expected to fail")
+	}
+	c.Disconnect()
+	if err = c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if regs, err := c.ReadHoldingRegisters(0, 1); err != nil || regs[0] != 42 {
+		t.Fatalf("register expected 42 after the faults were cleared, actual %v %v", regs, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = sim.Play(ctx, &Scenario{Steps: []Step{{At: modbustcp.Duration(time.Hour), Disconnect: true}}}); err != context.DeadlineExceeded {
+		t.Fatalf("error expected %v, actual %v", context.DeadlineExceeded, err)
+	}
+	if err = sim.Play(ctx, &Scenario{Steps: []Step{{Unit: 2, Set: &BlockConfig{Address: "40001"}}}}); err == nil {
+		t.Fatal("step of an unknown unit expected to fail")
+	}
+}
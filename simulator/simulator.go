@@ -0,0 +1,640 @@
+// Package simulator runs simulated Modbus TCP devices for development
+// and CI, built on the server of package modbustcp. A simulator hosts
+// devices under their unit ids, each with its own data tables holding
+// the initial values of its configuration:
+//
+//	cfg, err := simulator.LoadConfig("devices.yaml")
+//	...
+//	sim, err := simulator.NewFromConfig(cfg)
+//	...
+//	sim.ListenAndServe()
+//
+// Several simulators serve several sets of devices on different
+// addresses. A configuration file in YAML or JSON looks like:
+//
+//	listen: ":502"
+//	# copies of the devices on the ports 502 to 511, see Fleet
+//	instances: 10
+//	# web page and JSON API of the data tables, see Simulator.ServeHTTP
+//	http: ":8080"
+//	# update interval of the generators
+//	interval: 100ms
+//	# faults injected from the start, see FaultConfig
+//	faults:
+//	  - {function: 3, address: "40001", quantity: 10, exceptions: [6], probability: 0.1}
+//	# response times, the first matching latency applies, see Latency
+//	latencies:
+//	  - {unit: 2, function: 16, distribution: fixed, delay: 200ms}
+//	  - {distribution: normal, delay: 20ms, jitter: 5ms}
+//	devices:
+//	  - unit: 1
+//	    profile: power_meter
+//	  - unit: 2
+//	    # copies of the device
+//	    units: [3, 4]
+//	    name: tank
+//	    identity: {vendor_name: ACME, product_code: T1, revision: "1.0"}
+//	    blocks:
+//	      - {address: "40001", type: float32, values: [21.5]}
+//	      - {address: "holding:10", text: TANK, quantity: 4}
+//	      - {address: "00001", values: [1, 0]}
+//	    generators:
+//	      - {address: "30001", kind: sine, period: 1m, offset: 500, amplitude: 100}
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+	"github.com/patdhlk/modbustcp/internal/yaml"
+)
+
+// BlockConfig configures contiguous values of a data table.
+type BlockConfig struct {
+	// Address of the first value in Modicon notation or as table and
+	// protocol offset, e.g. "40001" or "holding:0".
+	Address modbustcp.AddressRef `json:"address"`
+	// Type is the data type of register values, "uint16" if empty.
+	Type      string `json:"type,omitempty"`
+	WordOrder string `json:"word_order,omitempty"`
+	// Values are the initial values, 0 or 1 for coils and inputs.
+	Values []float64 `json:"values,omitempty"`
+	// Text is an initial string packed into registers instead of Values,
+	// two characters per register.
+	Text string `json:"text,omitempty"`
+	// Quantity reserves values initialized with zero, it defaults to the
+	// number of Values or the registers of Text.
+	Quantity int `json:"quantity,omitempty"`
+}
+
+// DeviceConfig configures a simulated device.
+type DeviceConfig struct {
+	Unit byte `json:"unit"`
+	// Units host copies of the device under further unit ids.
+	Units []byte `json:"units,omitempty"`
+	Name  string `json:"name,omitempty"`
+	// Profile is the name of the emulated profile, see RegisterProfile.
+	Profile string `json:"profile,omitempty"`
+	// Identity overrides the identification of the profile.
+	Identity   *IdentityConfig   `json:"identity,omitempty"`
+	Blocks     []BlockConfig     `json:"blocks"`
+	Generators []GeneratorConfig `json:"generators,omitempty"`
+}
+
+// Config configures a simulator.
+type Config struct {
+	// Listen is the address of the server, ":502" if empty.
+	Listen string `json:"listen,omitempty"`
+	// Instances is the number of simulators of a Fleet serving copies of
+	// the devices on the consecutive ports from the port of Listen.
+	Instances int `json:"instances,omitempty"`
+	// HTTP is the address of the web endpoint, disabled if empty.
+	HTTP string `json:"http,omitempty"`
+	// Interval is the update interval of the generators, 100ms if zero.
+	Interval modbustcp.Duration `json:"interval,omitempty"`
+	// Profiles are available to the devices in addition to the registered
+	// profiles, which they take precedence over.
+	Profiles []Profile `json:"profiles,omitempty"`
+	// Faults are injected from the start.
+	Faults []FaultConfig `json:"faults,omitempty"`
+	// Latencies delay the responses, the first matching latency applies.
+	Latencies []Latency      `json:"latencies,omitempty"`
+	Devices   []DeviceConfig `json:"devices"`
+}
+
+// LoadConfig reads a simulator configuration from a .json, .yaml or .yml
+// file.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if err := load(path, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// load decodes the .json, .yaml or .yml file at path into v.
+func load(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, v)
+	default:
+		err = json.Unmarshal(data, v)
+	}
+	if err != nil {
+		return fmt.Errorf("%v: %v", path, err)
+	}
+	return nil
+}
+
+// block is a parsed BlockConfig.
+type block struct {
+	address modbustcp.Address
+	codec   modbustcp.Codec
+	values  []float64
+	text    string
+	// size is the number of bits or registers
+	size int
+}
+
+func parseBlock(cfg BlockConfig) (block, error) {
+	b := block{values: cfg.Values, text: cfg.Text}
+	var err error
+	if b.address, err = modbustcp.ParseAddress(string(cfg.Address)); err != nil {
+		return b, err
+	}
+	if cfg.Type != "" {
+		if b.codec.Type, err = modbustcp.ParseDataType(cfg.Type); err != nil {
+			return b, err
+		}
+	}
+	if cfg.WordOrder != "" {
+		if b.codec.Order, err = modbustcp.ParseWordOrder(cfg.WordOrder); err != nil {
+			return b, err
+		}
+	}
+	n := max(cfg.Quantity, len(cfg.Values))
+	switch {
+	case cfg.Text != "" && b.address.Table.IsBit():
+		return b, fmt.Errorf("simulator: text at bit address '%v'", cfg.Address)
+	case cfg.Text != "":
+		n = max(cfg.Quantity, (len(cfg.Text)+1)/2)
+	case !b.address.Table.IsBit():
+		n *= b.codec.Registers()
+	}
+	b.size = n
+	if int(b.address.Offset)+n > 0x10000 {
+		return b, fmt.Errorf("simulator: block at '%v' exceeds the address space", cfg.Address)
+	}
+	return b, nil
+}
+
+// template is a parsed DeviceConfig shared by the copies of a device.
+// Its store holds the values of the copies which have not been written,
+// so unmodified copies cost no data tables and share one update of
+// their generated values.
+type template struct {
+	name       string
+	identity   modbustcp.DeviceInfo
+	sizes      [4]int
+	store      *modbustcp.DataStore
+	mu         sync.Mutex
+	generators []*generator
+}
+
+// newTemplate parses cfg looking up its profile in profiles before the
+// registered profiles.
+func newTemplate(cfg DeviceConfig, profiles []Profile) (*template, error) {
+	var identity IdentityConfig
+	if cfg.Profile != "" {
+		p := findProfile(cfg.Profile, profiles)
+		if p == nil {
+			return nil, fmt.Errorf("simulator: unit %v: unknown profile '%v'", cfg.Unit, cfg.Profile)
+		}
+		identity = p.Identity
+		cfg.Blocks = append(append([]BlockConfig(nil), p.Blocks...), cfg.Blocks...)
+		cfg.Generators = append(append([]GeneratorConfig(nil), p.Generators...), cfg.Generators...)
+	}
+	if cfg.Identity != nil {
+		identity = *cfg.Identity
+	}
+	t := &template{name: cfg.Name, identity: identity.DeviceInfo()}
+	blocks := make([]block, len(cfg.Blocks))
+	for i, bc := range cfg.Blocks {
+		b, err := parseBlock(bc)
+		if err != nil {
+			return nil, fmt.Errorf("simulator: unit %v: %w", cfg.Unit, err)
+		}
+		blocks[i] = b
+		table := b.address.Table
+		t.sizes[table] = max(t.sizes[table], int(b.address.Offset)+b.size)
+	}
+	t.generators = make([]*generator, len(cfg.Generators))
+	for i, gc := range cfg.Generators {
+		g, err := newGenerator(gc)
+		if err != nil {
+			return nil, fmt.Errorf("simulator: unit %v: %w", cfg.Unit, err)
+		}
+		t.generators[i] = g
+		table := g.address.Table
+		t.sizes[table] = max(t.sizes[table], int(g.address.Offset)+g.size())
+	}
+	t.store = t.newStore()
+	for _, b := range blocks {
+		if err := set(t.store, b); err != nil {
+			return nil, fmt.Errorf("simulator: unit %v: %w", cfg.Unit, err)
+		}
+	}
+	return t, nil
+}
+
+// newStore creates empty data tables of the size of the template.
+func (t *template) newStore() *modbustcp.DataStore {
+	return modbustcp.NewDataStore(t.sizes[modbustcp.TableCoils], t.sizes[modbustcp.TableDiscreteInputs],
+		t.sizes[modbustcp.TableHoldingRegisters], t.sizes[modbustcp.TableInputRegisters])
+}
+
+// update sets the generated values of the shared store.
+func (t *template) update(elapsed time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return updateAll(t.generators, t.store, elapsed)
+}
+
+// device creates a copy of the template under unit.
+func (t *template) device(unit byte) *Device {
+	return &Device{Unit: unit, Name: t.name, Identity: t.identity, template: t}
+}
+
+// Device is a simulated device. A device shares the data tables of the
+// other copies of its configuration until it is written by a master,
+// the web endpoint or a scenario.
+type Device struct {
+	Unit     byte
+	Name     string
+	Identity modbustcp.DeviceInfo
+
+	template *template
+	mu       sync.Mutex
+	// store and generators are the own ones after the first write
+	store      *modbustcp.DataStore
+	generators []*generator
+}
+
+// NewDevice creates a device with the data tables of cfg. Each table
+// holds the values up to the end of its last block or generated value,
+// addresses beyond are answered with an illegal data address exception.
+func NewDevice(cfg DeviceConfig) (*Device, error) {
+	t, err := newTemplate(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return t.device(cfg.Unit), nil
+}
+
+// Store returns the data tables of the device for reading and writing,
+// copying the shared tables on the first call.
+func (d *Device) Store() *modbustcp.DataStore {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.store != nil {
+		return d.store
+	}
+	t := d.template
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	store := t.newStore()
+	for _, table := range []modbustcp.Table{modbustcp.TableCoils, modbustcp.TableDiscreteInputs} {
+		bits, _ := t.store.GetBits(table, 0, t.store.Size(table))
+		store.SetBits(table, 0, bits)
+	}
+	for _, table := range []modbustcp.Table{modbustcp.TableInputRegisters, modbustcp.TableHoldingRegisters} {
+		regs, _ := t.store.GetRegisters(table, 0, t.store.Size(table))
+		store.SetRegisters(table, 0, regs)
+	}
+	d.generators = make([]*generator, len(t.generators))
+	for i, g := range t.generators {
+		clone := *g
+		d.generators[i] = &clone
+	}
+	d.store = store
+	return store
+}
+
+// view returns the data tables of the device for reading, the shared
+// ones until the device is written.
+func (d *Device) view() *modbustcp.DataStore {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.store != nil {
+		return d.store
+	}
+	return d.template.store
+}
+
+// shared reports whether the device uses the shared data tables.
+func (d *Device) shared() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.store == nil
+}
+
+// set stores the values of b in store.
+func set(store *modbustcp.DataStore, b block) error {
+	t, address := b.address.Table, b.address.Offset
+	if t.IsBit() {
+		bits := make([]bool, len(b.values))
+		for i, v := range b.values {
+			bits[i] = v != 0
+		}
+		return store.SetBits(t, address, bits)
+	}
+	if b.text != "" {
+		regs, err := modbustcp.EncodeString(b.text, b.size, modbustcp.StringOptions{})
+		if err != nil {
+			return err
+		}
+		return store.SetRegisters(t, address, regs)
+	}
+	regs := make([]uint16, 0, b.size)
+	for _, v := range b.values {
+		encoded, err := b.codec.EncodeRaw(v)
+		if err != nil {
+			return err
+		}
+		regs = append(regs, encoded...)
+	}
+	return store.SetRegisters(t, address, regs)
+}
+
+// Update sets the generated values after elapsed time since the start
+// of the simulation. Updating a device which shares its data tables
+// updates all copies sharing them.
+func (d *Device) Update(elapsed time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var err error
+	if d.store != nil {
+		err = updateAll(d.generators, d.store, elapsed)
+	} else {
+		err = d.template.update(elapsed)
+	}
+	if err != nil {
+		return fmt.Errorf("simulator: unit %v: %w", d.Unit, err)
+	}
+	return nil
+}
+
+// updateAll sets the values of generators in store.
+func updateAll(generators []*generator, store *modbustcp.DataStore, elapsed time.Duration) error {
+	for _, g := range generators {
+		if err := g.update(store, elapsed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Simulator serves simulated devices. Requests of units without device
+// are answered with a gateway target device failed exception.
+type Simulator struct {
+	// Server serves the devices, it may be tuned before serving.
+	Server *modbustcp.Server
+	// Listen is the address of the server, ":502" if empty.
+	Listen string
+	// HTTP is the address of the web endpoint served by ListenAndServe,
+	// disabled if empty.
+	HTTP string
+	// Interval is the update interval of the generators, 100ms if zero.
+	Interval time.Duration
+	// ErrorHandler is invoked for failed updates.
+	ErrorHandler func(err error)
+
+	mu        sync.RWMutex
+	devices   map[byte]*Device
+	latencies []Latency
+	// update serializes the updates of the generators
+	update sync.Mutex
+	// secondary instances of a fleet leave the updates of the shared
+	// data tables to the first instance
+	secondary bool
+}
+
+// New creates a simulator without devices.
+func New() *Simulator {
+	s := &Simulator{devices: make(map[byte]*Device)}
+	// the server does without the data tables of NewServer
+	s.Server = &modbustcp.Server{Handler: s}
+	s.Server.Use(s.delay)
+	return s
+}
+
+// NewFromConfig creates a simulator with the devices of cfg.
+func NewFromConfig(cfg *Config) (*Simulator, error) {
+	templates, err := newTemplates(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newSimulator(cfg, templates)
+}
+
+// newTemplates parses the devices of cfg.
+func newTemplates(cfg *Config) ([]*template, error) {
+	templates := make([]*template, len(cfg.Devices))
+	for i, dc := range cfg.Devices {
+		t, err := newTemplate(dc, cfg.Profiles)
+		if err != nil {
+			return nil, err
+		}
+		templates[i] = t
+	}
+	return templates, nil
+}
+
+// newSimulator creates a simulator of cfg with copies of templates, the
+// parsed devices of cfg.
+func newSimulator(cfg *Config, templates []*template) (*Simulator, error) {
+	s := New()
+	s.Listen = cfg.Listen
+	s.HTTP = cfg.HTTP
+	s.Interval = time.Duration(cfg.Interval)
+	for i, dc := range cfg.Devices {
+		for _, unit := range append([]byte{dc.Unit}, dc.Units...) {
+			if err := s.Add(templates[i].device(unit)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	faults := make([]modbustcp.Fault, len(cfg.Faults))
+	for i, fc := range cfg.Faults {
+		f, err := fc.Fault()
+		if err != nil {
+			return nil, err
+		}
+		faults[i] = f
+	}
+	s.Server.Faults = faults
+	if err := s.SetLatencies(cfg.Latencies...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add hosts d under its unit id.
+func (s *Simulator) Add(d *Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.devices[d.Unit]; ok {
+		return fmt.Errorf("simulator: duplicate device of unit '%v'", d.Unit)
+	}
+	s.devices[d.Unit] = d
+	return nil
+}
+
+// Device returns the device of unit, nil if there is none.
+func (s *Simulator) Device(unit byte) *Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.devices[unit]
+}
+
+// Units returns the unit ids of the devices in ascending order.
+func (s *Simulator) Units() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	units := make([]byte, 0, len(s.devices))
+	for unit := range s.devices {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i] < units[j] })
+	return units
+}
+
+// ListenAndServe serves the devices and the web endpoint and updates
+// their generated values until Close is called.
+func (s *Simulator) ListenAndServe() error {
+	listen := s.Listen
+	if listen == "" {
+		listen = ":502"
+	}
+	if s.HTTP != "" {
+		l, err := net.Listen("tcp", s.HTTP)
+		if err != nil {
+			return err
+		}
+		web := &http.Server{Handler: s}
+		go web.Serve(l)
+		defer web.Close()
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.run(stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	return s.Server.ListenAndServe(listen)
+}
+
+// run updates the devices each interval until stop is closed.
+func (s *Simulator) run(stop <-chan struct{}) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	start := time.Now()
+	s.Update(0)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-t.C:
+			s.Update(now.Sub(start))
+		}
+	}
+}
+
+// Update sets the generated values of all devices after elapsed time
+// since the start of the simulation. Devices sharing their data tables
+// are updated once, by the first instance of a fleet.
+func (s *Simulator) Update(elapsed time.Duration) {
+	s.update.Lock()
+	defer s.update.Unlock()
+	s.mu.RLock()
+	devices := make([]*Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		devices = append(devices, d)
+	}
+	s.mu.RUnlock()
+	updated := make(map[*template]bool)
+	for _, d := range devices {
+		if d.shared() {
+			if s.secondary || updated[d.template] {
+				continue
+			}
+			updated[d.template] = true
+		}
+		if err := d.Update(elapsed); err != nil && s.ErrorHandler != nil {
+			s.ErrorHandler(err)
+		}
+	}
+}
+
+// Close stops serving.
+func (s *Simulator) Close() error {
+	return s.Server.Close()
+}
+
+// Identify implements modbustcp.Identifier.
+func (s *Simulator) Identify(unit byte) (modbustcp.DeviceInfo, bool) {
+	if d := s.Device(unit); d != nil {
+		return d.Identity, true
+	}
+	return modbustcp.DeviceInfo{}, false
+}
+
+// store returns the data tables of unit, shared ones unless they are
+// written.
+func (s *Simulator) store(unit byte, write bool) (*modbustcp.DataStore, error) {
+	d := s.Device(unit)
+	switch {
+	case d == nil:
+		return nil, modbustcp.ErrorGatewayTargetFailed
+	case write:
+		return d.Store(), nil
+	}
+	return d.view(), nil
+}
+
+// ReadBits implements modbustcp.Handler.
+func (s *Simulator) ReadBits(unit byte, table modbustcp.Table, address uint16, quantity int) ([]bool, error) {
+	store, err := s.store(unit, false)
+	if err != nil {
+		return nil, err
+	}
+	return store.ReadBits(unit, table, address, quantity)
+}
+
+// ReadRegisters implements modbustcp.Handler.
+func (s *Simulator) ReadRegisters(unit byte, table modbustcp.Table, address uint16, quantity int) ([]uint16, error) {
+	store, err := s.store(unit, false)
+	if err != nil {
+		return nil, err
+	}
+	return store.ReadRegisters(unit, table, address, quantity)
+}
+
+// WriteCoils implements modbustcp.Handler.
+func (s *Simulator) WriteCoils(unit byte, address uint16, values []bool) error {
+	store, err := s.store(unit, true)
+	if err != nil {
+		return err
+	}
+	return store.WriteCoils(unit, address, values)
+}
+
+// WriteHoldingRegisters implements modbustcp.Handler.
+func (s *Simulator) WriteHoldingRegisters(unit byte, address uint16, values []uint16) error {
+	store, err := s.store(unit, true)
+	if err != nil {
+		return err
+	}
+	return store.WriteHoldingRegisters(unit, address, values)
+}
@@ -0,0 +1,181 @@
+package simulator
+
+import (
+	"errors"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// start serves sim and returns a client connected to unit 1.
+func start(t *testing.T, sim *Simulator) *modbustcp.ModbusTcpClient {
+	sim.Listen = "127.0.0.1:0"
+	done := make(chan error, 1)
+	go func() { done <- sim.ListenAndServe() }()
+	t.Cleanup(func() {
+		sim.Close()
+		if err := <-done; !errors.Is(err, modbustcp.ErrorServerClosed) {
+			t.Errorf("ListenAndServe expected %v, actual %v", modbustcp.ErrorServerClosed, err)
+		}
+	})
+	for sim.Server.Addr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	host, port, _ := net.SplitHostPort(sim.Server.Addr().String())
+	p, _ := strconv.Atoi(port)
+	c := modbustcp.NewModbusTcpClient(host, p)
+	c.SlaveId = 1
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Disconnect() })
+	return c
+}
+
+const testConfig = `listen: ":1502"
+devices:
+  - unit: 1
+    name: meter
+    blocks:
+      - address: "40001"
+        values: [1, 2, 3]
+      - address: "holding:10"
+        type: float32
+        values: [1.5]
+      - address: "00001"
+        values: [1, 0, 1]
+  - unit: 2
+    blocks:
+      - address: "30001"
+        quantity: 100
+`
+
+func TestSimulator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sim.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Listen != ":1502" || len(cfg.Devices) != 2 {
+		t.Fatalf("configuration expected 2 devices, actual %+v", cfg)
+	}
+	sim, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := sim.Device(2).Store().Size(modbustcp.TableInputRegisters); size != 100 {
+		t.Fatalf("input registers expected 100, actual %v", size)
+	}
+	c := start(t, sim)
+	regs, err := c.ReadHoldingRegisters(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[2] != 3 {
+		t.Fatalf("registers expected [1 2 3], actual %v", regs)
+	}
+	regs, err = c.ReadHoldingRegisters(10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := math.Float32frombits(modbustcp.RegistersToUint32(regs)); v != 1.5 {
+		t.Fatalf("float expected 1.5, actual %v", v)
+	}
+	coils, err := c.ReadCoils(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !coils[0] || coils[1] || !coils[2] {
+		t.Fatalf("coils expected [true false true], actual %v", coils)
+	}
+	if _, err = c.ReadHoldingRegisters(12, 1); err != modbustcp.ErrorIllegalDataAddress {
+		t.Fatalf("error expected %v, actual %v", modbustcp.ErrorIllegalDataAddress, err)
+	}
+	if err = c.WriteSingleRegister(1, 7); err != nil {
+		t.Fatal(err)
+	}
+	if regs, _ = sim.Device(1).Store().GetRegisters(modbustcp.TableHoldingRegisters, 1, 1); regs[0] != 7 {
+		t.Fatalf("written register expected 7, actual %v", regs[0])
+	}
+	c.SlaveId = 3
+	if _, err = c.ReadHoldingRegisters(0, 1); err != modbustcp.ErrorGatewayTargetFailed {
+		t.Fatalf("error expected %v, actual %v", modbustcp.ErrorGatewayTargetFailed, err)
+	}
+}
+
+func TestNewFromConfigErrors(t *testing.T) {
+	for _, cfg := range []*Config{
+		{Devices: []DeviceConfig{{Unit: 1}, {Unit: 1}}},
+		{Devices: []DeviceConfig{{Unit: 1, Blocks: []BlockConfig{{Address: "50001"}}}}},
+		{Devices: []DeviceConfig{{Unit: 1, Blocks: []BlockConfig{{Address: "40001", Type: "int8"}}}}},
+		{Devices: []DeviceConfig{{Unit: 1, Blocks: []BlockConfig{{Address: "465536", Type: "uint32", Values: []float64{1}}}}}},
+		{Devices: []DeviceConfig{{Unit: 1, Blocks: []BlockConfig{{Address: "40001", Values: []float64{-1}}}}}},
+	} {
+		if _, err := NewFromConfig(cfg); err == nil {
+			t.Fatalf("configuration %+v expected to fail", cfg.Devices)
+		}
+	}
+}
+
+const schemaConfig = `listen: ":502"
+http: ":8080"
+interval: 100ms
+faults:
+  - {function: 3, address: "40001", quantity: 10, exceptions: [6], probability: 0.1}
+latencies:
+  - {unit: 2, function: 16, distribution: fixed, delay: 200ms}
+  - {distribution: normal, delay: 20ms, jitter: 5ms}
+devices:
+  - unit: 1
+    profile: power_meter
+  - unit: 2
+    units: [3, 4]
+    name: tank
+    identity: {vendor_name: ACME, product_code: T1, revision: "1.0"}
+    blocks:
+      - {address: "40001", type: float32, values: [21.5]}
+      - {address: "holding:10", text: TANK, quantity: 4}
+      - {address: "00001", values: [1, 0]}
+    generators:
+      - {address: "30001", kind: sine, period: 1m, offset: 500, amplitude: 100}
+`
+
+func TestConfigSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sim.yml")
+	if err := os.WriteFile(path, []byte(schemaConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := NewFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if units := sim.Units(); string(units) != string([]byte{1, 2, 3, 4}) {
+		t.Fatalf("units expected [1 2 3 4], actual %v", units)
+	}
+	if sim.Interval != 100*time.Millisecond || len(sim.Server.Faults) != 1 || sim.Server.Faults[0].Range.Quantity != 10 {
+		t.Fatalf("interval and fault expected, actual %v %+v", sim.Interval, sim.Server.Faults)
+	}
+	if sim.HTTP != ":8080" || len(sim.latencies) != 2 || time.Duration(sim.latencies[1].Jitter) != 5*time.Millisecond {
+		t.Fatalf("web endpoint and latencies expected, actual %v %+v", sim.HTTP, sim.latencies)
+	}
+	if d := sim.Device(4); d.Name != "tank" || d.Identity.ProductCode != "T1" {
+		t.Fatalf("copy of the tank expected, actual %+v", d)
+	}
+	text, _ := sim.Device(3).Store().GetRegisters(modbustcp.TableHoldingRegisters, 10, 4)
+	if s := modbustcp.DecodeString(text, modbustcp.StringOptions{}); s != "TANK" {
+		t.Fatalf("text expected TANK, actual %v", s)
+	}
+}
@@ -0,0 +1,300 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+// ServeHTTP implements http.Handler, exposing the data tables of the
+// devices for inspection and live editing with JSON bodies:
+//
+//	GET /                                  minimal web page
+//	GET /units
+//	GET /units/{unit}/{table}/{address}?count=n
+//	PUT /units/{unit}/{table}/{address}    {"values": [1, 2]}
+//
+// Tables are named as by modbustcp.ParseTable, addresses are protocol
+// offsets. All tables are writable, including discrete inputs and input
+// registers, though generated values are overwritten by the next update.
+// The query parameters type and word_order select the data type of
+// register values, e.g. "?type=float32&count=2" reads two floats.
+// Failures are answered with {"error": "..."}.
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/" {
+		if r.Method != http.MethodGet {
+			writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("simulator: method '%v' not allowed", r.Method))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, page)
+		return
+	}
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case path[0] != "units" || len(path) != 1 && len(path) != 4:
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("simulator: unknown path '%v'", r.URL.Path))
+	case len(path) == 1 && r.Method == http.MethodGet:
+		s.listUnits(w)
+	case len(path) == 4 && r.Method == http.MethodGet:
+		s.readValues(w, r, path)
+	case len(path) == 4 && r.Method == http.MethodPut:
+		s.writeValues(w, r, path)
+	default:
+		writeJSONError(w, http.StatusMethodNotAllowed, fmt.Errorf("simulator: method '%v' not allowed", r.Method))
+	}
+}
+
+// unitStatus describes a device in the unit list.
+type unitStatus struct {
+	Unit byte   `json:"unit"`
+	Name string `json:"name,omitempty"`
+	// Sizes are the number of values of each table.
+	Sizes map[string]int `json:"sizes"`
+}
+
+type valuesResponse struct {
+	Unit    byte            `json:"unit"`
+	Table   modbustcp.Table `json:"table"`
+	Address uint16          `json:"address"`
+	// Registers is the number of registers of each value.
+	Registers int         `json:"registers,omitempty"`
+	Values    interface{} `json:"values"`
+}
+
+type valuesRequest struct {
+	Values []json.RawMessage `json:"values"`
+}
+
+func (s *Simulator) listUnits(w http.ResponseWriter) {
+	units := []unitStatus{}
+	for _, unit := range s.Units() {
+		d := s.Device(unit)
+		sizes := make(map[string]int)
+		for _, t := range []modbustcp.Table{modbustcp.TableCoils, modbustcp.TableDiscreteInputs, modbustcp.TableInputRegisters, modbustcp.TableHoldingRegisters} {
+			sizes[t.String()] = d.view().Size(t)
+		}
+		units = append(units, unitStatus{Unit: unit, Name: d.Name, Sizes: sizes})
+	}
+	writeJSON(w, http.StatusOK, units)
+}
+
+// location parses the device, table and address of the request path and
+// the codec of its query, it answers failures itself.
+func (s *Simulator) location(w http.ResponseWriter, r *http.Request, path []string) (*Device, modbustcp.Table, uint16, modbustcp.Codec, bool) {
+	var codec modbustcp.Codec
+	unit, err := strconv.ParseUint(path[1], 10, 8)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("simulator: invalid unit '%v'", path[1]))
+		return nil, 0, 0, codec, false
+	}
+	d := s.Device(byte(unit))
+	if d == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("simulator: unknown unit '%v'", unit))
+		return nil, 0, 0, codec, false
+	}
+	table, err := modbustcp.ParseTable(path[2])
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return nil, 0, 0, codec, false
+	}
+	address, err := strconv.ParseUint(path[3], 10, 16)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("simulator: invalid address '%v'", path[3]))
+		return nil, 0, 0, codec, false
+	}
+	query := r.URL.Query()
+	if v := query.Get("type"); v != "" {
+		codec.Type, err = modbustcp.ParseDataType(v)
+	}
+	if v := query.Get("word_order"); v != "" && err == nil {
+		codec.Order, err = modbustcp.ParseWordOrder(v)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return nil, 0, 0, codec, false
+	}
+	return d, table, uint16(address), codec, true
+}
+
+func (s *Simulator) readValues(w http.ResponseWriter, r *http.Request, path []string) {
+	d, table, address, codec, ok := s.location(w, r, path)
+	if !ok {
+		return
+	}
+	count := uint64(1)
+	if v := r.URL.Query().Get("count"); v != "" {
+		var err error
+		if count, err = strconv.ParseUint(v, 10, 16); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Errorf("simulator: invalid count '%v'", v))
+			return
+		}
+	}
+	response := valuesResponse{Unit: d.Unit, Table: table, Address: address}
+	if table.IsBit() {
+		bits, err := d.view().GetBits(table, address, int(count))
+		if err != nil {
+			writeJSONError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		response.Values = bits
+	} else {
+		n := codec.Registers()
+		regs, err := d.view().GetRegisters(table, address, int(count)*n)
+		if err != nil {
+			writeJSONError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		decoded := make([]float64, count)
+		for i := range decoded {
+			if decoded[i], err = codec.Raw(regs[i*n : (i+1)*n]); err != nil {
+				writeJSONError(w, http.StatusUnprocessableEntity, err)
+				return
+			}
+		}
+		response.Registers, response.Values = n, decoded
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Simulator) writeValues(w http.ResponseWriter, r *http.Request, path []string) {
+	d, table, address, codec, ok := s.location(w, r, path)
+	if !ok {
+		return
+	}
+	var req valuesRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("simulator: invalid request body: %v", err))
+		return
+	}
+	var err error
+	if table.IsBit() {
+		bits := make([]bool, len(req.Values))
+		for i, v := range req.Values {
+			if json.Unmarshal(v, &bits[i]) != nil {
+				var n float64
+				if json.Unmarshal(v, &n) != nil {
+					writeJSONError(w, http.StatusBadRequest, fmt.Errorf("simulator: invalid bit value '%s'", v))
+					return
+				}
+				bits[i] = n != 0
+			}
+		}
+		err = d.Store().SetBits(table, address, bits)
+	} else {
+		regs := make([]uint16, 0, len(req.Values)*codec.Registers())
+		for _, v := range req.Values {
+			var n float64
+			if json.Unmarshal(v, &n) != nil {
+				writeJSONError(w, http.StatusBadRequest, fmt.Errorf("simulator: invalid register value '%s'", v))
+				return
+			}
+			encoded, err := codec.EncodeRaw(n)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err)
+				return
+			}
+			regs = append(regs, encoded...)
+		}
+		err = d.Store().SetRegisters(table, address, regs)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// page lists the devices and views and edits ranges of their tables by
+// the JSON API.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Modbus simulator</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-top: 1em; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: right; }
+input.value { width: 7em; }
+#error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Modbus simulator</h1>
+<form id="range">
+<select id="unit"></select>
+<select id="table">
+<option>holding</option><option>input</option><option>coils</option><option>discrete</option>
+</select>
+address <input id="address" type="number" min="0" max="65535" value="0">
+count <input id="count" type="number" min="1" max="125" value="10">
+type <input id="type" placeholder="uint16" size="8">
+<button>Read</button>
+</form>
+<p id="error"></p>
+<table id="values"></table>
+<script>
+const $ = id => document.getElementById(id);
+function query() {
+	const t = $("type").value;
+	return t ? "type=" + encodeURIComponent(t) + "&" : "";
+}
+function url() {
+	return "units/" + $("unit").value + "/" + $("table").value + "/" + $("address").value;
+}
+async function call(method, path, body) {
+	const r = await fetch(path, {method: method, body: body && JSON.stringify(body)});
+	if (r.status == 204) return null;
+	const v = await r.json();
+	if (!r.ok) throw new Error(v.error);
+	return v;
+}
+let size = 1;
+async function read() {
+	$("error").textContent = "";
+	try {
+		const v = await call("GET", url() + "?" + query() + "count=" + $("count").value);
+		size = v.registers || 1;
+		const rows = v.values.map((value, i) =>
+			"<tr><th>" + (v.address + i * size) + "</th><td><input class=value data-i=" + i +
+			" value=" + JSON.stringify(String(value)) + "></td></tr>");
+		$("values").innerHTML = "<tr><th>address</th><th>value</th></tr>" + rows.join("");
+	} catch (e) {
+		$("error").textContent = e.message;
+	}
+}
+$("values").addEventListener("change", async e => {
+	const address = Number($("address").value) + Number(e.target.dataset.i) * size;
+	const value = e.target.value == "true" ? 1 : e.target.value == "false" ? 0 : Number(e.target.value);
+	try {
+		await call("PUT", "units/" + $("unit").value + "/" + $("table").value + "/" + address + "?" + query(), {values: [value]});
+		read();
+	} catch (err) {
+		$("error").textContent = err.message;
+	}
+});
+$("range").addEventListener("submit", e => { e.preventDefault(); read(); });
+call("GET", "units").then(units => {
+	$("unit").innerHTML = units.map(u => "<option value=" + u.unit + ">" + u.unit + (u.name ? " " + u.name : "") + "</option>").join("");
+	read();
+});
+</script>
+</body>
+</html>
+`
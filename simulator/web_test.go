@@ -0,0 +1,85 @@
+package simulator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/patdhlk/modbustcp"
+)
+
+func TestSimulatorHTTP(t *testing.T) {
+	d, err := NewDevice(DeviceConfig{Unit: 1, Name: "meter", Blocks: []BlockConfig{
+		{Address: "40001", Values: []float64{1, 2}},
+		{Address: "40011", Type: "float32", Values: []float64{1.5}},
+		{Address: "00001", Values: []float64{1}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim := New()
+	sim.Add(d)
+	ts := httptest.NewServer(sim)
+	defer ts.Close()
+	request := func(method, path, body string, v interface{}) int {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil {
+			json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode
+	}
+
+	var units []unitStatus
+	if status := request("GET", "/units", "", &units); status != http.StatusOK || len(units) != 1 || units[0].Name != "meter" || units[0].Sizes["holding"] != 12 {
+		t.Fatalf("unit list expected meter with 12 holding registers, actual %v %+v", status, units)
+	}
+	var values struct{ Values []float64 }
+	if status := request("GET", "/units/1/holding/0?count=2", "", &values); status != http.StatusOK || len(values.Values) != 2 || values.Values[1] != 2 {
+		t.Fatalf("registers expected [1 2], actual %v %v", status, values.Values)
+	}
+	if status := request("GET", "/units/1/holding/10?type=float32", "", &values); status != http.StatusOK || values.Values[0] != 1.5 {
+		t.Fatalf("float expected 1.5, actual %v %v", status, values.Values)
+	}
+	if status := request("PUT", "/units/1/holding/10?type=float32", `{"values": [-2.25]}`, nil); status != http.StatusNoContent {
+		t.Fatalf("status expected %v, actual %v", http.StatusNoContent, status)
+	}
+	if regs, _ := d.Store().GetRegisters(modbustcp.TableHoldingRegisters, 10, 2); regs[0] != 0xc010 || regs[1] != 0 {
+		t.Fatalf("registers expected [c010 0], actual %x", regs)
+	}
+	if status := request("PUT", "/units/1/coils/0", `{"values": [false]}`, nil); status != http.StatusNoContent {
+		t.Fatalf("status expected %v, actual %v", http.StatusNoContent, status)
+	}
+	if bits, _ := d.Store().GetBits(modbustcp.TableCoils, 0, 1); bits[0] {
+		t.Fatalf("coil expected false, actual %v", bits[0])
+	}
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/units/2/holding/0", "", http.StatusNotFound},
+		{"GET", "/units/1/holding/12", "", http.StatusUnprocessableEntity},
+		{"GET", "/units/1/holding/0?type=float128", "", http.StatusBadRequest},
+		{"PUT", "/units/1/holding/0", `{"values": ["x"]}`, http.StatusBadRequest},
+		{"DELETE", "/units", "", http.StatusMethodNotAllowed},
+		{"GET", "/devices", "", http.StatusNotFound},
+	} {
+		var e map[string]string
+		if status := request(c.method, c.path, c.body, &e); status != c.status || e["error"] == "" {
+			t.Fatalf("%v %v expected %v, actual %v %v", c.method, c.path, c.status, status, e)
+		}
+	}
+	if status := request("GET", "/", "", nil); status != http.StatusOK {
+		t.Fatalf("page status expected %v, actual %v", http.StatusOK, status)
+	}
+}
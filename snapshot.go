@@ -0,0 +1,70 @@
+package modbustcp
+
+import (
+	"time"
+)
+
+// Snapshot is the content of a range at a point in time. It marshals to
+// JSON for storing configuration audits.
+type Snapshot struct {
+	Range     AddressRange
+	Time      time.Time
+	Registers []uint16 `json:",omitempty"`
+	Bits      []bool   `json:",omitempty"`
+}
+
+// value returns the value at address as register, bits being 0 or 1.
+func (s *Snapshot) value(address uint16) uint16 {
+	i := int(address) - int(s.Range.Address)
+	if s.Range.Table.IsBit() {
+		return boolRegister(s.Bits[i])
+	}
+	return s.Registers[i]
+}
+
+// Change is a value differing between two snapshots.
+type Change struct {
+	Table   Table
+	Address uint16
+	Old     uint16
+	New     uint16
+}
+
+// Snapshot reads r from the device, bypassing the cache. A zero unit id
+// reads from SlaveId.
+func (c *ModbusTcpClient) Snapshot(r AddressRange) (*Snapshot, error) {
+	if r.UnitId == 0 {
+		r.UnitId = c.SlaveId
+	}
+	now := time.Now()
+	regs, bits, err := c.ReadFresh(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{Range: r, Time: now, Registers: regs, Bits: bits}, nil
+}
+
+// DiffSnapshots returns the changes from old to new in ascending address
+// order. Only the addresses covered by both snapshots are compared,
+// snapshots of different units or tables have none in common.
+func DiffSnapshots(old, new *Snapshot) []Change {
+	a, b := old.Range, new.Range
+	if a.UnitId != b.UnitId || a.Table != b.Table {
+		return nil
+	}
+	start, end := int(a.Address), a.end()
+	if int(b.Address) > start {
+		start = int(b.Address)
+	}
+	if b.end() < end {
+		end = b.end()
+	}
+	var changes []Change
+	for address := start; address < end; address++ {
+		o, n := old.value(uint16(address)), new.value(uint16(address))
+		if o != n {
+			changes = append(changes, Change{Table: a.Table, Address: uint16(address), Old: o, New: n})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,30 @@
+package modbustcp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	var reads int
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		reads++
+		if reads == 1 {
+			return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{6, 0, 1, 0, 2, 0, 3}}
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{4, 0, 5, 0, 3}}
+	})
+	before, err := c.Snapshot(AddressRange{Table: TableHoldingRegisters, Address: 10, Quantity: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := c.Snapshot(AddressRange{Table: TableHoldingRegisters, Address: 11, Quantity: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := DiffSnapshots(before, after)
+	expected := []Change{{Table: TableHoldingRegisters, Address: 11, Old: 2, New: 5}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("changes expected %v, actual %v", expected, changes)
+	}
+}
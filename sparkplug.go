@@ -0,0 +1,353 @@
+package modbustcp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patdhlk/modbustcp/internal/pb"
+)
+
+// Sparkplug B metric data types
+const (
+	SparkplugInt32   = 3
+	SparkplugInt64   = 4
+	SparkplugUInt32  = 7
+	SparkplugUInt64  = 8
+	SparkplugDouble  = 10
+	SparkplugBoolean = 11
+	SparkplugString  = 12
+)
+
+const sparkplugRebirth = "Node Control/Rebirth"
+
+// SparkplugDevice is a device of a Sparkplug edge node publishing tags
+// as metrics.
+type SparkplugDevice struct {
+	ID   string
+	Tags []Tag
+}
+
+type sparkplugMetric struct {
+	device   string
+	name     string
+	alias    uint64
+	datatype uint64
+	value    float64
+	valid    bool
+}
+
+// sparkplugDatatype returns the metric data type of tag.
+func sparkplugDatatype(tag *Tag) uint64 {
+	if tag.Table.IsBit() {
+		return SparkplugBoolean
+	}
+	if !tag.Codec.Scale.identity() || tag.Codec.DisplayUnit != "" {
+		return SparkplugDouble
+	}
+	switch tag.Codec.Type {
+	case TypeInt16, TypeInt32:
+		return SparkplugInt32
+	case TypeUint16, TypeUint32:
+		return SparkplugUInt32
+	case TypeInt64:
+		return SparkplugInt64
+	case TypeUint64:
+		return SparkplugUInt64
+	}
+	return SparkplugDouble
+}
+
+// SparkplugBridge publishes tag updates as Sparkplug B edge node. It
+// maintains the birth and death lifecycle, metric aliases and sequence
+// numbers, and writes the tags addressed by device commands. Its Record
+// method can be used as Handler of a Poller.
+//
+// The MQTT client has to be connected with the will returned by Will.
+type SparkplugBridge struct {
+	MQTT MQTTClient
+	// Client executes the writes of device commands.
+	Client  *ModbusTcpClient
+	GroupID string
+	NodeID  string
+	Devices []SparkplugDevice
+	// CommandQueue is the number of device commands waiting to be
+	// written, 100 if zero. Commands received while the queue is full are
+	// dropped.
+	CommandQueue int
+	// ErrorHandler is invoked for failed publications and commands.
+	ErrorHandler func(err error)
+
+	commands commandQueue
+
+	mu      sync.Mutex
+	bdSeq   uint64
+	seq     uint64
+	metrics map[string]*sparkplugMetric
+}
+
+// NewSparkplugBridge creates the edge node nodeID of groupID.
+func NewSparkplugBridge(m MQTTClient, client *ModbusTcpClient, groupID, nodeID string, devices ...SparkplugDevice) *SparkplugBridge {
+	return &SparkplugBridge{MQTT: m, Client: client, GroupID: groupID, NodeID: nodeID, Devices: devices}
+}
+
+func (b *SparkplugBridge) topic(messageType, device string) string {
+	t := "spBv1.0/" + b.GroupID + "/" + messageType + "/" + b.NodeID
+	if device != "" {
+		t += "/" + device
+	}
+	return t
+}
+
+// Will returns the NDEATH message to register as will of the MQTT
+// session, to be published with QoS 1 by the broker.
+func (b *SparkplugBridge) Will() (topic string, payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.topic("NDEATH", ""), b.death()
+}
+
+func (b *SparkplugBridge) death() []byte {
+	p := pb.AppendVarint(nil, 1, uint64(time.Now().UnixMilli()))
+	return pb.AppendBytes(p, 2, encodeMetric("bdSeq", 0, SparkplugUInt64, float64(b.bdSeq), true, true))
+}
+
+// Start assigns the metric aliases, subscribes to the commands of the
+// node and its devices and publishes the birth certificates. Device
+// commands are written by a worker goroutine until Stop.
+func (b *SparkplugBridge) Start() error {
+	b.mu.Lock()
+	b.metrics = make(map[string]*sparkplugMetric)
+	var alias uint64
+	for _, d := range b.Devices {
+		for i := range d.Tags {
+			tag := &d.Tags[i]
+			alias++
+			b.metrics[tag.Name] = &sparkplugMetric{device: d.ID, name: tag.Name, alias: alias, datatype: sparkplugDatatype(tag)}
+		}
+	}
+	b.mu.Unlock()
+	b.commands.start(b.CommandQueue)
+	if err := b.MQTT.Subscribe(b.topic("NCMD", ""), 0, b.handle(b.nodeCommand, false)); err != nil {
+		return err
+	}
+	if err := b.MQTT.Subscribe(b.topic("DCMD", "+"), 0, b.handle(b.deviceCommand, true)); err != nil {
+		return err
+	}
+	return b.Rebirth()
+}
+
+// Stop waits for the device commands received before and publishes
+// NDEATH before a deliberate disconnect. The next session uses a new
+// birth/death sequence number.
+func (b *SparkplugBridge) Stop() error {
+	b.commands.stop()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.MQTT.Publish(b.topic("NDEATH", ""), 1, false, b.death())
+	b.bdSeq++
+	return err
+}
+
+// Rebirth publishes NBIRTH and the DBIRTH of each device with the last
+// known values, resetting the sequence number.
+func (b *SparkplugBridge) Rebirth() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := uint64(time.Now().UnixMilli())
+	b.seq = 0
+	p := pb.AppendVarint(nil, 1, now)
+	p = pb.AppendBytes(p, 2, encodeMetric("bdSeq", 0, SparkplugUInt64, float64(b.bdSeq), true, true))
+	p = pb.AppendBytes(p, 2, encodeMetric(sparkplugRebirth, 0, SparkplugBoolean, 0, true, true))
+	p = pb.AppendVarint(p, 3, b.seq)
+	if err := b.MQTT.Publish(b.topic("NBIRTH", ""), 0, false, p); err != nil {
+		return err
+	}
+	for _, d := range b.Devices {
+		p := pb.AppendVarint(nil, 1, now)
+		for i := range d.Tags {
+			m := b.metrics[d.Tags[i].Name]
+			p = pb.AppendBytes(p, 2, encodeMetric(m.name, m.alias, m.datatype, m.value, m.valid, true))
+		}
+		p = pb.AppendVarint(p, 3, b.nextSeq())
+		if err := b.MQTT.Publish(b.topic("DBIRTH", d.ID), 0, false, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *SparkplugBridge) nextSeq() uint64 {
+	b.seq = (b.seq + 1) % 256
+	return b.seq
+}
+
+// Record publishes u as DDATA of the device of its tag, updates of tags
+// of no device are ignored.
+func (b *SparkplugBridge) Record(u TagUpdate) {
+	if err := b.Publish(u); err != nil && b.ErrorHandler != nil {
+		b.ErrorHandler(err)
+	}
+}
+
+// Publish publishes u and returns the error of the MQTT client.
+func (b *SparkplugBridge) Publish(u TagUpdate) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	m, ok := b.metrics[u.Tag]
+	if !ok {
+		return nil
+	}
+	m.value, m.valid = u.Value, true
+	p := pb.AppendVarint(nil, 1, uint64(u.Time.UnixMilli()))
+	p = pb.AppendBytes(p, 2, encodeMetric("", m.alias, m.datatype, m.value, true, false))
+	p = pb.AppendVarint(p, 3, b.nextSeq())
+	return b.MQTT.Publish(b.topic("DDATA", m.device), 0, false, p)
+}
+
+// handle returns the MQTT handler of commands executed by f, on the
+// worker goroutine if queued as f blocks.
+func (b *SparkplugBridge) handle(f func(topic string, metrics []sparkplugCommand) error, queued bool) func(string, []byte) {
+	report := func(topic string, err error) {
+		if err != nil && b.ErrorHandler != nil {
+			b.ErrorHandler(fmt.Errorf("modbus: sparkplug command '%v': %v", topic, err))
+		}
+	}
+	return func(topic string, payload []byte) {
+		metrics, err := decodeSparkplugCommand(payload)
+		switch {
+		case err != nil:
+		case queued:
+			err = b.commands.submit(func() { report(topic, f(topic, metrics)) })
+		default:
+			err = f(topic, metrics)
+		}
+		report(topic, err)
+	}
+}
+
+func (b *SparkplugBridge) nodeCommand(topic string, metrics []sparkplugCommand) error {
+	for _, m := range metrics {
+		if m.name == sparkplugRebirth && m.value == true {
+			return b.Rebirth()
+		}
+	}
+	return nil
+}
+
+func (b *SparkplugBridge) deviceCommand(topic string, metrics []sparkplugCommand) error {
+	device := topic[strings.LastIndexByte(topic, '/')+1:]
+	for _, cmd := range metrics {
+		name := b.metricName(device, cmd)
+		if name == "" {
+			return fmt.Errorf("unknown metric '%v' alias '%v' of device '%v'", cmd.name, cmd.alias, device)
+		}
+		if err := b.Client.WriteTag(name, cmd.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metricName resolves the tag addressed by cmd on device.
+func (b *SparkplugBridge) metricName(device string, cmd sparkplugCommand) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, m := range b.metrics {
+		if m.device == device && (cmd.name != "" && m.name == cmd.name || cmd.name == "" && m.alias == cmd.alias) {
+			return m.name
+		}
+	}
+	return ""
+}
+
+// encodeMetric encodes a Sparkplug B metric, omitting the name if empty.
+func encodeMetric(name string, alias, datatype uint64, value float64, valid, withType bool) []byte {
+	var p []byte
+	if name != "" {
+		p = pb.AppendString(p, 1, name)
+	}
+	if alias != 0 {
+		p = pb.AppendVarint(p, 2, alias)
+	}
+	if withType {
+		p = pb.AppendVarint(p, 4, datatype)
+	}
+	if !valid {
+		return pb.AppendBool(p, 7, true)
+	}
+	switch datatype {
+	case SparkplugBoolean:
+		return pb.AppendBool(p, 14, value != 0)
+	case SparkplugInt32:
+		return pb.AppendVarint(p, 10, uint64(uint32(int32(value))))
+	case SparkplugUInt32:
+		return pb.AppendVarint(p, 10, uint64(uint32(value)))
+	case SparkplugInt64:
+		return pb.AppendVarint(p, 11, uint64(int64(value)))
+	case SparkplugUInt64:
+		return pb.AppendVarint(p, 11, uint64(value))
+	}
+	return pb.AppendDouble(p, 13, value)
+}
+
+// sparkplugCommand is a metric of a command, value being a float64, bool
+// or string.
+type sparkplugCommand struct {
+	name  string
+	alias uint64
+	value interface{}
+}
+
+func decodeSparkplugCommand(payload []byte) ([]sparkplugCommand, error) {
+	fields, err := pb.Fields(payload)
+	if err != nil {
+		return nil, err
+	}
+	var commands []sparkplugCommand
+	for _, f := range fields {
+		if f.Number != 2 || f.Wire != pb.Bytes {
+			continue
+		}
+		metric, err := pb.Fields(f.Data)
+		if err != nil {
+			return nil, err
+		}
+		var cmd sparkplugCommand
+		var datatype uint64
+		for _, m := range metric {
+			switch m.Number {
+			case 1:
+				cmd.name = string(m.Data)
+			case 2:
+				cmd.alias = m.Value
+			case 4:
+				datatype = m.Value
+			case 10:
+				cmd.value = float64(m.Value)
+				if datatype >= 1 && datatype <= 3 {
+					cmd.value = float64(int32(uint32(m.Value)))
+				}
+			case 11:
+				cmd.value = float64(m.Value)
+				if datatype == SparkplugInt64 {
+					cmd.value = float64(int64(m.Value))
+				}
+			case 12:
+				cmd.value = float64(m.Float())
+			case 13:
+				cmd.value = m.Double()
+			case 14:
+				cmd.value = m.Value != 0
+			case 15:
+				cmd.value = string(m.Data)
+			}
+		}
+		if cmd.value == nil {
+			return nil, fmt.Errorf("metric '%v' has no supported value", cmd.name)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
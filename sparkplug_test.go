@@ -0,0 +1,79 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/patdhlk/modbustcp/internal/pb"
+)
+
+type recordingMQTT struct {
+	fakeMQTT
+	topics   []string
+	payloads [][]byte
+}
+
+func (r *recordingMQTT) Publish(topic string, qos byte, retained bool, payload []byte) error {
+	r.topics = append(r.topics, topic)
+	r.payloads = append(r.payloads, payload)
+	return nil
+}
+
+// sparkplugSeq returns the sequence number of a payload.
+func sparkplugSeq(t *testing.T, payload []byte) uint64 {
+	fields, err := pb.Fields(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fields {
+		if f.Number == 3 {
+			return f.Value
+		}
+	}
+	t.Fatal("payload without seq")
+	return 0
+}
+
+func TestSparkplugBridge(t *testing.T) {
+	written := make(chan uint16, 1)
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		written <- binary.BigEndian.Uint16(request.Data[2:])
+		return request
+	})
+	device := SparkplugDevice{ID: "meter1", Tags: []Tag{
+		{Name: "power", Table: TableInputRegisters, Address: 0},
+		{Name: "limit", Table: TableHoldingRegisters, Address: 1, Writable: true},
+	}}
+	c.Tags, _ = NewTagDatabase(device.Tags...)
+	m := &recordingMQTT{fakeMQTT: fakeMQTT{handlers: map[string]func(string, []byte){}}}
+	b := NewSparkplugBridge(m, c, "plant", "edge1", device)
+	b.ErrorHandler = func(err error) { t.Error(err) }
+	if topic, _ := b.Will(); topic != "spBv1.0/plant/NDEATH/edge1" {
+		t.Fatalf("will topic unexpected %v", topic)
+	}
+	if err := b.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+	b.Record(TagUpdate{Tag: "power", Time: time.Now(), Reading: Reading{Value: 1500}})
+	expected := []string{"spBv1.0/plant/NBIRTH/edge1", "spBv1.0/plant/DBIRTH/edge1/meter1", "spBv1.0/plant/DDATA/edge1/meter1"}
+	for i, topic := range expected {
+		if m.topics[i] != topic {
+			t.Fatalf("topic %v expected %v, actual %v", i, topic, m.topics[i])
+		}
+		if seq := sparkplugSeq(t, m.payloads[i]); seq != uint64(i) {
+			t.Fatalf("seq of %v expected %v, actual %v", topic, i, seq)
+		}
+	}
+	metric := encodeMetric("", 2, SparkplugUInt32, 77, true, true)
+	m.handlers["spBv1.0/plant/DCMD/edge1/+"]("spBv1.0/plant/DCMD/edge1/meter1", pb.AppendBytes(nil, 2, metric))
+	if v := <-written; v != 77 {
+		t.Fatalf("written expected %v, actual %v", 77, v)
+	}
+	m.handlers["spBv1.0/plant/NCMD/edge1"]("spBv1.0/plant/NCMD/edge1",
+		pb.AppendBytes(nil, 2, pb.AppendBool(pb.AppendString(nil, 1, sparkplugRebirth), 14, true)))
+	if n := len(m.topics); n != 5 || m.topics[3] != expected[0] {
+		t.Fatalf("rebirth expected, actual %v", m.topics)
+	}
+}
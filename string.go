@@ -0,0 +1,74 @@
+package modbustcp
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// StringOptions describes how a string is packed into registers.
+type StringOptions struct {
+	// ByteSwap stores the first character in the low byte of each register.
+	ByteSwap bool
+	// Padding fills the unused bytes of the register range on write.
+	// The zero value pads with NUL bytes, ' ' is also common.
+	Padding byte
+}
+
+// DecodeString unpacks an ASCII or UTF-8 string from regs. The string ends
+// at the first NUL byte, trailing padding is removed.
+func DecodeString(regs []uint16, opts StringOptions) string {
+	b := make([]byte, 2*len(regs))
+	for i, r := range regs {
+		if opts.ByteSwap {
+			r = r<<8 | r>>8
+		}
+		b[2*i] = byte(r >> 8)
+		b[2*i+1] = byte(r)
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	b = bytes.TrimRight(b, " ")
+	if opts.Padding != 0 {
+		b = bytes.TrimRight(b, string([]byte{opts.Padding}))
+	}
+	return string(b)
+}
+
+// EncodeString packs s into length registers, filling the remainder with
+// the padding byte.
+func EncodeString(s string, length int, opts StringOptions) ([]uint16, error) {
+	if len(s) > 2*length {
+		return nil, fmt.Errorf("modbus: string length '%v' exceeds '%v' bytes", len(s), 2*length)
+	}
+	b := bytes.Repeat([]byte{opts.Padding}, 2*length)
+	copy(b, s)
+	regs := make([]uint16, length)
+	for i := range regs {
+		r := uint16(b[2*i])<<8 | uint16(b[2*i+1])
+		if opts.ByteSwap {
+			r = r<<8 | r>>8
+		}
+		regs[i] = r
+	}
+	return regs, nil
+}
+
+// ReadString reads a string packed into length holding registers.
+func (c *ModbusTcpClient) ReadString(address, length uint16, opts StringOptions) (string, error) {
+	regs, err := c.ReadHoldingRegisters(address, length)
+	if err != nil {
+		return "", err
+	}
+	return DecodeString(regs, opts), nil
+}
+
+// WriteString writes s into length holding registers. The string must not
+// exceed the register range.
+func (c *ModbusTcpClient) WriteString(address, length uint16, s string, opts StringOptions) error {
+	regs, err := EncodeString(s, int(length), opts)
+	if err != nil {
+		return err
+	}
+	return c.WriteMultipleRegisters(address, regs)
+}
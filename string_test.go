@@ -0,0 +1,25 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestStringRegisters(t *testing.T) {
+	opts := StringOptions{ByteSwap: true, Padding: ' '}
+	regs, err := EncodeString("ABC", 3, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 0x4241 || regs[1] != 0x2043 || regs[2] != 0x2020 {
+		t.Fatalf("registers expected [4241 2043 2020], actual %x", regs)
+	}
+	if s := DecodeString(regs, opts); s != "ABC" {
+		t.Fatalf("string expected %q, actual %q", "ABC", s)
+	}
+	if s := DecodeString([]uint16{0x4869, 0x0058}, StringOptions{}); s != "Hi" {
+		t.Fatalf("string expected %q, actual %q", "Hi", s)
+	}
+	if _, err := EncodeString("toolong", 3, opts); err == nil {
+		t.Fatal("error expected for string exceeding the register range")
+	}
+}
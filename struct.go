@@ -0,0 +1,385 @@
+package modbustcp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// structField describes the register mapping of a single struct field.
+type structField struct {
+	name   string
+	index  int
+	offset int
+	codec  Codec
+	length int // registers of a string field, zero for numbers
+	opts   StringOptions
+}
+
+func (f *structField) registers() int {
+	if f.length > 0 {
+		return f.length
+	}
+	return f.codec.Registers()
+}
+
+// parseStructFields parses the modbus struct tags of t. The tag holds the
+// register offset relative to the start of the block followed by options:
+//
+//	Power   int32   `modbus:"0,int32,cdab,gain=0.1"`
+//	Temp    float64 `modbus:"2,int16,gain=0.1,offset=-40"`
+//	Serial  string  `modbus:"3,len=8,swap,pad=' '"`
+//
+// The data type defaults to the size of the field type.
+func parseStructFields(t reflect.Type) ([]structField, error) {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("modbus")
+		if !ok || tag == "-" {
+			continue
+		}
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("modbus: unexported field '%v' has a modbus tag", sf.Name)
+		}
+		parts := strings.Split(tag, ",")
+		offset, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("modbus: field '%v' has invalid register offset '%v'", sf.Name, parts[0])
+		}
+		f := structField{name: sf.Name, index: i, offset: int(offset), codec: Codec{Type: defaultDataType(sf.Type.Kind())}}
+		// the first option which requires a string field
+		var stringOpt string
+		for _, opt := range parts[1:] {
+			key, value, _ := strings.Cut(opt, "=")
+			switch key {
+			case "len", "swap", "pad":
+				if stringOpt == "" {
+					stringOpt = key
+				}
+			}
+			switch key {
+			case "gain":
+				f.codec.Scale.Gain, err = strconv.ParseFloat(value, 64)
+			case "offset":
+				f.codec.Scale.Offset, err = strconv.ParseFloat(value, 64)
+			case "len":
+				f.length, err = strconv.Atoi(value)
+			case "swap":
+				f.opts.ByteSwap = true
+			case "pad":
+				if pad := strings.Trim(value, "'"); len(pad) == 1 {
+					f.opts.Padding = pad[0]
+				} else {
+					err = fmt.Errorf("invalid padding '%v'", value)
+				}
+			default:
+				if typ, e := ParseDataType(key); e == nil {
+					f.codec.Type = typ
+				} else if order, e := ParseWordOrder(key); e == nil {
+					f.codec.Order = order
+				} else {
+					err = fmt.Errorf("unknown option '%v'", key)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("modbus: field '%v': %v", sf.Name, err)
+			}
+		}
+		switch sf.Type.Kind() {
+		case reflect.String:
+			if f.length <= 0 {
+				return nil, fmt.Errorf("modbus: string field '%v' requires a len option", sf.Name)
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if stringOpt != "" {
+				return nil, fmt.Errorf("modbus: option '%v' of field '%v' requires a string field", stringOpt, sf.Name)
+			}
+		default:
+			return nil, fmt.Errorf("modbus: field '%v' has unsupported type '%v'", sf.Name, sf.Type)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func defaultDataType(kind reflect.Kind) DataType {
+	switch kind {
+	case reflect.Int8, reflect.Int16:
+		return TypeInt16
+	case reflect.Int32:
+		return TypeInt32
+	case reflect.Uint32:
+		return TypeUint32
+	case reflect.Int, reflect.Int64:
+		return TypeInt64
+	case reflect.Uint, reflect.Uint64:
+		return TypeUint64
+	}
+	return TypeUint16
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return rv, fmt.Errorf("modbus: expected struct, got '%T'", v)
+	}
+	return rv, nil
+}
+
+// blockSize returns the number of registers spanned by fields.
+func blockSize(fields []structField) int {
+	size := 0
+	for i := range fields {
+		if end := fields[i].offset + fields[i].registers(); end > size {
+			size = end
+		}
+	}
+	return size
+}
+
+// UnmarshalRegisters decodes regs into the struct pointed to by v according
+// to its modbus struct tags. regs[0] corresponds to register offset 0.
+func UnmarshalRegisters(regs []uint16, v interface{}) error {
+	if rv := reflect.ValueOf(v); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("modbus: expected non-nil pointer, got '%T'", v)
+	}
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields, err := parseStructFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	if size := blockSize(fields); len(regs) < size {
+		return fmt.Errorf("modbus: struct requires '%v' registers, got '%v'", size, len(regs))
+	}
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		r := regs[f.offset:]
+		if f.length > 0 {
+			fv.SetString(DecodeString(r[:f.length], f.opts))
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.Float32, reflect.Float64:
+			value, err := f.codec.Decode(r)
+			if err != nil {
+				return err
+			}
+			if fv.OverflowFloat(value) {
+				return f.overflow(fv.Type())
+			}
+			fv.SetFloat(value)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, ok, err := f.signed(r)
+			if err != nil {
+				return err
+			}
+			if !ok || fv.OverflowInt(n) {
+				return f.overflow(fv.Type())
+			}
+			fv.SetInt(n)
+		default:
+			n, ok, err := f.unsigned(r)
+			if err != nil {
+				return err
+			}
+			if !ok || fv.OverflowUint(n) {
+				return f.overflow(fv.Type())
+			}
+			fv.SetUint(n)
+		}
+	}
+	return nil
+}
+
+// signed decodes the value of an integer field from r, ok is false if it
+// is out of the range of int64.
+func (f *structField) signed(r []uint16) (n int64, ok bool, err error) {
+	if f.codec.Scale.identity() && !f.codec.Type.float() {
+		if f.codec.Type == TypeUint64 && f.codec.bits(r) > math.MaxInt64 {
+			return 0, false, nil
+		}
+		return f.codec.integer(r), true, nil
+	}
+	value, err := f.codec.Decode(r)
+	if err != nil {
+		return 0, false, err
+	}
+	value = math.Round(value)
+	// float64(math.MaxInt64) is 2^63
+	if !(value >= math.MinInt64 && value < math.MaxInt64) {
+		return 0, false, nil
+	}
+	return int64(value), true, nil
+}
+
+// unsigned decodes the value of an unsigned integer field from r, ok is
+// false if it is negative or out of the range of uint64.
+func (f *structField) unsigned(r []uint16) (n uint64, ok bool, err error) {
+	if f.codec.Scale.identity() && !f.codec.Type.float() {
+		if f.codec.signed() {
+			if v := f.codec.integer(r); v >= 0 {
+				return uint64(v), true, nil
+			}
+			return 0, false, nil
+		}
+		return f.codec.bits(r), true, nil
+	}
+	value, err := f.codec.Decode(r)
+	if err != nil {
+		return 0, false, err
+	}
+	value = math.Round(value)
+	// float64(math.MaxUint64) is 2^64
+	if !(value >= 0 && value < math.MaxUint64) {
+		return 0, false, nil
+	}
+	return uint64(value), true, nil
+}
+
+func (f *structField) overflow(t reflect.Type) error {
+	return fmt.Errorf("modbus: '%v' value overflows field '%v' of type '%v'", f.codec.Type, f.name, t)
+}
+
+// integerBounds returns the exact range of the integer type t, which
+// Bounds cannot represent for the 64-bit types.
+func integerBounds(t DataType) (int64, uint64) {
+	switch t {
+	case TypeInt16:
+		return math.MinInt16, math.MaxInt16
+	case TypeUint32:
+		return 0, math.MaxUint32
+	case TypeInt32:
+		return math.MinInt32, math.MaxInt32
+	case TypeUint64:
+		return 0, math.MaxUint64
+	case TypeInt64:
+		return math.MinInt64, math.MaxInt64
+	}
+	return 0, math.MaxUint16
+}
+
+// marshalFields encodes the fields of rv, returning the register block and
+// a mask of the registers covered by fields.
+func marshalFields(rv reflect.Value, fields []structField) ([]uint16, []bool, error) {
+	size := blockSize(fields)
+	regs := make([]uint16, size)
+	used := make([]bool, size)
+	for _, f := range fields {
+		fv := rv.Field(f.index)
+		var encoded []uint16
+		var err error
+		switch {
+		case f.length > 0:
+			encoded, err = EncodeString(fv.String(), f.length, f.opts)
+		case fv.Kind() == reflect.Float32 || fv.Kind() == reflect.Float64:
+			encoded, err = f.codec.Encode(fv.Float())
+		case fv.CanInt():
+			if f.codec.Scale.identity() && !f.codec.Type.float() {
+				min, max := integerBounds(f.codec.Type)
+				if n := fv.Int(); n < min || n > 0 && uint64(n) > max {
+					err = fmt.Errorf("modbus: raw value '%v' out of range for '%v'", n, f.codec.Type)
+				} else {
+					encoded = f.codec.putBits(uint64(n))
+				}
+			} else {
+				encoded, err = f.codec.Encode(float64(fv.Int()))
+			}
+		default:
+			if f.codec.Scale.identity() && !f.codec.Type.float() {
+				if _, max := integerBounds(f.codec.Type); fv.Uint() > max {
+					err = fmt.Errorf("modbus: raw value '%v' out of range for '%v'", fv.Uint(), f.codec.Type)
+				} else {
+					encoded = f.codec.putBits(fv.Uint())
+				}
+			} else {
+				encoded, err = f.codec.Encode(float64(fv.Uint()))
+			}
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("modbus: field '%v': %v", rv.Type().Field(f.index).Name, err)
+		}
+		copy(regs[f.offset:], encoded)
+		for i := range encoded {
+			used[f.offset+i] = true
+		}
+	}
+	return regs, used, nil
+}
+
+// MarshalRegisters encodes the struct v into a register block according
+// to its modbus struct tags. Registers not covered by a field are zero.
+func MarshalRegisters(v interface{}) ([]uint16, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := parseStructFields(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	regs, _, err := marshalFields(rv, fields)
+	return regs, err
+}
+
+// ReadStruct reads the holding registers mapped by the struct tags of the
+// struct pointed to by v in a single request starting at address and
+// decodes them into v.
+func (c *ModbusTcpClient) ReadStruct(address uint16, v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields, err := parseStructFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	regs, err := c.ReadHoldingRegisters(address, uint16(blockSize(fields)))
+	if err != nil {
+		return err
+	}
+	return UnmarshalRegisters(regs, v)
+}
+
+// WriteStruct encodes v and writes it to the holding registers starting at
+// address. Each contiguous run of mapped registers is written with a single
+// request, registers in gaps between fields are left untouched.
+func (c *ModbusTcpClient) WriteStruct(address uint16, v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fields, err := parseStructFields(rv.Type())
+	if err != nil {
+		return err
+	}
+	regs, used, err := marshalFields(rv, fields)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(regs); {
+		if !used[start] {
+			start++
+			continue
+		}
+		end := start
+		for end < len(regs) && used[end] {
+			end++
+		}
+		if err := c.WriteMultipleRegisters(address+uint16(start), regs[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
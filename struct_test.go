@@ -0,0 +1,112 @@
+package modbustcp
+
+import (
+	"math"
+	"testing"
+)
+
+type testMeter struct {
+	Power   int32   `modbus:"0,cdab"`
+	Temp    float64 `modbus:"2,int16,gain=0.1,offset=-40"`
+	Name    string  `modbus:"3,len=2"`
+	Counter uint64  `modbus:"5"`
+	Ignored int
+}
+
+func TestMarshalRegisters(t *testing.T) {
+	in := testMeter{Power: -2, Temp: 22.5, Name: "abc", Counter: 1 << 60}
+	regs, err := MarshalRegisters(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regs) != 9 {
+		t.Fatalf("register count expected %v, actual %v", 9, len(regs))
+	}
+	if regs[0] != 0xFFFE || regs[1] != 0xFFFF || regs[2] != 625 {
+		t.Fatalf("unexpected registers %x", regs)
+	}
+	var out testMeter
+	if err := UnmarshalRegisters(regs, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Fatalf("struct expected %+v, actual %+v", in, out)
+	}
+}
+
+func TestUnmarshalRegistersInvalid(t *testing.T) {
+	var unexported struct {
+		value uint16 `modbus:"0"`
+	}
+	if err := UnmarshalRegisters([]uint16{1}, &unexported); err == nil {
+		t.Fatal("unexported field expected to fail")
+	}
+	var small struct {
+		Value int8 `modbus:"0,int32"`
+	}
+	if err := UnmarshalRegisters([]uint16{0, 200}, &small); err == nil {
+		t.Fatalf("overflow expected to fail, actual %v", small.Value)
+	}
+	if err := UnmarshalRegisters([]uint16{0xffff, 0xff80}, &small); err != nil || small.Value != -128 {
+		t.Fatalf("value expected -128, actual %v %v", small.Value, err)
+	}
+	var unsigned struct {
+		Value uint32 `modbus:"0,int16"`
+	}
+	if err := UnmarshalRegisters([]uint16{0xffff}, &unsigned); err == nil {
+		t.Fatalf("negative value expected to fail, actual %v", unsigned.Value)
+	}
+	var scaled struct {
+		Value uint8 `modbus:"0,gain=10"`
+	}
+	if err := UnmarshalRegisters([]uint16{26}, &scaled); err == nil {
+		t.Fatalf("scaled overflow expected to fail, actual %v", scaled.Value)
+	}
+	var length struct {
+		Value uint16 `modbus:"0,len=2"`
+	}
+	if err := UnmarshalRegisters([]uint16{1, 2}, &length); err == nil {
+		t.Fatal("len option of a number expected to fail")
+	}
+	var swap struct {
+		Value int32 `modbus:"0,swap"`
+	}
+	if _, err := MarshalRegisters(&swap); err == nil {
+		t.Fatal("swap option of a number expected to fail")
+	}
+}
+
+func TestMarshalRegistersLimits(t *testing.T) {
+	type limits struct {
+		Int64  int64  `modbus:"0"`
+		Uint64 uint64 `modbus:"4"`
+		Int16  int    `modbus:"8,int16"`
+	}
+	in := limits{Int64: math.MaxInt64, Uint64: math.MaxUint64, Int16: math.MinInt16}
+	regs, err := MarshalRegisters(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out limits
+	if err := UnmarshalRegisters(regs, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out != in {
+		t.Fatalf("struct expected %+v, actual %+v", in, out)
+	}
+	for _, v := range []interface{}{
+		&struct {
+			Value int `modbus:"0,int16"`
+		}{Value: math.MaxInt16 + 1},
+		&struct {
+			Value int64 `modbus:"0,uint64"`
+		}{Value: -1},
+		&struct {
+			Value uint64 `modbus:"0,int64"`
+		}{Value: math.MaxInt64 + 1},
+	} {
+		if _, err := MarshalRegisters(v); err == nil {
+			t.Fatalf("%+v expected to fail", v)
+		}
+	}
+}
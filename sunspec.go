@@ -0,0 +1,291 @@
+package modbustcp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SunSpec model ids of the standard models with typed accessors.
+const (
+	SunSpecCommon          = 1
+	SunSpecInverterSingle  = 101
+	SunSpecInverterSplit   = 102
+	SunSpecInverterThree   = 103
+	SunSpecMeterSingle     = 201
+	SunSpecMeterSplit      = 202
+	SunSpecMeterThreeWye   = 203
+	SunSpecMeterThreeDelta = 204
+	SunSpecStorage         = 124
+
+	sunSpecEnd = 0xFFFF
+)
+
+// SunSpecBaseAddresses are the protocol offsets searched for the "SunS" marker.
+var SunSpecBaseAddresses = []uint16{40000, 50000, 0}
+
+// ErrorSunSpecNotFound is returned if no base address holds the marker.
+var ErrorSunSpecNotFound = errors.New("modbus: SunSpec marker not found")
+
+// SunSpecModel locates a model in the register space.
+type SunSpecModel struct {
+	ID uint16
+	// Address is the protocol offset of the model header.
+	Address uint16
+	// Length is the number of registers following the header.
+	Length uint16
+}
+
+// SunSpecDevice is a device whose SunSpec model chain has been discovered.
+type SunSpecDevice struct {
+	Client *ModbusTcpClient
+	// Base is the protocol offset of the "SunS" marker.
+	Base   uint16
+	Models []SunSpecModel
+}
+
+// readOffset reads holding registers by protocol offset.
+func (c *ModbusTcpClient) readOffset(offset uint16, quantity int) ([]uint16, error) {
+	address, err := c.apiAddress(offset)
+	if err != nil {
+		return nil, err
+	}
+	return c.ReadHoldingRegisters(address, uint16(quantity))
+}
+
+// DiscoverSunSpec scans the base addresses for the "SunS" marker and walks
+// the model chain up to the end model.
+func (c *ModbusTcpClient) DiscoverSunSpec() (*SunSpecDevice, error) {
+	for _, base := range SunSpecBaseAddresses {
+		marker, err := c.readOffset(base, 2)
+		if err != nil || marker[0] != 0x5375 || marker[1] != 0x6e53 {
+			continue
+		}
+		d := &SunSpecDevice{Client: c, Base: base}
+		address := int(base) + 2
+		for {
+			if address > 0xFFFF-1 {
+				return nil, fmt.Errorf("modbus: SunSpec model chain exceeds the address space")
+			}
+			header, err := c.readOffset(uint16(address), 2)
+			if err != nil {
+				return nil, err
+			}
+			if header[0] == sunSpecEnd {
+				return d, nil
+			}
+			d.Models = append(d.Models, SunSpecModel{ID: header[0], Address: uint16(address), Length: header[1]})
+			address += 2 + int(header[1])
+		}
+	}
+	return nil, ErrorSunSpecNotFound
+}
+
+// Model returns the first model with the given id.
+func (d *SunSpecDevice) Model(id uint16) (SunSpecModel, bool) {
+	for _, m := range d.Models {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return SunSpecModel{}, false
+}
+
+// ReadModel reads the registers of the first model with the given id,
+// excluding the header.
+func (d *SunSpecDevice) ReadModel(id uint16) ([]uint16, error) {
+	m, ok := d.Model(id)
+	if !ok {
+		return nil, fmt.Errorf("modbus: SunSpec model '%v' not present", id)
+	}
+	return d.Client.readOffset(m.Address+2, int(m.Length))
+}
+
+// readFirstModel reads the first present model of ids.
+func (d *SunSpecDevice) readFirstModel(ids ...uint16) (uint16, []uint16, error) {
+	for _, id := range ids {
+		if _, ok := d.Model(id); ok {
+			regs, err := d.ReadModel(id)
+			return id, regs, err
+		}
+	}
+	return 0, nil, fmt.Errorf("modbus: none of the SunSpec models %v present", ids)
+}
+
+// sunSpecValue applies the scale factor register sf to the value register,
+// returning NaN for values marked as not implemented.
+func sunSpecValue(regs []uint16, value, sf int, signed bool) float64 {
+	if value >= len(regs) || sf >= len(regs) {
+		return math.NaN()
+	}
+	v := regs[value]
+	if (signed && v == 0x8000) || (!signed && v == 0xFFFF) || regs[sf] == 0x8000 {
+		return math.NaN()
+	}
+	raw := float64(v)
+	if signed {
+		raw = float64(int16(v))
+	}
+	return raw * math.Pow10(int(int16(regs[sf])))
+}
+
+// sunSpecAcc32 applies the scale factor to a 32-bit accumulator.
+func sunSpecAcc32(regs []uint16, value, sf int) float64 {
+	if value+1 >= len(regs) || sf >= len(regs) || regs[sf] == 0x8000 {
+		return math.NaN()
+	}
+	v := RegistersToUint32(regs[value:])
+	if v == 0 {
+		return math.NaN()
+	}
+	return float64(v) * math.Pow10(int(int16(regs[sf])))
+}
+
+// SunSpecCommonModel holds the identification of model 1.
+type SunSpecCommonModel struct {
+	Manufacturer  string
+	Model         string
+	Options       string
+	Version       string
+	SerialNumber  string
+	DeviceAddress uint16
+}
+
+// Common reads the common model.
+func (d *SunSpecDevice) Common() (*SunSpecCommonModel, error) {
+	regs, err := d.ReadModel(SunSpecCommon)
+	if err != nil {
+		return nil, err
+	}
+	if len(regs) < 65 {
+		return nil, fmt.Errorf("modbus: SunSpec common model too short '%v'", len(regs))
+	}
+	return &SunSpecCommonModel{
+		Manufacturer:  DecodeString(regs[0:16], StringOptions{}),
+		Model:         DecodeString(regs[16:32], StringOptions{}),
+		Options:       DecodeString(regs[32:40], StringOptions{}),
+		Version:       DecodeString(regs[40:48], StringOptions{}),
+		SerialNumber:  DecodeString(regs[48:64], StringOptions{}),
+		DeviceAddress: regs[64],
+	}, nil
+}
+
+// SunSpecInverterModel holds the measurements of the integer inverter
+// models 101 to 103. Values not implemented by the device are NaN.
+type SunSpecInverterModel struct {
+	ID             uint16
+	Current        float64 // A
+	Voltage        float64 // V, phase A to neutral
+	Power          float64 // W
+	Frequency      float64 // Hz
+	ApparentPower  float64 // VA
+	ReactivePower  float64 // var
+	PowerFactor    float64 // %
+	Energy         float64 // Wh, lifetime
+	DCCurrent      float64 // A
+	DCVoltage      float64 // V
+	DCPower        float64 // W
+	CabinetTemp    float64 // °C
+	OperatingState uint16
+}
+
+// Inverter reads the first present inverter model.
+func (d *SunSpecDevice) Inverter() (*SunSpecInverterModel, error) {
+	id, r, err := d.readFirstModel(SunSpecInverterSingle, SunSpecInverterSplit, SunSpecInverterThree)
+	if err != nil {
+		return nil, err
+	}
+	if len(r) < 37 {
+		return nil, fmt.Errorf("modbus: SunSpec inverter model too short '%v'", len(r))
+	}
+	return &SunSpecInverterModel{
+		ID:             id,
+		Current:        sunSpecValue(r, 0, 4, false),
+		Voltage:        sunSpecValue(r, 8, 11, false),
+		Power:          sunSpecValue(r, 12, 13, true),
+		Frequency:      sunSpecValue(r, 14, 15, false),
+		ApparentPower:  sunSpecValue(r, 16, 17, true),
+		ReactivePower:  sunSpecValue(r, 18, 19, true),
+		PowerFactor:    sunSpecValue(r, 20, 21, true),
+		Energy:         sunSpecAcc32(r, 22, 24),
+		DCCurrent:      sunSpecValue(r, 25, 26, false),
+		DCVoltage:      sunSpecValue(r, 27, 28, false),
+		DCPower:        sunSpecValue(r, 29, 30, true),
+		CabinetTemp:    sunSpecValue(r, 31, 35, true),
+		OperatingState: r[36],
+	}, nil
+}
+
+// SunSpecMeterModel holds the measurements of the integer meter models
+// 201 to 204. Values not implemented by the device are NaN.
+type SunSpecMeterModel struct {
+	ID             uint16
+	Current        float64 // A
+	Voltage        float64 // V, line to neutral average
+	Frequency      float64 // Hz
+	Power          float64 // W
+	ApparentPower  float64 // VA
+	ReactivePower  float64 // var
+	PowerFactor    float64 // %
+	EnergyExported float64 // Wh
+	EnergyImported float64 // Wh
+}
+
+// Meter reads the first present meter model.
+func (d *SunSpecDevice) Meter() (*SunSpecMeterModel, error) {
+	id, r, err := d.readFirstModel(SunSpecMeterSingle, SunSpecMeterSplit, SunSpecMeterThreeWye, SunSpecMeterThreeDelta)
+	if err != nil {
+		return nil, err
+	}
+	if len(r) < 53 {
+		return nil, fmt.Errorf("modbus: SunSpec meter model too short '%v'", len(r))
+	}
+	return &SunSpecMeterModel{
+		ID:             id,
+		Current:        sunSpecValue(r, 0, 4, true),
+		Voltage:        sunSpecValue(r, 5, 13, true),
+		Frequency:      sunSpecValue(r, 14, 15, true),
+		Power:          sunSpecValue(r, 16, 20, true),
+		ApparentPower:  sunSpecValue(r, 21, 25, true),
+		ReactivePower:  sunSpecValue(r, 26, 30, true),
+		PowerFactor:    sunSpecValue(r, 31, 35, true),
+		EnergyExported: sunSpecAcc32(r, 36, 52),
+		EnergyImported: sunSpecAcc32(r, 44, 52),
+	}, nil
+}
+
+// SunSpecStorageModel holds the basic storage controls of model 124.
+// Values not implemented by the device are NaN.
+type SunSpecStorageModel struct {
+	MaxChargePower  float64 // W
+	MinReserve      float64 // %
+	StateOfCharge   float64 // %
+	BatteryVoltage  float64 // V
+	ChargeStatus    uint16
+	StorageControl  uint16
+	DischargeRate   float64 // % of MaxChargePower
+	ChargeRate      float64 // % of MaxChargePower
+	AvailableEnergy float64 // Ah
+}
+
+// Storage reads the storage model.
+func (d *SunSpecDevice) Storage() (*SunSpecStorageModel, error) {
+	r, err := d.ReadModel(SunSpecStorage)
+	if err != nil {
+		return nil, err
+	}
+	if len(r) < 24 {
+		return nil, fmt.Errorf("modbus: SunSpec storage model too short '%v'", len(r))
+	}
+	return &SunSpecStorageModel{
+		MaxChargePower:  sunSpecValue(r, 0, 16, false),
+		StorageControl:  r[3],
+		MinReserve:      sunSpecValue(r, 5, 19, false),
+		StateOfCharge:   sunSpecValue(r, 6, 20, false),
+		AvailableEnergy: sunSpecValue(r, 7, 21, false),
+		BatteryVoltage:  sunSpecValue(r, 8, 22, false),
+		ChargeStatus:    r[9],
+		DischargeRate:   sunSpecValue(r, 10, 23, true),
+		ChargeRate:      sunSpecValue(r, 11, 23, true),
+	}, nil
+}
@@ -0,0 +1,50 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestDiscoverSunSpec(t *testing.T) {
+	space := make([]uint16, 40200)
+	copy(space[40000:], []uint16{0x5375, 0x6e53, 1, 66})
+	name, _ := EncodeString("Fronius", 16, StringOptions{})
+	copy(space[40004:], name)
+	inverter := make([]uint16, 50)
+	inverter[12], inverter[13] = 1234, 0xFFFF // W with scale factor -1
+	inverter[14], inverter[15] = 0xFFFF, 0    // Hz not implemented
+	copy(space[40070:], append([]uint16{103, 50}, inverter...))
+	space[40122] = 0xFFFF
+
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		address := binary.BigEndian.Uint16(request.Data)
+		quantity := binary.BigEndian.Uint16(request.Data[2:])
+		data := []byte{byte(2 * quantity)}
+		for _, r := range space[address : address+quantity] {
+			data = append(data, byte(r>>8), byte(r))
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: data}
+	})
+	d, err := c.DiscoverSunSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Base != 40000 || len(d.Models) != 2 || d.Models[1].ID != 103 {
+		t.Fatalf("unexpected models %+v", d.Models)
+	}
+	common, err := d.Common()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.Manufacturer != "Fronius" {
+		t.Fatalf("manufacturer expected %v, actual %v", "Fronius", common.Manufacturer)
+	}
+	inv, err := d.Inverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(inv.Power-123.4) > 1e-9 || !math.IsNaN(inv.Frequency) {
+		t.Fatalf("unexpected inverter values %+v", inv)
+	}
+}
@@ -0,0 +1,267 @@
+package modbustcp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrorUnknownTag is returned for tag names missing in the tag database.
+	ErrorUnknownTag = errors.New("modbus: unknown tag")
+	// ErrorTagNotWritable is returned when writing a tag not marked writable.
+	ErrorTagNotWritable = errors.New("modbus: tag is not writable")
+)
+
+// Tag names a value in one of the data tables of a slave.
+type Tag struct {
+	Name string
+	// UnitId of the slave, zero selects the slave of the client.
+	UnitId byte
+	Table  Table
+	// Address of the first register or bit as passed to the client API.
+	Address uint16
+	// Codec decodes register values, it is ignored for bit tables.
+	Codec Codec
+	// Writable permits WriteTag.
+	Writable bool
+	// Deadband suppresses polled updates whose value differs less than
+	// the absolute amount from the last reported value.
+	Deadband float64
+	// DeadbandPercent suppresses polled updates whose value differs less
+	// than the percentage of the last reported value.
+	DeadbandPercent float64
+	// MaxReportInterval forces an update once the last report is older,
+	// even if the value did not change.
+	MaxReportInterval time.Duration
+}
+
+// exceedsDeadband reports whether value differs from last by more than
+// the deadbands of the tag. Any change exceeds a zero deadband.
+func (t *Tag) exceedsDeadband(last, value float64) bool {
+	diff := math.Abs(value - last)
+	if t.Deadband == 0 && t.DeadbandPercent == 0 {
+		return diff != 0
+	}
+	if t.Deadband > 0 && diff > t.Deadband {
+		return true
+	}
+	return t.DeadbandPercent > 0 && diff > math.Abs(last)*t.DeadbandPercent/100
+}
+
+// quantity returns the number of registers or bits occupied by the tag.
+func (t *Tag) quantity() uint16 {
+	if t.Table.IsBit() {
+		return 1
+	}
+	return uint16(t.Codec.Registers())
+}
+
+// TagDatabase is a concurrency safe set of tags addressed by name.
+type TagDatabase struct {
+	mu    sync.RWMutex
+	tags  map[string]*Tag
+	names []string
+}
+
+// NewTagDatabase creates a tag database holding tags.
+func NewTagDatabase(tags ...Tag) (*TagDatabase, error) {
+	db := &TagDatabase{tags: make(map[string]*Tag)}
+	for _, tag := range tags {
+		if err := db.Add(tag); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// Add adds tag to the database. Names must be unique.
+func (db *TagDatabase) Add(tag Tag) error {
+	if tag.Name == "" {
+		return fmt.Errorf("modbus: tag name must not be empty")
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.tags[tag.Name]; ok {
+		return fmt.Errorf("modbus: duplicate tag '%v'", tag.Name)
+	}
+	db.tags[tag.Name] = &tag
+	db.names = append(db.names, tag.Name)
+	return nil
+}
+
+// Get returns the named tag.
+func (db *TagDatabase) Get(name string) (Tag, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	tag, ok := db.tags[name]
+	if !ok {
+		return Tag{}, false
+	}
+	return *tag, true
+}
+
+// Names returns the tag names in the order they were added.
+func (db *TagDatabase) Names() []string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return append([]string(nil), db.names...)
+}
+
+// tag looks up name in the tag database of the client.
+func (c *ModbusTcpClient) tag(name string) (Tag, error) {
+	if c.Tags == nil {
+		return Tag{}, fmt.Errorf("%w '%v'", ErrorUnknownTag, name)
+	}
+	tag, ok := c.Tags.Get(name)
+	if !ok {
+		return Tag{}, fmt.Errorf("%w '%v'", ErrorUnknownTag, name)
+	}
+	return tag, nil
+}
+
+// unit returns the unit id addressed by tag.
+func (c *ModbusTcpClient) unit(tag *Tag) byte {
+	if tag.UnitId != 0 {
+		return tag.UnitId
+	}
+	return c.SlaveId
+}
+
+// ReadTag reads and decodes the named tag of the tag database.
+func (c *ModbusTcpClient) ReadTag(name string) (Reading, error) {
+	tag, err := c.tag(name)
+	if err != nil {
+		return Reading{}, err
+	}
+	return c.readTag(&tag)
+}
+
+// WriteTag encodes value and writes it to the named tag. The value may be
+// a number, a bool or the label of an enumerated tag.
+func (c *ModbusTcpClient) WriteTag(name string, value interface{}) error {
+	tag, err := c.tag(name)
+	if err != nil {
+		return err
+	}
+	return c.writeTag(&tag, value)
+}
+
+// readTag reads and decodes the value of tag. Bits are reported as 0 or 1.
+func (c *ModbusTcpClient) readTag(tag *Tag) (Reading, error) {
+	unit := c.unit(tag)
+	if tag.Table.IsBit() {
+		bits, err := c.readBits(PriorityNormal, unit, tag.Table.ReadFunction(), tag.Address, 1)
+		if err != nil {
+			return Reading{}, err
+		}
+		return bitReading(bits[0]), nil
+	}
+	regs, err := c.readRegisters(PriorityNormal, unit, tag.Table.ReadFunction(), tag.Address, tag.quantity())
+	if err != nil {
+		return Reading{}, err
+	}
+	return tag.Codec.Reading(regs)
+}
+
+func (c *ModbusTcpClient) writeTag(tag *Tag, value interface{}) error {
+	if !tag.Writable || !tag.Table.Writable() {
+		return fmt.Errorf("%w '%v'", ErrorTagNotWritable, tag.Name)
+	}
+	unit := c.unit(tag)
+	if label, ok := value.(string); ok {
+		if tag.Table.IsBit() {
+			return fmt.Errorf("modbus: tag '%v' does not accept labels", tag.Name)
+		}
+		regs, err := tag.Codec.EncodeLabel(label)
+		if err != nil {
+			return err
+		}
+		return c.writeTagRegisters(unit, tag, regs)
+	}
+	f, ok := toFloat(value)
+	if !ok {
+		return fmt.Errorf("modbus: unsupported value type '%T' for tag '%v'", value, tag.Name)
+	}
+	if tag.Table.IsBit() {
+		return c.writeSingle(PriorityNormal, unit, FunctionWriteSingleCoil, tag.Address, coilValue(f != 0))
+	}
+	regs, err := tag.Codec.Encode(f)
+	if err != nil {
+		return err
+	}
+	return c.writeTagRegisters(unit, tag, regs)
+}
+
+func (c *ModbusTcpClient) writeTagRegisters(unit byte, tag *Tag, regs []uint16) error {
+	if len(regs) == 1 {
+		return c.writeSingle(PriorityNormal, unit, FunctionWriteSingleRegister, tag.Address, regs[0])
+	}
+	return c.writeRegisters(unit, tag.Address, regs)
+}
+
+func coilValue(on bool) uint16 {
+	if on {
+		return 0xFF00
+	}
+	return 0
+}
+
+// toFloat converts numeric and boolean values to float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// tagRange returns the address range occupied by tag.
+func (c *ModbusTcpClient) tagRange(tag *Tag) AddressRange {
+	return AddressRange{UnitId: c.unit(tag), Table: tag.Table, Address: tag.Address, Quantity: tag.quantity()}
+}
+
+// decodeTag decodes tag from the registers or bits read for block.
+func decodeTag(tag *Tag, block AddressRange, regs []uint16, bits []bool) (Reading, error) {
+	offset := int(tag.Address) - int(block.Address)
+	if tag.Table.IsBit() {
+		return bitReading(bits[offset]), nil
+	}
+	return tag.Codec.Reading(regs[offset:])
+}
+
+func bitReading(bit bool) Reading {
+	if bit {
+		return Reading{Raw: []uint16{1}, Value: 1}
+	}
+	return Reading{Raw: []uint16{0}, Value: 0}
+}
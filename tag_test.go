@@ -0,0 +1,39 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestReadWriteTag(t *testing.T) {
+	var written uint16
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		switch request.FunctionCode {
+		case FunctionWriteSingleRegister:
+			written = binary.BigEndian.Uint16(request.Data[2:])
+			return request
+		}
+		return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0x00, 0xFA}}
+	})
+	c.Tags, _ = NewTagDatabase(
+		Tag{Name: "line1.flow", Table: TableInputRegisters, Address: 3, Codec: Codec{Scale: Scale{Gain: 0.1}, Unit: "l/s"}},
+		Tag{Name: "line1.mode", Table: TableHoldingRegisters, Address: 7, Writable: true, Codec: Codec{Enum: Enum{0: "Off", 1: "Auto"}}},
+	)
+	r, err := c.ReadTag("line1.flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Value != 25 || r.Unit != "l/s" {
+		t.Fatalf("reading expected 25 l/s, actual %v %v", r.Value, r.Unit)
+	}
+	if err := c.WriteTag("line1.mode", "Auto"); err != nil || written != 1 {
+		t.Fatalf("written expected %v, actual %v (%v)", 1, written, err)
+	}
+	if err := c.WriteTag("line1.flow", 1); !errors.Is(err, ErrorTagNotWritable) {
+		t.Fatalf("error expected %v, actual %v", ErrorTagNotWritable, err)
+	}
+	if _, err := c.ReadTag("line2.flow"); !errors.Is(err, ErrorUnknownTag) {
+		t.Fatalf("error expected %v, actual %v", ErrorUnknownTag, err)
+	}
+}
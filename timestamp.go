@@ -0,0 +1,132 @@
+package modbustcp
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeFormat identifies how a point in time is packed into registers.
+type TimeFormat int
+
+const (
+	// TimeUnix32 holds seconds since the Unix epoch in two registers.
+	TimeUnix32 TimeFormat = iota
+	// TimeSplitWords holds the calendar fields in four registers:
+	// year, month<<8|day, hour<<8|minute, second.
+	TimeSplitWords
+	// TimeBCD holds packed BCD fields in three registers:
+	// YYMM, DDhh, mmss with years counted from 2000.
+	TimeBCD
+)
+
+// TimeCodec describes the encoding of a timestamp in registers.
+type TimeCodec struct {
+	Format TimeFormat
+	// Order applies to TimeUnix32.
+	Order WordOrder
+	// Location of the device clock for the calendar formats, UTC if nil.
+	Location *time.Location
+}
+
+// Registers returns the number of registers occupied by the timestamp.
+func (c TimeCodec) Registers() int {
+	switch c.Format {
+	case TimeSplitWords:
+		return 4
+	case TimeBCD:
+		return 3
+	}
+	return 2
+}
+
+func (c TimeCodec) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// Decode decodes a timestamp from regs.
+func (c TimeCodec) Decode(regs []uint16) (time.Time, error) {
+	if len(regs) < c.Registers() {
+		return time.Time{}, fmt.Errorf("modbus: timestamp requires '%v' registers, got '%v'", c.Registers(), len(regs))
+	}
+	var year, month, day, hour, minute, second int
+	switch c.Format {
+	case TimeUnix32:
+		return time.Unix(int64(c.Order.Uint32(regs)), 0).UTC(), nil
+	case TimeSplitWords:
+		year = int(regs[0])
+		month, day = int(regs[1]>>8), int(regs[1]&0xFF)
+		hour, minute = int(regs[2]>>8), int(regs[2]&0xFF)
+		second = int(regs[3])
+	case TimeBCD:
+		var fields [6]int
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 2; j++ {
+				v, err := DecodeBCD([]uint16{regs[i] >> uint(8*(1-j)) & 0xFF}, 2, OrderABCD)
+				if err != nil {
+					return time.Time{}, err
+				}
+				fields[2*i+j] = int(v)
+			}
+		}
+		year, month, day, hour, minute, second = 2000+fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]
+	default:
+		return time.Time{}, fmt.Errorf("modbus: unknown time format '%v'", c.Format)
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, fmt.Errorf("modbus: invalid timestamp %04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, second, 0, c.location()), nil
+}
+
+// Encode encodes t into registers, truncating it to whole seconds.
+func (c TimeCodec) Encode(t time.Time) ([]uint16, error) {
+	switch c.Format {
+	case TimeUnix32:
+		sec := t.Unix()
+		if sec < 0 || sec > 0xFFFFFFFF {
+			return nil, fmt.Errorf("modbus: time '%v' out of range for 32-bit epoch", t)
+		}
+		return c.Order.PutUint32(uint32(sec)), nil
+	case TimeSplitWords:
+		t = t.In(c.location())
+		return []uint16{
+			uint16(t.Year()),
+			uint16(t.Month())<<8 | uint16(t.Day()),
+			uint16(t.Hour())<<8 | uint16(t.Minute()),
+			uint16(t.Second()),
+		}, nil
+	case TimeBCD:
+		t = t.In(c.location())
+		if t.Year() < 2000 || t.Year() > 2099 {
+			return nil, fmt.Errorf("modbus: year '%v' out of range for BCD timestamp", t.Year())
+		}
+		fields := []int{t.Year() - 2000, int(t.Month()), t.Day(), t.Hour(), t.Minute(), t.Second()}
+		regs := make([]uint16, 3)
+		for i, f := range fields {
+			regs[i/2] |= uint16(f/10<<4|f%10) << uint(8*(1-i%2))
+		}
+		return regs, nil
+	}
+	return nil, fmt.Errorf("modbus: unknown time format '%v'", c.Format)
+}
+
+// ReadTime reads a timestamp described by codec from holding registers.
+func (c *ModbusTcpClient) ReadTime(address uint16, codec TimeCodec) (time.Time, error) {
+	regs, err := c.ReadHoldingRegisters(address, uint16(codec.Registers()))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return codec.Decode(regs)
+}
+
+// WriteTime writes t to holding registers as described by codec.
+func (c *ModbusTcpClient) WriteTime(address uint16, codec TimeCodec, t time.Time) error {
+	regs, err := codec.Encode(t)
+	if err != nil {
+		return err
+	}
+	return c.WriteMultipleRegisters(address, regs)
+}
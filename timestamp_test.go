@@ -0,0 +1,28 @@
+package modbustcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeCodec(t *testing.T) {
+	ts := time.Date(2024, time.March, 7, 13, 45, 9, 0, time.UTC)
+	for _, format := range []TimeFormat{TimeUnix32, TimeSplitWords, TimeBCD} {
+		codec := TimeCodec{Format: format, Order: OrderCDAB}
+		regs, err := codec.Encode(ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := codec.Decode(regs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !decoded.Equal(ts) {
+			t.Errorf("format %v: time expected %v, actual %v", format, ts, decoded)
+		}
+	}
+	regs, _ := TimeCodec{Format: TimeBCD}.Encode(ts)
+	if regs[0] != 0x2403 || regs[1] != 0x0713 || regs[2] != 0x4509 {
+		t.Fatalf("registers expected [2403 0713 4509], actual %x", regs)
+	}
+}
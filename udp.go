@@ -0,0 +1,151 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+const (
+	// maxUDPSessions bounds the peers of ServeUDP with session state
+	maxUDPSessions = 1024
+	// udpSessionIdle is the time after which the session state of a
+	// silent peer may be dropped
+	udpSessionIdle = time.Minute
+)
+
+// ListenAndServeUDP listens on the UDP address, e.g. ":502", and serves
+// requests of one ADU per datagram until Close is called.
+func (s *Server) ListenAndServeUDP(address string) error {
+	conn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return err
+	}
+	return s.ServeUDP(conn)
+}
+
+// ServeUDP serves the datagrams received by conn until Close is called.
+// Datagrams not holding exactly one ADU are dropped. The rate limit
+// applies to each source address like to a connection, the client rules
+// to the address of each datagram.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return ErrorServerClosed
+	}
+	if s.packetConn != nil {
+		s.mu.Unlock()
+		conn.Close()
+		return fmt.Errorf("modbus: server already listening on udp")
+	}
+	s.packetConn = conn
+	s.mu.Unlock()
+	sessions := udpSessions{peers: make(map[string]*udpPeer)}
+	buf := make([]byte, MaxLength+1)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrorServerClosed
+			}
+			return err
+		}
+		sess := sessions.get(addr, time.Now())
+		if response := s.serveDatagram(sess, buf[:n], addr); response != nil {
+			conn.WriteTo(response, addr)
+		}
+	}
+}
+
+// udpSessions holds the session state of the peers of ServeUDP by their
+// addresses, for up to maxUDPSessions peers.
+type udpSessions struct {
+	peers map[string]*udpPeer
+}
+
+type udpPeer struct {
+	sess session
+	seen time.Time
+}
+
+// get returns the session of addr, creating it if needed. Once all
+// sessions are taken, idle sessions and otherwise the least recently seen
+// one are dropped.
+func (u *udpSessions) get(addr net.Addr, now time.Time) *session {
+	key := addr.String()
+	if p, ok := u.peers[key]; ok {
+		p.seen = now
+		return &p.sess
+	}
+	if len(u.peers) >= maxUDPSessions {
+		var oldest string
+		for k, p := range u.peers {
+			if now.Sub(p.seen) >= udpSessionIdle {
+				delete(u.peers, k)
+			} else if oldest == "" || p.seen.Before(u.peers[oldest].seen) {
+				oldest = k
+			}
+		}
+		if len(u.peers) >= maxUDPSessions {
+			delete(u.peers, oldest)
+		}
+	}
+	p := &udpPeer{seen: now}
+	u.peers[key] = p
+	return &p.sess
+}
+
+// UDPAddr returns the address the server listens on for datagrams, nil
+// before ListenAndServeUDP.
+func (s *Server) UDPAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.packetConn == nil {
+		return nil
+	}
+	return s.packetConn.LocalAddr()
+}
+
+// serveDatagram serves the ADU received from addr and returns the
+// response ADU, nil if there is none.
+func (s *Server) serveDatagram(sess *session, adu []byte, addr net.Addr) []byte {
+	if len(adu) < HeaderSize+1 || len(adu) > MaxLength ||
+		binary.BigEndian.Uint16(adu[2:]) != TcpProtocolIdentifier || int(binary.BigEndian.Uint16(adu[4:])) != len(adu)-6 {
+		s.diag.busError()
+		return nil
+	}
+	var header [HeaderSize]byte
+	copy(header[:], adu)
+	s.stats.message(adu[HeaderSize])
+	s.diag.busMessage()
+	unit := header[6]
+	broadcast := s.Broadcast && unit == 0
+	if !broadcast && s.UnitId != 0 && unit != s.UnitId {
+		return nil
+	}
+	if len(s.Clients) > 0 {
+		var ip netip.Addr
+		if udp, ok := addr.(*net.UDPAddr); ok {
+			ip = udp.AddrPort().Addr().Unmap()
+		}
+		rule := s.clientRule(ip, "")
+		if rule == nil {
+			s.logf("modbus: rejecting datagram from %v", addr)
+			return nil
+		}
+		sess.readOnly = rule.ReadOnly
+	}
+	request := &Pdu{FunctionCode: adu[HeaderSize], Data: adu[HeaderSize+1:]}
+	response := s.process(sess, &Request{Unit: unit, Pdu: request, RemoteAddr: addr}, broadcast)
+	if response == nil {
+		return nil
+	}
+	return sess.truncated(responseAdu(header, response))
+}
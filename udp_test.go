@@ -0,0 +1,107 @@
+package modbustcp
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServerUDP(t *testing.T) {
+	s := NewServer()
+	s.Store.SetRegisters(TableInputRegisters, 4, []uint16{0xbeef})
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAndServeUDP("127.0.0.1:0") }()
+	defer func() {
+		s.Close()
+		if err := <-done; !errors.Is(err, ErrorServerClosed) {
+			t.Errorf("ListenAndServeUDP expected %v, actual %v", ErrorServerClosed, err)
+		}
+	}()
+	for s.UDPAddr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	conn, err := net.Dial("udp", s.UDPAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// a truncated datagram is dropped, the next one is answered
+	conn.Write([]byte{0, 1, 0, 0, 0, 6, 1, FunctionReadInputRegister, 0})
+	if _, err = conn.Write([]byte{0, 2, 0, 0, 0, 6, 1, FunctionReadInputRegister, 0, 4, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	response := make([]byte, MaxLength)
+	n, err := conn.Read(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0, 2, 0, 0, 0, 5, 1, FunctionReadInputRegister, 2, 0xbe, 0xef}
+	if !bytes.Equal(response[:n], expected) {
+		t.Fatalf("response expected % x, actual % x", expected, response[:n])
+	}
+}
+
+func TestServerUDPRateLimitPerPeer(t *testing.T) {
+	s := NewServer()
+	s.RateLimit, s.RateBurst = 0.001, 1
+	done := make(chan error, 1)
+	go func() { done <- s.ListenAndServeUDP("127.0.0.1:0") }()
+	defer func() {
+		s.Close()
+		<-done
+	}()
+	for s.UDPAddr() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	request := func(conn net.Conn) byte {
+		if _, err := conn.Write([]byte{0, 1, 0, 0, 0, 6, 1, FunctionReadHoldingRegister, 0, 0, 0, 1}); err != nil {
+			t.Fatal(err)
+		}
+		response := make([]byte, MaxLength)
+		n, err := conn.Read(response)
+		if err != nil || n <= HeaderSize {
+			t.Fatalf("response expected, actual % x %v", response[:n], err)
+		}
+		return response[HeaderSize]
+	}
+	var peers [2]net.Conn
+	for i := range peers {
+		conn, err := net.Dial("udp", s.UDPAddr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		peers[i] = conn
+	}
+	if code := request(peers[0]); code != FunctionReadHoldingRegister {
+		t.Fatalf("first request expected to be answered, actual function %v", code)
+	}
+	if code := request(peers[0]); code != FunctionReadHoldingRegister|ExcExceptionOffset {
+		t.Fatalf("second request expected to be rate limited, actual function %v", code)
+	}
+	if code := request(peers[1]); code != FunctionReadHoldingRegister {
+		t.Fatalf("request of another peer expected to be answered, actual function %v", code)
+	}
+}
+
+func TestUDPSessions(t *testing.T) {
+	u := udpSessions{peers: make(map[string]*udpPeer)}
+	now := time.Now()
+	first := u.get(&net.UDPAddr{Port: 1}, now)
+	if u.get(&net.UDPAddr{Port: 1}, now) != first {
+		t.Fatal("session of the same peer expected")
+	}
+	for port := 2; port <= maxUDPSessions+1; port++ {
+		u.get(&net.UDPAddr{Port: port}, now.Add(time.Duration(port)))
+	}
+	if len(u.peers) != maxUDPSessions {
+		t.Fatalf("sessions expected %v, actual %v", maxUDPSessions, len(u.peers))
+	}
+	if _, ok := u.peers[(&net.UDPAddr{Port: 1}).String()]; ok {
+		t.Fatal("least recently seen session expected to be dropped")
+	}
+}
@@ -0,0 +1,63 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sync"
+)
+
+// linearConversion converts a value v into v*Factor + Offset.
+type linearConversion struct {
+	Factor float64
+	Offset float64
+}
+
+var (
+	unitMu          sync.RWMutex
+	unitConversions = map[[2]string]linearConversion{}
+)
+
+func init() {
+	RegisterUnitConversion("°C", "°F", 1.8, 32)
+	RegisterUnitConversion("K", "°C", 1, -273.15)
+	RegisterUnitConversion("Wh", "kWh", 0.001, 0)
+	RegisterUnitConversion("kWh", "MWh", 0.001, 0)
+	RegisterUnitConversion("W", "kW", 0.001, 0)
+	RegisterUnitConversion("kW", "MW", 0.001, 0)
+	RegisterUnitConversion("VAh", "kVAh", 0.001, 0)
+	RegisterUnitConversion("varh", "kvarh", 0.001, 0)
+	RegisterUnitConversion("mbar", "bar", 0.001, 0)
+	RegisterUnitConversion("Pa", "bar", 0.00001, 0)
+	RegisterUnitConversion("l/s", "m³/h", 3.6, 0)
+}
+
+// RegisterUnitConversion registers the linear conversion
+// to = from*factor + offset together with its inverse.
+func RegisterUnitConversion(from, to string, factor, offset float64) {
+	unitMu.Lock()
+	defer unitMu.Unlock()
+	unitConversions[[2]string{from, to}] = linearConversion{factor, offset}
+	unitConversions[[2]string{to, from}] = linearConversion{1 / factor, -offset / factor}
+}
+
+// ConvertUnit converts value from one unit to another. Units without a
+// registered conversion are rejected unless they are equal.
+func ConvertUnit(value float64, from, to string) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+	unitMu.RLock()
+	conv, ok := unitConversions[[2]string{from, to}]
+	unitMu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("modbus: no conversion from unit '%v' to '%v'", from, to)
+	}
+	return value*conv.Factor + conv.Offset, nil
+}
+
+// displayUnit returns the unit of decoded values.
+func (c Codec) displayUnit() string {
+	if c.DisplayUnit != "" {
+		return c.DisplayUnit
+	}
+	return c.Unit
+}
@@ -0,0 +1,30 @@
+package modbustcp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCodecUnitConversion(t *testing.T) {
+	codec := Codec{Type: TypeInt16, Scale: Scale{Gain: 0.1}, Unit: "°C", DisplayUnit: "°F"}
+	r, err := codec.Reading([]uint16{250})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(r.Value-77) > 1e-9 || r.Unit != "°F" {
+		t.Fatalf("reading expected 77 °F, actual %v %v", r.Value, r.Unit)
+	}
+	regs, err := codec.Encode(77)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if regs[0] != 250 {
+		t.Fatalf("register expected %v, actual %v", 250, regs[0])
+	}
+	if v, _ := ConvertUnit(1500, "Wh", "kWh"); v != 1.5 {
+		t.Fatalf("value expected %v, actual %v", 1.5, v)
+	}
+	if _, err := ConvertUnit(1, "V", "A"); err == nil {
+		t.Fatal("error expected for unknown conversion")
+	}
+}
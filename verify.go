@@ -0,0 +1,67 @@
+package modbustcp
+
+import (
+	"fmt"
+)
+
+// VerifyError is returned by writes of a client with VerifyWrites set when
+// the value read back differs from the value written, e.g. because the
+// device ignored or clamped it.
+type VerifyError struct {
+	Table    Table
+	Address  uint16
+	Written  uint16
+	ReadBack uint16
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("modbus: %v %v read back '%v' instead of written '%v'", e.Table, e.Address, e.ReadBack, e.Written)
+}
+
+// verifyRegisters reads back the holding registers written at address.
+func (c *ModbusTcpClient) verifyRegisters(prio Priority, unit byte, address uint16, values []uint16) error {
+	regs, err := c.readRegisters(prio, unit, FunctionReadHoldingRegister, address, uint16(len(values)))
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		if regs[i] != v {
+			return &VerifyError{Table: TableHoldingRegisters, Address: address + uint16(i), Written: v, ReadBack: regs[i]}
+		}
+	}
+	return nil
+}
+
+// verifyMask reads back the holding register modified by a mask write at
+// address, only the bits not kept by andMask are known.
+func (c *ModbusTcpClient) verifyMask(prio Priority, unit byte, address, andMask, orMask uint16) error {
+	regs, err := c.readRegisters(prio, unit, FunctionReadHoldingRegister, address, 1)
+	if err != nil {
+		return err
+	}
+	if expected := regs[0]&andMask | orMask&^andMask; regs[0] != expected {
+		return &VerifyError{Table: TableHoldingRegisters, Address: address, Written: expected, ReadBack: regs[0]}
+	}
+	return nil
+}
+
+// verifyBits reads back the coils written at address.
+func (c *ModbusTcpClient) verifyBits(prio Priority, unit byte, address uint16, values []bool) error {
+	bits, err := c.readBits(prio, unit, FunctionReadCoil, address, uint16(len(values)))
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		if bits[i] != v {
+			return &VerifyError{Table: TableCoils, Address: address + uint16(i), Written: boolRegister(v), ReadBack: boolRegister(bits[i])}
+		}
+	}
+	return nil
+}
+
+func boolRegister(b bool) uint16 {
+	if b {
+		return 1
+	}
+	return 0
+}
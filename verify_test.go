@@ -0,0 +1,72 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestVerifyWrites(t *testing.T) {
+	var mem [10]uint16
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		address := binary.BigEndian.Uint16(request.Data)
+		switch request.FunctionCode {
+		case FunctionWriteSingleRegister:
+			v := binary.BigEndian.Uint16(request.Data[2:])
+			if v > 100 {
+				v = 100
+			}
+			mem[address] = v
+			return request
+		case FunctionMaskWriteRegister:
+			andMask := binary.BigEndian.Uint16(request.Data[2:])
+			orMask := binary.BigEndian.Uint16(request.Data[4:])
+			// register 5 is read-only
+			if address != 5 {
+				mem[address] = mem[address]&andMask | orMask&^andMask
+			}
+			return request
+		case FunctionReadWriteMultipleRegister:
+			// clamps like single writes, reading is not needed
+			v := binary.BigEndian.Uint16(request.Data[9:])
+			if v > 100 {
+				v = 100
+			}
+			mem[binary.BigEndian.Uint16(request.Data[4:])] = v
+			return &Pdu{FunctionCode: request.FunctionCode, Data: []byte{2, 0, 0}}
+		case FunctionReadHoldingRegister:
+			quantity := binary.BigEndian.Uint16(request.Data[2:])
+			data := []byte{byte(2 * quantity)}
+			for _, v := range mem[address : address+quantity] {
+				data = append(data, byte(v>>8), byte(v))
+			}
+			return &Pdu{FunctionCode: request.FunctionCode, Data: data}
+		}
+		return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{ExcIllegalFunction}}
+	})
+	c.VerifyWrites = true
+	if err := c.WriteSingleRegister(3, 50); err != nil {
+		t.Fatal(err)
+	}
+	err := c.WriteSingleRegister(4, 150)
+	verr, ok := err.(*VerifyError)
+	if !ok {
+		t.Fatalf("error expected *VerifyError, actual %v", err)
+	}
+	if verr.Address != 4 || verr.Written != 150 || verr.ReadBack != 100 {
+		t.Fatalf("verify error expected 4 150 100, actual %v %v %v", verr.Address, verr.Written, verr.ReadBack)
+	}
+	if err = c.MaskWriteRegister(3, 0xff00, 0x0012); err != nil || mem[3] != 0x12 {
+		t.Fatalf("mask write expected 0x12, actual %#x %v", mem[3], err)
+	}
+	err = c.MaskWriteRegister(5, 0xfff0, 0x000f)
+	if verr, ok = err.(*VerifyError); !ok || verr.Address != 5 || verr.Written != 0xf || verr.ReadBack != 0 {
+		t.Fatalf("verify error of the mask write expected 5 15 0, actual %v", err)
+	}
+	if _, err = c.ReadWriteMultipleRegisters(0, 1, 6, []uint16{80}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.ReadWriteMultipleRegisters(0, 1, 7, []uint16{150})
+	if verr, ok = err.(*VerifyError); !ok || verr.Address != 7 || verr.ReadBack != 100 {
+		t.Fatalf("verify error of the read/write expected 7 150 100, actual %v", err)
+	}
+}
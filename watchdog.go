@@ -0,0 +1,95 @@
+package modbustcp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Watchdog periodically writes a heartbeat to a holding register, as
+// required by PLC programs supervising the communication with the master.
+// The writes use PriorityHigh so that polling does not delay them.
+type Watchdog struct {
+	Client *ModbusTcpClient
+	// UnitId is the unit written, SlaveId of the client if zero.
+	UnitId   byte
+	Address  uint16
+	Interval time.Duration
+	// Value is written on each beat unless Counter is set.
+	Value uint16
+	// Counter writes a value incremented on each beat, wrapping at 0xFFFF.
+	Counter bool
+	// ErrorHandler is invoked for each failed write.
+	ErrorHandler func(err error)
+
+	count uint16
+	mu    sync.Mutex
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewWatchdog creates a watchdog writing value to address every interval.
+func NewWatchdog(client *ModbusTcpClient, address uint16, interval time.Duration, value uint16) *Watchdog {
+	return &Watchdog{Client: client, Address: address, Interval: interval, Value: value}
+}
+
+// Start begins writing the heartbeat in a goroutine.
+func (w *Watchdog) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return fmt.Errorf("modbus: watchdog already started")
+	}
+	if w.Interval <= 0 {
+		return fmt.Errorf("modbus: watchdog has no interval")
+	}
+	w.stop = make(chan struct{})
+	w.wg.Add(1)
+	go w.run(w.stop)
+	return nil
+}
+
+// Stop ends the heartbeat and waits for a running write to complete.
+func (w *Watchdog) Stop() {
+	w.mu.Lock()
+	if w.stop == nil {
+		w.mu.Unlock()
+		return
+	}
+	close(w.stop)
+	w.stop = nil
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+func (w *Watchdog) run(stop chan struct{}) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Beat(); err != nil && w.ErrorHandler != nil {
+			w.ErrorHandler(err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Beat writes the heartbeat once.
+func (w *Watchdog) Beat() error {
+	value := w.Value
+	if w.Counter {
+		w.mu.Lock()
+		w.count++
+		value = w.count
+		w.mu.Unlock()
+	}
+	unit := w.UnitId
+	if unit == 0 {
+		unit = w.Client.SlaveId
+	}
+	return w.Client.writeSingle(PriorityHigh, unit, FunctionWriteSingleRegister, w.Address, value)
+}
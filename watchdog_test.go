@@ -0,0 +1,50 @@
+package modbustcp
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	values := make(chan uint16, 16)
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		select {
+		case values <- binary.BigEndian.Uint16(request.Data[2:]):
+		default:
+		}
+		return request
+	})
+	w := NewWatchdog(c, 10, 5*time.Millisecond, 0)
+	w.Counter = true
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	first, second := <-values, <-values
+	w.Stop()
+	if first != 1 || second != 2 {
+		t.Fatalf("counter expected 1 2, actual %v %v", first, second)
+	}
+}
+
+func TestWatchdogError(t *testing.T) {
+	c := newTestClient(t, func(request *Pdu) *Pdu {
+		return &Pdu{FunctionCode: request.FunctionCode | ExcExceptionOffset, Data: []byte{ExcIllegalDataAdr}}
+	})
+	errs := make(chan error, 16)
+	w := NewWatchdog(c, 10, time.Millisecond, 1)
+	w.ErrorHandler = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	err := <-errs
+	w.Stop()
+	if err != ErrorIllegalDataAddress {
+		t.Fatalf("error expected %v, actual %v", ErrorIllegalDataAddress, err)
+	}
+}
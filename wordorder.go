@@ -0,0 +1,95 @@
+package modbustcp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WordOrder describes how the bytes of a multi-register value are laid out
+// on the wire. The letters name the bytes of the value from most (A) to
+// least (D) significant in transmission order.
+type WordOrder int
+
+const (
+	// OrderABCD is big endian, the order defined by the specification.
+	OrderABCD WordOrder = iota
+	// OrderBADC swaps the bytes within each register.
+	OrderBADC
+	// OrderCDAB transmits the least significant register first.
+	OrderCDAB
+	// OrderDCBA is little endian.
+	OrderDCBA
+)
+
+var wordOrderNames = []string{"ABCD", "BADC", "CDAB", "DCBA"}
+
+func (o WordOrder) String() string {
+	if o < 0 || int(o) >= len(wordOrderNames) {
+		return fmt.Sprintf("WordOrder(%d)", int(o))
+	}
+	return wordOrderNames[o]
+}
+
+// ParseWordOrder parses the case insensitive name of a word order, e.g. "cdab".
+func ParseWordOrder(s string) (WordOrder, error) {
+	for i, name := range wordOrderNames {
+		if strings.EqualFold(s, name) {
+			return WordOrder(i), nil
+		}
+	}
+	return OrderABCD, fmt.Errorf("modbus: unknown word order '%v'", s)
+}
+
+func (o WordOrder) swapsBytes() bool {
+	return o == OrderBADC || o == OrderDCBA
+}
+
+func (o WordOrder) swapsWords() bool {
+	return o == OrderCDAB || o == OrderDCBA
+}
+
+// arrange converts between wire order and big endian order. The
+// transformation is its own inverse.
+func (o WordOrder) arrange(regs []uint16) []uint16 {
+	out := make([]uint16, len(regs))
+	for i, r := range regs {
+		if o.swapsBytes() {
+			r = r<<8 | r>>8
+		}
+		if o.swapsWords() {
+			out[len(regs)-1-i] = r
+		} else {
+			out[i] = r
+		}
+	}
+	return out
+}
+
+// Uint32 decodes the first two registers in this order.
+func (o WordOrder) Uint32(regs []uint16) uint32 {
+	return RegistersToUint32(o.arrange(regs[:2]))
+}
+
+// PutUint32 encodes v into two registers in this order.
+func (o WordOrder) PutUint32(v uint32) []uint16 {
+	return o.arrange(Uint32ToRegisters(v))
+}
+
+// Uint64 decodes the first four registers in this order.
+func (o WordOrder) Uint64(regs []uint16) uint64 {
+	return RegistersToUint64(o.arrange(regs[:4]))
+}
+
+// PutUint64 encodes v into four registers in this order.
+func (o WordOrder) PutUint64(v uint64) []uint16 {
+	return o.arrange(Uint64ToRegisters(v))
+}
+
+// wordOrder returns the per call override if present and the configured
+// word order of the client otherwise.
+func (c *ModbusTcpClient) wordOrder(order []WordOrder) WordOrder {
+	if len(order) > 0 {
+		return order[0]
+	}
+	return c.WordOrder
+}
@@ -0,0 +1,29 @@
+package modbustcp
+
+import (
+	"testing"
+)
+
+func TestWordOrder(t *testing.T) {
+	tests := []struct {
+		order WordOrder
+		regs  []uint16
+	}{
+		{OrderABCD, []uint16{0x1122, 0x3344}},
+		{OrderBADC, []uint16{0x2211, 0x4433}},
+		{OrderCDAB, []uint16{0x3344, 0x1122}},
+		{OrderDCBA, []uint16{0x4433, 0x2211}},
+	}
+	for _, test := range tests {
+		if v := test.order.Uint32(test.regs); v != 0x11223344 {
+			t.Errorf("%v: value expected %x, actual %x", test.order, 0x11223344, v)
+		}
+		regs := test.order.PutUint32(0x11223344)
+		if regs[0] != test.regs[0] || regs[1] != test.regs[1] {
+			t.Errorf("%v: registers expected %x, actual %x", test.order, test.regs, regs)
+		}
+	}
+	if v := OrderCDAB.Uint64([]uint16{4, 3, 2, 1}); v != 0x0001000200030004 {
+		t.Errorf("value expected %x, actual %x", 0x0001000200030004, v)
+	}
+}